@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Anomaly tracks how far a metric of a result deviates from the EWMA
+// baseline of its test on the same device in the history store
+type Anomaly struct {
+	Metric   string  `json:"metric"`
+	Value    float64 `json:"value"`
+	Expected float64 `json:"expected"`
+	StdDev   float64 `json:"stddev"`
+	// Sigmas is the deviation from the expected value in standard
+	// deviations, positive when worse
+	Sigmas float64 `json:"sigmas"`
+	// Streak counts the runs in a row, this one included, that deviated
+	Streak int `json:"streak"`
+	// Alert is set on the run completing a sustained deviation
+	Alert bool `json:"alert,omitempty"`
+}
+
+const (
+	// anomalyAlpha is the weight of a run in the moving average, a run
+	// counts about as much as the last 1/alpha runs do together
+	anomalyAlpha = 0.2
+	// anomalyWarmup is how many runs the baseline needs before runs are
+	// judged
+	anomalyWarmup = 5
+	// anomalySigma is how many standard deviations worse than the
+	// baseline a run deviates
+	anomalySigma = 3
+	// anomalyMinChange is the smallest relative change that deviates, so
+	// very stable tests do not alert on noise
+	anomalyMinChange = 0.05
+)
+
+// anomalyMetrics are the metrics watched for anomalies
+var anomalyMetrics = []string{"iops", "p99"}
+
+// detectAnomalies compares a result with the baseline the earlier runs of
+// its test on the same device built up in the history store, an
+// exponentially weighted moving average and variance. A run worse than the
+// baseline only counts as a deviation; the result alerts when --anomaly-runs
+// runs in a row deviated, so a single noisy run of a scheduled suite does not
+// page anyone. The baseline then moves to the new level, which alerts once.
+// The history store must not contain the result yet.
+func detectAnomalies(result *TestResult) {
+	if result.Status != "PASSED" || opts.History == "" {
+		return
+	}
+
+	// The passed runs of the test in the namespace on the same drive, or on
+	// the same model without a serial number, oldest first
+	var serial, model string
+	if result.Device != nil {
+		serial, model = result.Device.Serial, result.Device.Model
+	}
+	var records []HistoryRecord
+	err := scanHistory(opts.History, HistoryFilter{Test: result.TestName, Kind: historyResult, Namespace: namespace, SameNamespace: true}, func(record HistoryRecord) error {
+		if record.Status != "PASSED" {
+			return nil
+		}
+		if serial != "" && (record.Device == nil || record.Device.Serial != serial) {
+			return nil
+		}
+		if serial == "" && model != "" && (record.Device == nil || record.Device.Model != model) {
+			return nil
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		result.warn(severityWarning, "anomaly", "cannot read history: %v", err)
+		return
+	}
+
+	records = append(records, newHistoryRecord(historyResult, RunInfo{}, *result))
+	for _, metric := range anomalyMetrics {
+		value, higherIsBetter, _ := historyMetric(metric)
+		anomaly := trackAnomaly(records, value, higherIsBetter)
+		if anomaly == nil {
+			continue
+		}
+		anomaly.Metric = metric
+		result.Anomalies = append(result.Anomalies, *anomaly)
+		if anomaly.Alert {
+			result.warn(severityWarning, "anomaly", "%s", anomaly.String())
+		}
+	}
+}
+
+// trackAnomaly runs the baseline over the records and returns the state of
+// the last one, nil when it did not deviate or the baseline is still warming
+// up
+func trackAnomaly(records []HistoryRecord, value func(HistoryRecord) float64, higherIsBetter bool) *Anomaly {
+	var mean, variance float64
+	var seen, streak int
+	var streakSum float64
+	var last *Anomaly
+	for _, record := range records {
+		v := value(record)
+		last = nil
+		if seen >= anomalyWarmup {
+			stddev := math.Sqrt(variance)
+			worse := v - mean
+			if higherIsBetter {
+				worse = -worse
+			}
+			if worse > anomalySigma*stddev && worse > anomalyMinChange*math.Abs(mean) {
+				streak++
+				streakSum += v
+				last = &Anomaly{Value: v, Expected: mean, StdDev: stddev, Streak: streak}
+				if stddev > 0 {
+					last.Sigmas = worse / stddev
+				}
+				if streak >= opts.AnomalyRuns {
+					// Sustained, the deviating runs become the new baseline
+					last.Alert = true
+					mean = streakSum / float64(streak)
+					streak, streakSum = 0, 0
+				}
+				// Deviating runs stay out of the baseline until then
+				continue
+			}
+		}
+		streak, streakSum = 0, 0
+		seen++
+		if seen == 1 {
+			mean = v
+			continue
+		}
+		diff := v - mean
+		mean += anomalyAlpha * diff
+		variance = (1 - anomalyAlpha) * (variance + anomalyAlpha*diff*diff)
+	}
+	return last
+}
+
+// String describes the deviation in one line
+func (a Anomaly) String() string {
+	text := fmt.Sprintf("%s %.2f vs %.2f expected", a.Metric, a.Value, a.Expected)
+	if a.Sigmas > 0 {
+		text += fmt.Sprintf(" (%.1fσ worse)", a.Sigmas)
+	}
+	if a.Alert {
+		return fmt.Sprintf("%s, %d runs in a row", text, a.Streak)
+	}
+	return fmt.Sprintf("%s, run %d of %d before alerting", text, a.Streak, opts.AnomalyRuns)
+}
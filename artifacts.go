@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// artifactPath returns the path of a file stored for the test in the
+// artifact bundle of the run, creating the test directory when needed
+func artifactPath(run RunInfo, test FioTest, name string) (string, error) {
+	dir := filepath.Join(run.ArtifactsDir, sanitizeName(test.Name))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// collectArtifacts returns the files fio created for the given path. Jobs
+// cloned with numjobs may write their own copy with a numeric suffix.
+func collectArtifacts(path string) []string {
+	matches, _ := filepath.Glob(path + "*")
+	var files []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.Size() > 0 {
+			files = append(files, match)
+		}
+	}
+	return files
+}
+
+// sanitizeName makes a test name safe to use as a file name
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// Events of the audit trail
+const (
+	auditRunStarted   = "run_started"
+	auditTestStarted  = "test_started"
+	auditTestFinished = "test_finished"
+	auditInterrupted  = "interrupted"
+	auditRunFinished  = "run_finished"
+)
+
+// AuditTrail records who ran a suite, where, how and when, and is kept in
+// the results so benchmark claims can be traced back to the run behind them
+type AuditTrail struct {
+	User       string `json:"user"`
+	UID        string `json:"uid,omitempty"`
+	Host       string `json:"host"`
+	WorkingDir string `json:"working_dir"`
+	// Command is the full command line, Overrides the options it set
+	// away from their defaults
+	Command     []string          `json:"command"`
+	Overrides   map[string]string `json:"overrides,omitempty"`
+	ConfigFiles []AuditFile       `json:"config_files,omitempty"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	Events      []AuditEvent      `json:"events"`
+}
+
+// AuditFile is a configuration file the run loaded, by the SHA-256 of the
+// content it had
+type AuditFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// AuditEvent is an entry of the chronological log of the run
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Test   string    `json:"test,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// audit is the audit trail of the test run, nil for subcommands
+var audit *AuditTrail
+
+// startAudit starts the audit trail of a test run
+func startAudit(now time.Time, run RunInfo) {
+	audit = &AuditTrail{
+		Command:   os.Args,
+		Overrides: map[string]string{},
+		StartedAt: now,
+	}
+	if current, err := user.Current(); err == nil {
+		audit.User = current.Username
+		audit.UID = current.Uid
+	} else if audit.User = os.Getenv("USER"); audit.User == "" {
+		audit.User = os.Getenv("USERNAME")
+	}
+	audit.Host, _ = os.Hostname()
+	audit.WorkingDir, _ = os.Getwd()
+	// Options set by the configuration layers count as overrides too
+	flag.Visit(func(f *flag.Flag) {
+		audit.Overrides[f.Name] = f.Value.String()
+	})
+	for _, file := range configFiles {
+		auditConfigFile(file.Path, file.data)
+	}
+	auditEvent(auditRunStarted, "", "run "+run.ID)
+}
+
+// auditConfigFile records a configuration file the run loaded, once per path
+func auditConfigFile(filename string, data []byte) {
+	if audit == nil {
+		return
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	for _, file := range audit.ConfigFiles {
+		if file.Path == filename {
+			return
+		}
+	}
+	sum := sha256.Sum256(data)
+	audit.ConfigFiles = append(audit.ConfigFiles, AuditFile{Path: filename, SHA256: hex.EncodeToString(sum[:])})
+}
+
+// auditEvent appends an event to the audit trail
+func auditEvent(event, test, detail string) {
+	if audit == nil {
+		return
+	}
+	audit.Events = append(audit.Events, AuditEvent{Time: time.Now(), Event: event, Test: test, Detail: detail})
+}
+
+// finishAudit ends the audit trail with the outcome of the run
+func finishAudit(results []TestResult) {
+	if audit == nil {
+		return
+	}
+	passed := 0
+	for _, r := range results {
+		if r.Status == "PASSED" {
+			passed++
+		}
+	}
+	auditEvent(auditRunFinished, "", fmt.Sprintf("%d passed, %d failed", passed, len(results)-passed))
+	audit.FinishedAt = audit.Events[len(audit.Events)-1].Time
+}
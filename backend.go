@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend generates the IO of a test with another tool than fio, to
+// cross-check fio with a simpler generator. It maps what the tool measured
+// to the metrics of the result, which are reported like those of fio.
+type Backend interface {
+	// Check reports why the backend cannot run a test as configured
+	Check(test FioTest) error
+	// Command is the command line running a test
+	Command(test FioTest) []string
+	// Run runs a test and fills the read and write metrics of its result
+	Run(ctx context.Context, test FioTest, result *TestResult) error
+}
+
+// backends are the generators tests select with "backend", tests without
+// one run fio
+var backends = map[string]Backend{
+	"dd":      ddBackend{},
+	"ioping":  iopingBackend{},
+	"command": commandBackend{},
+}
+
+// checkBackends makes sure every test selects a known backend that can run
+// it
+func checkBackends(tests []FioTest) error {
+	for _, test := range tests {
+		if test.Backend == "" || test.Backend == "fio" {
+			continue
+		}
+		backend, ok := backends[test.Backend]
+		if !ok {
+			var names []string
+			for name := range backends {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("test %s: unknown backend %q, use fio, %s", test.Name, test.Backend, strings.Join(names, ", "))
+		}
+		if err := backend.Check(test); err != nil {
+			return fmt.Errorf("test %s: backend %s: %v", test.Name, test.Backend, err)
+		}
+	}
+	return nil
+}
+
+// runBackend runs a test with another backend than fio and evaluates its
+// result like one of fio, as far as the backend measured the metrics
+func runBackend(ctx context.Context, backend Backend, test FioTest, run RunInfo, result *TestResult) {
+	start := time.Now()
+	monitors := startMonitors(newMonitors(test, run), result)
+	err := backend.Run(ctx, test, result)
+	stopMonitors(monitors, result)
+	result.Duration = time.Since(start)
+	if ctx.Err() != nil {
+		result.cancel()
+		return
+	}
+	if err != nil {
+		result.Error = fmt.Errorf("%s failed: %v", test.Backend, err)
+		return
+	}
+
+	result.sumDirections()
+	compareCalibration(result)
+	result.Status = "PASSED"
+	if critical := criticalDmesgFindings(result.Dmesg); critical > 0 && opts.DmesgFail {
+		result.Status = "FAILED"
+		result.Error = fmt.Errorf("kernel log reported %d critical errors during the test", critical)
+	}
+	evaluateBaselines(test, result)
+	result.Verdicts = thresholdVerdicts(test, *result)
+	if result.Status == "PASSED" {
+		applyComparators(test, result)
+	}
+}
+
+// sumDirections sets the totals from the read and write metrics, the
+// latency of mixed workloads is the mean of both directions
+func (r *TestResult) sumDirections() {
+	r.TotalIOPS = r.ReadIOPS + r.WriteIOPS
+	r.TotalBWMBps = r.ReadBWMBps + r.WriteBWMBps
+	if r.ReadIOPS > 0 && r.WriteIOPS > 0 {
+		r.AvgLatencyUs = (r.ReadLatencyUs + r.WriteLatencyUs) / 2
+	} else if r.ReadIOPS > 0 {
+		r.AvgLatencyUs = r.ReadLatencyUs
+	} else {
+		r.AvgLatencyUs = r.WriteLatencyUs
+	}
+}
+
+// backendOutput runs a backend tool in the C locale, so numbers are printed
+// with a decimal point
+func backendOutput(ctx context.Context, args []string) ([]byte, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := runFio(ctx, cmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v\nOutput: %s", err, output)
+	}
+	return output, nil
+}
+
+// ddBackend copies the test size sequentially at queue depth 1 with dd, the
+// smoke test that needs nothing but coreutils
+type ddBackend struct{}
+
+// ddCopiedPattern matches the summary dd prints when it finishes, like
+// "1073741824 bytes (1.1 GB, 1.0 GiB) copied, 1.23 s, 873 MB/s"
+var ddCopiedPattern = regexp.MustCompile(`(\d+) bytes.* copied, ([0-9.]+) s`)
+
+func (ddBackend) Check(test FioTest) error {
+	if test.RW != "read" && test.RW != "write" {
+		return fmt.Errorf("dd only runs sequential reads or writes, not %s", test.RW)
+	}
+	if parseSize(test.BS) <= 0 || parseSize(test.Size) < parseSize(test.BS) {
+		return fmt.Errorf("dd needs a block size and a size of at least one block in bytes")
+	}
+	return nil
+}
+
+func (ddBackend) Command(test FioTest) []string {
+	bs := parseSize(test.BS)
+	args := []string{"dd", fmt.Sprintf("bs=%d", bs), fmt.Sprintf("count=%d", parseSize(test.Size)/bs)}
+	if test.RW == "read" {
+		args = append(args, "if="+test.Filename, "of=/dev/null")
+		if test.Direct == 1 {
+			args = append(args, "iflag=direct")
+		}
+	} else {
+		// Buffered writes are only done once they reached the device
+		args = append(args, "if=/dev/zero", "of="+test.Filename, "conv=notrunc,fdatasync")
+		if test.Direct == 1 {
+			args = append(args, "oflag=direct")
+		}
+	}
+	return args
+}
+
+func (b ddBackend) Run(ctx context.Context, test FioTest, result *TestResult) error {
+	output, err := backendOutput(ctx, b.Command(test))
+	if err != nil {
+		return err
+	}
+
+	match := ddCopiedPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return fmt.Errorf("cannot find the transfer summary in the output of dd:\n%s", output)
+	}
+	bytes, _ := strconv.ParseFloat(match[1], 64)
+	seconds, _ := strconv.ParseFloat(match[2], 64)
+	if bytes == 0 || seconds == 0 {
+		return fmt.Errorf("dd copied %s bytes in %s s", match[1], match[2])
+	}
+	ios := bytes / float64(parseSize(test.BS))
+	iops := ios / seconds
+	bw := bytes / seconds / 1024 / 1024
+	// One IO at a time, so every IO takes the mean time
+	latency := seconds / ios * 1e6
+	if test.RW == "read" {
+		result.ReadIOPS, result.ReadBWMBps, result.ReadLatencyUs = iops, bw, latency
+	} else {
+		result.WriteIOPS, result.WriteBWMBps, result.WriteLatencyUs = iops, bw, latency
+	}
+	return nil
+}
+
+// iopingBackend probes the read latency with ioping, one request right after
+// the other for the runtime of the test
+type iopingBackend struct{}
+
+func (iopingBackend) Check(test FioTest) error {
+	if test.RW != "read" && test.RW != "randread" {
+		return fmt.Errorf("ioping only probes reads, not %s", test.RW)
+	}
+	if parseSize(test.BS) <= 0 || test.Runtime <= 0 {
+		return fmt.Errorf("ioping needs a block size in bytes and a runtime")
+	}
+	return nil
+}
+
+func (iopingBackend) Command(test FioTest) []string {
+	args := []string{"ioping", "-B", "-i", "0", "-w", fmt.Sprintf("%ds", test.Runtime), "-s", strconv.FormatInt(parseSize(test.BS), 10)}
+	if test.Direct == 1 {
+		args = append(args, "-D")
+	}
+	if test.RW == "read" {
+		args = append(args, "-L")
+	}
+	if size := parseSize(test.Size); size > 0 {
+		args = append(args, "-S", strconv.FormatInt(size, 10))
+	}
+	return append(args, test.Filename)
+}
+
+func (b iopingBackend) Run(ctx context.Context, test FioTest, result *TestResult) error {
+	output, err := backendOutput(ctx, b.Command(test))
+	if err != nil {
+		return err
+	}
+
+	// Batch mode prints the raw statistics on the last line: requests,
+	// time, requests per second, bytes per second and the min, avg, max and
+	// mdev of the request time, times in nanoseconds
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 8 {
+		return fmt.Errorf("cannot understand the statistics of ioping:\n%s", output)
+	}
+	iops, err1 := strconv.ParseFloat(fields[2], 64)
+	bytesPerSecond, err2 := strconv.ParseFloat(fields[3], 64)
+	avgNs, err3 := strconv.ParseFloat(fields[5], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return fmt.Errorf("cannot understand the statistics of ioping:\n%s", output)
+	}
+	result.ReadIOPS = iops
+	result.ReadBWMBps = bytesPerSecond / 1024 / 1024
+	result.ReadLatencyUs = avgNs / 1000
+	return nil
+}
+
+// commandBackend runs any command, like a wrapper around a vendor tool, that
+// prints its measurements as a JSON object
+type commandBackend struct{}
+
+// backendMetrics is what the command of the command backend prints, omitted
+// metrics are zero
+type backendMetrics struct {
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	ReadBWMBps     float64 `json:"read_bw_mbps"`
+	WriteBWMBps    float64 `json:"write_bw_mbps"`
+	ReadLatencyUs  float64 `json:"read_latency_us"`
+	WriteLatencyUs float64 `json:"write_latency_us"`
+}
+
+func (commandBackend) Check(test FioTest) error {
+	if len(test.BackendCommand) == 0 {
+		return fmt.Errorf("backend_command is not set")
+	}
+	return nil
+}
+
+// Command fills the placeholders of the command with the parameters of the
+// test
+func (commandBackend) Command(test FioTest) []string {
+	// The command gets the parameters of the test in place of placeholders
+	replacer := strings.NewReplacer(
+		"{filename}", test.Filename,
+		"{rw}", test.RW,
+		"{bs}", test.BS,
+		"{size}", test.Size,
+		"{iodepth}", strconv.Itoa(test.IODepth),
+		"{numjobs}", strconv.Itoa(test.NumJobs),
+		"{runtime}", strconv.Itoa(test.Runtime),
+	)
+	args := make([]string, len(test.BackendCommand))
+	for i, arg := range test.BackendCommand {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+func (b commandBackend) Run(ctx context.Context, test FioTest, result *TestResult) error {
+	args := b.Command(test)
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("%v\nOutput: %s", err, exitErr.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	var metrics backendMetrics
+	if err := json.Unmarshal(output, &metrics); err != nil {
+		return fmt.Errorf("%s did not print the metrics as JSON: %v", args[0], err)
+	}
+	result.ReadIOPS, result.WriteIOPS = metrics.ReadIOPS, metrics.WriteIOPS
+	result.ReadBWMBps, result.WriteBWMBps = metrics.ReadBWMBps, metrics.WriteBWMBps
+	result.ReadLatencyUs, result.WriteLatencyUs = metrics.ReadLatencyUs, metrics.WriteLatencyUs
+	return nil
+}
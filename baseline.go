@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Defaults of the statistical baselines
+const (
+	defaultBaselineRuns    = 20
+	defaultBaselineSigma   = 3
+	defaultBaselineMinRuns = 5
+)
+
+// BaselineConfig fails a test when a metric is worse than the mean of the
+// last runs of the test on the same drive model by more than a number of
+// standard deviations, e.g. IOPS below mean-3σ of the last 20 runs
+type BaselineConfig struct {
+	Metric  string  `json:"metric"`
+	Runs    int     `json:"runs,omitempty"`
+	Sigma   float64 `json:"sigma,omitempty"`
+	MinRuns int     `json:"min_runs,omitempty"`
+	// ApprovedOnly builds the baseline from signed off runs only
+	ApprovedOnly bool `json:"approved_only,omitempty"`
+}
+
+// BaselineSnapshot records the baseline a result was compared with, so the
+// verdict can be audited after the history store has moved on
+type BaselineSnapshot struct {
+	Metric      string     `json:"metric"`
+	DeviceModel string     `json:"device_model,omitempty"`
+	Runs        []string   `json:"runs"`
+	MinRuns     int        `json:"min_runs"`
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	Mean        float64    `json:"mean"`
+	StdDev      float64    `json:"stddev"`
+	Sigma       float64    `json:"sigma"`
+	Threshold   float64    `json:"threshold"`
+	Value       float64    `json:"value"`
+	// Insufficient is set when the history holds fewer runs than required,
+	// the result is not judged then
+	Insufficient bool `json:"insufficient_history,omitempty"`
+	Met          bool `json:"met"`
+}
+
+// withDefaults fills in the defaults of the unset fields
+func (c BaselineConfig) withDefaults() BaselineConfig {
+	if c.Runs == 0 {
+		c.Runs = defaultBaselineRuns
+	}
+	if c.Sigma == 0 {
+		c.Sigma = defaultBaselineSigma
+	}
+	if c.MinRuns == 0 {
+		c.MinRuns = min(defaultBaselineMinRuns, c.Runs)
+	}
+	return c
+}
+
+// checkBaselines validates the baselines before any test runs
+func checkBaselines(tests []FioTest) error {
+	for _, test := range tests {
+		for _, config := range test.Baseline {
+			if _, _, err := historyMetric(config.Metric); err != nil {
+				return fmt.Errorf("test %s: baseline: %v", test.Name, err)
+			}
+			if config.Runs < 0 || config.Sigma < 0 || config.MinRuns < 0 {
+				return fmt.Errorf("test %s: baseline runs, sigma and min_runs must not be negative", test.Name)
+			}
+			if config := config.withDefaults(); config.MinRuns < 2 || config.MinRuns > config.Runs {
+				return fmt.Errorf("test %s: baseline min_runs must be between 2 and runs (%d)", test.Name, config.Runs)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateBaselines compares the result with the baselines of its test and
+// fails it when a metric is outside of the tolerated range. The history
+// store must not contain the result yet.
+func evaluateBaselines(test FioTest, result *TestResult) {
+	if len(test.Baseline) == 0 || opts.History == "" {
+		return
+	}
+
+	var model string
+	if result.Device != nil {
+		model = result.Device.Model
+	}
+	// The passed runs of the test in the namespace on the same drive model,
+	// or on any drive when the model is unknown, oldest first
+	var records []HistoryRecord
+	err := scanHistory(opts.History, HistoryFilter{Test: result.TestName, Kind: historyResult, Namespace: namespace, SameNamespace: true}, func(record HistoryRecord) error {
+		if record.Status != "PASSED" {
+			return nil
+		}
+		if model != "" && (record.Device == nil || record.Device.Model != model) {
+			return nil
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		result.warn(severityWarning, "baseline", "cannot read history: %v", err)
+		return
+	}
+
+	var approved []HistoryRecord
+	for _, config := range test.Baseline {
+		if config.ApprovedOnly && approved == nil {
+			runs, err := approvedRuns(opts.History)
+			if err != nil {
+				result.warn(severityWarning, "baseline", "cannot read history: %v", err)
+				return
+			}
+			approved = []HistoryRecord{}
+			for _, record := range records {
+				if runs[record.Run] {
+					approved = append(approved, record)
+				}
+			}
+		}
+	}
+
+	current := newHistoryRecord(historyResult, RunInfo{}, *result)
+	for _, config := range test.Baseline {
+		config = config.withDefaults()
+		value, higherIsBetter, _ := historyMetric(config.Metric)
+		candidates := records
+		if config.ApprovedOnly {
+			candidates = approved
+		}
+		snapshot := newBaselineSnapshot(config, candidates, value)
+		snapshot.DeviceModel = model
+		snapshot.Value = value(current)
+		if snapshot.Insufficient {
+			snapshot.Met = true
+		} else if higherIsBetter {
+			snapshot.Threshold = snapshot.Mean - config.Sigma*snapshot.StdDev
+			snapshot.Met = snapshot.Value >= snapshot.Threshold
+		} else {
+			snapshot.Threshold = snapshot.Mean + config.Sigma*snapshot.StdDev
+			snapshot.Met = snapshot.Value <= snapshot.Threshold
+		}
+		result.Baseline = append(result.Baseline, snapshot)
+
+		if !snapshot.Met && result.Status == "PASSED" {
+			result.Status = "FAILED"
+			result.Error = fmt.Errorf("baseline: %s", snapshot.String())
+		}
+	}
+}
+
+// newBaselineSnapshot computes the statistics of a metric over the last
+// runs of records
+func newBaselineSnapshot(config BaselineConfig, records []HistoryRecord, value func(HistoryRecord) float64) BaselineSnapshot {
+	snapshot := BaselineSnapshot{Metric: config.Metric, Sigma: config.Sigma, MinRuns: config.MinRuns, Runs: []string{}}
+	if len(records) > config.Runs {
+		records = records[len(records)-config.Runs:]
+	}
+	if len(records) < config.MinRuns {
+		snapshot.Insufficient = true
+		for _, record := range records {
+			snapshot.Runs = append(snapshot.Runs, record.Run)
+		}
+		return snapshot
+	}
+
+	var sum float64
+	for _, record := range records {
+		snapshot.Runs = append(snapshot.Runs, record.Run)
+		sum += value(record)
+	}
+	snapshot.From = &records[0].Time
+	snapshot.To = &records[len(records)-1].Time
+	snapshot.Mean = sum / float64(len(records))
+	var squares float64
+	for _, record := range records {
+		squares += (value(record) - snapshot.Mean) * (value(record) - snapshot.Mean)
+	}
+	// Sample standard deviation
+	snapshot.StdDev = math.Sqrt(squares / float64(len(records)-1))
+	return snapshot
+}
+
+func (s BaselineSnapshot) String() string {
+	if s.Insufficient {
+		return fmt.Sprintf("%s %.2f, not judged: %d of %d required runs in history", s.Metric, s.Value, len(s.Runs), s.MinRuns)
+	}
+	sign := "-"
+	if s.Threshold > s.Mean {
+		sign = "+"
+	}
+	state := "met"
+	if !s.Met {
+		state = "missed"
+	}
+	return fmt.Sprintf("%s %.2f vs mean%s%gσ %.2f of %d runs (mean %.2f, σ %.2f, %s)",
+		s.Metric, s.Value, sign, s.Sigma, s.Threshold, len(s.Runs), s.Mean, s.StdDev, state)
+}
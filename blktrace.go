@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// BlktraceSummary summarizes the block layer latencies traced during a test
+type BlktraceSummary struct {
+	Device    string          `json:"device"`
+	TraceDir  string          `json:"trace_dir"`
+	Truncated bool            `json:"truncated,omitempty"`
+	Phases    []BlktracePhase `json:"phases,omitempty"`
+}
+
+// BlktracePhase contains the latency of one IO phase as reported by btt,
+// e.g. Q2C (queue to completion) or D2C (issued to device to completion)
+type BlktracePhase struct {
+	Phase string  `json:"phase"`
+	MinUs float64 `json:"min_us"`
+	AvgUs float64 `json:"avg_us"`
+	MaxUs float64 `json:"max_us"`
+	Count int64   `json:"count"`
+}
+
+// blktraceMonitor records the block layer events of the test target with
+// blktrace, bounded by --blktrace-max-duration and --blktrace-max-size
+type blktraceMonitor struct {
+	test      FioTest
+	run       RunInfo
+	device    string
+	dir       string
+	cmd       *exec.Cmd
+	stderr    bytes.Buffer
+	done      chan struct{}
+	truncated atomic.Bool
+}
+
+func (m *blktraceMonitor) name() string {
+	return "blktrace"
+}
+
+func (m *blktraceMonitor) start() error {
+	device, err := resolveBlockDevice(m.test.Filename)
+	if err != nil {
+		return err
+	}
+	m.device = device
+
+	m.dir, err = artifactPath(m.run, m.test, "blktrace")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+
+	seconds := int(opts.BlktraceMaxDuration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	m.cmd = exec.Command("blktrace", "-d", m.device, "-D", m.dir, "-o", "trace", "-w", strconv.Itoa(seconds))
+	m.cmd.Stderr = &m.stderr
+	if err := m.cmd.Start(); err != nil {
+		return err
+	}
+
+	m.done = make(chan struct{})
+	go m.limitSize()
+	return nil
+}
+
+// limitSize stops blktrace once the trace exceeds the size limit
+func (m *blktraceMonitor) limitSize() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if dirSize(m.dir) > opts.BlktraceMaxSize*1024*1024 {
+				m.truncated.Store(true)
+				m.cmd.Process.Signal(os.Interrupt)
+				return
+			}
+		}
+	}
+}
+
+func (m *blktraceMonitor) stop(result *TestResult) {
+	close(m.done)
+	// blktrace flushes its buffers when interrupted, it may already have
+	// exited because of the duration limit
+	m.cmd.Process.Signal(os.Interrupt)
+	if err := m.cmd.Wait(); err != nil && m.stderr.Len() > 0 {
+		result.warn(severityWarning, "monitor", "blktrace failed: %s", bytes.TrimSpace(m.stderr.Bytes()))
+		return
+	}
+
+	summary := &BlktraceSummary{
+		Device:    m.device,
+		TraceDir:  m.dir,
+		Truncated: m.truncated.Load(),
+	}
+	result.Blktrace = summary
+	result.Artifacts = append(result.Artifacts, m.dir)
+	// Once blkparse and btt are done with the traces
+	defer compressArtifacts([]string{m.dir}, result)
+	if summary.Truncated {
+		result.warn(severityNotice, "monitor", "blktrace stopped early after reaching %d MB", opts.BlktraceMaxSize)
+	}
+
+	phases, err := summarizeBlktrace(m.dir)
+	if err != nil {
+		result.warn(severityWarning, "monitor", "blktrace summary unavailable: %v", err)
+		return
+	}
+	summary.Phases = phases
+}
+
+// bttPhasePattern matches a row of the "All Devices" table of btt, with the
+// latencies in seconds
+var bttPhasePattern = regexp.MustCompile(`^([A-Z]2[A-Z])\s+([0-9.]+)\s+([0-9.]+)\s+([0-9.]+)\s+(\d+)`)
+
+// summarizeBlktrace merges the per-CPU traces with blkparse and extracts the
+// latency breakdown of all devices from btt
+func summarizeBlktrace(dir string) ([]BlktracePhase, error) {
+	dump := filepath.Join(dir, "trace.bin")
+	parse := exec.Command("blkparse", "-i", "trace", "-D", dir, "-d", dump, "-O")
+	if output, err := parse.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("blkparse failed: %v: %s", err, bytes.TrimSpace(output))
+	}
+
+	output, err := exec.Command("btt", "-i", dump).Output()
+	if err != nil {
+		return nil, fmt.Errorf("btt failed: %v", err)
+	}
+
+	var phases []BlktracePhase
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := bttPhasePattern.FindStringSubmatch(line)
+		if match == nil {
+			// The first table ends at the first blank line after its rows
+			if len(phases) > 0 && strings.TrimSpace(line) == "" {
+				break
+			}
+			continue
+		}
+
+		phase := BlktracePhase{Phase: match[1]}
+		phase.MinUs = parseFloat(match[2]) * 1e6
+		phase.AvgUs = parseFloat(match[3]) * 1e6
+		phase.MaxUs = parseFloat(match[4]) * 1e6
+		phase.Count, _ = strconv.ParseInt(match[5], 10, 64)
+		phases = append(phases, phase)
+	}
+
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("no latency data in btt output")
+	}
+	return phases, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func displayBlktrace(summary *BlktraceSummary) {
+	if len(summary.Phases) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "Block Layer Breakdown (blktrace %s, microseconds)\n", summary.Device)
+	btTable := tablewriter.NewWriter(out)
+	btTable.SetHeader([]string{"Phase", "Min", "Avg", "Max", "Count"})
+	configureTable(btTable, 5)
+	btTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, phase := range summary.Phases {
+		btTable.Append([]string{
+			phase.Phase,
+			fmt.Sprintf("%.2f", phase.MinUs),
+			fmt.Sprintf("%.2f", phase.AvgUs),
+			fmt.Sprintf("%.2f", phase.MaxUs),
+			strconv.FormatInt(phase.Count, 10),
+		})
+	}
+	btTable.Render()
+	fmt.Fprintln(out)
+}
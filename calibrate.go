@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// defaultCalibrationFile is where calibrate stores the host ceiling
+const defaultCalibrationFile = "fio-qa-calibration.json"
+
+// Calibration is the ceiling of the host measured by running the suite on a
+// null_blk device. null_blk completes IOs without any media, so its results
+// are what the kernel and fio can do on this host at best.
+type Calibration struct {
+	Time     time.Time          `json:"time"`
+	RunID    string             `json:"run_id"`
+	Device   string             `json:"device"`
+	Hostname string             `json:"hostname,omitempty"`
+	Tests    []CalibrationEntry `json:"tests"`
+}
+
+// CalibrationEntry is the ceiling of one test
+type CalibrationEntry struct {
+	Test      string  `json:"test"`
+	IOPS      float64 `json:"iops"`
+	BWMBps    float64 `json:"bw_mbps"`
+	LatencyUs float64 `json:"avg_latency_us"`
+}
+
+// HostCeiling expresses a result as a percentage of the calibrated ceiling
+// of the host for the same test
+type HostCeiling struct {
+	Calibrated time.Time `json:"calibrated"`
+	IOPS       float64   `json:"iops"`
+	BWMBps     float64   `json:"bw_mbps"`
+	LatencyUs  float64   `json:"avg_latency_us"`
+	IOPSPc     float64   `json:"iops_percent"`
+	BWPc       float64   `json:"bw_percent"`
+}
+
+// calibration is the host ceiling loaded with --calibration, if any
+var calibration *Calibration
+
+// loadCalibration loads the host ceiling. A missing file is not an error,
+// results are then not related to the host ceiling.
+func loadCalibration(filename string) error {
+	if filename == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	auditConfigFile(filename, data)
+	var loaded Calibration
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	calibration = &loaded
+	return nil
+}
+
+// compareCalibration relates the result to the host ceiling of its test
+func compareCalibration(result *TestResult) {
+	if calibration == nil {
+		return
+	}
+	for _, entry := range calibration.Tests {
+		if entry.Test != result.TestName {
+			continue
+		}
+		ceiling := &HostCeiling{
+			Calibrated: calibration.Time,
+			IOPS:       entry.IOPS,
+			BWMBps:     entry.BWMBps,
+			LatencyUs:  entry.LatencyUs,
+		}
+		if entry.IOPS > 0 {
+			ceiling.IOPSPc = 100 * result.TotalIOPS / entry.IOPS
+		}
+		if entry.BWMBps > 0 {
+			ceiling.BWPc = 100 * result.TotalBWMBps / entry.BWMBps
+		}
+		result.HostCeiling = ceiling
+		return
+	}
+}
+
+func (c *HostCeiling) String() string {
+	return fmt.Sprintf("%.1f%% of %.0f IOPS, %.1f%% of %.2f MB/s", c.IOPSPc, c.IOPS, c.BWPc, c.BWMBps)
+}
+
+// calibrationTest returns the test as it runs on the null_blk device,
+// without the steps that only make sense on real media
+func calibrationTest(test FioTest, device string) FioTest {
+	test.Filename = device
+	test.ResetDevice = ""
+	test.FillLevel = 0
+	test.FillLevels = nil
+	test.Endurance = nil
+	test.Retention = nil
+	test.Fault = nil
+	test.CooldownSeconds = 0
+	test.CooldownTemp = 0
+	test.Comparators = nil
+	test.Baseline = nil
+	test.ReplayRedirect = ""
+	return test
+}
+
+// runCalibrate runs the suite against a null_blk device and stores the
+// results as the ceiling of the host
+func runCalibrate(args []string) int {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	device := flags.String("device", "/dev/nullb0", "null_blk device to run the tests on")
+	output := flags.String("output", defaultCalibrationFile, "file to store the host ceiling in")
+	testcases := flags.String("testcases", "fio-testcases.json", "test cases to calibrate")
+	suite := flags.String("suite", "", "calibrate all suites of a manifest instead of --testcases")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	// Anything but null_blk would measure media instead of the host
+	if !strings.HasPrefix(filepath.Base(*device), "nullb") {
+		return usageError("%s is not a null_blk device, load the null_blk module and use /dev/nullbN", *device)
+	}
+	if info, err := os.Stat(*device); err != nil {
+		return usageError("%v", err)
+	} else if info.Mode()&os.ModeDevice == 0 {
+		return usageError("%s is not a block device", *device)
+	}
+	if !checkFioInstalled() {
+		fmt.Fprintln(os.Stderr, "Error: fio is not installed or not in PATH")
+		return exitEnvironment
+	}
+
+	var testCases *TestCases
+	var err error
+	if *suite != "" {
+		testCases, err = loadSuiteManifest(*suite)
+	} else {
+		testCases, err = loadTestCases(*testcases)
+	}
+	if err != nil {
+		return usageError("loading test cases: %v", err)
+	}
+	// Named like in the test runs the ceilings are looked up for
+	for _, rename := range uniqueTestNames(testCases.Tests) {
+		fmt.Fprintf(out, "Warning: %s\n", rename)
+	}
+
+	now := time.Now()
+	runID = newRunID(now)
+	run := RunInfo{ID: runID, Timestamp: now.Format("2006-01-02-150405")}
+	if err := createRunTempDir(&run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitEnvironment
+	}
+	defer os.RemoveAll(run.TempDir)
+
+	result := Calibration{Time: now, RunID: run.ID, Device: *device}
+	result.Hostname, _ = os.Hostname()
+	failed := 0
+	ctx := interruptContext()
+	for i, test := range testCases.Tests {
+		if ctx.Err() != nil {
+			return exitTestsFailed
+		}
+		fmt.Fprintf(out, "[%d/%d] Calibrating %s on %s\n", i+1, len(testCases.Tests), test.Name, *device)
+		r := runTest(ctx, calibrationTest(test, *device), run)
+		if r.Status != "PASSED" {
+			fmt.Fprintf(out, "Warning: %s failed: %v\n", test.Name, r.Error)
+			failed++
+			continue
+		}
+		result.Tests = append(result.Tests, CalibrationEntry{
+			Test:      test.Name,
+			IOPS:      r.TotalIOPS,
+			BWMBps:    r.TotalBWMBps,
+			LatencyUs: r.AvgLatencyUs,
+		})
+	}
+	fmt.Fprintln(out)
+	displayCalibration(result)
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err == nil {
+		err = os.WriteFile(*output, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot save the host ceiling: %v\n", err)
+		return exitOutput
+	}
+	fmt.Fprintf(out, "Host ceiling of %d tests saved to %s\n", len(result.Tests), *output)
+	if failed > 0 {
+		return exitTestsFailed
+	}
+	return exitOK
+}
+
+func displayCalibration(c Calibration) {
+	fmt.Fprintf(out, "Host Ceiling (%s)\n", c.Device)
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Test", "IOPS", "BW (MB/s)", "Lat (" + usUnit() + ")"})
+	configureTable(table, 4)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, entry := range c.Tests {
+		table.Append([]string{
+			entry.Test,
+			formatCount(entry.IOPS),
+			fmt.Sprintf("%.2f", entry.BWMBps),
+			fmt.Sprintf("%.2f", entry.LatencyUs),
+		})
+	}
+	table.Render()
+	fmt.Fprintln(out)
+}
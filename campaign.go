@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Campaign accumulates the sessions of a burn-in campaign, a series of runs
+// on the same drives spread over days or weeks, in one file that grows with
+// every run made with --campaign
+type Campaign struct {
+	Name     string            `json:"name"`
+	Sessions []CampaignSession `json:"sessions"`
+
+	path string
+}
+
+// CampaignSession is one run of a campaign
+type CampaignSession struct {
+	Run       string    `json:"run"`
+	Namespace string    `json:"namespace,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	// BytesWritten is what fio wrote during the session, endurance tests
+	// only count their last checkpoint
+	BytesWritten int64            `json:"bytes_written"`
+	Devices      []CampaignDevice `json:"devices,omitempty"`
+	Tests        []CampaignTest   `json:"tests"`
+}
+
+// CampaignDevice holds the wear counters of a tested drive before and after
+// a session
+type CampaignDevice struct {
+	Device     string     `json:"device"`
+	Model      string     `json:"model,omitempty"`
+	Serial     string     `json:"serial,omitempty"`
+	StartSMART *SMARTData `json:"start_smart,omitempty"`
+	EndSMART   *SMARTData `json:"end_smart,omitempty"`
+}
+
+// CampaignTest is the performance of a test in a session
+type CampaignTest struct {
+	Test         string  `json:"test"`
+	Status       string  `json:"status"`
+	IOPS         float64 `json:"iops"`
+	BWMBps       float64 `json:"bw_mbps"`
+	LatencyUs    float64 `json:"avg_latency_us"`
+	P99LatencyUs float64 `json:"p99_latency_us"`
+	BytesWritten int64   `json:"bytes_written"`
+}
+
+// campaignFile is where a campaign is kept: the name itself when it is a
+// .json file, otherwise fio-qa-campaign-<name>.json
+func campaignFile(name string) string {
+	if strings.HasSuffix(name, ".json") {
+		return name
+	}
+	return "fio-qa-campaign-" + sanitizeName(name) + ".json"
+}
+
+// openCampaign reads a campaign, a campaign without a file yet starts empty.
+// Nothing is opened without a name.
+func openCampaign(name string) (*Campaign, error) {
+	if name == "" {
+		return nil, nil
+	}
+	path := campaignFile(name)
+	campaign := &Campaign{Name: strings.TrimSuffix(name, ".json"), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return campaign, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("campaign: %v", err)
+	}
+	if err := json.Unmarshal(data, campaign); err != nil {
+		return nil, fmt.Errorf("campaign %s: %v", path, err)
+	}
+	return campaign, nil
+}
+
+// startSession begins the session of a run, reading the wear counters of
+// the drives holding the test targets
+func (c *Campaign) startSession(run RunInfo, tests []FioTest) *CampaignSession {
+	if c == nil {
+		return nil
+	}
+	session := &CampaignSession{Run: run.ID, Namespace: run.Namespace, Start: time.Now()}
+	seen := map[string]bool{}
+	for _, test := range tests {
+		metadata, err := targetDevice(test.Filename)
+		if err != nil || seen[metadata.Device] {
+			continue
+		}
+		seen[metadata.Device] = true
+		device := CampaignDevice{Device: metadata.Device, Model: metadata.Model, Serial: metadata.Serial}
+		device.StartSMART, _ = readSMART(metadata.Device)
+		session.Devices = append(session.Devices, device)
+	}
+	return session
+}
+
+// record adds a test result to the session, before --lite drops the fio
+// data it counts the written bytes from
+func (s *CampaignSession) record(result TestResult) {
+	if s == nil {
+		return
+	}
+	test := CampaignTest{
+		Test:         result.TestName,
+		Status:       result.Status,
+		IOPS:         result.TotalIOPS,
+		BWMBps:       result.TotalBWMBps,
+		LatencyUs:    result.AvgLatencyUs,
+		P99LatencyUs: p99LatencyUs(result),
+	}
+	if result.FioJob != nil {
+		test.BytesWritten = int64(result.FioJob.Write.IOKBytes * 1024)
+	}
+	if result.Status == "PASSED" {
+		s.Passed++
+	} else {
+		s.Failed++
+	}
+	s.BytesWritten += test.BytesWritten
+	s.Tests = append(s.Tests, test)
+}
+
+// finish reads the wear counters after the session, adds the session to the
+// campaign and saves it
+func (c *Campaign) finish(session *CampaignSession) error {
+	if c == nil {
+		return nil
+	}
+	session.End = time.Now()
+	for i := range session.Devices {
+		session.Devices[i].EndSMART, _ = readSMART(session.Devices[i].Device)
+	}
+	c.Sessions = append(c.Sessions, *session)
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// campaignDeviceWear follows a drive over all sessions of the campaign: its
+// counters before the first session and after the last
+type campaignDeviceWear struct {
+	device      CampaignDevice
+	first, last *SMARTData
+	sessions    int
+}
+
+// deviceWear collects the first and last wear counters of every drive,
+// drives are told apart by serial number when they have one
+func (c *Campaign) deviceWear() []*campaignDeviceWear {
+	var wear []*campaignDeviceWear
+	index := map[string]*campaignDeviceWear{}
+	for _, session := range c.Sessions {
+		for _, device := range session.Devices {
+			key := device.Serial
+			if key == "" {
+				key = device.Device
+			}
+			w := index[key]
+			if w == nil {
+				w = &campaignDeviceWear{device: device}
+				index[key] = w
+				wear = append(wear, w)
+			}
+			w.sessions++
+			if w.first == nil {
+				w.first = device.StartSMART
+			}
+			if device.EndSMART != nil {
+				w.last = device.EndSMART
+			}
+		}
+	}
+	return wear
+}
+
+// campaignTrajectory is the performance of a test over the sessions of the
+// campaign
+type campaignTrajectory struct {
+	test              string
+	sessions, failed  int
+	firstIOPS         float64
+	lastIOPS          float64
+	firstP99, lastP99 float64
+}
+
+// trajectories follows every test over the passed sessions of the campaign,
+// in the order the tests first ran
+func (c *Campaign) trajectories() []*campaignTrajectory {
+	var trajectories []*campaignTrajectory
+	index := map[string]*campaignTrajectory{}
+	for _, session := range c.Sessions {
+		for _, test := range session.Tests {
+			t := index[test.Test]
+			if t == nil {
+				t = &campaignTrajectory{test: test.Test}
+				index[test.Test] = t
+				trajectories = append(trajectories, t)
+			}
+			if test.Status != "PASSED" {
+				t.failed++
+				continue
+			}
+			t.sessions++
+			if t.sessions == 1 {
+				t.firstIOPS, t.firstP99 = test.IOPS, test.P99LatencyUs
+			}
+			t.lastIOPS, t.lastP99 = test.IOPS, test.P99LatencyUs
+		}
+	}
+	return trajectories
+}
+
+// displayCampaign shows the campaign report: what was written and how the
+// drives wore over all sessions, the sessions and how the performance of
+// every test developed
+func displayCampaign(c *Campaign) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	fmt.Fprintf(out, "=== CAMPAIGN %s ===\n", c.Name)
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	fmt.Fprintln(out)
+	if len(c.Sessions) == 0 {
+		fmt.Fprintf(out, "No sessions in %s yet\n", c.path)
+		return
+	}
+
+	first, last := c.Sessions[0], c.Sessions[len(c.Sessions)-1]
+	var written int64
+	for _, session := range c.Sessions {
+		written += session.BytesWritten
+	}
+	fmt.Fprintf(out, "Sessions: %d from %s to %s (%s)\n", len(c.Sessions),
+		first.Start.Local().Format("2006-01-02 15:04"), last.End.Local().Format("2006-01-02 15:04"),
+		last.End.Sub(first.Start).Round(time.Minute))
+	fmt.Fprintf(out, "Written by fio: %.1f GB\n\n", float64(written)/1e9)
+
+	for _, w := range c.deviceWear() {
+		deviceTable := tablewriter.NewWriter(out)
+		deviceTable.SetHeader([]string{"Metric", "Value"})
+		configureTable(deviceTable, 2)
+		deviceTable.Append([]string{"Device", w.device.Device})
+		if w.device.Model != "" {
+			deviceTable.Append([]string{"Model", w.device.Model})
+		}
+		if w.device.Serial != "" {
+			deviceTable.Append([]string{"Serial", w.device.Serial})
+		}
+		deviceTable.Append([]string{"Sessions", strconv.Itoa(w.sessions)})
+		if w.first != nil && w.last != nil {
+			if w.first.DataWrittenBytes > 0 {
+				deviceTable.Append([]string{"Drive Writes", fmt.Sprintf("%.1f GB", float64(w.last.DataWrittenBytes-w.first.DataWrittenBytes)/1e9)})
+			}
+			deviceTable.Append([]string{"Wear (Percentage Used)", fmt.Sprintf("%.0f%% to %.0f%%", w.first.PercentageUsed, w.last.PercentageUsed)})
+			deviceTable.Append([]string{"Media Errors", fmt.Sprintf("%d to %d", w.first.MediaErrors, w.last.MediaErrors)})
+			deviceTable.Append([]string{"Power-On Hours", fmt.Sprintf("%d to %d", w.first.PowerOnHours, w.last.PowerOnHours)})
+		} else {
+			deviceTable.Append([]string{"Wear (Percentage Used)", "unavailable"})
+		}
+		deviceTable.Render()
+		fmt.Fprintln(out)
+	}
+
+	sessionTable := tablewriter.NewWriter(out)
+	sessionTable.SetHeader([]string{"#", "Date", "Run ID", "Passed", "Written", "Wear"})
+	configureTable(sessionTable, 6)
+	for i, session := range c.Sessions {
+		wear := "-"
+		for _, device := range session.Devices {
+			if device.EndSMART != nil {
+				wear = fmt.Sprintf("%.0f%%", device.EndSMART.PercentageUsed)
+				break
+			}
+		}
+		sessionTable.Append([]string{
+			strconv.Itoa(i + 1),
+			session.Start.Local().Format("2006-01-02 15:04"),
+			session.Run,
+			fmt.Sprintf("%d/%d", session.Passed, session.Passed+session.Failed),
+			fmt.Sprintf("%.1f GB", float64(session.BytesWritten)/1e9),
+			wear,
+		})
+	}
+	sessionTable.Render()
+	fmt.Fprintln(out)
+
+	trajectoryTable := tablewriter.NewWriter(out)
+	trajectoryTable.SetHeader([]string{"Test", "Passed", "First IOPS", "Last IOPS", "Change", "p99 (" + usUnit() + ")"})
+	configureTable(trajectoryTable, 6)
+	for _, t := range c.trajectories() {
+		row := []string{t.test, fmt.Sprintf("%d/%d", t.sessions, t.sessions+t.failed), "-", "-", "-", "-"}
+		if t.sessions > 0 {
+			row[2] = formatCount(t.firstIOPS)
+			row[3] = formatCount(t.lastIOPS)
+			if t.firstIOPS > 0 {
+				row[4] = fmt.Sprintf("%+.1f%%", 100*(t.lastIOPS-t.firstIOPS)/t.firstIOPS)
+			}
+			row[5] = fmt.Sprintf("%.2f to %.2f", t.firstP99, t.lastP99)
+		}
+		trajectoryTable.Append(row)
+	}
+	trajectoryTable.Render()
+}
+
+// runCampaign shows the report of a campaign without running tests
+func runCampaign(args []string) int {
+	flags := flag.NewFlagSet("campaign", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		return usageError("usage: fio-qa campaign <name|campaign.json>")
+	}
+	if _, err := os.Stat(campaignFile(flags.Arg(0))); err != nil {
+		return usageError("%v", err)
+	}
+	campaign, err := openCampaign(flags.Arg(0))
+	if err != nil {
+		return usageError("%v", err)
+	}
+	displayCampaign(campaign)
+	return exitOK
+}
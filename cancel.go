@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// interruptContext returns the context of a run, cancelled by SIGINT or
+// SIGTERM. A second signal ends fio-qa right away.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx
+}
+
+// cancel marks a result as cancelled. Whatever was measured before is kept,
+// the test fails since its measurement is incomplete.
+func (r *TestResult) cancel() {
+	r.Status = "FAILED"
+	r.Cancelled = true
+	r.Error = fmt.Errorf("cancelled after %s", r.Duration.Round(time.Second))
+}
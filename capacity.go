@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+// CapacityMetrics normalizes the metrics of a test by the capacity of its
+// drive, so drives of different sizes can be compared for fleet planning.
+// Capacities are decimal like the drive labels, 1 TB is 10^12 bytes.
+type CapacityMetrics struct {
+	CapacityBytes int64   `json:"capacity_bytes"`
+	IOPSPerGB     float64 `json:"iops_per_gb"`
+	MBpsPerGB     float64 `json:"mbps_per_gb"`
+	IOPSPerTB     float64 `json:"iops_per_tb"`
+	MBpsPerTB     float64 `json:"mbps_per_tb"`
+}
+
+// capacityMetrics normalizes the metrics of a result by the size of the
+// detected drive, nil when the size is unknown or nothing was measured, and
+// for metadata tests that do not measure IO
+func capacityMetrics(r TestResult) *CapacityMetrics {
+	if r.Device == nil || r.Device.SizeBytes <= 0 || r.Metadata != nil || r.TotalIOPS == 0 && r.TotalBWMBps == 0 {
+		return nil
+	}
+	tb := float64(r.Device.SizeBytes) / 1e12
+	return &CapacityMetrics{
+		CapacityBytes: r.Device.SizeBytes,
+		IOPSPerGB:     r.TotalIOPS / tb / 1000,
+		MBpsPerGB:     r.TotalBWMBps / tb / 1000,
+		IOPSPerTB:     r.TotalIOPS / tb,
+		MBpsPerTB:     r.TotalBWMBps / tb,
+	}
+}
+
+func (c *CapacityMetrics) String() string {
+	return fmt.Sprintf("%s IOPS/TB, %.2f MB/s per TB (%.2f TB)", formatCount(c.IOPSPerTB), c.MBpsPerTB, float64(c.CapacityBytes)/1e12)
+}
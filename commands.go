@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// subcommands are run instead of the tests when named as the first argument,
+// they return the exit code
+var subcommands = map[string]func(args []string) int{
+	"calibrate":       runCalibrate,
+	"campaign":        runCampaign,
+	"convert":         runConvert,
+	"diff":            runDiff,
+	"nvme-namespaces": runNVMeNamespaces,
+	"prune":           runPrune,
+	"report":          runReport,
+	"results":         runResults,
+	"schema":          runSchema,
+	"sign":            runSign,
+	"trend":           runTrend,
+	"validate":        runValidate,
+}
+
+// The subcommands that list the other subcommands are added when the
+// program starts, the map cannot refer to itself
+func init() {
+	subcommands["completion"] = runCompletion
+	subcommands["help"] = runHelp
+}
+
+// runSubcommand runs the subcommand named by the first argument, if any
+func runSubcommand(args []string) (int, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+	command, ok := subcommands[args[0]]
+	if !ok {
+		return 0, false
+	}
+	return command(args[1:]), true
+}
+
+// subcommandNames lists the subcommands for usage messages
+func subcommandNames() string {
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// usageError reports invalid arguments of a subcommand
+func usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", fmt.Sprintf(format, args...))
+	return exitUsage
+}
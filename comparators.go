@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"fio-qa/compare"
+	"fio-qa/runner"
+)
+
+// ComparatorConfig enables a comparator for a test
+type ComparatorConfig struct {
+	Name   string          `json:"name"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func init() {
+	compare.Register("thresholds", compare.Func(compareThresholds))
+	compare.Register("fleet_median", compare.Func(compareFleetMedian))
+}
+
+// checkComparators validates that the comparators of all tests exist
+func checkComparators(tests []FioTest) error {
+	for _, test := range tests {
+		for _, config := range test.Comparators {
+			if _, ok := compare.Lookup(config.Name); !ok {
+				return fmt.Errorf("test %s: unknown comparator %q, available: %s", test.Name, config.Name, strings.Join(compare.Names(), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// comparedResult is the result of a test as comparators see it
+func comparedResult(r TestResult) runner.Result {
+	result := runner.Result{
+		Name:      r.TestName,
+		Duration:  r.Duration,
+		IOPS:      r.TotalIOPS,
+		BWMBps:    r.TotalBWMBps,
+		LatencyUs: r.AvgLatencyUs,
+		Cancelled: r.Cancelled,
+		Err:       r.Error,
+	}
+	if r.FioJob != nil {
+		result.Jobs = []runner.JobResult{*r.FioJob}
+	}
+	if r.Device != nil {
+		result.DeviceModel = r.Device.Model
+	}
+	return result
+}
+
+// applyComparators fails a passed result that does not meet the criteria of
+// one of the comparators of its test
+func applyComparators(test FioTest, result *TestResult) {
+	compared := comparedResult(*result)
+	for _, config := range test.Comparators {
+		comparator, _ := compare.Lookup(config.Name)
+		if err := comparator.Compare(compared, config.Params); err != nil {
+			result.Status = "FAILED"
+			result.Error = fmt.Errorf("%s: %v", config.Name, err)
+			return
+		}
+	}
+}
+
+// thresholdLimits are the parameters of the thresholds comparator
+type thresholdLimits struct {
+	MinIOPS      float64 `json:"min_iops"`
+	MinBWMBps    float64 `json:"min_bw_mbps"`
+	MaxLatencyUs float64 `json:"max_latency_us"`
+	MaxP99Us     float64 `json:"max_p99_us"`
+	// MarginPercent is how close to a limit a metric is marginal
+	MarginPercent *float64 `json:"margin_percent,omitempty"`
+}
+
+// compareThresholds checks static limits of the main metrics
+func compareThresholds(result runner.Result, params json.RawMessage) error {
+	var limits thresholdLimits
+	if err := compare.DecodeParams(params, &limits); err != nil {
+		return err
+	}
+	switch {
+	case limits.MinIOPS > 0 && result.IOPS < limits.MinIOPS:
+		return fmt.Errorf("IOPS %.0f below %.0f", result.IOPS, limits.MinIOPS)
+	case limits.MinBWMBps > 0 && result.BWMBps < limits.MinBWMBps:
+		return fmt.Errorf("bandwidth %.2f MB/s below %.2f MB/s", result.BWMBps, limits.MinBWMBps)
+	case limits.MaxLatencyUs > 0 && result.LatencyUs > limits.MaxLatencyUs:
+		return fmt.Errorf("average latency %.2fμs above %.2fμs", result.LatencyUs, limits.MaxLatencyUs)
+	case limits.MaxP99Us > 0 && result.P99LatencyUs() > limits.MaxP99Us:
+		return fmt.Errorf("p99 latency %.2fμs above %.2fμs", result.P99LatencyUs(), limits.MaxP99Us)
+	}
+	return nil
+}
+
+// compareFleetMedian compares a metric with the median of the earlier
+// results of the same test on drives of the same model in the history
+// store, e.g. "p99 within 10% of the fleet median for this drive model"
+func compareFleetMedian(result runner.Result, params json.RawMessage) error {
+	config := struct {
+		Metric           string  `json:"metric"`
+		TolerancePercent float64 `json:"tolerance_percent"`
+		MinSamples       int     `json:"min_samples"`
+	}{Metric: "p99", TolerancePercent: 10, MinSamples: 5}
+	if err := compare.DecodeParams(params, &config); err != nil {
+		return err
+	}
+	value, higherIsBetter, err := historyMetric(config.Metric)
+	if err != nil {
+		return err
+	}
+	if result.DeviceModel == "" || opts.History == "" {
+		// Without a drive model there is no fleet to compare with
+		return nil
+	}
+
+	current := HistoryRecord{IOPS: result.IOPS, BWMBps: result.BWMBps, LatencyUs: result.LatencyUs, P99LatencyUs: result.P99LatencyUs()}
+	var samples []float64
+	err = scanHistory(opts.History, HistoryFilter{Test: result.Name, Kind: historyResult, Namespace: namespace, SameNamespace: true}, func(record HistoryRecord) error {
+		if record.Status == "PASSED" && record.Device != nil && record.Device.Model == result.DeviceModel {
+			samples = append(samples, value(record))
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read history: %v", err)
+	}
+	if len(samples) < config.MinSamples {
+		return nil
+	}
+
+	fleet := median(samples)
+	deviation := 100 * (value(current) - fleet) / fleet
+	if higherIsBetter {
+		deviation = -deviation
+	}
+	if deviation > config.TolerancePercent {
+		return fmt.Errorf("%s %.2f is %.1f%% worse than the median %.2f of %d runs on %s", config.Metric, value(current), deviation, fleet, len(samples), result.DeviceModel)
+	}
+	return nil
+}
+
+// historyMetric returns the accessor of a metric of history records and
+// whether higher values are better
+func historyMetric(metric string) (func(HistoryRecord) float64, bool, error) {
+	switch metric {
+	case "iops":
+		return func(r HistoryRecord) float64 { return r.IOPS }, true, nil
+	case "bw":
+		return func(r HistoryRecord) float64 { return r.BWMBps }, true, nil
+	case "lat":
+		return func(r HistoryRecord) float64 { return r.LatencyUs }, false, nil
+	case "p99":
+		return func(r HistoryRecord) float64 { return r.P99LatencyUs }, false, nil
+	}
+	return nil, false, fmt.Errorf("unknown metric %q, use iops, bw, lat or p99", metric)
+}
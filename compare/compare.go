@@ -0,0 +1,83 @@
+// Package compare holds the comparators of fio-qa, custom pass criteria of
+// test results beyond the checks built into the tool. Comparators are
+// registered by name with Register, usually from the init function of the
+// package implementing them, and enabled per test with "comparators" in the
+// test case. A build of fio-qa uses every comparator of the packages linked
+// into it, a blank import of the package is enough.
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"fio-qa/runner"
+)
+
+// Comparator implements a custom pass criterion for test results
+type Comparator interface {
+	// Compare returns nil when the result passes and otherwise an error
+	// explaining why it failed. Params are the raw JSON parameters of the
+	// test case.
+	Compare(result runner.Result, params json.RawMessage) error
+}
+
+// Func adapts a function to the Comparator interface
+type Func func(result runner.Result, params json.RawMessage) error
+
+// Compare calls f(result, params)
+func (f Func) Compare(result runner.Result, params json.RawMessage) error {
+	return f(result, params)
+}
+
+var (
+	comparatorsMu sync.Mutex
+	comparators   = map[string]Comparator{}
+)
+
+// Register makes a comparator available to test cases under its name. It
+// panics when the name is registered twice.
+func Register(name string, comparator Comparator) {
+	comparatorsMu.Lock()
+	defer comparatorsMu.Unlock()
+	if _, ok := comparators[name]; ok {
+		panic("comparator " + name + " registered twice")
+	}
+	comparators[name] = comparator
+}
+
+// Lookup returns the comparator registered under the name
+func Lookup(name string) (Comparator, bool) {
+	comparatorsMu.Lock()
+	defer comparatorsMu.Unlock()
+	comparator, ok := comparators[name]
+	return comparator, ok
+}
+
+// Names returns the names of the registered comparators, sorted
+func Names() []string {
+	comparatorsMu.Lock()
+	defer comparatorsMu.Unlock()
+	names := make([]string, 0, len(comparators))
+	for name := range comparators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeParams decodes the parameters of a comparator, rejecting unknown
+// fields so that typos do not silently disable a check
+func DecodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid params: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"fio-qa/runner"
+)
+
+// TestRegister registers a comparator limiting the p99 latency and applies
+// it like a test case enabling it does
+func TestRegister(t *testing.T) {
+	Register("max_p99", Func(func(result runner.Result, params json.RawMessage) error {
+		var limit struct {
+			Us float64 `json:"us"`
+		}
+		if err := DecodeParams(params, &limit); err != nil {
+			return err
+		}
+		if p99 := result.P99LatencyUs(); p99 > limit.Us {
+			return fmt.Errorf("p99 %.0fus above %.0fus", p99, limit.Us)
+		}
+		return nil
+	}))
+	comparator, ok := Lookup("max_p99")
+	if !ok {
+		t.Fatalf("max_p99 is not registered, registered are %v", Names())
+	}
+
+	var job runner.JobResult
+	job.Read.Clat.Percentile = map[string]float64{"99.000000": 120000}
+	result := runner.Result{Name: "randread", Jobs: []runner.JobResult{job}}
+	for _, tt := range []struct {
+		params string
+		want   string
+	}{
+		{`{"us": 200}`, ""},
+		{`{"us": 100}`, "p99 120us above 100us"},
+		{`{"usec": 100}`, `invalid params: json: unknown field "usec"`},
+	} {
+		err := comparator.Compare(result, json.RawMessage(tt.params))
+		if got := fmt.Sprint(err); tt.want == "" && err != nil || tt.want != "" && got != tt.want {
+			t.Errorf("params %s: error %v, want %q", tt.params, err, tt.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering max_p99 twice did not panic")
+		}
+	}()
+	Register("max_p99", comparator)
+}
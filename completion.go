@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// completionScripts are the completion scripts of the shells. They call
+// "fio-qa completion complete" with the words of the command line on every
+// tab and fall back to file names when it offers nothing.
+var completionScripts = map[string]string{
+	"bash": `# bash completion of fio-qa, load with: source <(fio-qa completion bash)
+_fio_qa() {
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" completion complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _fio_qa fio-qa
+`,
+	"zsh": `#compdef fio-qa
+# zsh completion of fio-qa, load with: source <(fio-qa completion zsh)
+_fio_qa() {
+	local -a candidates
+	candidates=("${(@f)$("${words[1]}" completion complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${candidates[1]}" ]]; then
+		compadd -Q -a candidates
+	else
+		_files
+	fi
+}
+compdef _fio_qa fio-qa
+`,
+	"fish": `# fish completion of fio-qa, load with: fio-qa completion fish | source
+function __fio_qa_complete
+	set -l tokens (commandline -opc)
+	$tokens[1] completion complete -- $tokens[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c fio-qa -a '(__fio_qa_complete)'
+`,
+}
+
+// subcommandArguments are the values of the first argument of subcommands
+// that take a fixed set of them
+var subcommandArguments = map[string]func() []string{
+	"completion": func() []string { return []string{"bash", "fish", "zsh"} },
+	"help": func() []string {
+		return append(strings.Split(subcommandNames(), ", "), "config")
+	},
+	"results": func() []string { return []string{"query"} },
+	"schema":  func() []string { return []string{"results", "suite", "testcases"} },
+}
+
+// optionValues complete the values of options with a fixed set of values,
+// by flag name
+var optionValues = map[string]func() []string{
+	"container-runtime": func() []string { return []string{"docker", "podman"} },
+	"kind":              func() []string { return []string{"results", "suite", "testcases"} },
+	"profile": func() []string {
+		var names []string
+		for name := range workloadProfiles {
+			names = append(names, name)
+		}
+		return names
+	},
+	"to": func() []string { return []string{"json", "pdf", "proto", "xlsx"} },
+}
+
+// completionFlag is a flag of the test run or a subcommand
+type completionFlag struct {
+	name  string
+	value bool
+}
+
+// runCompletion prints the completion script of a shell, or with complete
+// the completions of the current word of a command line
+func runCompletion(args []string) int {
+	if len(args) > 0 && args[0] == "complete" {
+		words := args[1:]
+		if len(words) > 0 && words[0] == "--" {
+			words = words[1:]
+		}
+		for _, candidate := range completeWords(words) {
+			fmt.Fprintln(out, candidate)
+		}
+		return exitOK
+	}
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		return usageError("usage: fio-qa completion bash|zsh|fish")
+	}
+	fmt.Fprint(out, completionScripts[args[0]])
+	return exitOK
+}
+
+// completeWords returns the completions of the last of the words after the
+// program name. Nothing is offered where a file name fits, the shell
+// completes those.
+func completeWords(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current, previous := words[len(words)-1], words[:len(words)-1]
+	command := ""
+	if len(previous) > 0 && subcommands[previous[0]] != nil {
+		command, previous = previous[0], previous[1:]
+	}
+	if command == "" && len(previous) == 0 && !strings.HasPrefix(current, "-") {
+		return matching(strings.Split(subcommandNames(), ", "), current)
+	}
+
+	var flags []completionFlag
+	if command == "" {
+		flags = mainFlags()
+	} else {
+		flags = subcommandFlags(command, previous)
+	}
+	// The value of the option before the current word
+	if len(previous) > 0 {
+		last := previous[len(previous)-1]
+		for _, f := range flags {
+			if f.value && (last == "-"+f.name || last == "--"+f.name) {
+				return completeValue(f.name, current, words)
+			}
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		var names []string
+		for _, f := range flags {
+			names = append(names, "--"+f.name)
+		}
+		return matching(names, current)
+	}
+
+	switch {
+	case command == "help" && len(previous) == 1 && previous[0] == "config":
+		return matching(configFieldPaths(), current)
+	case subcommandArguments[command] != nil && len(previous) == 0:
+		return matching(subcommandArguments[command](), current)
+	case command == "diff" && strings.Contains(current, "#"):
+		return matching(resultTestNames(current[:strings.LastIndex(current, "#")]), current)
+	}
+	return nil
+}
+
+// completeValue completes the value of an option
+func completeValue(name, current string, words []string) []string {
+	if name == "test" {
+		return matching(testNames(words), current)
+	}
+	if values := optionValues[name]; values != nil {
+		return matching(values(), current)
+	}
+	return nil
+}
+
+// matching returns the candidates starting with the current word, sorted
+func matching(candidates []string, current string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// mainFlags returns the flags of the test run
+func mainFlags() []completionFlag {
+	defineOptions()
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, completionFlag{name: f.Name, value: !isBoolFlag(f)})
+	})
+	return flags
+}
+
+// isBoolFlag tells whether a flag is given without a value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// subcommandFlags returns the flags of a subcommand, read from the usage it
+// prints for -h since subcommands define their flags when they run
+func subcommandFlags(command string, args []string) []completionFlag {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	helpArgs := []string{command}
+	if command == "results" && len(args) > 0 {
+		helpArgs = append(helpArgs, args[0])
+	}
+	output, _ := exec.Command(executable, append(helpArgs, "-h")...).CombinedOutput()
+
+	var flags []completionFlag
+	for _, line := range strings.Split(string(output), "\n") {
+		// "  -name type" for options with a value, "  -name" for switches
+		if !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		fields := strings.Fields(line)
+		flags = append(flags, completionFlag{name: strings.TrimPrefix(fields[0], "-"), value: len(fields) > 1})
+	}
+	return flags
+}
+
+// testNames returns the names of the tests of the command line: those of
+// the suite manifest or test cases file it names, fio-testcases.json
+// otherwise
+func testNames(words []string) []string {
+	load := func() (*TestCases, error) { return loadTestCases("fio-testcases.json") }
+	for i := 0; i+1 < len(words); i++ {
+		switch strings.TrimLeft(words[i], "-") {
+		case "suite":
+			manifest := words[i+1]
+			load = func() (*TestCases, error) { return loadSuiteManifest(manifest) }
+		case "testcases":
+			file := words[i+1]
+			load = func() (*TestCases, error) { return loadTestCases(file) }
+		}
+	}
+	testCases, err := load()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, test := range testCases.Tests {
+		names = append(names, test.Name)
+	}
+	return names
+}
+
+// resultTestNames returns the tests of a results file as path#name
+func resultTestNames(path string) []string {
+	results, _, err := readResultsFile(path)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, r := range results.TestResults {
+		names = append(names, path+"#"+r.TestName)
+	}
+	return names
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// artifactReader decompresses an artifact while it is read and closes the
+// file with it
+type artifactReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r artifactReader) Close() error {
+	return r.file.Close()
+}
+
+// openArtifact opens a file for reading, decompressing it on the fly when it
+// is gzip compressed. Compression is detected from the content, not the
+// name, so every reader accepts artifacts and results files either way.
+func openArtifact(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReaderSize(f, 64*1024)
+	if magic, _ := reader.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return artifactReader{reader, f}, nil
+	}
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return artifactReader{gz, f}, nil
+}
+
+// readArtifact reads a whole file, decompressing it when it is gzip
+// compressed
+func readArtifact(path string) ([]byte, error) {
+	reader, err := openArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return data, nil
+}
+
+// compressFile replaces a file with its gzip compressed copy and returns
+// the path of the copy
+func compressFile(path string) (string, error) {
+	return path + ".gz", compressFileTo(path, path+".gz")
+}
+
+// compressFileTo writes the gzip compressed copy of a file to target and
+// removes the file
+func compressFileTo(path, target string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// compressArtifacts compresses the files of the artifact bundle with
+// --compress-artifacts and returns their new paths. Directories, like the
+// traces of blktrace, are compressed file by file. Files that cannot be
+// compressed are kept as they are with a warning.
+func compressArtifacts(paths []string, result *TestResult) []string {
+	if !opts.CompressArtifacts {
+		return paths
+	}
+	compressed := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			compressed = append(compressed, path)
+			continue
+		}
+		if info.IsDir() {
+			filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() && !strings.HasSuffix(file, ".gz") {
+					if _, err := compressFile(file); err != nil {
+						result.warn(severityWarning, "monitor", "cannot compress %s: %v", file, err)
+					}
+				}
+				return nil
+			})
+			compressed = append(compressed, path)
+			continue
+		}
+		gz, err := compressFile(path)
+		if err != nil {
+			result.warn(severityWarning, "monitor", "cannot compress %s: %v", path, err)
+			gz = path
+		}
+		compressed = append(compressed, gz)
+	}
+	return compressed
+}
+
+// decompressArtifact writes the decompressed copy of a gzip compressed file
+// into dir for tools like fio that only read plain files. Plain files are
+// returned as they are.
+func decompressArtifact(path, dir string) (string, error) {
+	reader, err := openArtifact(path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	if _, ok := reader.(artifactReader).Reader.(*gzip.Reader); !ok {
+		return path, nil
+	}
+
+	dst, err := os.CreateTemp(dir, strings.TrimSuffix(filepath.Base(path), ".gz")+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, reader); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return dst.Name(), dst.Close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// fioOption is a single option passed to fio on the command line
+type fioOption struct {
+	Name  string
+	Value string
+	Flag  bool
+}
+
+// parseFioArgs splits "--name=value" arguments into options. Flags given
+// without a value, like --time_based, are reported with the value "1".
+func parseFioArgs(args []string) []fioOption {
+	options := make([]fioOption, 0, len(args))
+	for _, arg := range args {
+		name, value, found := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !found {
+			value = "1"
+		}
+		options = append(options, fioOption{Name: name, Value: value, Flag: !found})
+	}
+	return options
+}
+
+// commandLineOptions are fio options that apply to the fio process rather
+// than the job, so they never appear in the reported job options
+var commandLineOptions = map[string]bool{
+	"eta":           true,
+	"eta-newline":   true,
+	"output":        true,
+	"output-format": true,
+}
+
+// syncEngines are the ioengines that complete every IO before submitting
+// the next one, so fio caps their queue depth at 1
+var syncEngines = map[string]bool{
+	"sync":    true,
+	"psync":   true,
+	"vsync":   true,
+	"pvsync":  true,
+	"pvsync2": true,
+	"mmap":    true,
+	"splice":  true,
+}
+
+// checkJobOptions compares the options requested for the test with the
+// options fio reports for the job, returning a warning for every option
+// that was silently dropped, changed or has no effect
+func checkJobOptions(test FioTest, args []string, globalOptions map[string]string, job FioJobResult) []string {
+	var warnings []string
+
+	// Older fio versions do not report job options
+	if len(job.JobOptions) > 0 {
+		reported := make(map[string]string, len(globalOptions)+len(job.JobOptions))
+		for name, value := range globalOptions {
+			reported[name] = value
+		}
+		for name, value := range job.JobOptions {
+			reported[name] = value
+		}
+
+		for _, option := range parseFioArgs(args) {
+			if commandLineOptions[option.Name] {
+				continue
+			}
+			value, ok := reported[option.Name]
+			switch {
+			case !ok:
+				warnings = append(warnings, fmt.Sprintf("option %s=%s was not applied by fio", option.Name, option.Value))
+			case !option.Flag && !strings.EqualFold(strings.TrimSpace(value), option.Value):
+				warnings = append(warnings, fmt.Sprintf("option %s=%s was changed by fio to %s", option.Name, option.Value, value))
+			}
+		}
+	}
+
+	if test.IODepth > 1 {
+		if syncEngines[test.IOEngine] {
+			warnings = append(warnings, fmt.Sprintf("iodepth=%d has no effect with the synchronous %s engine, queue depth is capped at 1", test.IODepth, test.IOEngine))
+		} else if job.IODepths["1"] >= 99.9 {
+			warnings = append(warnings, fmt.Sprintf("iodepth=%d was requested but fio kept the queue depth at 1", test.IODepth))
+		}
+	}
+
+	return warnings
+}
+
+// displayConfig shows the options fio was actually run with, after the
+// workload template and test case overrides were applied
+func displayConfig(result TestResult) {
+	if len(result.FioArgs) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "Test Configuration")
+	configTable := tablewriter.NewWriter(out)
+	configTable.SetHeader([]string{"Option", "Value"})
+	configureTable(configTable, 2)
+	configTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	if result.Config.Template != "" {
+		configTable.Append([]string{"template", result.Config.Template})
+	}
+	for _, option := range parseFioArgs(result.FioArgs) {
+		configTable.Append([]string{option.Name, option.Value})
+	}
+	configTable.Render()
+	fmt.Fprintln(out)
+}
+
+// parseSize converts a fio size like "4k" or "10G" to bytes, using fio's
+// default base of 1024. Only the first value of lists ("4k,8k") and ranges
+// ("4k-16k") is considered. Returns 0 when the size cannot be parsed.
+func parseSize(size string) int64 {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if i := strings.IndexAny(size, ",-:"); i >= 0 {
+		size = size[:i]
+	}
+	size = strings.TrimSuffix(strings.TrimSuffix(size, "b"), "i")
+
+	multiplier := int64(1)
+	if size != "" {
+		if i := strings.IndexByte("kmgtp", size[len(size)-1]); i >= 0 {
+			for ; i >= 0; i-- {
+				multiplier *= 1024
+			}
+			size = size[:len(size)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(value * float64(multiplier))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configHash returns the SHA-256 of the effective configuration of a test,
+// everything that decides what is measured. The name, description and suite
+// only label the test and the target is what is measured, so the same
+// workload hashes the same on every device.
+func configHash(test FioTest) string {
+	test.Name = ""
+	test.Description = ""
+	test.Suite = ""
+	test.Filename = ""
+	data, _ := json.Marshal(test)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// runConfigHash returns the SHA-256 of the configurations of all tests of a
+// run by name, in the order they ran
+func runConfigHash(results []TestResult) string {
+	h := sha256.New()
+	for _, r := range results {
+		fmt.Fprintf(h, "%s\x00%s\n", r.TestName, configHash(r.Config))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resultConfigHash returns the configuration hash of a test in a results
+// file, computed from its configuration for results written without one
+func resultConfigHash(r JSONTestResult) string {
+	if r.ConfigHash != "" {
+		return r.ConfigHash
+	}
+	return configHash(r.Config)
+}
+
+// configDrift lists the configuration values that differ between two runs
+// of a test, nil when the configurations hash the same
+func configDrift(a, b JSONTestResult) []string {
+	if resultConfigHash(a) == resultConfigHash(b) {
+		return nil
+	}
+	var fieldsA, fieldsB []diffField
+	flattenResult("", reflect.ValueOf(a.Config), &fieldsA)
+	flattenResult("", reflect.ValueOf(b.Config), &fieldsB)
+	values := map[string]string{}
+	for _, field := range fieldsA {
+		values[field.Path] = formatDiffValue(field.Value)
+	}
+	seen := map[string]bool{}
+	var drift []string
+	for _, field := range fieldsB {
+		seen[field.Path] = true
+		if value, ok := values[field.Path]; (!ok || value != formatDiffValue(field.Value)) && !configLabels[field.Path] {
+			drift = append(drift, field.Path)
+		}
+	}
+	for _, field := range fieldsA {
+		if !seen[field.Path] && !configLabels[field.Path] {
+			drift = append(drift, field.Path)
+		}
+	}
+	if len(drift) == 0 {
+		// Values lost in the results file, like options a template sets
+		drift = append(drift, "config")
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// configLabels are the fields of a configuration left out of its hash
+var configLabels = map[string]bool{
+	"name":        true,
+	"description": true,
+	"suite":       true,
+	"filename":    true,
+}
+
+// resultsConfigDrift lists the tests of two results files whose
+// configurations differ, of the tests both files hold
+func resultsConfigDrift(a, b JSONResults) []string {
+	hashes := map[string]string{}
+	for _, r := range a.TestResults {
+		hashes[r.TestName] = resultConfigHash(r)
+	}
+	var drift []string
+	for _, r := range b.TestResults {
+		if hash, ok := hashes[r.TestName]; ok && hash != resultConfigHash(r) {
+			drift = append(drift, r.TestName)
+		}
+	}
+	return drift
+}
+
+// checkConfigDrift refuses to compare results of different configurations
+// unless the drift is allowed, then it is only pointed out
+func checkConfigDrift(what string, drift []string, allow bool) error {
+	if len(drift) == 0 {
+		return nil
+	}
+	if !allow {
+		return fmt.Errorf("the configurations of %s differ (%s), the results are not comparable; pass --allow-config-drift to compare anyway",
+			what, strings.Join(drift, ", "))
+	}
+	fmt.Fprintf(out, "WARNING: the configurations of %s differ (%s), this is not a like-for-like comparison\n\n", what, strings.Join(drift, ", "))
+	return nil
+}
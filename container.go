@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ContainerInfo describes the container image fio is executed in
+type ContainerInfo struct {
+	Runtime    string `json:"runtime"`
+	Image      string `json:"image"`
+	Digest     string `json:"digest"`
+	FioVersion string `json:"fio_version"`
+}
+
+// inspectContainer resolves the digest of the image, pulling it first if it
+// is not available locally, and checks that fio can be executed inside it
+func inspectContainer(image string) (*ContainerInfo, error) {
+	info := &ContainerInfo{
+		Runtime: opts.ContainerRuntime,
+		Image:   image,
+	}
+
+	digest, err := imageDigest(image)
+	if err != nil {
+		pull := exec.Command(opts.ContainerRuntime, "pull", image)
+		if output, err := pull.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to pull image: %v\nOutput: %s", err, string(output))
+		}
+		digest, err = imageDigest(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	info.Digest = digest
+
+	output, err := exec.Command(opts.ContainerRuntime, "run", "--rm", image, "fio", "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("fio is not available in image: %v", err)
+	}
+	info.FioVersion = strings.TrimSpace(string(output))
+
+	return info, nil
+}
+
+func imageDigest(image string) (string, error) {
+	format := "{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}"
+	output, err := exec.Command(opts.ContainerRuntime, "image", "inspect", "--format", format, image).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// fioPaths splits a filename or directory option of fio into its paths,
+// which are separated by colons unless escaped with a backslash, like C\:
+func fioPaths(value string) []string {
+	var paths []string
+	var path strings.Builder
+	for i := 0; i <= len(value); i++ {
+		switch {
+		case i == len(value) || value[i] == ':':
+			if path.Len() > 0 {
+				paths = append(paths, path.String())
+			}
+			path.Reset()
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == ':':
+			path.WriteByte(':')
+			i++
+		default:
+			path.WriteByte(value[i])
+		}
+	}
+	return paths
+}
+
+// containerArgs builds the container runtime arguments needed to run fio for
+// the test. Block devices are passed through with --device, while the
+// directories of the test, those holding its files and the given output
+// files are bind mounted at the same path so that fio arguments can be used
+// unchanged. Files named relative to the directories of the test are in
+// them.
+func containerArgs(test FioTest, files ...string) []string {
+	args := []string{"run", "--rm", "--network", "none"}
+	mounts := map[string]bool{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			mounts[filepath.Dir(abs)] = true
+		}
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		mounts[cwd] = true
+		args = append(args, "--workdir", cwd)
+	}
+
+	directories := fioPaths(test.Directory)
+	for _, dir := range directories {
+		if abs, err := filepath.Abs(dir); err == nil {
+			mounts[abs] = true
+		}
+	}
+	for _, file := range fioPaths(test.Filename) {
+		if len(directories) > 0 && !filepath.IsAbs(file) {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.Mode()&os.ModeDevice != 0 {
+			args = append(args, "--device", file)
+		} else if abs, err := filepath.Abs(file); err == nil {
+			mounts[filepath.Dir(abs)] = true
+		}
+	}
+
+	if test.ReadIOLog != "" {
+		if abs, err := filepath.Abs(test.ReadIOLog); err == nil {
+			mounts[filepath.Dir(abs)] = true
+		}
+	}
+
+	dirs := make([]string, 0, len(mounts))
+	for dir := range mounts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		args = append(args, "--volume", fmt.Sprintf("%s:%s", dir, dir))
+	}
+
+	return append(args, opts.Containerize)
+}
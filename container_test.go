@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+// TestContainerArgsMounts expects the directories of a test and of every
+// file of its dataset to be mounted, and nothing for an empty filename
+func TestContainerArgsMounts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("containers mount Unix paths")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(image string) { opts.Containerize = image }(opts.Containerize)
+	opts.Containerize = "fio:3.36"
+
+	tests := []struct {
+		name   string
+		test   FioTest
+		mounts []string
+	}{
+		{"directories", FioTest{Directory: "/srv/a:/srv/b", NrFiles: 4}, []string{"/srv/a", "/srv/b", cwd}},
+		{"files in the directory", FioTest{Directory: "/srv/a", Filename: "data1:/mnt/x/data2"}, []string{"/mnt/x", "/srv/a", cwd}},
+		{"files", FioTest{Filename: "/mnt/x/data1:/mnt/y/data2"}, []string{"/mnt/x", "/mnt/y", cwd}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mounts []string
+			args := containerArgs(tt.test)
+			for i, arg := range args {
+				if arg == "--volume" {
+					mounts = append(mounts, args[i+1])
+				}
+			}
+			var want []string
+			for _, dir := range tt.mounts {
+				want = append(want, dir+":"+dir)
+			}
+			sort.Strings(want)
+			if !reflect.DeepEqual(mounts, want) {
+				t.Errorf("mounts = %v, want %v", mounts, want)
+			}
+		})
+	}
+}
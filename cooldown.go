@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CooldownInfo records the pause taken before a test
+type CooldownInfo struct {
+	Seconds    float64 `json:"seconds"`
+	TargetTemp float64 `json:"target_temp_c,omitempty"`
+	StartTemp  float64 `json:"start_temp_c,omitempty"`
+	EndTemp    float64 `json:"end_temp_c,omitempty"`
+	TimedOut   bool    `json:"timed_out,omitempty"`
+}
+
+// cooldownPollInterval is how often the temperature is checked while
+// waiting for the device to cool down
+const cooldownPollInterval = 5 * time.Second
+
+// cooldown pauses before a test for cooldown_seconds and, with a target
+// temperature, until the device is at most that warm. The test settings
+// override the global options. It returns nil when no pause is configured.
+func cooldown(test FioTest) *CooldownInfo {
+	seconds := opts.CooldownSeconds
+	if test.CooldownSeconds > 0 {
+		seconds = test.CooldownSeconds
+	}
+	target := opts.CooldownTemp
+	if test.CooldownTemp > 0 {
+		target = test.CooldownTemp
+	}
+	if seconds <= 0 && target <= 0 {
+		return nil
+	}
+
+	info := &CooldownInfo{TargetTemp: target}
+	start := time.Now()
+	minimum := time.Duration(seconds) * time.Second
+
+	device := ""
+	if target > 0 {
+		if metadata, err := targetDevice(test.Filename); err == nil {
+			device = metadata.Device
+		}
+	}
+	readTemp := func() float64 {
+		if device == "" {
+			return 0
+		}
+		temp, _ := deviceTemperature(device)
+		return temp
+	}
+
+	info.StartTemp = readTemp()
+	if target > 0 {
+		fmt.Fprintf(out, "Cooling down for at least %ds until the device is at most %.0f%s\n", seconds, target, celsiusUnit())
+	} else {
+		fmt.Fprintf(out, "Cooling down for %ds\n", seconds)
+	}
+
+	time.Sleep(minimum)
+	info.EndTemp = readTemp()
+	for target > 0 && info.EndTemp > target {
+		if time.Since(start) >= opts.CooldownMax {
+			info.TimedOut = true
+			break
+		}
+		time.Sleep(cooldownPollInterval)
+		info.EndTemp = readTemp()
+	}
+
+	info.Seconds = time.Since(start).Seconds()
+	return info
+}
+
+// String describes the cooldown in one line for the result tables
+func (c *CooldownInfo) String() string {
+	text := fmt.Sprintf("%.0fs", c.Seconds)
+	if c.StartTemp > 0 || c.EndTemp > 0 {
+		text += fmt.Sprintf(" (%.0f%s to %.0f%s", c.StartTemp, celsiusUnit(), c.EndTemp, celsiusUnit())
+		if c.TargetTemp > 0 {
+			text += fmt.Sprintf(", target %.0f%s", c.TargetTemp, celsiusUnit())
+		}
+		text += ")"
+	}
+	return text
+}
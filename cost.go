@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// CostModel prices the drives under test so results can be compared by
+// what their performance costs, loaded with --cost-model
+type CostModel struct {
+	// Currency labels the prices, "$" when not set
+	Currency string       `json:"currency,omitempty"`
+	Prices   []DrivePrice `json:"prices"`
+}
+
+// DrivePrice is the price of the drives whose model matches Model, a glob
+// pattern, empty for all drives. A drive costs PerDevice, or PerGB times its
+// capacity when only that is given.
+type DrivePrice struct {
+	Model     string  `json:"model,omitempty"`
+	PerDevice float64 `json:"per_device,omitempty"`
+	PerGB     float64 `json:"per_gb,omitempty"`
+}
+
+// CostMetrics relates the performance of a test to the price of its drive
+type CostMetrics struct {
+	Currency    string  `json:"currency"`
+	DevicePrice float64 `json:"device_price"`
+	// Per100kIOPS is the price of 100k IOPS, PerGBps that of 1 GB/s
+	// (1024 MB/s) of bandwidth
+	Per100kIOPS float64 `json:"per_100k_iops,omitempty"`
+	PerGBps     float64 `json:"per_gbps,omitempty"`
+}
+
+// costModel is the cost model loaded with --cost-model, if any
+var costModel *CostModel
+
+// loadCostModel loads the prices of the drives
+func loadCostModel(filename string) error {
+	if filename == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	auditConfigFile(filename, data)
+	var loaded CostModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(loaded.Prices) == 0 {
+		return fmt.Errorf("%s does not list any prices", filename)
+	}
+	for i, price := range loaded.Prices {
+		if _, err := path.Match(price.Model, ""); err != nil {
+			return fmt.Errorf("%s: price %d: invalid model pattern %q", filename, i+1, price.Model)
+		}
+		if price.PerDevice < 0 || price.PerGB < 0 || price.PerDevice == 0 && price.PerGB == 0 {
+			return fmt.Errorf("%s: price %d: set a positive per_device or per_gb", filename, i+1)
+		}
+	}
+	if loaded.Currency == "" {
+		loaded.Currency = "$"
+	}
+	costModel = &loaded
+	return nil
+}
+
+// devicePrice returns the price of a drive from the first price matching its
+// model, 0 when none does or the capacity of the drive is unknown
+func (m *CostModel) devicePrice(device *DeviceMetadata) float64 {
+	for _, price := range m.Prices {
+		if ok, _ := path.Match(price.Model, device.Model); price.Model != "" && !ok {
+			continue
+		}
+		if price.PerDevice > 0 {
+			return price.PerDevice
+		}
+		return price.PerGB * float64(device.SizeBytes) / 1e9
+	}
+	return 0
+}
+
+// costMetrics relates a result to the price of its drive, nil without a cost
+// model or a price for the drive and for metadata tests
+func costMetrics(r TestResult) *CostMetrics {
+	if costModel == nil || r.Device == nil || r.Metadata != nil {
+		return nil
+	}
+	price := costModel.devicePrice(r.Device)
+	if price == 0 || r.TotalIOPS == 0 && r.TotalBWMBps == 0 {
+		return nil
+	}
+	cost := &CostMetrics{Currency: costModel.Currency, DevicePrice: price}
+	if r.TotalIOPS > 0 {
+		cost.Per100kIOPS = price / (r.TotalIOPS / 100000)
+	}
+	if r.TotalBWMBps > 0 {
+		cost.PerGBps = price / (r.TotalBWMBps / 1024)
+	}
+	return cost
+}
+
+func (c *CostMetrics) String() string {
+	return fmt.Sprintf("%s per 100k IOPS, %s per GB/s (drive %s)",
+		formatCost(c.Currency, c.Per100kIOPS), formatCost(c.Currency, c.PerGBps), formatCost(c.Currency, c.DevicePrice))
+}
+
+// formatCost shows an amount with its currency, symbols like $ go before the
+// amount and codes like USD after it
+func formatCost(currency string, amount float64) string {
+	if len(currency) > 1 && len([]rune(currency)) == len(currency) {
+		return fmt.Sprintf("%.2f %s", amount, currency)
+	}
+	return fmt.Sprintf("%s%.2f", currency, amount)
+}
+
+// displayCostComparison shows what the performance of every drive model
+// costs per workload, for procurement comparisons
+func displayCostComparison(groups []DeviceGroup) {
+	var priced []DeviceGroup
+	for _, group := range groups {
+		if group.Price > 0 {
+			priced = append(priced, group)
+		}
+	}
+	if len(priced) == 0 || costModel == nil {
+		return
+	}
+	currency := costModel.Currency
+	fmt.Fprintln(out, "Cost by Device Model")
+	costTable := tablewriter.NewWriter(out)
+	costTable.SetHeader([]string{"Model", "Price", "Workload", "IOPS", "Per 100k", "Per GB/s"})
+	configureTable(costTable, 6)
+	costTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, group := range priced {
+		for _, stats := range group.Workloads {
+			costTable.Append([]string{
+				group.groupName(),
+				formatCost(currency, group.Price),
+				stats.Workload,
+				formatCount(stats.MeanIOPS),
+				formatCost(currency, stats.CostPer100kIOPS),
+				formatCost(currency, stats.CostPerGBps),
+			})
+		}
+	}
+	costTable.Render()
+	fmt.Fprintln(out)
+}
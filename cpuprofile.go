@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// cpuBoundPercent is the CPU use above which a CPU is considered saturated
+const cpuBoundPercent = 95
+
+// CPUProfile shows where the CPU time of the host went while a test ran and
+// whether the result was limited by the CPU instead of the device
+type CPUProfile struct {
+	CPUs        int     `json:"cpus"`
+	UserPc      float64 `json:"user_percent"`
+	SystemPc    float64 `json:"system_percent"`
+	IRQPc       float64 `json:"irq_percent"`
+	IOWaitPc    float64 `json:"iowait_percent"`
+	IdlePc      float64 `json:"idle_percent"`
+	BusiestCPU  int     `json:"busiest_cpu"`
+	BusiestPc   float64 `json:"busiest_cpu_percent"`
+	FioThreadPc float64 `json:"fio_thread_percent"`
+	DeviceUtil  float64 `json:"device_util_percent,omitempty"`
+	CPUBound    bool    `json:"cpu_bound"`
+	// Samples are the host CPU use of every second of the test
+	Samples []CPUSample `json:"samples,omitempty"`
+}
+
+// CPUSample is the CPU use of the host over one second
+type CPUSample struct {
+	Second    int     `json:"second"`
+	UserPc    float64 `json:"user_percent"`
+	SystemPc  float64 `json:"system_percent"`
+	IRQPc     float64 `json:"irq_percent"`
+	BusiestPc float64 `json:"busiest_cpu_percent"`
+}
+
+// cpuTimes are the counters of one line of /proc/stat, in clock ticks
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+func (t cpuTimes) total() uint64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+func (t cpuTimes) busy() uint64 {
+	return t.total() - t.idle - t.iowait
+}
+
+func (t cpuTimes) sub(o cpuTimes) cpuTimes {
+	return cpuTimes{t.user - o.user, t.nice - o.nice, t.system - o.system, t.idle - o.idle,
+		t.iowait - o.iowait, t.irq - o.irq, t.softirq - o.softirq, t.steal - o.steal}
+}
+
+// cpuStat is a snapshot of /proc/stat: the sum of all CPUs and every CPU
+type cpuStat struct {
+	all  cpuTimes
+	cpus []cpuTimes
+}
+
+func readCPUStat() (cpuStat, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return cpuStat{}, err
+	}
+	defer f.Close()
+
+	var stat cpuStat
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		var values [8]uint64
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+		}
+		times := cpuTimes{values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7]}
+		if fields[0] == "cpu" {
+			stat.all = times
+		} else {
+			stat.cpus = append(stat.cpus, times)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuStat{}, err
+	}
+	if len(stat.cpus) == 0 {
+		return cpuStat{}, fmt.Errorf("no CPUs in /proc/stat")
+	}
+	return stat, nil
+}
+
+// percent returns part as a percentage of total
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// busiest returns the CPU with the highest busy share between two snapshots
+func busiest(start, end cpuStat) (int, float64) {
+	cpu, busy := 0, 0.0
+	for i := 0; i < len(end.cpus) && i < len(start.cpus); i++ {
+		delta := end.cpus[i].sub(start.cpus[i])
+		if pc := percent(delta.busy(), delta.total()); pc > busy {
+			cpu, busy = i, pc
+		}
+	}
+	return cpu, busy
+}
+
+// cpuMonitor samples /proc/stat every second while fio runs
+type cpuMonitor struct {
+	first   cpuStat
+	done    chan struct{}
+	stopped chan struct{}
+	mu      sync.Mutex
+	samples []CPUSample
+}
+
+func (m *cpuMonitor) name() string {
+	return "cpu"
+}
+
+func (m *cpuMonitor) start() error {
+	stat, err := readCPUStat()
+	if err != nil {
+		return err
+	}
+	m.first = stat
+	m.done = make(chan struct{})
+	m.stopped = make(chan struct{})
+	go m.sample()
+	return nil
+}
+
+func (m *cpuMonitor) sample() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	previous := m.first
+	for second := 1; ; second++ {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		stat, err := readCPUStat()
+		if err != nil {
+			continue
+		}
+		delta := stat.all.sub(previous.all)
+		_, busy := busiest(previous, stat)
+		m.mu.Lock()
+		m.samples = append(m.samples, CPUSample{
+			Second:    second,
+			UserPc:    percent(delta.user+delta.nice, delta.total()),
+			SystemPc:  percent(delta.system, delta.total()),
+			IRQPc:     percent(delta.irq+delta.softirq, delta.total()),
+			BusiestPc: busy,
+		})
+		m.mu.Unlock()
+		previous = stat
+	}
+}
+
+func (m *cpuMonitor) stop(result *TestResult) {
+	close(m.done)
+	<-m.stopped
+	end, err := readCPUStat()
+	if err != nil {
+		result.warn(severityWarning, "monitor", "cannot read CPU statistics: %v", err)
+		return
+	}
+
+	delta := end.all.sub(m.first.all)
+	profile := &CPUProfile{
+		CPUs:     len(end.cpus),
+		UserPc:   percent(delta.user+delta.nice, delta.total()),
+		SystemPc: percent(delta.system, delta.total()),
+		IRQPc:    percent(delta.irq+delta.softirq, delta.total()),
+		IOWaitPc: percent(delta.iowait, delta.total()),
+		IdlePc:   percent(delta.idle, delta.total()),
+		Samples:  m.samples,
+	}
+	profile.BusiestCPU, profile.BusiestPc = busiest(m.first, end)
+	result.CPU = profile
+}
+
+// analyze flags results limited by the CPU: a fio thread or a single CPU
+// of the host, e.g. the one handling the interrupts of the device, was
+// saturated while the device was not
+func (p *CPUProfile) analyze(result *TestResult) {
+	if result.FioJob == nil {
+		return
+	}
+	p.FioThreadPc = result.FioJob.UsrCPU + result.FioJob.SysCPU
+	for _, disk := range result.DiskUtil {
+		if disk.Util > p.DeviceUtil {
+			p.DeviceUtil = disk.Util
+		}
+	}
+	if p.DeviceUtil >= cpuBoundPercent {
+		return
+	}
+
+	device := "device utilization unknown"
+	if p.DeviceUtil > 0 {
+		device = fmt.Sprintf("the device was %.0f%% busy", p.DeviceUtil)
+	}
+	switch {
+	case p.FioThreadPc >= cpuBoundPercent:
+		p.CPUBound = true
+		result.warn(severityWarning, "cpu_bound", "fio used %.0f%% of a CPU while %s, the result is limited by the host", p.FioThreadPc, device)
+	case p.BusiestPc >= cpuBoundPercent:
+		p.CPUBound = true
+		result.warn(severityWarning, "cpu_bound", "CPU %d was %.0f%% busy while %s, the result may be limited by the host", p.BusiestCPU, p.BusiestPc, device)
+	}
+}
+
+func displayCPUProfile(profile *CPUProfile) {
+	fmt.Fprintf(out, "Host CPU Profile (%d CPUs)\n", profile.CPUs)
+	cpuTable := tablewriter.NewWriter(out)
+	cpuTable.SetHeader([]string{"Metric", "Value"})
+	configureTable(cpuTable, 2)
+	cpuTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	cpuTable.Append([]string{"User", fmt.Sprintf("%.1f%%", profile.UserPc)})
+	cpuTable.Append([]string{"System", fmt.Sprintf("%.1f%%", profile.SystemPc)})
+	cpuTable.Append([]string{"IRQ + SoftIRQ", fmt.Sprintf("%.1f%%", profile.IRQPc)})
+	cpuTable.Append([]string{"IO Wait", fmt.Sprintf("%.1f%%", profile.IOWaitPc)})
+	cpuTable.Append([]string{"Idle", fmt.Sprintf("%.1f%%", profile.IdlePc)})
+	cpuTable.Append([]string{"Busiest CPU", fmt.Sprintf("cpu%d %.1f%%", profile.BusiestCPU, profile.BusiestPc)})
+	cpuTable.Append([]string{"fio Thread (usr+sys)", fmt.Sprintf("%.1f%%", profile.FioThreadPc)})
+	bound := "no"
+	if profile.CPUBound {
+		bound = "yes"
+	}
+	cpuTable.Append([]string{"CPU Bound", bound})
+	cpuTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeviceMetadata identifies the device a test ran on
+type DeviceMetadata struct {
+	Device     string `json:"device"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Firmware   string `json:"firmware,omitempty"`
+	SizeBytes  int64  `json:"size_bytes,omitempty"`
+	Rotational bool   `json:"rotational"`
+	Protocol   string `json:"protocol,omitempty"`
+	// Sector geometry in bytes, direct IO has to be aligned to the logical
+	// sectors and writes smaller than the physical ones are read first
+	LogicalSector  int `json:"logical_sector_bytes,omitempty"`
+	PhysicalSector int `json:"physical_sector_bytes,omitempty"`
+	// AtomicWriteMax is the largest write the device completes atomically
+	AtomicWriteMax int `json:"atomic_write_max_bytes,omitempty"`
+	// Queue holds the block layer queue parameters of the disk
+	Queue map[string]string `json:"queue,omitempty"`
+}
+
+// queueParams are the sysfs queue parameters captured with the device
+// metadata and accepted in the tuning of a test
+var queueParams = []string{"scheduler", "nr_requests", "read_ahead_kb", "wbt_lat_usec", "nomerges", "rq_affinity"}
+
+// targetDevice returns the metadata of the disk holding the test target
+func targetDevice(target string) (*DeviceMetadata, error) {
+	device, err := resolveBlockDevice(target)
+	if err != nil {
+		return nil, err
+	}
+	return deviceMetadata(wholeDisk(device))
+}
+
+// String describes the device in one line for the result tables
+func (m *DeviceMetadata) String() string {
+	parts := []string{}
+	if m.Model != "" {
+		parts = append(parts, m.Model)
+	}
+	if m.Firmware != "" {
+		parts = append(parts, "fw "+m.Firmware)
+	}
+	if m.SizeBytes > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f GB", float64(m.SizeBytes)/1e9))
+	}
+	if m.Protocol != "" {
+		parts = append(parts, m.Protocol)
+	}
+	if m.LogicalSector > 0 {
+		parts = append(parts, m.sectorFormat())
+	}
+	if m.Rotational {
+		parts = append(parts, "HDD")
+	} else {
+		parts = append(parts, "SSD")
+	}
+	return fmt.Sprintf("%s (%s)", m.Device, strings.Join(parts, ", "))
+}
+
+// sectorFormat names the sector format of the device like 512n, 512e or 4Kn:
+// the logical sector size, emulated on larger physical sectors or native
+func (m *DeviceMetadata) sectorFormat() string {
+	size := strconv.Itoa(m.LogicalSector)
+	if m.LogicalSector%1024 == 0 {
+		size = strconv.Itoa(m.LogicalSector/1024) + "K"
+	}
+	if m.PhysicalSector > m.LogicalSector {
+		return size + "e"
+	}
+	return size + "n"
+}
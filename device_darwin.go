@@ -0,0 +1,152 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// resolveBlockDevice returns the disk device holding the test target. For
+// files this is the device of the filesystem reported by df.
+func resolveBlockDevice(target string) (string, error) {
+	if strings.HasPrefix(target, "/dev/") {
+		return target, nil
+	}
+	path := target
+	if _, err := os.Stat(path); err != nil {
+		path = "."
+		if i := strings.LastIndex(target, "/"); i > 0 {
+			path = target[:i]
+		}
+	}
+	output, err := exec.Command("df", "-P", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("df failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/dev/") {
+		return "", fmt.Errorf("%s is not on a disk device", target)
+	}
+	return fields[0], nil
+}
+
+// diskSlicePattern matches the slice suffix of a device like /dev/disk3s1
+var diskSlicePattern = regexp.MustCompile(`^(/dev/r?disk\d+)s\d+$`)
+
+// wholeDisk returns the disk a slice belongs to
+func wholeDisk(device string) string {
+	if match := diskSlicePattern.FindStringSubmatch(device); match != nil {
+		return match[1]
+	}
+	return device
+}
+
+func deviceNumber(device string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("device numbers are not supported on darwin")
+}
+
+// deviceMetadata reads the disk description from diskutil
+func deviceMetadata(device string) (*DeviceMetadata, error) {
+	output, err := exec.Command("diskutil", "info", "-plist", device).Output()
+	if err != nil {
+		return nil, fmt.Errorf("diskutil failed: %v", err)
+	}
+	info, err := parsePlistDict(output)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &DeviceMetadata{
+		Device:     device,
+		Model:      info["MediaName"],
+		Protocol:   info["BusProtocol"],
+		Rotational: info["SolidState"] == "false",
+	}
+	metadata.SizeBytes, _ = strconv.ParseInt(info["TotalSize"], 10, 64)
+	if metadata.SizeBytes == 0 {
+		metadata.SizeBytes, _ = strconv.ParseInt(info["Size"], 10, 64)
+	}
+	metadata.LogicalSector, _ = strconv.Atoi(info["DeviceBlockSize"])
+	return metadata, nil
+}
+
+// parsePlistDict returns the scalar values of the top level dictionary of an
+// XML property list, booleans become "true" or "false"
+func parsePlistDict(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	key := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "dict" || t.Name.Local == "array" {
+				depth++
+				continue
+			}
+			if depth != 1 {
+				continue
+			}
+			switch t.Name.Local {
+			case "key":
+				var name string
+				if err := decoder.DecodeElement(&name, &t); err != nil {
+					return nil, err
+				}
+				key = name
+			case "true", "false":
+				values[key] = t.Name.Local
+			case "string", "integer", "real":
+				var value string
+				if err := decoder.DecodeElement(&value, &t); err != nil {
+					return nil, err
+				}
+				values[key] = value
+			}
+		case xml.EndElement:
+			if t.Name.Local == "dict" || t.Name.Local == "array" {
+				depth--
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no properties in diskutil output")
+	}
+	return values, nil
+}
+
+func deviceTemperature(device string) (float64, error) {
+	return smartctlTemperature(device)
+}
+
+// dropCaches purges the unified buffer cache
+func dropCaches() error {
+	if output, err := exec.Command("purge").CombinedOutput(); err != nil {
+		return fmt.Errorf("purge failed: %v: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func readQueueParam(device, param string) (string, error) {
+	return "", fmt.Errorf("queue parameters are not supported on darwin")
+}
+
+func writeQueueParam(device, param, value string) error {
+	return fmt.Errorf("queue parameters are not supported on darwin")
+}
+
+func nvmeNamespaces(controller string) ([]NVMeNamespace, error) {
+	return nil, fmt.Errorf("NVMe namespaces are not supported on darwin")
+}
@@ -0,0 +1,198 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// resolveBlockDevice returns the block device holding the test target. For
+// a device path this is the device itself, for a file it is the device of
+// the filesystem the file (or its directory, if it does not exist yet)
+// lives on.
+func resolveBlockDevice(target string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(target, &stat); err != nil {
+		if err := syscall.Stat(filepath.Dir(target), &stat); err != nil {
+			return "", err
+		}
+	}
+
+	if stat.Mode&syscall.S_IFMT == syscall.S_IFBLK {
+		return target, nil
+	}
+
+	major, minor := devMajorMinor(uint64(stat.Dev))
+	link, err := os.Readlink(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return "", fmt.Errorf("%s is not on a block device", target)
+	}
+	return "/dev/" + filepath.Base(link), nil
+}
+
+// devMajorMinor decodes a Linux dev_t
+func devMajorMinor(dev uint64) (uint64, uint64) {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return major, minor
+}
+
+// wholeDisk returns the disk a partition belongs to, or the device itself
+// when it is not a partition
+func wholeDisk(device string) string {
+	name := filepath.Base(device)
+	if _, err := os.Stat(filepath.Join("/sys/class/block", name, "partition")); err != nil {
+		return device
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return device
+	}
+	return "/dev/" + filepath.Base(filepath.Dir(path))
+}
+
+// deviceNumber returns the major and minor number of a block device
+func deviceNumber(device string) (uint64, uint64, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/class/block", filepath.Base(device), "dev"))
+	if err != nil {
+		return 0, 0, err
+	}
+	var major, minor uint64
+	if _, err := fmt.Sscanf(string(data), "%d:%d", &major, &minor); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse device number of %s: %v", device, err)
+	}
+	return major, minor, nil
+}
+
+// deviceMetadata reads the model, serial and firmware of a disk from sysfs
+func deviceMetadata(device string) (*DeviceMetadata, error) {
+	dir := filepath.Join("/sys/class/block", filepath.Base(device))
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("%s is not a block device", device)
+	}
+
+	metadata := &DeviceMetadata{
+		Device:     device,
+		Model:      readSysfsString(filepath.Join(dir, "device", "model")),
+		Serial:     readSysfsString(filepath.Join(dir, "device", "serial")),
+		Firmware:   readSysfsString(filepath.Join(dir, "device", "firmware_rev")),
+		Rotational: readSysfsString(filepath.Join(dir, "queue", "rotational")) == "1",
+	}
+	if metadata.Firmware == "" {
+		// SCSI and SATA disks report their firmware as the revision
+		metadata.Firmware = readSysfsString(filepath.Join(dir, "device", "rev"))
+	}
+	if sectors, err := strconv.ParseInt(readSysfsString(filepath.Join(dir, "size")), 10, 64); err == nil {
+		metadata.SizeBytes = sectors * 512
+	}
+	metadata.LogicalSector, _ = strconv.Atoi(readSysfsString(filepath.Join(dir, "queue", "logical_block_size")))
+	metadata.PhysicalSector, _ = strconv.Atoi(readSysfsString(filepath.Join(dir, "queue", "physical_block_size")))
+	// Only reported by kernels supporting atomic writes
+	metadata.AtomicWriteMax, _ = strconv.Atoi(readSysfsString(filepath.Join(dir, "queue", "atomic_write_unit_max_bytes")))
+	for _, param := range queueParams {
+		if value, err := readQueueParam(device, param); err == nil {
+			if metadata.Queue == nil {
+				metadata.Queue = map[string]string{}
+			}
+			metadata.Queue[param] = value
+		}
+	}
+	switch name := filepath.Base(device); {
+	case strings.HasPrefix(name, "nvme"):
+		metadata.Protocol = "NVMe"
+	case strings.HasPrefix(name, "sd"):
+		metadata.Protocol = "SCSI"
+	case strings.HasPrefix(name, "vd"):
+		metadata.Protocol = "virtio"
+	}
+	return metadata, nil
+}
+
+// readQueueParam returns a queue parameter of a disk from sysfs. The
+// scheduler is reported as the active one of the listed schedulers.
+func readQueueParam(device, param string) (string, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/class/block", filepath.Base(device), "queue", param))
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if start, end := strings.Index(value, "["), strings.Index(value, "]"); param == "scheduler" && start >= 0 && end > start {
+		value = value[start+1 : end]
+	}
+	return value, nil
+}
+
+// writeQueueParam sets a queue parameter of a disk in sysfs
+func writeQueueParam(device, param, value string) error {
+	return os.WriteFile(filepath.Join("/sys/class/block", filepath.Base(device), "queue", param), []byte(value), 0644)
+}
+
+// readSysfsString returns the trimmed content of a sysfs attribute, or an
+// empty string if it cannot be read
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// deviceTemperature returns the temperature of a disk in degrees Celsius,
+// from hwmon (NVMe, or SATA with the drivetemp module) or else smartctl
+func deviceTemperature(device string) (float64, error) {
+	dir := filepath.Join("/sys/class/block", filepath.Base(device), "device")
+	for _, pattern := range []string{"hwmon*/temp1_input", "hwmon/hwmon*/temp1_input"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, match := range matches {
+			if millidegrees, err := readSysfsFloat(match); err == nil {
+				return millidegrees / 1000, nil
+			}
+		}
+	}
+	return smartctlTemperature(device)
+}
+
+// dropCaches writes dirty pages back and drops the page cache
+func dropCaches() error {
+	syscall.Sync()
+	return os.WriteFile("/proc/sys/vm/drop_caches", []byte("3"), 0644)
+}
+
+// nvmeNamespaces lists the namespaces attached to an NVMe controller, by
+// namespace ID
+func nvmeNamespaces(controller string) ([]NVMeNamespace, error) {
+	entries, err := os.ReadDir(filepath.Join("/sys/class/nvme", controller))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an NVMe controller", controller)
+	}
+
+	var namespaces []NVMeNamespace
+	for _, entry := range entries {
+		match := nvmeNamespacePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		// The paths of a multipath namespace are used through its head
+		name := "nvme" + match[1] + "n" + match[2]
+		dir := filepath.Join("/sys/class/block", name)
+		namespace := NVMeNamespace{Controller: controller, Device: "/dev/" + name}
+		if nsid, err := strconv.Atoi(readSysfsString(filepath.Join(dir, "nsid"))); err == nil {
+			namespace.NSID = nsid
+		} else {
+			namespace.NSID, _ = strconv.Atoi(match[2])
+		}
+		if sectors, err := strconv.ParseInt(readSysfsString(filepath.Join(dir, "size")), 10, 64); err == nil {
+			namespace.SizeBytes = sectors * 512
+		}
+		namespace.BlockSize, _ = strconv.ParseInt(readSysfsString(filepath.Join(dir, "queue", "logical_block_size")), 10, 64)
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].NSID < namespaces[j].NSID })
+	return namespaces, nil
+}
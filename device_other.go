@@ -0,0 +1,44 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func resolveBlockDevice(target string) (string, error) {
+	return "", fmt.Errorf("block device detection is not supported on %s", runtime.GOOS)
+}
+
+func wholeDisk(device string) string {
+	return device
+}
+
+func deviceNumber(device string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("device numbers are not supported on %s", runtime.GOOS)
+}
+
+func deviceMetadata(device string) (*DeviceMetadata, error) {
+	return nil, fmt.Errorf("device metadata is not supported on %s", runtime.GOOS)
+}
+
+func deviceTemperature(device string) (float64, error) {
+	return smartctlTemperature(device)
+}
+
+func dropCaches() error {
+	return fmt.Errorf("dropping caches is not supported on %s", runtime.GOOS)
+}
+
+func readQueueParam(device, param string) (string, error) {
+	return "", fmt.Errorf("queue parameters are not supported on %s", runtime.GOOS)
+}
+
+func writeQueueParam(device, param, value string) error {
+	return fmt.Errorf("queue parameters are not supported on %s", runtime.GOOS)
+}
+
+func nvmeNamespaces(controller string) ([]NVMeNamespace, error) {
+	return nil, fmt.Errorf("NVMe namespaces are not supported on %s", runtime.GOOS)
+}
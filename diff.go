@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// diffSkipped are the fields of a result that describe the run rather than
+// the measurement and are left out of a diff
+var diffSkipped = map[string]bool{
+	"test_name":       true,
+	"description":     true,
+	"artifacts":       true,
+	"fio_args":        true,
+	"fio_arg_changes": true,
+	"warnings":        true,
+	"latency_heatmap": true,
+	"job_file":        true,
+	"run_id":          true,
+	"namespace":       true,
+	"config_hash":     true,
+}
+
+// diffField is a single value of a result, named by its JSON path
+type diffField struct {
+	Path  string
+	Value reflect.Value
+}
+
+// flattenResult lists every value of a result with its JSON path, in the
+// order of the results file. Maps are sorted by key and list elements are
+// named by their name, device or percentile where they have one, so the
+// same element is compared even when the lists differ.
+func flattenResult(path string, v reflect.Value, fields *[]diffField) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			flattenResult(path, v.Elem(), fields)
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			*fields = append(*fields, diffField{path, v})
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if path == "" && diffSkipped[name] {
+				continue
+			}
+			flattenResult(joinPath(path, name), v.Field(i), fields)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return naturalLess(fmt.Sprint(keys[i]), fmt.Sprint(keys[j])) })
+		for _, key := range keys {
+			flattenResult(joinPath(path, fmt.Sprint(key)), v.MapIndex(key), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.String {
+			parts := make([]string, v.Len())
+			for i := range parts {
+				parts[i] = v.Index(i).String()
+			}
+			*fields = append(*fields, diffField{path, reflect.ValueOf(strings.Join(parts, " "))})
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			flattenResult(fmt.Sprintf("%s[%s]", path, elementKey(v.Index(i), i)), v.Index(i), fields)
+		}
+	default:
+		*fields = append(*fields, diffField{path, v})
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// elementKey names an element of a list by its Name, Device or Percent
+// field, or by its index
+func elementKey(v reflect.Value, index int) string {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		for _, name := range []string{"Name", "Device", "Percent"} {
+			if field := v.FieldByName(name); field.IsValid() {
+				if s := formatDiffValue(field); s != "" {
+					return s
+				}
+			}
+		}
+	}
+	return strconv.Itoa(index)
+}
+
+// naturalLess orders keys by their number where both have one, so IO depth
+// levels sort as 1, 2, 4, ..., >=64
+func naturalLess(a, b string) bool {
+	x, errA := strconv.ParseFloat(strings.TrimLeft(a, "<>="), 64)
+	y, errB := strconv.ParseFloat(strings.TrimLeft(b, "<>="), 64)
+	if errA == nil && errB == nil && x != y {
+		return x < y
+	}
+	return a < b
+}
+
+// formatDiffValue formats a value of a result for the diff table
+func formatDiffValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Invalid:
+		return ""
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// diffChange describes how a value changed from a to b
+func diffChange(a, b reflect.Value) string {
+	x, okA := numericValue(a)
+	y, okB := numericValue(b)
+	switch {
+	case okA && okB && x == y:
+		return ""
+	case okA && okB && x != 0:
+		return fmt.Sprintf("%+.1f%%", 100*(y-x)/x)
+	case formatDiffValue(a) == formatDiffValue(b):
+		return ""
+	case !a.IsValid():
+		return "added"
+	case !b.IsValid():
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// loadDiffResult reads the test named after the # of an argument like
+// results.json#test-name. The name may be left out for files with a single
+// test or when it is the fallback name. With approvedOnly the file must be
+// signed off.
+func loadDiffResult(arg, fallback string, approvedOnly bool) (JSONTestResult, string, error) {
+	path, name := arg, fallback
+	if i := strings.LastIndex(arg, "#"); i >= 0 {
+		path, name = arg[:i], arg[i+1:]
+	}
+	results, _, err := readResultsFile(path)
+	if err != nil {
+		return JSONTestResult{}, "", err
+	}
+	if approvedOnly {
+		if err := checkApproved(path, results); err != nil {
+			return JSONTestResult{}, "", err
+		}
+	}
+	if name == "" {
+		if len(results.TestResults) != 1 {
+			return JSONTestResult{}, "", fmt.Errorf("%s has %d tests, name one as %s#test-name", path, len(results.TestResults), path)
+		}
+		return results.TestResults[0], results.TestResults[0].TestName, nil
+	}
+
+	var names []string
+	for _, r := range results.TestResults {
+		if r.TestName == name {
+			return r, name, nil
+		}
+		names = append(names, r.TestName)
+	}
+	return JSONTestResult{}, "", fmt.Errorf("%s has no test %q, available: %s", path, name, strings.Join(names, ", "))
+}
+
+// runDiff compares one test of two results files field by field
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	all := flags.Bool("all", false, "also show the fields that did not change")
+	groups := flags.Bool("groups", false, "compare the statistics per device model and firmware of two results files instead of one test")
+	allowDrift := flags.Bool("allow-config-drift", false, "compare results whose test configurations differ, with a warning")
+	approvedOnly := flags.Bool("approved-only", false, "refuse results files that were not signed off with fio-qa sign")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 {
+		return usageError("usage: fio-qa diff [--all] [--allow-config-drift] [--approved-only] runA.json#test-name runB.json[#test-name]\n       fio-qa diff --groups [--allow-config-drift] [--approved-only] runA.json runB.json")
+	}
+	if *groups {
+		return diffGroups(flags.Arg(0), flags.Arg(1), *allowDrift, *approvedOnly)
+	}
+
+	a, name, err := loadDiffResult(flags.Arg(0), "", *approvedOnly)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	b, _, err := loadDiffResult(flags.Arg(1), name, *approvedOnly)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	if err := checkConfigDrift(name, configDrift(a, b), *allowDrift); err != nil {
+		return usageError("%v", err)
+	}
+
+	var fieldsA, fieldsB []diffField
+	flattenResult("", reflect.ValueOf(a), &fieldsA)
+	flattenResult("", reflect.ValueOf(b), &fieldsB)
+
+	// Fields only in B are listed after the fields of A
+	var paths []string
+	valuesA := map[string]reflect.Value{}
+	valuesB := map[string]reflect.Value{}
+	for _, field := range fieldsA {
+		paths = append(paths, field.Path)
+		valuesA[field.Path] = field.Value
+	}
+	for _, field := range fieldsB {
+		if _, ok := valuesA[field.Path]; !ok {
+			paths = append(paths, field.Path)
+		}
+		valuesB[field.Path] = field.Value
+	}
+
+	fmt.Fprintf(out, "A: %s\nB: %s\n", flags.Arg(0), flags.Arg(1))
+	diffTable := tablewriter.NewWriter(out)
+	diffTable.SetHeader([]string{"Field", "A", "B", "Change"})
+	configureTable(diffTable, 4)
+	diffTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	changed := 0
+	for _, path := range paths {
+		change := diffChange(valuesA[path], valuesB[path])
+		if change != "" {
+			changed++
+		} else if !*all {
+			continue
+		}
+		diffTable.Append([]string{path, formatDiffValue(valuesA[path]), formatDiffValue(valuesB[path]), change})
+	}
+	if changed == 0 && !*all {
+		fmt.Fprintln(out, "No differences")
+		return exitOK
+	}
+	diffTable.Render()
+	fmt.Fprintf(out, "%d of %d fields differ\n", changed, len(paths))
+	return exitOK
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// DmesgFinding is a kernel log message logged while a test ran
+type DmesgFinding struct {
+	Severity string `json:"severity"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// dmesgPatterns classify kernel log messages, the first match wins
+var dmesgPatterns = []struct {
+	Severity string
+	Category string
+	Pattern  *regexp.Regexp
+}{
+	{"critical", "medium_error", regexp.MustCompile(`(?i)(medium error|unrecovered read error)`)},
+	{"critical", "io_error", regexp.MustCompile(`(?i)I/O error`)},
+	{"critical", "reset", regexp.MustCompile(`(?i)(resetting controller|reset controller|controller is down|hard resetting link)`)},
+	{"notice", "thermal_throttle", regexp.MustCompile(`(?i)(temperature above threshold|throttl)`)},
+	{"critical", "timeout", regexp.MustCompile(`(?i)(timeout|timed out)`)},
+	{"critical", "offline", regexp.MustCompile(`(?i)(device offline|offlining device)`)},
+	{"notice", "nvme_aer", regexp.MustCompile(`(?i)nvme.*(async event|aer)`)},
+	{"notice", "hung_task", regexp.MustCompile(`blocked for more than \d+ seconds`)},
+}
+
+// dmesgMonitor scans the kernel log messages added while a test ran
+type dmesgMonitor struct {
+	lastLine string
+}
+
+func (m *dmesgMonitor) name() string {
+	return "dmesg"
+}
+
+func (m *dmesgMonitor) start() error {
+	lines, err := readDmesg()
+	if err != nil {
+		return err
+	}
+	if len(lines) > 0 {
+		m.lastLine = lines[len(lines)-1]
+	}
+	return nil
+}
+
+func (m *dmesgMonitor) stop(result *TestResult) {
+	lines, err := readDmesg()
+	if err != nil {
+		result.warn(severityWarning, "monitor", "cannot read kernel log: %v", err)
+		return
+	}
+
+	// New messages follow the last message seen before the test. If it was
+	// rotated out of the ring buffer the whole buffer is new.
+	start := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == m.lastLine {
+			start = i + 1
+			break
+		}
+	}
+
+	for _, line := range lines[start:] {
+		for _, p := range dmesgPatterns {
+			if p.Pattern.MatchString(line) {
+				result.Dmesg = append(result.Dmesg, DmesgFinding{
+					Severity: p.Severity,
+					Category: p.Category,
+					Message:  strings.TrimSpace(line),
+				})
+				break
+			}
+		}
+	}
+}
+
+func readDmesg() ([]string, error) {
+	output, err := exec.Command("dmesg").Output()
+	if err != nil {
+		return nil, fmt.Errorf("dmesg failed: %v", err)
+	}
+	return strings.Split(strings.TrimRight(string(output), "\n"), "\n"), nil
+}
+
+// criticalDmesgFindings returns the number of critical kernel log findings
+func criticalDmesgFindings(findings []DmesgFinding) int {
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity == "critical" {
+			count++
+		}
+	}
+	return count
+}
+
+func displayDmesg(findings []DmesgFinding) {
+	fmt.Fprintln(out, "Kernel Log Findings")
+	dmesgTable := tablewriter.NewWriter(out)
+	dmesgTable.SetHeader([]string{"Finding", "Message"})
+	configureTable(dmesgTable, 2)
+	dmesgTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	for _, finding := range findings {
+		dmesgTable.Append([]string{fmt.Sprintf("%s (%s)", finding.Category, finding.Severity), finding.Message})
+	}
+	dmesgTable.Render()
+	fmt.Fprintln(out)
+}
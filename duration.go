@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// suspectShare is the share of its expected duration a test has to run
+// for, tests finishing sooner are marked suspect
+const suspectShare = 0.5
+
+// SuspectResult explains why a passed test finished far sooner than its
+// expected duration, its numbers are likely not those of the intended
+// workload
+type SuspectResult struct {
+	ExpectedSeconds float64 `json:"expected_seconds"`
+	ActualSeconds   float64 `json:"actual_seconds"`
+	Reason          string  `json:"reason"`
+}
+
+// checkExpectedDurations validates the expected durations of the tests
+// before any test runs
+func checkExpectedDurations(tests []FioTest) error {
+	for _, test := range tests {
+		if test.ExpectedDuration == "" {
+			continue
+		}
+		if test.Endurance != nil {
+			return fmt.Errorf("test %s: expected_duration does not apply to endurance tests", test.Name)
+		}
+		duration, err := time.ParseDuration(test.ExpectedDuration)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("test %s: invalid expected_duration %q, use a duration like 60s or 5m", test.Name, test.ExpectedDuration)
+		}
+	}
+	return nil
+}
+
+// checkDuration marks a test as suspect when fio finished in less than half
+// of its expected duration, explaining the likely cause from the options fio
+// ran with
+func checkDuration(test FioTest, result *TestResult) {
+	expected, err := time.ParseDuration(test.ExpectedDuration)
+	if test.ExpectedDuration == "" || err != nil || result.FioJob == nil {
+		return
+	}
+	// fio's own runtime leaves out its startup and the file layout
+	actual := time.Duration(max(result.FioJob.Read.Runtime, result.FioJob.Write.Runtime)) * time.Millisecond
+	if actual == 0 {
+		actual = result.Duration
+	}
+	if actual.Seconds() >= suspectShare*expected.Seconds() {
+		return
+	}
+
+	result.Suspect = &SuspectResult{
+		ExpectedSeconds: expected.Seconds(),
+		ActualSeconds:   actual.Seconds(),
+		Reason:          durationReason(result.FioArgs, actual),
+	}
+	result.warn(severityWarning, "suspect", "fio ran for %s of the expected %s: %s",
+		actual.Round(time.Millisecond), expected, result.Suspect.Reason)
+}
+
+// durationReason explains why fio stopped early from its options
+func durationReason(args []string, actual time.Duration) string {
+	options := map[string]string{}
+	for _, option := range parseFioArgs(args) {
+		options[option.Name] = option.Value
+	}
+	timeBased := options["time_based"] != "" && options["time_based"] != "0"
+	runtime, _ := strconv.Atoi(strings.TrimSuffix(options["runtime"], "s"))
+
+	switch {
+	case runtime > 0 && actual.Seconds() >= 0.9*float64(runtime):
+		return fmt.Sprintf("runtime=%d is shorter than the expected duration", runtime)
+	case options["io_size"] != "" || options["number_ios"] != "":
+		return "the IO limit (io_size or number_ios) was reached before the runtime"
+	case !timeBased && options["size"] != "" && runtime > 0:
+		return fmt.Sprintf("size=%s was transferred before runtime=%d, set time_based to run for the whole runtime", options["size"], runtime)
+	case !timeBased:
+		return "the job ended once it covered the file, set time_based and a runtime to run for a fixed time"
+	}
+	return "the job exited early, check the fio output for errors"
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// BPFLatency attributes the completion latency seen by fio to the block
+// layer and the device, measured with bpftrace on the block tracepoints
+type BPFLatency struct {
+	Device        string  `json:"device"`
+	DeviceCount   int64   `json:"device_ios"`
+	DeviceAvgUs   float64 `json:"device_avg_us"`
+	DeviceP50Us   float64 `json:"device_p50_us"`
+	DeviceP99Us   float64 `json:"device_p99_us"`
+	BlockCount    int64   `json:"block_layer_ios"`
+	BlockAvgUs    float64 `json:"block_layer_avg_us"`
+	FioClatAvgUs  float64 `json:"fio_clat_avg_us"`
+	OutsideAvgUs  float64 `json:"outside_block_layer_avg_us"`
+	DeviceSharePc float64 `json:"device_share_percent"`
+}
+
+// bpfLatencyScript measures the time requests spend queued in the block
+// layer (insert to issue) and on the device (issue to completion). Requests
+// issued directly without a scheduler skip the insert tracepoint and only
+// count towards the device time.
+const bpfLatencyScript = `
+tracepoint:block:block_rq_insert /args.dev == %[1]d/ { @insert[args.sector] = nsecs; }
+tracepoint:block:block_rq_issue /args.dev == %[1]d/ {
+	if (@insert[args.sector]) {
+		@block = stats((nsecs - @insert[args.sector]) / 1000);
+		delete(@insert[args.sector]);
+	}
+	@issue[args.sector] = nsecs;
+}
+tracepoint:block:block_rq_complete /args.dev == %[1]d && @issue[args.sector]/ {
+	@device = stats((nsecs - @issue[args.sector]) / 1000);
+	@device_hist = hist((nsecs - @issue[args.sector]) / 1000);
+	delete(@issue[args.sector]);
+}
+END { clear(@insert); clear(@issue); }
+`
+
+// bpfMonitor runs the latency script with bpftrace while fio runs
+type bpfMonitor struct {
+	test   FioTest
+	device string
+	cmd    *exec.Cmd
+	output bytes.Buffer
+	stderr bytes.Buffer
+	copied chan struct{}
+}
+
+func (m *bpfMonitor) name() string {
+	return "ebpf"
+}
+
+func (m *bpfMonitor) start() error {
+	device, err := resolveBlockDevice(m.test.Filename)
+	if err != nil {
+		return err
+	}
+	// Requests are traced on the whole disk, not on partitions
+	m.device = wholeDisk(device)
+	major, minor, err := deviceNumber(m.device)
+	if err != nil {
+		return err
+	}
+
+	// The kernel encodes dev_t as major << 20 | minor in the tracepoints
+	script := fmt.Sprintf(bpfLatencyScript, major<<20|minor)
+	m.cmd = exec.Command("bpftrace", "-e", script)
+	m.cmd.Stderr = &m.stderr
+	stdout, err := m.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := m.cmd.Start(); err != nil {
+		return err
+	}
+
+	// Wait until the probes are attached so the whole test is covered
+	reader := bufio.NewReader(stdout)
+	attached := make(chan error, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		if err == nil && !strings.HasPrefix(line, "Attaching") {
+			err = fmt.Errorf("unexpected bpftrace output: %s", strings.TrimSpace(line))
+		}
+		attached <- err
+	}()
+
+	select {
+	case err = <-attached:
+	case <-time.After(30 * time.Second):
+		err = fmt.Errorf("timed out attaching probes")
+	}
+	if err != nil {
+		m.cmd.Process.Kill()
+		m.cmd.Wait()
+		if m.stderr.Len() > 0 {
+			return fmt.Errorf("bpftrace failed: %s", bytes.TrimSpace(m.stderr.Bytes()))
+		}
+		return err
+	}
+
+	m.copied = make(chan struct{})
+	go func() {
+		io.Copy(&m.output, reader)
+		close(m.copied)
+	}()
+	return nil
+}
+
+func (m *bpfMonitor) stop(result *TestResult) {
+	// bpftrace prints its maps when interrupted
+	m.cmd.Process.Signal(os.Interrupt)
+	<-m.copied
+	m.cmd.Wait()
+
+	latency, err := parseBPFLatency(m.output.String())
+	if err != nil {
+		result.warn(severityWarning, "monitor", "ebpf latency unavailable: %v", err)
+		return
+	}
+	latency.Device = m.device
+	result.BPF = latency
+}
+
+var (
+	bpfStatsPattern = regexp.MustCompile(`^@(\w+): count (\d+), average (\d+), total \d+`)
+	bpfHistPattern  = regexp.MustCompile(`^\[(\d+[KMG]?), (\d+[KMG]?)\)\s+(\d+)`)
+)
+
+func parseBPFLatency(output string) (*BPFLatency, error) {
+	latency := &BPFLatency{}
+	type bucket struct {
+		upper float64
+		count int64
+	}
+	var hist []bucket
+	var total int64
+
+	for _, line := range strings.Split(output, "\n") {
+		if match := bpfStatsPattern.FindStringSubmatch(line); match != nil {
+			count, _ := strconv.ParseInt(match[2], 10, 64)
+			avg := parseFloat(match[3])
+			switch match[1] {
+			case "device":
+				latency.DeviceCount, latency.DeviceAvgUs = count, avg
+			case "block":
+				latency.BlockCount, latency.BlockAvgUs = count, avg
+			}
+		} else if match := bpfHistPattern.FindStringSubmatch(line); match != nil {
+			count, _ := strconv.ParseInt(match[3], 10, 64)
+			hist = append(hist, bucket{upper: parseHistValue(match[2]), count: count})
+			total += count
+		}
+	}
+
+	if latency.DeviceCount == 0 {
+		return nil, fmt.Errorf("no completed IOs were traced")
+	}
+
+	// Percentiles are reported as the upper bound of the log2 bucket
+	var seen int64
+	for _, b := range hist {
+		seen += b.count
+		if latency.DeviceP50Us == 0 && seen*100 >= total*50 {
+			latency.DeviceP50Us = b.upper
+		}
+		if latency.DeviceP99Us == 0 && seen*100 >= total*99 {
+			latency.DeviceP99Us = b.upper
+		}
+	}
+	return latency, nil
+}
+
+// parseHistValue converts bpftrace histogram bounds like "512" or "2K"
+func parseHistValue(value string) float64 {
+	multiplier := 1.0
+	switch value[len(value)-1] {
+	case 'K':
+		multiplier = 1024
+	case 'M':
+		multiplier = 1024 * 1024
+	case 'G':
+		multiplier = 1024 * 1024 * 1024
+	}
+	return parseFloat(strings.TrimRight(value, "KMG")) * multiplier
+}
+
+// compare relates the traced latencies to the completion latency reported
+// by fio, weighting reads and writes by their IOPS
+func (l *BPFLatency) compare(result TestResult) {
+	if result.FioJob == nil || result.TotalIOPS == 0 {
+		return
+	}
+	job := result.FioJob
+	l.FioClatAvgUs = (job.Read.Clat.Mean*result.ReadIOPS + job.Write.Clat.Mean*result.WriteIOPS) / result.TotalIOPS / 1000
+	l.OutsideAvgUs = l.FioClatAvgUs - l.DeviceAvgUs - l.BlockAvgUs
+	if l.FioClatAvgUs > 0 {
+		l.DeviceSharePc = 100 * l.DeviceAvgUs / l.FioClatAvgUs
+	}
+}
+
+func displayBPFLatency(latency *BPFLatency) {
+	fmt.Fprintf(out, "Kernel vs Device Latency (eBPF %s, microseconds)\n", latency.Device)
+	bpfTable := tablewriter.NewWriter(out)
+	bpfTable.SetHeader([]string{"Metric", "Value"})
+	configureTable(bpfTable, 2)
+	bpfTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	bpfTable.Append([]string{"fio Completion Lat (clat) Avg", fmt.Sprintf("%.2f", latency.FioClatAvgUs)})
+	bpfTable.Append([]string{"Device Lat Avg (issue to complete)", fmt.Sprintf("%.2f", latency.DeviceAvgUs)})
+	bpfTable.Append([]string{"Device Lat p50", fmt.Sprintf("<= %.0f", latency.DeviceP50Us)})
+	bpfTable.Append([]string{"Device Lat p99", fmt.Sprintf("<= %.0f", latency.DeviceP99Us)})
+	bpfTable.Append([]string{"Block Layer Lat Avg (insert to issue)", fmt.Sprintf("%.2f", latency.BlockAvgUs)})
+	bpfTable.Append([]string{"Outside Block Layer Avg", fmt.Sprintf("%.2f", latency.OutsideAvgUs)})
+	bpfTable.Append([]string{"Device Share of clat", fmt.Sprintf("%.1f%%", latency.DeviceSharePc)})
+	bpfTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EnduranceConfig turns a test into a long running endurance test, run as a
+// sequence of checkpoint intervals
+type EnduranceConfig struct {
+	Duration           string `json:"duration"`
+	CheckpointInterval string `json:"checkpoint_interval"`
+	// KeepCheckpoints is the number of checkpoints whose artifacts are kept
+	KeepCheckpoints int `json:"keep_checkpoints,omitempty"`
+}
+
+// EnduranceSummary summarizes the checkpoints of an endurance test
+type EnduranceSummary struct {
+	Elapsed      string     `json:"elapsed"`
+	Checkpoints  int        `json:"checkpoints"`
+	FirstIOPS    float64    `json:"first_iops"`
+	LastIOPS     float64    `json:"last_iops"`
+	IOPSChangePc float64    `json:"iops_change_percent"`
+	StartSMART   *SMARTData `json:"start_smart,omitempty"`
+	EndSMART     *SMARTData `json:"end_smart,omitempty"`
+}
+
+// defaultKeepCheckpoints bounds the artifacts of endurance tests without
+// keep_checkpoints
+const defaultKeepCheckpoints = 10
+
+// runEndurance runs the workload of an endurance test one checkpoint
+// interval at a time until the duration is reached. After every interval the
+// fio stats, SMART wear counters and temperature are added to the history
+// store, and the artifacts of old checkpoints are removed.
+func runEndurance(ctx context.Context, test FioTest, run RunInfo) TestResult {
+	result := TestResult{
+		TestName:    test.Name,
+		Description: test.Description,
+		Status:      "FAILED",
+		Config:      test,
+	}
+
+	duration, err := time.ParseDuration(test.Endurance.Duration)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid endurance duration: %v", err)
+		return result
+	}
+	interval, err := time.ParseDuration(test.Endurance.CheckpointInterval)
+	if err != nil || interval < time.Second {
+		result.Error = fmt.Errorf("invalid endurance checkpoint_interval %q", test.Endurance.CheckpointInterval)
+		return result
+	}
+	keep := test.Endurance.KeepCheckpoints
+	if keep <= 0 {
+		keep = defaultKeepCheckpoints
+	}
+
+	summary := &EnduranceSummary{}
+	device := ""
+	if metadata, err := targetDevice(test.Filename); err == nil {
+		device = metadata.Device
+		summary.StartSMART, _ = readSMART(device)
+	}
+
+	start := time.Now()
+	var last TestResult
+	var warnings []Warning
+	seen := map[Warning]bool{}
+	for checkpoint := 1; time.Since(start) < duration; checkpoint++ {
+		stage := test
+		stage.Endurance = nil
+		stage.Name = fmt.Sprintf("%s_cp%04d", test.Name, checkpoint)
+		stage.TimeBased = true
+		stage.Runtime = int(interval.Seconds())
+		if remaining := duration - time.Since(start); remaining < interval {
+			stage.Runtime = int(remaining.Seconds()) + 1
+		}
+		if checkpoint > 1 {
+			// Only the first interval starts from a reset or filled device
+			stage.ResetDevice = ""
+			stage.FillLevel = 0
+		}
+
+		last = runTest(ctx, stage, run)
+		for _, warning := range last.Warnings {
+			if !seen[warning] {
+				seen[warning] = true
+				warnings = append(warnings, warning)
+			}
+		}
+
+		record := newHistoryRecord(historyCheckpoint, run, last)
+		record.Test = test.Name
+		record.Checkpoint = checkpoint
+		record.ElapsedSeconds = time.Since(start).Seconds()
+		if device != "" {
+			record.SMART, _ = readSMART(device)
+			summary.EndSMART = record.SMART
+		}
+		if err := appendHistory(record); err != nil {
+			fmt.Fprintf(out, "Warning: failed to record checkpoint in history: %v\n", err)
+		}
+		displayCheckpoint(record)
+
+		if checkpoint > keep {
+			os.RemoveAll(filepath.Join(run.ArtifactsDir, sanitizeName(fmt.Sprintf("%s_cp%04d", test.Name, checkpoint-keep))))
+		}
+
+		summary.Checkpoints = checkpoint
+		if checkpoint == 1 {
+			summary.FirstIOPS = last.TotalIOPS
+		}
+		summary.LastIOPS = last.TotalIOPS
+		if last.Status != "PASSED" {
+			break
+		}
+	}
+
+	// The result of the last interval stands for the whole test
+	result = last
+	result.TestName = test.Name
+	result.Description = test.Description
+	result.Config = test
+	result.Duration = time.Since(start)
+	result.Warnings = warnings
+	if summary.FirstIOPS > 0 {
+		summary.IOPSChangePc = 100 * (summary.LastIOPS - summary.FirstIOPS) / summary.FirstIOPS
+	}
+	summary.Elapsed = result.Duration.Round(time.Second).String()
+	result.Endurance = summary
+	return result
+}
+
+// displayCheckpoint shows a checkpoint of an endurance test on one line
+func displayCheckpoint(record HistoryRecord) {
+	line := fmt.Sprintf("Checkpoint %d after %s: %s iops=%.0f bw=%.2fMB/s lat=%.2f%s",
+		record.Checkpoint, (time.Duration(record.ElapsedSeconds) * time.Second).String(), record.Status,
+		record.IOPS, record.BWMBps, record.LatencyUs, usUnit())
+	if record.SMART != nil {
+		line += fmt.Sprintf(" temp=%.0f%s wear=%.0f%%", record.SMART.TemperatureC, celsiusUnit(), record.SMART.PercentageUsed)
+	}
+	fmt.Fprintln(out, line)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// etaEstimator estimates the time left in a run from the wall time the
+// tests took in earlier runs, as recorded in the history store. Tests
+// without history are estimated from their configured runtime. The
+// estimates are calibrated with how long the tests finished so far took
+// compared with their estimate.
+type etaEstimator struct {
+	history   map[string]float64
+	predicted float64
+	actual    float64
+}
+
+// newETAEstimator loads the mean wall time of the tests from the history
+// store, using the records of the current namespace only
+func newETAEstimator(tests []FioTest) *etaEstimator {
+	e := &etaEstimator{history: map[string]float64{}}
+	if opts.History == "" {
+		return e
+	}
+
+	wanted := map[string]bool{}
+	for _, test := range tests {
+		wanted[test.Name] = true
+	}
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	filter := HistoryFilter{Kind: historyResult, Namespace: namespace, SameNamespace: true}
+	err := scanHistory(opts.History, filter, func(record HistoryRecord) error {
+		if !wanted[record.Test] {
+			return nil
+		}
+		seconds := record.WallSeconds
+		if seconds == 0 {
+			// Records written before the wall time was recorded
+			seconds = record.DurationSeconds
+		}
+		sums[record.Test] += seconds
+		counts[record.Test]++
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(out, "Warning: cannot estimate the run time from history: %v\n", err)
+	}
+	for name, sum := range sums {
+		e.history[name] = sum / float64(counts[name])
+	}
+	return e
+}
+
+// predict returns the expected wall time of a test in seconds
+func (e *etaEstimator) predict(test FioTest) (float64, bool) {
+	if seconds, ok := e.history[test.Name]; ok {
+		return seconds, true
+	}
+	if test.Endurance != nil {
+		if duration, err := time.ParseDuration(test.Endurance.Duration); err == nil {
+			return duration.Seconds(), true
+		}
+	}
+	if test.Retention != nil {
+		// Writing and verifying the pattern takes as long as the drive
+		// needs for the size of the test, known after the first run
+		return 0, false
+	}
+	if test.Runtime > 0 {
+		return float64(test.Runtime + test.CooldownSeconds), true
+	}
+	return 0, false
+}
+
+// finished records the wall time a test took
+func (e *etaEstimator) finished(test FioTest, wall time.Duration) {
+	if predicted, ok := e.predict(test); ok {
+		e.predicted += predicted
+		e.actual += wall.Seconds()
+	}
+}
+
+// remaining estimates the wall time of the tests, false if one of them
+// cannot be estimated
+func (e *etaEstimator) remaining(tests []FioTest) (time.Duration, bool) {
+	var seconds float64
+	for _, test := range tests {
+		predicted, ok := e.predict(test)
+		if !ok {
+			return 0, false
+		}
+		seconds += predicted
+	}
+
+	// Scale by how the tests of this run compared with their estimates,
+	// within reason so a single outlier does not dominate
+	if e.predicted > 0 {
+		factor := e.actual / e.predicted
+		if factor < 0.5 {
+			factor = 0.5
+		} else if factor > 2 {
+			factor = 2
+		}
+		seconds *= factor
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// etaLabel describes the time left for the progress line
+func etaLabel(left time.Duration) string {
+	return fmt.Sprintf("about %s left, done around %s", left.Round(time.Second), time.Now().Add(left).Format("15:04"))
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+
+	"fio-qa/stats"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Experiment compares variants of the setup, like IO schedulers or mount
+// options, by running every test once per variant and repetition
+type Experiment struct {
+	Name string `json:"name"`
+	// Repetitions is how often the suite runs per variant, default 3
+	Repetitions int                 `json:"repetitions,omitempty"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is one setup of an experiment, the first variant is the
+// control the others are compared with
+type ExperimentVariant struct {
+	Name string `json:"name"`
+	// Tuning sets queue parameters like the tuning of a test
+	Tuning map[string]string `json:"tuning,omitempty"`
+	// Options override fields of every test, e.g. {"ioengine": "io_uring"}
+	Options json.RawMessage `json:"options,omitempty"`
+	// Setup and Teardown are shell commands run before and after every
+	// test of the variant, e.g. remounting with other mount options
+	Setup    []string `json:"setup,omitempty"`
+	Teardown []string `json:"teardown,omitempty"`
+}
+
+// ExperimentStage identifies the run of a test in an experiment
+type ExperimentStage struct {
+	Test       string `json:"test"`
+	Variant    string `json:"variant"`
+	Repetition int    `json:"repetition"`
+
+	variant *ExperimentVariant
+	index   int
+}
+
+// ExperimentReport is the outcome of an experiment in the JSON results
+type ExperimentReport struct {
+	Name        string                 `json:"name"`
+	Seed        int64                  `json:"seed"`
+	Repetitions int                    `json:"repetitions"`
+	Variants    []string               `json:"variants"`
+	Comparisons []ExperimentComparison `json:"comparisons"`
+}
+
+// ExperimentComparison holds the results of a variant for one test over all
+// repetitions, compared with the control variant
+type ExperimentComparison struct {
+	Test        string  `json:"test"`
+	Variant     string  `json:"variant"`
+	Control     bool    `json:"control,omitempty"`
+	Runs        int     `json:"runs"`
+	IOPSMean    float64 `json:"iops_mean"`
+	IOPSStdDev  float64 `json:"iops_stddev"`
+	P99Mean     float64 `json:"p99_latency_us_mean"`
+	IOPSChange  float64 `json:"iops_change_percent,omitempty"`
+	P99Change   float64 `json:"p99_change_percent,omitempty"`
+	IOPSPValue  float64 `json:"iops_p_value,omitempty"`
+	P99PValue   float64 `json:"p99_p_value,omitempty"`
+	Significant bool    `json:"significant,omitempty"`
+}
+
+const (
+	defaultExperimentRepetitions = 3
+	// experimentAlpha is the p-value below which a difference to the
+	// control is significant
+	experimentAlpha = 0.05
+)
+
+// loadExperiment reads an experiment file, nil without a file
+func loadExperiment(filename string) (*Experiment, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("experiment: %v", err)
+	}
+	auditConfigFile(filename, data)
+	var experiment Experiment
+	if err := json.Unmarshal(data, &experiment); err != nil {
+		return nil, fmt.Errorf("experiment %s: %v", filename, err)
+	}
+	if experiment.Repetitions == 0 {
+		experiment.Repetitions = defaultExperimentRepetitions
+	}
+	if len(experiment.Variants) < 2 {
+		return nil, fmt.Errorf("experiment %s: needs at least two variants", filename)
+	}
+	if experiment.Repetitions < 1 {
+		return nil, fmt.Errorf("experiment %s: repetitions must be positive", filename)
+	}
+	seen := map[string]bool{}
+	for _, variant := range experiment.Variants {
+		if variant.Name == "" || seen[variant.Name] {
+			return nil, fmt.Errorf("experiment %s: every variant needs a unique name", filename)
+		}
+		seen[variant.Name] = true
+	}
+	return &experiment, nil
+}
+
+// expand runs the tests once per variant in every repetition. Each
+// repetition runs the variants in another random order, so drift of the
+// device over the experiment does not favor one variant.
+func (e *Experiment) expand(tests []FioTest, seed int64) ([]FioTest, error) {
+	rng := rand.New(rand.NewSource(seed))
+	var expanded []FioTest
+	for repetition := 1; repetition <= e.Repetitions; repetition++ {
+		for _, index := range rng.Perm(len(e.Variants)) {
+			variant := &e.Variants[index]
+			for _, test := range tests {
+				stage := test
+				if len(variant.Options) > 0 {
+					// Decoded without templates, only the given fields change
+					type plain FioTest
+					if err := json.Unmarshal(variant.Options, (*plain)(&stage)); err != nil {
+						return nil, fmt.Errorf("experiment variant %s: options: %v", variant.Name, err)
+					}
+				}
+				if len(variant.Tuning) > 0 {
+					stage.Tuning = map[string]string{}
+					for param, value := range test.Tuning {
+						stage.Tuning[param] = value
+					}
+					for param, value := range variant.Tuning {
+						stage.Tuning[param] = value
+					}
+				}
+				stage.Experiment = &ExperimentStage{Test: test.Name, Variant: variant.Name, Repetition: repetition, variant: variant, index: index}
+				stage.Name = fmt.Sprintf("%s_%s_r%d", test.Name, sanitizeName(variant.Name), repetition)
+				stage.Description = fmt.Sprintf("%s (%s, repetition %d)", test.Description, variant.Name, repetition)
+				expanded = append(expanded, stage)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// runVariantCommands runs the setup or teardown commands of a variant
+func runVariantCommands(commands []string) error {
+	for _, command := range commands {
+		if output, err := exec.Command("sh", "-c", command).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v\nOutput: %s", command, err, output)
+		}
+	}
+	return nil
+}
+
+// experimentComparisons compares the passed results of every variant with
+// those of the control variant, test by test, with Welch's t-test over the
+// repetitions
+func experimentComparisons(results []TestResult) []ExperimentComparison {
+	type key struct {
+		test    string
+		variant int
+	}
+	var tests []string
+	seen := map[string]bool{}
+	names := map[int]string{}
+	variants := 0
+	samples := map[key][]TestResult{}
+	for _, r := range results {
+		stage := r.Config.Experiment
+		if stage == nil {
+			continue
+		}
+		if !seen[stage.Test] {
+			seen[stage.Test] = true
+			tests = append(tests, stage.Test)
+		}
+		names[stage.index] = stage.Variant
+		variants = max(variants, stage.index+1)
+		if r.Status == "PASSED" {
+			k := key{stage.Test, stage.index}
+			samples[k] = append(samples[k], r)
+		}
+	}
+
+	values := func(rs []TestResult, value func(TestResult) float64) []float64 {
+		v := make([]float64, len(rs))
+		for i, r := range rs {
+			v[i] = value(r)
+		}
+		return v
+	}
+	iops := func(r TestResult) float64 { return r.TotalIOPS }
+	change := func(control, value float64) float64 {
+		if control == 0 {
+			return 0
+		}
+		return 100 * (value - control) / control
+	}
+
+	var comparisons []ExperimentComparison
+	for _, test := range tests {
+		controlIOPS := values(samples[key{test, 0}], iops)
+		controlP99 := values(samples[key{test, 0}], p99LatencyUs)
+		controlIOPSMean, _ := stats.MeanStdDev(controlIOPS)
+		controlP99Mean, _ := stats.MeanStdDev(controlP99)
+		for variant := 0; variant < variants; variant++ {
+			rs := samples[key{test, variant}]
+			c := ExperimentComparison{Test: test, Variant: names[variant], Control: variant == 0, Runs: len(rs)}
+			variantIOPS := values(rs, iops)
+			variantP99 := values(rs, p99LatencyUs)
+			c.IOPSMean, c.IOPSStdDev = stats.MeanStdDev(variantIOPS)
+			c.P99Mean, _ = stats.MeanStdDev(variantP99)
+			if variant > 0 {
+				c.IOPSChange = change(controlIOPSMean, c.IOPSMean)
+				c.P99Change = change(controlP99Mean, c.P99Mean)
+				c.IOPSPValue = stats.WelchTTest(controlIOPS, variantIOPS)
+				c.P99PValue = stats.WelchTTest(controlP99, variantP99)
+				c.Significant = c.IOPSPValue < experimentAlpha || c.P99PValue < experimentAlpha
+			}
+			comparisons = append(comparisons, c)
+		}
+	}
+	return comparisons
+}
+
+// experimentReport summarizes the experiment of a run for the JSON results
+func experimentReport(run RunInfo, results []TestResult) *ExperimentReport {
+	if run.Experiment == nil {
+		return nil
+	}
+	report := &ExperimentReport{
+		Name:        run.Experiment.Name,
+		Seed:        run.ExperimentSeed,
+		Repetitions: run.Experiment.Repetitions,
+		Comparisons: experimentComparisons(results),
+	}
+	for _, variant := range run.Experiment.Variants {
+		report.Variants = append(report.Variants, variant.Name)
+	}
+	return report
+}
+
+// displayExperiment shows the mean IOPS and p99 latency of every variant per
+// test over the repetitions, with the change from the control variant and
+// the p-value of the difference. Significant differences are highlighted and
+// listed.
+func displayExperiment(results []TestResult) {
+	comparisons := experimentComparisons(results)
+	if len(comparisons) == 0 {
+		return
+	}
+	pValue := func(p float64) string {
+		if p < 0.001 {
+			return "<0.001"
+		}
+		return fmt.Sprintf("%.3f", p)
+	}
+	// The better direction is green, the worse red
+	changeColor := func(change, p float64, higherIsBetter bool) tablewriter.Colors {
+		if p >= experimentAlpha {
+			return tablewriter.Colors{}
+		}
+		if (change > 0) == higherIsBetter {
+			return verdictColor(verdictPass)
+		}
+		return verdictColor(verdictFail)
+	}
+
+	fmt.Fprintf(out, "Experiment (control: %s, significant at p < %.2f)\n", comparisons[0].Variant, experimentAlpha)
+	experimentTable := tablewriter.NewWriter(out)
+	experimentTable.SetHeader([]string{"Test / Variant", "Runs", "IOPS", "Change", "p99 (" + usUnit() + ")", "Change", "p"})
+	configureTable(experimentTable, 7)
+	experimentTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	var significant []string
+	for _, c := range comparisons {
+		row := []string{c.Test + " / " + c.Variant, fmt.Sprintf("%d", c.Runs), "-", "-", "-", "-", "-"}
+		colors := make([]tablewriter.Colors, len(row))
+		if c.Runs > 0 {
+			row[2] = fmt.Sprintf("%s ±%s", formatCount(c.IOPSMean), formatCount(c.IOPSStdDev))
+			row[4] = fmt.Sprintf("%.2f", c.P99Mean)
+		}
+		if !c.Control && c.Runs > 0 {
+			row[3] = fmt.Sprintf("%+.1f%%", c.IOPSChange)
+			row[5] = fmt.Sprintf("%+.1f%%", c.P99Change)
+			row[6] = pValue(min(c.IOPSPValue, c.P99PValue))
+			colors[3] = changeColor(c.IOPSChange, c.IOPSPValue, true)
+			colors[5] = changeColor(c.P99Change, c.P99PValue, false)
+		}
+		if c.Significant {
+			var changes []string
+			if c.IOPSPValue < experimentAlpha {
+				changes = append(changes, fmt.Sprintf("IOPS %+.1f%% (p %s)", c.IOPSChange, pValue(c.IOPSPValue)))
+			}
+			if c.P99PValue < experimentAlpha {
+				changes = append(changes, fmt.Sprintf("p99 %+.1f%% (p %s)", c.P99Change, pValue(c.P99PValue)))
+			}
+			significant = append(significant, fmt.Sprintf("%s with %s: %s", c.Test, c.Variant, strings.Join(changes, ", ")))
+		}
+		experimentTable.Rich(row, colors)
+	}
+	experimentTable.Render()
+	if len(significant) > 0 {
+		fmt.Fprintln(out, "Significant differences from the control:")
+		for _, line := range significant {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FaultConfig injects faults below the test by running it on a
+// device-mapper target stacked over the real device:
+//
+//   - delay delays reads and writes by a fixed time (dm-delay)
+//   - flakey passes IO through for up_seconds, then misbehaves for
+//     down_seconds as selected by features (dm-flakey)
+//   - dust fails reads of the listed bad blocks (dm-dust)
+type FaultConfig struct {
+	Target       string   `json:"target"`
+	ReadDelayMs  int      `json:"read_delay_ms,omitempty"`
+	WriteDelayMs int      `json:"write_delay_ms,omitempty"`
+	UpSeconds    int      `json:"up_seconds,omitempty"`
+	DownSeconds  int      `json:"down_seconds,omitempty"`
+	Features     []string `json:"features,omitempty"`
+	BadBlocks    []int64  `json:"bad_blocks,omitempty"`
+	BlockSize    int      `json:"block_size,omitempty"`
+}
+
+// FaultInfo records the fault profile a test ran under
+type FaultInfo struct {
+	FaultConfig
+	Device string `json:"device"`
+	Mapper string `json:"mapper_device"`
+	Table  string `json:"table"`
+	// IOErrors is the number of IOs that failed while the test ran
+	IOErrors int64 `json:"io_errors"`
+}
+
+// faultFeatures are the dm-flakey features without arguments
+var faultFeatures = map[string]bool{
+	"drop_writes":  true,
+	"error_writes": true,
+	"error_reads":  true,
+}
+
+// checkFaults validates the fault injection settings of the tests before
+// anything runs
+func checkFaults(tests []FioTest) error {
+	for _, test := range tests {
+		if test.Fault == nil {
+			continue
+		}
+		if err := test.Fault.check(); err != nil {
+			return fmt.Errorf("test %s: fault: %v", test.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f *FaultConfig) check() error {
+	if f.ReadDelayMs < 0 || f.WriteDelayMs < 0 || f.UpSeconds < 0 || f.DownSeconds < 0 || f.BlockSize < 0 {
+		return fmt.Errorf("delays, up_seconds, down_seconds and block_size must not be negative")
+	}
+	switch f.Target {
+	case "delay":
+		if f.ReadDelayMs == 0 && f.WriteDelayMs == 0 {
+			return fmt.Errorf("delay needs read_delay_ms or write_delay_ms")
+		}
+	case "flakey":
+		if f.DownSeconds == 0 {
+			return fmt.Errorf("flakey needs down_seconds")
+		}
+		for _, feature := range f.Features {
+			if !faultFeatures[feature] {
+				return fmt.Errorf("unknown flakey feature %q, available: drop_writes, error_reads, error_writes", feature)
+			}
+		}
+	case "dust":
+		if len(f.BadBlocks) == 0 {
+			return fmt.Errorf("dust needs bad_blocks")
+		}
+		if f.BlockSize != 0 && (f.BlockSize < 512 || f.BlockSize&(f.BlockSize-1) != 0) {
+			return fmt.Errorf("block_size must be a power of two of at least 512")
+		}
+	default:
+		return fmt.Errorf("unknown target %q, available: delay, dust, flakey", f.Target)
+	}
+	return nil
+}
+
+// table returns the device-mapper table of the fault over sectors 512-byte
+// sectors of device
+func (f *FaultConfig) table(device string, sectors int64) string {
+	switch f.Target {
+	case "delay":
+		return fmt.Sprintf("0 %d delay %s 0 %d %s 0 %d", sectors, device, f.ReadDelayMs, device, f.WriteDelayMs)
+	case "flakey":
+		table := fmt.Sprintf("0 %d flakey %s 0 %d %d", sectors, device, f.UpSeconds, f.DownSeconds)
+		if len(f.Features) > 0 {
+			table += fmt.Sprintf(" %d %s", len(f.Features), strings.Join(f.Features, " "))
+		}
+		return table
+	default:
+		blockSize := f.BlockSize
+		if blockSize == 0 {
+			blockSize = 512
+		}
+		return fmt.Sprintf("0 %d dust %s 0 %d", sectors, device, blockSize)
+	}
+}
+
+// injectsErrors reports whether IOs may fail under the fault
+func (f *FaultConfig) injectsErrors() bool {
+	switch f.Target {
+	case "dust":
+		return true
+	case "flakey":
+		// Without features all IO fails while the device is down
+		for _, feature := range f.Features {
+			if feature != "drop_writes" {
+				return true
+			}
+		}
+		return len(f.Features) == 0
+	}
+	return false
+}
+
+func (f *FaultInfo) String() string {
+	var profile string
+	switch f.Target {
+	case "delay":
+		profile = fmt.Sprintf("delay reads %dms, writes %dms", f.ReadDelayMs, f.WriteDelayMs)
+	case "flakey":
+		profile = fmt.Sprintf("flakey up %ds, down %ds", f.UpSeconds, f.DownSeconds)
+		if len(f.Features) > 0 {
+			profile += " (" + strings.Join(f.Features, ", ") + ")"
+		}
+	case "dust":
+		profile = fmt.Sprintf("dust %d bad blocks", len(f.BadBlocks))
+	}
+	if f.injectsErrors() {
+		profile += fmt.Sprintf(", %d IO errors", f.IOErrors)
+	}
+	return fmt.Sprintf("%s on %s", profile, f.Mapper)
+}
+
+// setupFault creates the device-mapper target of the fault of the test over
+// its target device. The test then runs on the returned mapper device.
+func setupFault(test FioTest, run RunInfo) (*FaultInfo, error) {
+	info, err := os.Stat(test.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeDevice == 0 {
+		return nil, fmt.Errorf("%s is not a block device", test.Filename)
+	}
+
+	output, err := exec.Command("blockdev", "--getsz", test.Filename).Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the size of %s: %v", test.Filename, err)
+	}
+	sectors, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the size of %s: %v", test.Filename, err)
+	}
+
+	// Device-mapper names are limited to 127 characters
+	name := "fio-qa-" + run.ID + "-" + sanitizeName(test.Name)
+	if len(name) > 127 {
+		name = name[:127]
+	}
+	fault := &FaultInfo{
+		FaultConfig: *test.Fault,
+		Device:      test.Filename,
+		Mapper:      filepath.Join("/dev/mapper", name),
+		Table:       test.Fault.table(test.Filename, sectors),
+	}
+
+	if err := runResetCommand("dmsetup", "create", name, "--table", fault.Table); err != nil {
+		return nil, err
+	}
+	if fault.Target == "dust" {
+		blocks := append([]int64(nil), fault.BadBlocks...)
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+		for _, block := range blocks {
+			if err = runResetCommand("dmsetup", "message", name, "0", "addbadblock", strconv.FormatInt(block, 10)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = runResetCommand("dmsetup", "message", name, "0", "enable")
+		}
+		if err != nil {
+			removeFault(fault)
+			return nil, err
+		}
+	}
+	return fault, nil
+}
+
+// removeFault removes the device-mapper target of a fault. udev may still
+// hold the device briefly after the test, so removal is retried.
+func removeFault(fault *FaultInfo) error {
+	name := filepath.Base(fault.Mapper)
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if err = runResetCommand("dmsetup", "remove", name); err == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return err
+}
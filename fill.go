@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// FillInfo records how much of the target was filled before a fill level
+// stage of a test ran
+type FillInfo struct {
+	Test         string  `json:"test"`
+	LevelPercent int     `json:"level_percent"`
+	FilledBytes  int64   `json:"filled_bytes"`
+	Seconds      float64 `json:"seconds"`
+}
+
+// expandFillLevels replaces every test with fill_levels by one stage per
+// level, in ascending order. Each stage first fills the target up to its
+// level and then runs the workload of the test.
+func expandFillLevels(tests []FioTest) ([]FioTest, error) {
+	var expanded []FioTest
+	for _, test := range tests {
+		if len(test.FillLevels) == 0 {
+			expanded = append(expanded, test)
+			continue
+		}
+
+		levels := append([]int(nil), test.FillLevels...)
+		sort.Ints(levels)
+		for _, level := range levels {
+			if level <= 0 || level > 100 {
+				return nil, fmt.Errorf("test %s: fill level %d%% is not between 1 and 100", test.Name, level)
+			}
+			stage := test
+			stage.FillLevels = levels
+			stage.FillLevel = level
+			stage.FillOf = test.Name
+			stage.Name = fmt.Sprintf("%s_fill%d", test.Name, level)
+			stage.Description = fmt.Sprintf("%s (%d%% full)", test.Description, level)
+			expanded = append(expanded, stage)
+		}
+	}
+	return expanded, nil
+}
+
+// fillTarget writes the target sequentially from the previous fill level up
+// to the level of the stage. After a device reset the target is empty, so
+// the fill starts from the beginning.
+func fillTarget(ctx context.Context, test FioTest) (*FillInfo, error) {
+	capacity, err := targetCapacity(test)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := 0
+	if test.ResetDevice == "" {
+		for _, level := range test.FillLevels {
+			if level < test.FillLevel {
+				previous = level
+			}
+		}
+	}
+	from := capacity * int64(previous) / 100
+	to := capacity * int64(test.FillLevel) / 100
+	info := &FillInfo{Test: test.FillOf, LevelPercent: test.FillLevel, FilledBytes: to}
+	if to <= from {
+		return info, nil
+	}
+
+	fmt.Fprintf(out, "Filling %s to %d%% (%.1f GB)\n", test.Filename, test.FillLevel, float64(to)/1e9)
+	args := []string{
+		fmt.Sprintf("--name=%s_fill", test.Name),
+		fmt.Sprintf("--filename=%s", fioFilename(test.Filename)),
+		"--rw=write",
+		"--bs=1M",
+		"--direct=1",
+		fmt.Sprintf("--ioengine=%s", platformIOEngine("")),
+		"--iodepth=32",
+		fmt.Sprintf("--offset=%d", from),
+		fmt.Sprintf("--size=%d", to-from),
+	}
+	args = append(args, rateCapArgs(FioTest{RW: "write"})...)
+	start := time.Now()
+	output, err := runFio(ctx, fioCommand(test, args), nil)
+	if err != nil {
+		return nil, fmt.Errorf("fill failed: %v: %s", err, output)
+	}
+	info.Seconds = time.Since(start).Seconds()
+	return info, nil
+}
+
+// targetCapacity returns the size of a block device target, or the size of
+// the test for file targets
+func targetCapacity(test FioTest) (int64, error) {
+	if info, err := os.Stat(test.Filename); err == nil && info.Mode()&os.ModeDevice != 0 {
+		f, err := os.Open(test.Filename)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return f.Seek(0, io.SeekEnd)
+	}
+	if size := parseSize(test.Size); size > 0 {
+		return size, nil
+	}
+	return 0, fmt.Errorf("cannot determine the capacity of %s", test.Filename)
+}
+
+// displayFillLevels shows how the performance of tests with fill levels
+// changed as the target filled up
+func displayFillLevels(results []TestResult) {
+	var tests []string
+	stages := map[string][]TestResult{}
+	for _, r := range results {
+		if r.Fill == nil {
+			continue
+		}
+		if _, ok := stages[r.Fill.Test]; !ok {
+			tests = append(tests, r.Fill.Test)
+		}
+		stages[r.Fill.Test] = append(stages[r.Fill.Test], r)
+	}
+
+	for _, test := range tests {
+		fmt.Fprintf(out, "Performance vs Fill Level: %s\n", test)
+		fillTable := tablewriter.NewWriter(out)
+		fillTable.SetHeader([]string{"Fill Level", "IOPS", "BW (MB/s)", "Lat (" + usUnit() + ")", "p99 Lat (" + usUnit() + ")"})
+		configureTable(fillTable, 5)
+		fillTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+		for _, r := range stages[test] {
+			row := []string{strconv.Itoa(r.Fill.LevelPercent) + "%", "-", "-", "-", "-"}
+			if r.Status == "PASSED" {
+				row[1] = formatCount(r.TotalIOPS)
+				row[2] = fmt.Sprintf("%.2f", r.TotalBWMBps)
+				row[3] = fmt.Sprintf("%.2f", r.AvgLatencyUs)
+				row[4] = fmt.Sprintf("%.2f", p99LatencyUs(r))
+			}
+			fillTable.Append(row)
+		}
+		fillTable.Render()
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// FioBinary is one of the fio binaries given with --fio-bin
+type FioBinary struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Label names the binary in test names and tables, its version unless
+	// two binaries report the same one
+	Label string `json:"label"`
+}
+
+// fioBinaryShiftPercent is how much the IOPS or p99 latency of a test may
+// differ from the first binary before the change is highlighted
+const fioBinaryShiftPercent = 5
+
+// inspectFioBinaries asks every binary for its version, which fails for
+// paths that are not a working fio
+func inspectFioBinaries(paths []string) ([]*FioBinary, error) {
+	var binaries []*FioBinary
+	versions := map[string]int{}
+	for i, path := range paths {
+		for _, previous := range paths[:i] {
+			if previous == path {
+				return nil, fmt.Errorf("--fio-bin %s is given twice", path)
+			}
+		}
+		output, err := fioCommand(FioTest{FioBinary: &FioBinary{Path: path}}, []string{"--version"}).Output()
+		if err != nil {
+			return nil, fmt.Errorf("--fio-bin %s: %v", path, err)
+		}
+		version := strings.TrimSpace(string(output))
+		versions[version]++
+		binaries = append(binaries, &FioBinary{Path: path, Version: version, Label: version})
+	}
+	for _, binary := range binaries {
+		if versions[binary.Version] > 1 {
+			binary.Label = binary.Path
+		}
+	}
+	return binaries, nil
+}
+
+// expandFioBinaries runs every test once per binary, one right after the
+// other so that each binary finds the device in the same state. A single
+// binary only replaces fio from PATH.
+func expandFioBinaries(tests []FioTest, binaries []*FioBinary) []FioTest {
+	if len(binaries) < 2 {
+		for i := range tests {
+			for _, binary := range binaries {
+				tests[i].FioBinary = binary
+			}
+		}
+		return tests
+	}
+	var expanded []FioTest
+	for _, test := range tests {
+		for _, binary := range binaries {
+			stage := test
+			stage.FioBinary = binary
+			stage.FioBinaryOf = test.Name
+			stage.Name = fmt.Sprintf("%s_%s", test.Name, strings.Trim(sanitizeName(binary.Label), "_"))
+			stage.Description = fmt.Sprintf("%s (%s)", test.Description, binary.Label)
+			expanded = append(expanded, stage)
+		}
+	}
+	return expanded
+}
+
+// displayFioBinaries shows the results of the tests run with several fio
+// binaries side by side, with the change from the first binary. Changes
+// beyond fioBinaryShiftPercent are highlighted and listed, they mean the fio
+// upgrade alone moves the results.
+func displayFioBinaries(results []TestResult) {
+	var binaries []*FioBinary
+	var tests []string
+	byTest := map[string]map[*FioBinary]TestResult{}
+	for _, r := range results {
+		if r.Config.FioBinaryOf == "" {
+			continue
+		}
+		if _, ok := byTest[r.Config.FioBinaryOf]; !ok {
+			tests = append(tests, r.Config.FioBinaryOf)
+			byTest[r.Config.FioBinaryOf] = map[*FioBinary]TestResult{}
+		}
+		byTest[r.Config.FioBinaryOf][r.Config.FioBinary] = r
+		known := false
+		for _, binary := range binaries {
+			known = known || binary == r.Config.FioBinary
+		}
+		if !known {
+			binaries = append(binaries, r.Config.FioBinary)
+		}
+	}
+	if len(binaries) < 2 {
+		return
+	}
+
+	var shifts []string
+	for _, metric := range matrixMetrics() {
+		fmt.Fprintf(out, "%s by fio Binary\n", metric.name)
+		// Labels may be paths, which keep their case
+		header := []string{"TEST"}
+		alignment := []int{tablewriter.ALIGN_LEFT}
+		for _, binary := range binaries {
+			header = append(header, binary.Label)
+			alignment = append(alignment, tablewriter.ALIGN_RIGHT)
+		}
+		binaryTable := tablewriter.NewWriter(out)
+		binaryTable.SetHeader(header)
+		configureTable(binaryTable, len(header))
+		binaryTable.SetAutoFormatHeaders(false)
+		binaryTable.SetColumnAlignment(alignment)
+		for _, test := range tests {
+			row := []string{test}
+			colors := []tablewriter.Colors{{}}
+			first, firstOK := byTest[test][binaries[0]]
+			for i, binary := range binaries {
+				r, ok := byTest[test][binary]
+				if !ok || r.Status != "PASSED" {
+					row = append(row, "-")
+					colors = append(colors, tablewriter.Colors{})
+					continue
+				}
+				cell := metric.format(metric.value(r))
+				color := tablewriter.Colors{}
+				if i > 0 && firstOK && first.Status == "PASSED" && metric.value(first) > 0 {
+					change := 100 * (metric.value(r) - metric.value(first)) / metric.value(first)
+					cell += fmt.Sprintf(" (%+.1f%%)", change)
+					if change > fioBinaryShiftPercent || change < -fioBinaryShiftPercent {
+						if (change < 0) == metric.higherIsBetter {
+							color = verdictColor(verdictFail)
+						} else {
+							color = verdictColor(verdictMarginal)
+						}
+						shifts = append(shifts, fmt.Sprintf("%s %s %+.1f%% with %s", test, metric.name, change, binary.Label))
+					}
+				}
+				row = append(row, cell)
+				colors = append(colors, color)
+			}
+			binaryTable.Rich(row, colors)
+		}
+		binaryTable.Render()
+		fmt.Fprintln(out)
+	}
+	if len(shifts) > 0 {
+		fmt.Fprintf(out, "Results shifted by more than %d%% from %s:\n", fioBinaryShiftPercent, binaries[0].Label)
+		for _, shift := range shifts {
+			fmt.Fprintf(out, "  %s\n", shift)
+		}
+		fmt.Fprintln(out)
+	}
+}
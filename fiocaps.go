@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FioArgChange records an argument that was changed because the installed
+// fio does not support it
+type FioArgChange struct {
+	Arg         string `json:"arg"`
+	Replacement string `json:"replacement,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// fioCapabilities are the options the installed fio accepts: command line
+// options from --help and job options from --cmdhelp
+type fioCapabilities struct {
+	// probe runs the fio binary the capabilities were detected for
+	probe      FioTest
+	version    string
+	cliOptions map[string]bool
+	jobOptions map[string]bool
+	// checked caches the answers of --cmdhelp=<option> for options missing
+	// from the full list, which does not show every alias
+	checked map[string]bool
+}
+
+// fioOptionFallbacks are older names of options for fio versions that do
+// not know the current ones
+var fioOptionFallbacks = map[string]string{
+	"iodepth_batch_submit": "iodepth_batch",
+	"lat_percentiles":      "clat_percentiles",
+}
+
+var (
+	// fioCaps holds the capabilities per fio binary, nil for binaries whose
+	// answer was not understood
+	fioCapsMu sync.Mutex
+	fioCaps   = map[string]*fioCapabilities{}
+
+	fioCLIOptionPattern = regexp.MustCompile(`^\s+--([a-z][a-z0-9_-]*)`)
+	fioJobOptionPattern = regexp.MustCompile(`^\s*([a-z][a-z0-9_]*)\s*:`)
+)
+
+// detectFioCapabilities asks the fio binary of a test once per run for the
+// options it supports. It returns nil when the answer cannot be understood,
+// the arguments are then passed to fio unchanged.
+func detectFioCapabilities(test FioTest) *fioCapabilities {
+	probe := FioTest{FioBinary: test.FioBinary}
+	path := fioPath(probe)
+	fioCapsMu.Lock()
+	defer fioCapsMu.Unlock()
+	if caps, ok := fioCaps[path]; ok {
+		return caps
+	}
+	fioCaps[path] = nil
+
+	version, err := fioCommand(probe, []string{"--version"}).Output()
+	if err != nil {
+		return nil
+	}
+	help, _ := fioCommand(probe, []string{"--help"}).Output()
+	cmdhelp, _ := fioCommand(probe, []string{"--cmdhelp=all"}).Output()
+
+	caps := &fioCapabilities{
+		probe:      probe,
+		version:    strings.TrimSpace(string(version)),
+		cliOptions: matchLines(help, fioCLIOptionPattern),
+		jobOptions: matchLines(cmdhelp, fioJobOptionPattern),
+		checked:    map[string]bool{},
+	}
+	// Every fio knows dozens of options, fewer means the output was not
+	// understood
+	if len(caps.cliOptions) < 5 || len(caps.jobOptions) < 20 {
+		return nil
+	}
+	fioCaps[path] = caps
+	return caps
+}
+
+// matchLines returns the first submatch of every line matching pattern
+func matchLines(output []byte, pattern *regexp.Regexp) map[string]bool {
+	matches := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if match := pattern.FindStringSubmatch(scanner.Text()); match != nil {
+			matches[match[1]] = true
+		}
+	}
+	return matches
+}
+
+// supports reports whether fio accepts an option on the command line
+func (c *fioCapabilities) supports(option string) bool {
+	if c.cliOptions[option] || c.jobOptions[option] {
+		return true
+	}
+	if supported, ok := c.checked[option]; ok {
+		return supported
+	}
+	// fio exits with an error for unknown options
+	err := fioCommand(c.probe, []string{"--cmdhelp=" + option}).Run()
+	c.checked[option] = err == nil
+	return err == nil
+}
+
+// adaptFioArgs omits or translates the arguments the fio of a test does not
+// support, so that an older fio runs the test instead of failing it with a
+// usage error
+func adaptFioArgs(test FioTest, args []string) ([]string, []FioArgChange) {
+	caps := detectFioCapabilities(test)
+	if caps == nil {
+		return args, nil
+	}
+
+	var adapted []string
+	var changes []FioArgChange
+	for _, arg := range args {
+		option, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || caps.supports(option) {
+			adapted = append(adapted, arg)
+			continue
+		}
+
+		reason := fmt.Sprintf("not supported by %s", caps.version)
+		if fallback, ok := fioOptionFallbacks[option]; ok && caps.supports(fallback) {
+			replacement := "--" + fallback
+			if hasValue {
+				replacement += "=" + value
+			}
+			adapted = append(adapted, replacement)
+			changes = append(changes, FioArgChange{Arg: arg, Replacement: replacement, Reason: reason})
+			continue
+		}
+		changes = append(changes, FioArgChange{Arg: arg, Reason: reason})
+	}
+	return adapted, changes
+}
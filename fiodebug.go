@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// fioDebugRuntime bounds the runtime of the debug re-run in seconds, the
+	// setup failures it is meant for show up right at the start
+	fioDebugRuntime = 10
+	// fioDebugTimeout kills a debug re-run that hangs like the failed run
+	// may have
+	fioDebugTimeout = 2 * time.Minute
+	// fioDebugMaxBytes caps the debug log, io debugging logs every IO
+	fioDebugMaxBytes = 64 * 1024 * 1024
+)
+
+// headWriter writes to a file until the limit is reached and discards the
+// rest
+type headWriter struct {
+	file      *os.File
+	remaining int64
+	truncated bool
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+		w.truncated = true
+	}
+	if len(p) > 0 {
+		if _, err := w.file.Write(p); err != nil {
+			return 0, err
+		}
+		w.remaining -= int64(len(p))
+	}
+	return n, nil
+}
+
+// fioDebugDroppedArgs are left out of the debug re-run: its output goes to
+// the console and the logs of the failed run in the artifact bundle are kept
+var fioDebugDroppedArgs = map[string]bool{
+	"--output":         true,
+	"--output-format":  true,
+	"--runtime":        true,
+	"--eta":            true,
+	"--write_iolog":    true,
+	"--write_hist_log": true,
+	"--log_hist_msec":  true,
+	"--write_bw_log":   true,
+	"--log_avg_msec":   true,
+}
+
+// debugFioArgs turns the arguments of a failed fio run into those of its
+// debug re-run: fio logs IO and process debugging to the console instead of
+// writing its JSON output, and stops after fioDebugRuntime seconds. A job
+// file stays the last argument.
+func debugFioArgs(args []string) []string {
+	var jobFile string
+	if len(args) > 0 && !strings.HasPrefix(args[len(args)-1], "--") {
+		jobFile, args = args[len(args)-1], args[:len(args)-1]
+	}
+	debug := make([]string, 0, len(args)+3)
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if fioDebugDroppedArgs[name] {
+			continue
+		}
+		debug = append(debug, arg)
+	}
+	debug = append(debug, "--debug=io,process", fmt.Sprintf("--runtime=%d", fioDebugRuntime))
+	if jobFile != "" {
+		debug = append(debug, jobFile)
+	}
+	return debug
+}
+
+// captureFioDebug re-runs a test fio failed on once with debug output and
+// keeps the output in the artifact bundle, so failures that only happen
+// now and then can be diagnosed afterwards
+func captureFioDebug(test FioTest, run RunInfo, args []string, files []string, result *TestResult) {
+	path, err := artifactPath(run, test, "fio-debug.log")
+	if err != nil {
+		result.warn(severityWarning, "monitor", "cannot capture fio debug output: %v", err)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		result.warn(severityWarning, "monitor", "cannot capture fio debug output: %v", err)
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(out, "fio failed, re-running %s once with debug output\n", test.Name)
+	writer := &headWriter{file: f, remaining: fioDebugMaxBytes}
+	cmd := fioCommand(test, debugFioArgs(args), files...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		result.warn(severityWarning, "monitor", "cannot capture fio debug output: %v", err)
+		return
+	}
+	timer := time.AfterFunc(fioDebugTimeout, func() { cmd.Process.Kill() })
+	err = cmd.Wait()
+	timedOut := !timer.Stop()
+	switch {
+	case timedOut:
+		fmt.Fprintf(f, "\nfio-qa: killed the debug run after %s\n", fioDebugTimeout)
+	case err == nil:
+		// Worth knowing when the failure is intermittent
+		fmt.Fprintln(f, "\nfio-qa: the debug run succeeded")
+	default:
+		fmt.Fprintf(f, "\nfio-qa: the debug run failed too: %v\n", err)
+	}
+	if writer.truncated {
+		fmt.Fprintf(f, "fio-qa: the output was cut off after %d MB\n", fioDebugMaxBytes/1024/1024)
+	}
+	f.Close()
+	result.Artifacts = append(result.Artifacts, compressArtifacts([]string{path}, result)...)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// DeviceGroup holds the statistics of the drives of one model and firmware,
+// so a run over a mixed population gives numbers per SKU
+type DeviceGroup struct {
+	Model         string             `json:"model"`
+	Firmware      string             `json:"firmware,omitempty"`
+	Devices       []string           `json:"devices"`
+	CapacityBytes int64              `json:"capacity_bytes,omitempty"`
+	Price         float64            `json:"price,omitempty"`
+	Workloads     []DeviceGroupStats `json:"workloads"`
+}
+
+// DeviceGroupStats aggregates the passed results of a workload on the drives
+// of a group, workloads are named like the rows of the device matrix
+type DeviceGroupStats struct {
+	Workload      string  `json:"workload"`
+	Results       int     `json:"results"`
+	MeanIOPS      float64 `json:"mean_iops"`
+	MinIOPS       float64 `json:"min_iops"`
+	MaxIOPS       float64 `json:"max_iops"`
+	MeanBWMBps    float64 `json:"mean_bw_mbps"`
+	MeanLatencyUs float64 `json:"mean_latency_us"`
+	MedianP99Us   float64 `json:"median_p99_us"`
+	// The means normalized by the capacity of the drives
+	MeanIOPSPerTB float64 `json:"mean_iops_per_tb,omitempty"`
+	MeanMBpsPerTB float64 `json:"mean_mbps_per_tb,omitempty"`
+	// The price of the mean performance with a cost model
+	CostPer100kIOPS float64 `json:"cost_per_100k_iops,omitempty"`
+	CostPerGBps     float64 `json:"cost_per_gbps,omitempty"`
+}
+
+// groupName names a group in tables, drives without a model are unknown
+func (g DeviceGroup) groupName() string {
+	name := g.Model
+	if name == "" {
+		name = "unknown"
+	}
+	if g.Firmware != "" {
+		name += " (fw " + g.Firmware + ")"
+	}
+	return name
+}
+
+// deviceGroups groups the passed results with device metadata by the model
+// and firmware of their drive, in the order the groups first appear
+func deviceGroups(results []TestResult) []DeviceGroup {
+	type key struct{ model, firmware string }
+	var keys []key
+	devices := map[key][]string{}
+	workloads := map[key][]string{}
+	samples := map[key]map[string][]TestResult{}
+	capacity := map[key]int64{}
+	price := map[key]float64{}
+	for _, r := range results {
+		if r.Status != "PASSED" || r.Device == nil {
+			continue
+		}
+		k := key{r.Device.Model, r.Device.Firmware}
+		if _, ok := samples[k]; !ok {
+			keys = append(keys, k)
+			samples[k] = map[string][]TestResult{}
+			capacity[k] = r.Device.SizeBytes
+		}
+		if r.Cost != nil {
+			price[k] = r.Cost.DevicePrice
+		}
+		workload := matrixWorkload(r.Config)
+		if _, ok := samples[k][workload]; !ok {
+			workloads[k] = append(workloads[k], workload)
+		}
+		samples[k][workload] = append(samples[k][workload], r)
+		known := false
+		for _, device := range devices[k] {
+			known = known || device == r.Device.Device
+		}
+		if !known {
+			devices[k] = append(devices[k], r.Device.Device)
+		}
+	}
+
+	groups := make([]DeviceGroup, 0, len(keys))
+	for _, k := range keys {
+		group := DeviceGroup{Model: k.model, Firmware: k.firmware, Devices: devices[k], CapacityBytes: capacity[k], Price: price[k]}
+		sort.Slice(group.Devices, func(i, j int) bool { return naturalLess(group.Devices[i], group.Devices[j]) })
+		for _, workload := range workloads[k] {
+			rs := samples[k][workload]
+			stats := DeviceGroupStats{Workload: workload, Results: len(rs), MinIOPS: rs[0].TotalIOPS}
+			p99s := make([]float64, 0, len(rs))
+			for _, r := range rs {
+				stats.MeanIOPS += r.TotalIOPS / float64(len(rs))
+				stats.MeanBWMBps += r.TotalBWMBps / float64(len(rs))
+				stats.MeanLatencyUs += r.AvgLatencyUs / float64(len(rs))
+				if r.TotalIOPS < stats.MinIOPS {
+					stats.MinIOPS = r.TotalIOPS
+				}
+				if r.TotalIOPS > stats.MaxIOPS {
+					stats.MaxIOPS = r.TotalIOPS
+				}
+				p99s = append(p99s, p99LatencyUs(r))
+			}
+			if group.CapacityBytes > 0 {
+				tb := float64(group.CapacityBytes) / 1e12
+				stats.MeanIOPSPerTB = stats.MeanIOPS / tb
+				stats.MeanMBpsPerTB = stats.MeanBWMBps / tb
+			}
+			if group.Price > 0 && stats.MeanIOPS > 0 {
+				stats.CostPer100kIOPS = group.Price / (stats.MeanIOPS / 100000)
+			}
+			if group.Price > 0 && stats.MeanBWMBps > 0 {
+				stats.CostPerGBps = group.Price / (stats.MeanBWMBps / 1024)
+			}
+			stats.MedianP99Us = median(p99s)
+			group.Workloads = append(group.Workloads, stats)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// displayDeviceGroups shows the statistics per drive model and firmware when
+// the run covered more than one
+func displayDeviceGroups(groups []DeviceGroup) {
+	if len(groups) < 2 {
+		return
+	}
+	// Models of different sizes are compared per TB as well
+	perTB := false
+	for _, group := range groups {
+		perTB = perTB || group.CapacityBytes > 0
+	}
+	fmt.Fprintln(out, "Results by Device Model")
+	groupTable := tablewriter.NewWriter(out)
+	header := []string{"Model", "Workload", "Results", "IOPS", "MB/s", "Lat (" + usUnit() + ")", "p99 (" + usUnit() + ")"}
+	alignment := []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT}
+	if perTB {
+		header = append(header, "IOPS/TB", "MB/s per TB")
+		alignment = append(alignment, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+	}
+	groupTable.SetHeader(header)
+	configureTable(groupTable, len(header))
+	groupTable.SetColumnAlignment(alignment)
+	for _, group := range groups {
+		for _, stats := range group.Workloads {
+			iops := formatCount(stats.MeanIOPS)
+			if stats.Results > 1 {
+				iops += fmt.Sprintf(" (%s-%s)", formatCount(stats.MinIOPS), formatCount(stats.MaxIOPS))
+			}
+			row := []string{
+				group.groupName(),
+				stats.Workload,
+				strconv.Itoa(stats.Results),
+				iops,
+				formatNumber(stats.MeanBWMBps, 2),
+				fmt.Sprintf("%.2f", stats.MeanLatencyUs),
+				fmt.Sprintf("%.2f", stats.MedianP99Us),
+			}
+			if perTB && group.CapacityBytes > 0 {
+				row = append(row, formatCount(stats.MeanIOPSPerTB), formatNumber(stats.MeanMBpsPerTB, 2))
+			} else if perTB {
+				row = append(row, "-", "-")
+			}
+			groupTable.Append(row)
+		}
+	}
+	groupTable.Render()
+	fmt.Fprintln(out)
+}
+
+// diffGroups compares the device groups of two results files, workload by
+// workload for the groups and workloads both have
+func diffGroups(pathA, pathB string, allowDrift, approvedOnly bool) int {
+	var groups [2][]DeviceGroup
+	var results [2]JSONResults
+	for i, path := range []string{pathA, pathB} {
+		var err error
+		results[i], _, err = readResultsFile(path)
+		if err != nil {
+			return usageError("%v", err)
+		}
+		if approvedOnly {
+			if err := checkApproved(path, results[i]); err != nil {
+				return usageError("%v", err)
+			}
+		}
+		if len(results[i].DeviceGroups) == 0 {
+			return usageError("%s has no device groups, its tests ran without device metadata", path)
+		}
+		groups[i] = results[i].DeviceGroups
+	}
+	if err := checkConfigDrift("the tests", resultsConfigDrift(results[0], results[1]), allowDrift); err != nil {
+		return usageError("%v", err)
+	}
+
+	statsB := map[string]DeviceGroupStats{}
+	for _, group := range groups[1] {
+		for _, stats := range group.Workloads {
+			statsB[group.groupName()+"\x00"+stats.Workload] = stats
+		}
+	}
+	change := func(a, b float64) string {
+		if a == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%+.1f%%", 100*(b-a)/a)
+	}
+
+	fmt.Fprintf(out, "A: %s\nB: %s\n", pathA, pathB)
+	diffTable := tablewriter.NewWriter(out)
+	diffTable.SetHeader([]string{"Model / Workload", "IOPS A", "IOPS B", "Change", "p99 A", "p99 B", "Change"})
+	configureTable(diffTable, 7)
+	diffTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	compared := 0
+	for _, group := range groups[0] {
+		for _, a := range group.Workloads {
+			b, ok := statsB[group.groupName()+"\x00"+a.Workload]
+			if !ok {
+				continue
+			}
+			compared++
+			diffTable.Append([]string{
+				group.groupName() + " / " + a.Workload,
+				formatCount(a.MeanIOPS),
+				formatCount(b.MeanIOPS),
+				change(a.MeanIOPS, b.MeanIOPS),
+				fmt.Sprintf("%.2f", a.MedianP99Us),
+				fmt.Sprintf("%.2f", b.MedianP99Us),
+				change(a.MedianP99Us, b.MedianP99Us),
+			})
+		}
+	}
+	if compared == 0 {
+		fmt.Fprintln(out, "No device model and workload in common")
+		return exitOK
+	}
+	diffTable.Render()
+	return exitOK
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Shades of the heatmap cells from empty to the whole interval, the ASCII
+// ramp has more steps since its characters are less distinct
+var (
+	heatmapShades      = []string{" ", "░", "▒", "▓", "█"}
+	heatmapASCIIShades = []string{" ", ".", ":", "-", "=", "+", "*", "#", "%", "@"}
+)
+
+// heatmapLabelWidth is the width of the latency labels left of the heatmap
+const heatmapLabelWidth = 9
+
+// heatmapLabel names a latency bucket by its upper bound
+func heatmapLabel(us float64) string {
+	switch {
+	case us < 1000:
+		return fmt.Sprintf("%.0f%s", us, usUnit())
+	case us < 1e6:
+		return fmt.Sprintf("%.0fms", us/1000)
+	default:
+		return fmt.Sprintf("%.1fs", us/1e6)
+	}
+}
+
+// heatmapCell shades a cell by the share of the IOs of its interval, any IO
+// at all shows, so rare outliers are not lost. Colors go from blue for a few
+// IOs over yellow to red for most of them.
+func heatmapCell(share float64) (shade, color string) {
+	shades := heatmapShades
+	if opts.ASCII {
+		shades = heatmapASCIIShades
+	}
+	if share <= 0 {
+		return shades[0], ""
+	}
+	level := 1 + int(share*float64(len(shades)-2)+0.5)
+	if level >= len(shades) {
+		level = len(shades) - 1
+	}
+	switch {
+	case opts.NoColor:
+		return shades[level], ""
+	case share < 0.1:
+		return shades[level], "\x1b[34m"
+	case share < 0.5:
+		return shades[level], "\x1b[33m"
+	default:
+		return shades[level], "\x1b[31m"
+	}
+}
+
+// heatmapLine draws a row of cells, switching colors only where they change
+func heatmapLine(label string, shares []float64) string {
+	var line strings.Builder
+	// Padded by characters, μs takes two bytes
+	line.WriteString(strings.Repeat(" ", max(heatmapLabelWidth-utf8.RuneCountInString(label), 0)) + label + " |")
+	current := ""
+	for _, share := range shares {
+		shade, color := heatmapCell(share)
+		if color != current {
+			if current != "" {
+				line.WriteString("\x1b[0m")
+			}
+			line.WriteString(color)
+			current = color
+		}
+		line.WriteString(shade)
+	}
+	if current != "" {
+		line.WriteString("\x1b[0m")
+	}
+	return line.String()
+}
+
+// displayHeatmap draws the latency heatmap of a test: time runs to the right
+// and latency upwards, every column shows how the IOs completed in its
+// interval spread over the latency buckets. Intervals are merged when there
+// are more than fit the table width. A row of IO counts below shows stalls
+// as gaps.
+func displayHeatmap(heatmap *LatencyHeatmap) {
+	if heatmap == nil || len(heatmap.Rows) == 0 {
+		return
+	}
+	// Intervals without IOs have no row, they are placed by their time
+	end := heatmap.Rows[len(heatmap.Rows)-1].TimeMs
+	intervals := int((end + heatmap.IntervalMs - 1) / heatmap.IntervalMs)
+	width := tableWidth - heatmapLabelWidth - 3
+	perColumn := (intervals + width - 1) / width
+	columns := make([][]int64, (intervals+perColumn-1)/perColumn)
+	for i := range columns {
+		columns[i] = make([]int64, len(heatmap.BucketsUs))
+	}
+	for _, row := range heatmap.Rows {
+		interval := int((row.TimeMs+heatmap.IntervalMs-1)/heatmap.IntervalMs) - 1
+		column := columns[min(max(interval, 0)/perColumn, len(columns)-1)]
+		for bucket, count := range row.Counts {
+			column[bucket] += count
+		}
+	}
+
+	// Only the buckets between the fastest and slowest IO are drawn
+	low, high := len(heatmap.BucketsUs), -1
+	totals := make([]int64, len(columns))
+	var maxTotal int64
+	for i, column := range columns {
+		for bucket, count := range column {
+			if count == 0 {
+				continue
+			}
+			totals[i] += count
+			low, high = min(low, bucket), max(high, bucket)
+		}
+		if totals[i] > maxTotal {
+			maxTotal = totals[i]
+		}
+	}
+	if high < 0 {
+		return
+	}
+
+	interval := time.Duration(heatmap.IntervalMs*int64(perColumn)) * time.Millisecond
+	fmt.Fprintf(out, "Latency Heatmap (%s per column, shade: share of the IOs of the interval)\n", interval)
+	for bucket := high; bucket >= low; bucket-- {
+		shares := make([]float64, len(columns))
+		for i, column := range columns {
+			if totals[i] > 0 {
+				shares[i] = float64(column[bucket]) / float64(totals[i])
+			}
+		}
+		fmt.Fprintln(out, heatmapLine("<="+heatmapLabel(heatmap.BucketsUs[bucket]), shares))
+	}
+
+	// IOs per interval relative to the busiest one, empty intervals are stalls
+	shares := make([]float64, len(columns))
+	stalls := 0
+	for i, total := range totals {
+		if total == 0 {
+			stalls++
+		}
+		shares[i] = float64(total) / float64(maxTotal)
+	}
+	fmt.Fprintln(out, heatmapLine("IOs", shares))
+
+	fmt.Fprintf(out, "%*s  0s%*s\n", heatmapLabelWidth, "", len(columns)-2, (time.Duration(end) * time.Millisecond).Round(time.Second))
+	if stalls > 0 {
+		fmt.Fprintf(out, "%d of %d intervals completed no IOs\n", stalls, len(columns))
+	}
+	fmt.Fprintln(out)
+}
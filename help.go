@@ -0,0 +1,305 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// subcommandSummaries describe the subcommands in the usage message
+var subcommandSummaries = map[string]string{
+	"calibrate":       "measure the host ceiling on a null_blk device",
+	"campaign":        "show the status of a burn-in campaign",
+	"completion":      "print the bash, zsh or fish completion script",
+	"convert":         "convert results between JSON, protobuf, XLSX and PDF",
+	"diff":            "compare one test or the device groups of two results files",
+	"help":            "show the options of a subcommand or the fields of the test cases",
+	"nvme-namespaces": "list the namespaces of an NVMe controller",
+	"prune":           "trim the history store and the artifact bundles",
+	"report":          "render a results file with a Go template",
+	"results":         "query the results in the history store",
+	"schema":          "print the JSON schema of test cases, suites or results",
+	"sign":            "approve a results file",
+	"trend":           "aggregate the results in the history store over time",
+	"validate":        "check files against their JSON schema",
+}
+
+// usage prints the usage of the tool for -h and --help
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "Usage: fio-qa [options]")
+	fmt.Fprintln(w, "       fio-qa <subcommand> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Runs the tests of fio-testcases.json and saves the results to test_results-<timestamp>.json.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Subcommands:")
+	for _, name := range strings.Split(subcommandNames(), ", ") {
+		fmt.Fprintf(w, "  %-17s %s\n", name, subcommandSummaries[name])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Options:")
+	flag.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "fio-qa help <subcommand>" for the options of a subcommand, "fio-qa help config"`)
+	fmt.Fprintln(w, `for the fields of the test cases and "fio-qa completion bash|zsh|fish" for shell completion.`)
+}
+
+// runHelp shows the usage of the tool, the options of a subcommand or the
+// fields of the test cases
+func runHelp(args []string) int {
+	switch {
+	case len(args) == 0:
+		defineOptions()
+		flag.CommandLine.SetOutput(out)
+		usage()
+		return exitOK
+	case args[0] == "config":
+		return helpConfig(args[1:])
+	case args[0] == "help":
+		return usageError("usage: fio-qa help [subcommand | config [field]]")
+	case subcommands[args[0]] != nil:
+		subcommands[args[0]](append(args[1:], "-h"))
+		return exitOK
+	}
+	return usageError("unknown help topic %q, available: config, %s", args[0], subcommandNames())
+}
+
+// configField describes a field of the test case configuration
+type configField struct {
+	Path        string
+	Type        string
+	Description string
+}
+
+// configFields lists the fields of a struct of the test case configuration
+// with their JSON paths, descending into nested objects and lists of
+// objects. Fields fio-qa sets itself are left out.
+func configFields(prefix string, t reflect.Type, fields *[]configField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || name == "" {
+			continue
+		}
+		path := prefix + name
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		*fields = append(*fields, configField{
+			Path:        path,
+			Type:        configFieldType(ft),
+			Description: configFieldHelp[t.Name()+"."+name],
+		})
+		switch {
+		case ft.Kind() == reflect.Struct && ft.Name() != "Time":
+			configFields(path+".", ft, fields)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			configFields(path+"[].", ft.Elem(), fields)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Pointer:
+			configFields(path+"[].", ft.Elem().Elem(), fields)
+		}
+	}
+}
+
+// configFieldType names the JSON type of a field
+func configFieldType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Map:
+		return "object of " + configFieldType(t.Elem()) + "s"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// json.RawMessage
+			return "any"
+		}
+		return "list of " + configFieldType(t.Elem()) + "s"
+	case reflect.Pointer:
+		return configFieldType(t.Elem())
+	}
+	return "object"
+}
+
+// helpConfig lists the fields of a test case, all of them or those under a
+// field
+func helpConfig(args []string) int {
+	if len(args) > 1 {
+		return usageError("usage: fio-qa help config [field]")
+	}
+	var fields []configField
+	configFields("", reflect.TypeOf(FioTest{}), &fields)
+	if len(args) == 1 {
+		var selected []configField
+		for _, field := range fields {
+			if field.Path == args[0] || strings.HasPrefix(field.Path, args[0]+".") || strings.HasPrefix(field.Path, args[0]+"[].") {
+				selected = append(selected, field)
+			}
+		}
+		if len(selected) == 0 {
+			return usageError("test cases have no field %q", args[0])
+		}
+		fields = selected
+	}
+
+	fmt.Fprintln(out, "Fields of the tests in fio-testcases.json, see also \"fio-qa schema testcases\"")
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Field", "Type", "Description"})
+	configureTable(table, 3)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	for _, field := range fields {
+		table.Append([]string{field.Path, field.Type, field.Description})
+	}
+	table.Render()
+	return exitOK
+}
+
+// configFieldHelp describes the fields of the test case configuration by
+// the type that holds them and their JSON name, so types used in several
+// places are described once
+var configFieldHelp = map[string]string{
+	"FioTest.name":              "name of the test, also the fio job name",
+	"FioTest.description":       "description shown with the results",
+	"FioTest.suite":             "suite of the test, set by suite manifests",
+	"FioTest.template":          "workload template the test starts from, its own fields override the template",
+	"FioTest.filename":          "file or block device to test",
+	"FioTest.size":              "size of the IO region, like 10G",
+	"FioTest.directory":         "directory fio creates the files of the test in",
+	"FioTest.nrfiles":           "number of files per job, laid out before the test runs",
+	"FioTest.filesize":          "size of every file of a many-file dataset",
+	"FioTest.openfiles":         "files kept open at the same time",
+	"FioTest.file_service_type": "how fio picks the file of every IO: roundrobin, sequential, random",
+	"FioTest.offset":            "start of the IO region in the target",
+	"FioTest.offset_increment":  "moves the region of every clone of a job with numjobs by this much",
+	"FioTest.blockalign":        "boundary the random offsets are aligned to, raised to the logical sector size for direct IO",
+	"FioTest.atomic":            "issue the writes as atomic writes, needs direct=1 and a device supporting them",
+	"FioTest.iomem":             "memory backend of the IO buffers: malloc, shm, shmhuge, mmap, mmaphuge:file, mmapshared or cudamalloc",
+	"FioTest.mem":               "alias of iomem, as in fio",
+	"FioTest.polled_comparison": "run the test with interrupt and with polled completion (hipri) and compare the latency",
+	"FioTest.lockmem":           "memory fio locks to limit what the page cache can use, like 1G",
+	"FioTest.region":            "part of the target to test, instead of offset",
+	"FioTest.nvme_controller":   "run the test on every namespace of this NVMe controller, like nvme0",
+	"FioTest.nvme_nsids":        "namespace IDs of nvme_controller to test (default: all)",
+	"FioTest.noisy_neighbor":    "run a victim job against an aggressor job at increasing caps",
+	"FioTest.direct":            "1 bypasses the page cache",
+	"FioTest.rw":                "IO pattern: read, write, randread, randwrite, rw, randrw",
+	"FioTest.rwmixread":         "percentage of reads of mixed patterns",
+	"FioTest.rwmixwrite":        "percentage of writes of mixed patterns, instead of rwmixread",
+	"FioTest.bs":                "block size, like 4k",
+	"FioTest.ioengine":          "fio IO engine, like libaio, io_uring or the metadata engines filecreate, filestat, filedelete",
+	"FioTest.iodepth":           "IOs in flight per job",
+	"FioTest.numjobs":           "parallel jobs",
+	"FioTest.time_based":        "run for runtime even when the region was covered",
+	"FioTest.group_reporting":   "report the jobs of the test together",
+	"FioTest.runtime":           "runtime in seconds",
+	"FioTest.expected_duration": "how long fio should run, like 60s, a test finishing in less than half of it is suspect",
+	"FioTest.eta_newline":       "interval of the live progress in seconds",
+	"FioTest.read_iolog":        "replay this fio iolog or blktrace instead of the pattern",
+	"FioTest.replay_redirect":   "device the replayed IOs are sent to",
+	"FioTest.replay_no_stall":   "replay as fast as possible instead of with the recorded timing",
+	"FioTest.capture_iolog":     "capture a fio iolog of the test into the artifact bundle",
+	"FioTest.hist_log":          "log latency histograms and build a latency heatmap",
+	"FioTest.write_cliff":       "look for the SLC cache write cliff in the bandwidth log",
+	"FioTest.score":             "reference value of the test in the suite score",
+	"FioTest.blktrace":          "trace the block layer of the target",
+	"FioTest.ebpf":              "attribute latency to the block layer and the device",
+	"FioTest.cpu_profile":       "sample the host CPU use during the test",
+	"FioTest.interrupts":        "count the interrupts of the target during the test",
+	"FioTest.cooldown_seconds":  "pause before the next test, overrides --cooldown-seconds",
+	"FioTest.cooldown_temp_c":   "also wait until the device is at most this warm, overrides --cooldown-temp",
+	"FioTest.reset_device":      "erase the target before the test: nvme-format, nvme-sanitize, blkdiscard, secure-erase",
+	"FioTest.fill_levels":       "run the test once per fill level of the target in percent",
+	"FioTest.backend":           "IO generator: fio (default), dd, ioping or command",
+	"FioTest.backend_command":   "command run by the command backend",
+	"FioTest.endurance":         "run the test for a long time with periodic checkpoints",
+	"FioTest.retention":         "write, wait or read and verify the target for a retention check",
+	"FioTest.slo":               "latency objective of the test, like 99.9% under 2ms",
+	"FioTest.percentiles":       "completion latency percentiles to report, overrides --percentiles",
+	"FioTest.json_plus":         "use fio's json+ output with the full latency histograms",
+	"FioTest.comparators":       "pass criteria run on the result",
+	"FioTest.baseline":          "fail when a metric is worse than the history of the test",
+	"FioTest.fault":             "run on a device-mapper target injecting latency or errors",
+	"FioTest.tuning":            "block queue parameters set for the test, like scheduler",
+	"FioTest.job_file":          "run fio from a generated job file instead of command line options",
+	"FioTest.jobs":              "job sections run together as one test",
+
+	"RegionConfig.zone":          "named zone: outer, middle or inner",
+	"RegionConfig.start_percent": "start of the region in percent of the capacity",
+	"RegionConfig.end_percent":   "end of the region in percent of the capacity",
+	"RegionConfig.start_lba":     "first logical block of the region",
+	"RegionConfig.end_lba":       "logical block after the region",
+
+	"NoisyNeighborConfig.victim":         "job whose latency is measured",
+	"NoisyNeighborConfig.aggressor":      "job competing with the victim",
+	"NoisyNeighborConfig.aggressor_mbps": "bandwidth caps of the aggressor, one stage each",
+	"NoisyNeighborConfig.unlimited":      "add a last stage with an uncapped aggressor",
+
+	"FioJobSection.name":             "name of the job",
+	"FioJobSection.rw":               "IO pattern of the job",
+	"FioJobSection.rwmixread":        "percentage of reads of mixed patterns",
+	"FioJobSection.rwmixwrite":       "percentage of writes of mixed patterns, instead of rwmixread",
+	"FioJobSection.bs":               "block size of the job",
+	"FioJobSection.iodepth":          "IOs in flight per job",
+	"FioJobSection.numjobs":          "clones of the job",
+	"FioJobSection.offset":           "start of the region of the job",
+	"FioJobSection.offset_increment": "moves the region of every clone by this much",
+	"FioJobSection.size":             "size of the region of the job",
+	"FioJobSection.rate_iops":        "IOPS cap of the job",
+	"FioJobSection.rate":             "bandwidth cap of the job, like 500m",
+	"FioJobSection.flow":             "weight of the job in fio's flow control",
+
+	"ScoreConfig.weight":    "weight of the test in the suite score (default 1)",
+	"ScoreConfig.metric":    "scored metric: iops, bandwidth or latency (default: by the workload)",
+	"ScoreConfig.reference": "value scoring 100",
+
+	"EnduranceConfig.duration":            "total duration, like 168h",
+	"EnduranceConfig.checkpoint_interval": "time between checkpoints, like 1h",
+	"EnduranceConfig.keep_checkpoints":    "checkpoints whose artifacts are kept",
+
+	"RetentionConfig.wait":          "time between writing and verifying, like 24h",
+	"RetentionConfig.read_pressure": "read the target during the wait, for read disturb",
+	"RetentionConfig.verify":        "fio verify method (default crc32c)",
+
+	"SLOConfig.percent": "percentile of the objective, like 99.9",
+	"SLOConfig.latency": "latency limit, like 2ms",
+
+	"ComparatorConfig.name":   "comparator, like fleet_median",
+	"ComparatorConfig.params": "parameters of the comparator",
+
+	"BaselineConfig.metric":        "judged metric: iops, bw, lat or p99",
+	"BaselineConfig.runs":          "passed runs the baseline is built from (default 20)",
+	"BaselineConfig.sigma":         "tolerated standard deviations (default 3)",
+	"BaselineConfig.min_runs":      "runs needed before the metric is judged (default 5)",
+	"BaselineConfig.approved_only": "build the baseline from signed off runs only",
+
+	"FaultConfig.target":         "device-mapper target: delay, flakey or dust",
+	"FaultConfig.read_delay_ms":  "delay of every read (delay)",
+	"FaultConfig.write_delay_ms": "delay of every write (delay)",
+	"FaultConfig.up_seconds":     "seconds IO passes through (flakey)",
+	"FaultConfig.down_seconds":   "seconds IO fails (flakey)",
+	"FaultConfig.features":       "error_reads, error_writes or drop_writes (flakey)",
+	"FaultConfig.bad_blocks":     "blocks whose reads fail until written (dust)",
+	"FaultConfig.block_size":     "block size of bad_blocks (dust, default 512)",
+}
+
+// configFieldPaths returns the JSON paths of all test case fields, sorted
+func configFieldPaths() []string {
+	var fields []configField
+	configFields("", reflect.TypeOf(FioTest{}), &fields)
+	paths := make([]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, field.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// HighlightConfig defines a highlight: the test with the highest or lowest
+// value of a metric among the passed tests, optionally only among the tests
+// whose names match a pattern and whose configuration has the given values
+type HighlightConfig struct {
+	Label     string `json:"label"`
+	Metric    string `json:"metric"`
+	Direction string `json:"direction"`
+	// Tests is a path.Match pattern of test names, like "qd1_*"
+	Tests string `json:"tests,omitempty"`
+	// Where selects tests by configuration fields, like {"iodepth": 1}
+	Where map[string]interface{} `json:"where,omitempty"`
+}
+
+// Directions of highlights
+const (
+	highlightHighest = "highest"
+	highlightLowest  = "lowest"
+)
+
+// highlightMetric is a metric highlights can rank tests by
+type highlightMetric struct {
+	value  func(TestResult) float64
+	format func(float64) string
+	unit   string
+}
+
+// highlightMetrics are the metrics of highlights by name
+var highlightMetrics = map[string]highlightMetric{
+	"iops":       {func(r TestResult) float64 { return r.TotalIOPS }, formatCount, "iops"},
+	"read_iops":  {func(r TestResult) float64 { return r.ReadIOPS }, formatCount, "iops"},
+	"write_iops": {func(r TestResult) float64 { return r.WriteIOPS }, formatCount, "iops"},
+	"bw":         {func(r TestResult) float64 { return r.TotalBWMBps }, formatMBps, "MB/s"},
+	"lat":        {func(r TestResult) float64 { return r.AvgLatencyUs }, formatLatency, "μs"},
+	"p99":        {p99LatencyUs, formatLatency, "μs"},
+	"cv":         {iopsCV, func(v float64) string { return fmt.Sprintf("%.1f%%", v) }, "%"},
+}
+
+func formatMBps(v float64) string {
+	return fmt.Sprintf("%.2f MB/s", v)
+}
+
+func formatLatency(v float64) string {
+	return fmt.Sprintf("%.2f %s", v, usUnit())
+}
+
+// The worst performers among the default highlights, also kept in their
+// own fields of the JSON results
+var (
+	lowestIOPSHighlight = HighlightConfig{Label: "Lowest IOPS", Metric: "iops", Direction: highlightLowest}
+	highestP99Highlight = HighlightConfig{Label: "Highest p99 Latency", Metric: "p99", Direction: highlightHighest}
+	highestCVHighlight  = HighlightConfig{Label: "Highest IOPS CV", Metric: "cv", Direction: highlightHighest}
+)
+
+// defaultHighlights are the highlights of test cases without their own set:
+// the best and the worst performers
+var defaultHighlights = []HighlightConfig{
+	{Label: "Highest IOPS", Metric: "iops", Direction: highlightHighest},
+	{Label: "Highest Bandwidth", Metric: "bw", Direction: highlightHighest},
+	{Label: "Lowest Latency", Metric: "lat", Direction: highlightLowest},
+	lowestIOPSHighlight,
+	highestP99Highlight,
+	highestCVHighlight,
+}
+
+// highlights are the highlights of the run, the test cases' own or the
+// defaults
+var highlights = defaultHighlights
+
+// checkHighlights validates the highlights of test cases before any test
+// runs
+func checkHighlights(configs []HighlightConfig) error {
+	labels := map[string]bool{}
+	for _, config := range configs {
+		if config.Label == "" {
+			return fmt.Errorf("highlights need a label")
+		}
+		if labels[config.Label] {
+			return fmt.Errorf("highlight %q is defined more than once", config.Label)
+		}
+		labels[config.Label] = true
+		if _, ok := highlightMetrics[config.Metric]; !ok {
+			return fmt.Errorf("highlight %q: unknown metric %q, available: iops, read_iops, write_iops, bw, lat, p99, cv", config.Label, config.Metric)
+		}
+		if config.Direction != highlightHighest && config.Direction != highlightLowest {
+			return fmt.Errorf("highlight %q: direction must be highest or lowest", config.Label)
+		}
+		if _, err := path.Match(config.Tests, ""); err != nil {
+			return fmt.Errorf("highlight %q: invalid tests pattern: %v", config.Label, err)
+		}
+	}
+	return nil
+}
+
+// matches tells whether a result is a candidate of the highlight
+func (c HighlightConfig) matches(r TestResult) bool {
+	if c.Tests != "" {
+		if ok, _ := path.Match(c.Tests, r.TestName); !ok {
+			return false
+		}
+	}
+	if len(c.Where) == 0 {
+		return true
+	}
+	data, _ := json.Marshal(r.Config)
+	var config map[string]interface{}
+	json.Unmarshal(data, &config)
+	for field, want := range c.Where {
+		if fmt.Sprint(config[field]) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// findHighlight finds the test of a highlight among the passed tests that
+// have its metric, nil without one. File operations are left out, they are
+// not comparable with IO.
+func findHighlight(config HighlightConfig, results []TestResult) *JSONHighlight {
+	metric := highlightMetrics[config.Metric]
+	var found *JSONHighlight
+	for _, r := range results {
+		if r.Status != "PASSED" || r.Metadata != nil || !config.matches(r) {
+			continue
+		}
+		value := metric.value(r)
+		if value <= 0 {
+			continue
+		}
+		if found == nil || config.Direction == highlightHighest && value > found.Value || config.Direction == highlightLowest && value < found.Value {
+			found = &JSONHighlight{Label: config.Label, TestName: r.TestName, Value: value, Unit: metric.unit}
+		}
+	}
+	return found
+}
+
+// evaluateHighlights returns the highlights of the run that have a test
+func evaluateHighlights(configs []HighlightConfig, results []TestResult) []JSONHighlight {
+	var found []JSONHighlight
+	for _, config := range configs {
+		if highlight := findHighlight(config, results); highlight != nil {
+			found = append(found, *highlight)
+		}
+	}
+	return found
+}
+
+// worstHighlights adds the worst performers, the problem tests and the
+// highlights of the run to the highlights of the JSON results
+func worstHighlights(h *JSONPerformanceHighlights, results []TestResult) {
+	h.LowestIOPS = findHighlight(lowestIOPSHighlight, results)
+	h.HighestP99Latency = findHighlight(highestP99Highlight, results)
+	h.HighestCV = findHighlight(highestCVHighlight, results)
+	h.ProblemTests = problemTests(results)
+	h.Highlights = evaluateHighlights(highlights, results)
+}
+
+// Statuses of the tests listed among the problem tests
+const (
+	problemFailed   = "FAILED"
+	problemUnstable = "UNSTABLE"
+	problemSuspect  = "SUSPECT"
+)
+
+// JSONProblemTest is a failed test, or a passed test whose measurement is
+// questionable, with the reason
+type JSONProblemTest struct {
+	TestName string `json:"test_name"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+}
+
+// problemTests lists the failed tests with their error, the suspect ones
+// that finished far sooner than expected and the unstable ones, passed tests
+// with warnings, with their first warning
+func problemTests(results []TestResult) []JSONProblemTest {
+	var problems []JSONProblemTest
+	for _, r := range results {
+		if r.Status != "PASSED" {
+			reason := "failed"
+			if r.Error != nil {
+				reason = r.Error.Error()
+			}
+			problems = append(problems, JSONProblemTest{TestName: r.TestName, Status: problemFailed, Reason: reason})
+			continue
+		}
+		if r.Suspect != nil {
+			reason := fmt.Sprintf("ran for %.1fs of the expected %.0fs, %s", r.Suspect.ActualSeconds, r.Suspect.ExpectedSeconds, r.Suspect.Reason)
+			problems = append(problems, JSONProblemTest{TestName: r.TestName, Status: problemSuspect, Reason: reason})
+			continue
+		}
+		var warnings []Warning
+		for _, warning := range r.Warnings {
+			if warning.Severity == severityWarning {
+				warnings = append(warnings, warning)
+			}
+		}
+		if len(warnings) > 0 {
+			reason := warnings[0].Message
+			if len(warnings) > 1 {
+				reason += fmt.Sprintf(" (+%d more)", len(warnings)-1)
+			}
+			problems = append(problems, JSONProblemTest{TestName: r.TestName, Status: problemUnstable, Reason: reason})
+		}
+	}
+	return problems
+}
+
+// displayHighlights shows the highlights of the run, test names cut to the
+// width of their column
+func displayHighlights(results []TestResult) {
+	nameWidth := columnWidths(3)[1]
+	highlightsTable := tablewriter.NewWriter(out)
+	highlightsTable.SetHeader([]string{"Category", "Test", "Value"})
+	configureTable(highlightsTable, 3)
+	highlightsTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	setHeaderColor(highlightsTable, 3, tablewriter.FgMagentaColor)
+	for _, config := range highlights {
+		highlight := findHighlight(config, results)
+		if highlight == nil {
+			continue
+		}
+		testName := highlight.TestName
+		if len(testName) > nameWidth {
+			testName = testName[:nameWidth]
+		}
+		highlightsTable.Append([]string{config.Label, testName, highlightMetrics[config.Metric].format(highlight.Value)})
+	}
+	highlightsTable.Render()
+}
+
+// displayProblemTests lists the failed and unstable tests below the
+// highlights, so they are not hidden behind the best results
+func displayProblemTests(results []TestResult) {
+	problems := problemTests(results)
+	if len(problems) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Failed and Unstable Tests")
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Test", "Status", "Reason"})
+	configureTable(table, 3)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	for _, problem := range problems {
+		table.Append([]string{problem.TestName, problem.Status, strings.TrimSpace(problem.Reason)})
+	}
+	table.Render()
+}
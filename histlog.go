@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fio-qa/stats"
+)
+
+// LatencyHeatmap counts the completed IOs per log interval (rows) and
+// completion latency bucket (columns). Bucket i holds the latencies up to
+// BucketsUs[i] microseconds, the first bucket everything below 1μs.
+type LatencyHeatmap struct {
+	IntervalMs int64        `json:"interval_ms"`
+	BucketsUs  []float64    `json:"buckets_us"`
+	Rows       []HeatmapRow `json:"rows"`
+}
+
+// HeatmapRow is one interval of the heatmap, by its end in ms since the
+// start of the test
+type HeatmapRow struct {
+	TimeMs int64   `json:"time_ms"`
+	Counts []int64 `json:"counts"`
+}
+
+// heatmapBucket returns the log2 bucket of a latency in ns
+func heatmapBucket(ns float64) int {
+	us := ns / 1000
+	if us < 1 {
+		return 0
+	}
+	return int(math.Log2(us)) + 1
+}
+
+// histLogFiles returns the histogram logs fio wrote for the prefix, one per
+// job unless the jobs are reported as a group
+func histLogFiles(prefix string) []string {
+	matches, _ := filepath.Glob(prefix + "_clat_hist.*.log")
+	sort.Strings(matches)
+	return matches
+}
+
+// parseHistLogs merges the histogram logs of all jobs into a heatmap and the
+// total fio bins of reads and writes. Each log line holds the bins of the IOs
+// of one direction completed in one interval:
+// "time_ms, direction, block_size, bin0, bin1, ...".
+func parseHistLogs(files []string, intervalMs int64) (*LatencyHeatmap, [2][]int64, error) {
+	rows := map[int64][]int64{}
+	buckets := 0
+	var bins [2][]int64
+
+	for _, file := range files {
+		f, err := openArtifact(file)
+		if err != nil {
+			return nil, bins, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			fields := strings.Split(scanner.Text(), ",")
+			if len(fields) < 4 {
+				continue
+			}
+			timeMs, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+			if err != nil {
+				f.Close()
+				return nil, bins, fmt.Errorf("%s:%d: invalid time %q", file, line, fields[0])
+			}
+			// Intervals of the jobs are aligned to the log interval
+			slot := (timeMs + intervalMs/2) / intervalMs * intervalMs
+			direction, _ := strconv.Atoi(strings.TrimSpace(fields[1]))
+
+			for i, field := range fields[3:] {
+				count, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+				if err != nil || count == 0 {
+					continue
+				}
+				if direction == 0 || direction == 1 {
+					for len(bins[direction]) <= i {
+						bins[direction] = append(bins[direction], 0)
+					}
+					bins[direction][i] += count
+				}
+				bucket := heatmapBucket(stats.FioBinValue(i))
+				for len(rows[slot]) <= bucket {
+					rows[slot] = append(rows[slot], 0)
+				}
+				rows[slot][bucket] += count
+				if bucket+1 > buckets {
+					buckets = bucket + 1
+				}
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, bins, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, bins, fmt.Errorf("histogram logs contain no samples")
+	}
+
+	heatmap := &LatencyHeatmap{IntervalMs: intervalMs}
+	for i := 0; i < buckets; i++ {
+		heatmap.BucketsUs = append(heatmap.BucketsUs, math.Pow(2, float64(i)))
+	}
+	var times []int64
+	for t := range rows {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	for _, t := range times {
+		counts := make([]int64, buckets)
+		copy(counts, rows[t])
+		heatmap.Rows = append(heatmap.Rows, HeatmapRow{TimeMs: t, Counts: counts})
+	}
+	return heatmap, bins, nil
+}
+
+// collectHistLogs builds the heatmap of a test from its histogram logs and
+// keeps the logs compressed in the artifact bundle
+func collectHistLogs(prefix string, result *TestResult) {
+	files := histLogFiles(prefix)
+	if len(files) == 0 {
+		result.warn(severityWarning, "monitor", "fio wrote no histogram logs")
+		return
+	}
+
+	heatmap, bins, err := parseHistLogs(files, int64(opts.HistLogMsec))
+	if err != nil {
+		result.warn(severityWarning, "monitor", "latency heatmap unavailable: %v", err)
+	} else {
+		result.Heatmap = heatmap
+		result.HistBins = bins
+	}
+
+	for _, file := range files {
+		compressed, err := compressFile(file)
+		if err != nil {
+			result.warn(severityWarning, "monitor", "cannot compress %s: %v", file, err)
+			result.Artifacts = append(result.Artifacts, file)
+			continue
+		}
+		result.Artifacts = append(result.Artifacts, compressed)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// HistoryRecord is one line of the history store, an append-only NDJSON file
+// collecting the results of all runs and the checkpoints of endurance tests
+type HistoryRecord struct {
+	Kind            string            `json:"kind"`
+	Time            time.Time         `json:"time"`
+	Run             string            `json:"run"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Test            string            `json:"test"`
+	Suite           string            `json:"suite,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	WallSeconds     float64           `json:"wall_seconds,omitempty"`
+	IOPS            float64           `json:"iops"`
+	BWMBps          float64           `json:"bw_mbps"`
+	LatencyUs       float64           `json:"avg_latency_us"`
+	P99LatencyUs    float64           `json:"p99_latency_us"`
+	Device          *DeviceMetadata   `json:"device,omitempty"`
+	Checkpoint      int               `json:"checkpoint,omitempty"`
+	ElapsedSeconds  float64           `json:"elapsed_seconds,omitempty"`
+	SMART           *SMARTData        `json:"smart,omitempty"`
+	Approver        string            `json:"approver,omitempty"`
+}
+
+// History record kinds
+const (
+	historyResult     = "result"
+	historyCheckpoint = "checkpoint"
+)
+
+// newHistoryRecord describes a test result for the history store
+func newHistoryRecord(kind string, run RunInfo, result TestResult) HistoryRecord {
+	return HistoryRecord{
+		Kind:            kind,
+		Time:            time.Now(),
+		Run:             run.ID,
+		Namespace:       run.Namespace,
+		Test:            result.TestName,
+		Suite:           result.Config.Suite,
+		Labels:          run.Labels,
+		Status:          result.Status,
+		DurationSeconds: result.Duration.Seconds(),
+		WallSeconds:     result.WallTime.Seconds(),
+		IOPS:            result.TotalIOPS,
+		BWMBps:          result.TotalBWMBps,
+		LatencyUs:       result.AvgLatencyUs,
+		P99LatencyUs:    p99LatencyUs(result),
+		Device:          result.Device,
+	}
+}
+
+// historyLockWait is how long a writer of the history store waits for
+// another one to finish
+const historyLockWait = 30 * time.Second
+
+// lockHistory takes the lock writers of the history store hold while they
+// append to it or rewrite it. The lock is a file next to the store, since
+// pruning replaces the store itself.
+func lockHistory(path string) (*os.File, error) {
+	deadline := time.Now().Add(historyLockWait)
+	for {
+		lock, err := lockFile(path + ".lock")
+		if err == nil {
+			return lock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("history store %s: %v", path, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// appendHistory adds records to the history store set with --history
+func appendHistory(records ...HistoryRecord) error {
+	if opts.History == "" {
+		return nil
+	}
+	lock, err := lockHistory(opts.History)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+	f, err := os.OpenFile(opts.History, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := bufio.NewWriter(f)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		writer.Write(append(data, '\n'))
+	}
+	return writer.Flush()
+}
+
+// HistoryFilter selects records of the history store. Zero fields match
+// everything, except for Namespace when SameNamespace is set: the records of
+// a run are only compared with the records of its own namespace, including
+// the default one.
+type HistoryFilter struct {
+	Since         time.Time
+	Until         time.Time
+	Test          string
+	Kind          string
+	Namespace     string
+	SameNamespace bool
+	// Runs limits the records to these runs when set
+	Runs map[string]bool
+}
+
+func (f HistoryFilter) match(record HistoryRecord) bool {
+	return (f.Until.IsZero() || record.Time.Before(f.Until)) &&
+		(f.Test == "" || record.Test == f.Test) &&
+		(f.Runs == nil || f.Runs[record.Run]) &&
+		(f.Kind == "" || record.Kind == f.Kind) &&
+		(f.Namespace == "" && !f.SameNamespace || record.Namespace == f.Namespace)
+}
+
+// scanHistory calls fn for every record matching the filter, reading the
+// history store line by line so that it never has to fit in memory. Records
+// are appended in time order, so the start of the Since window is found by
+// a binary search over the file instead of reading everything before it.
+func scanHistory(path string, filter HistoryFilter, fn func(HistoryRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var offset int64
+	if !filter.Since.IsZero() {
+		if offset, err = seekHistory(f, filter.Since); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%s: invalid record at offset %d: %v", path, offset, err)
+		}
+		offset += int64(len(scanner.Bytes())) + 1
+		if !filter.Until.IsZero() && !record.Time.Before(filter.Until) {
+			break
+		}
+		if record.Time.Before(filter.Since) || !filter.match(record) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// seekHistory returns the offset of the first record at or after since
+func seekHistory(f *os.File, since time.Time) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, recordTime, err := historyLineAt(f, mid)
+		if err == io.EOF || (err == nil && !recordTime.Before(since)) {
+			hi = mid
+		} else if err != nil {
+			return 0, err
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	start, _, err := historyLineAt(f, lo)
+	if err == io.EOF {
+		return info.Size(), nil
+	}
+	return start, err
+}
+
+// historyLineAt returns the offset and time of the first record starting at
+// or after offset
+func historyLineAt(f *os.File, offset int64) (int64, time.Time, error) {
+	start := offset
+	if offset > 0 {
+		// Unless offset is the start of a line, skip to the next one
+		start = offset - 1
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return 0, time.Time{}, err
+	}
+	reader := bufio.NewReader(f)
+	if offset > 0 {
+		skipped, err := reader.ReadBytes('\n')
+		if err != nil {
+			return 0, time.Time{}, io.EOF
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		return 0, time.Time{}, io.EOF
+	}
+	var record struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid record at offset %d: %v", start, err)
+	}
+	return start, record.Time, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPruneHistoryLocked appends a record while the history store is being
+// pruned and expects the append to wait for the rewrite instead of landing
+// in the replaced store
+func TestPruneHistoryLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.ndjson")
+	if err := os.WriteFile(path, []byte(`{"run":"old","test":"a"}`+"\n"+`{"run":"kept","test":"a"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(history string) { opts.History = history }(opts.History)
+	opts.History = path
+
+	// Hold the lock like a prune scanning the store
+	lock, err := lockHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appended := make(chan error)
+	go func() { appended <- appendHistory(HistoryRecord{Run: "new", Test: "a"}) }()
+	select {
+	case err := <-appended:
+		t.Fatalf("append did not wait for the lock: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	unlockFile(lock)
+	if err := <-appended; err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := pruneHistory(path, map[string]bool{"old": true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || strings.Contains(string(data), `"old"`) || !strings.Contains(string(data), `"kept"`) || !strings.Contains(string(data), `"new"`) {
+		t.Errorf("pruned %d records leaving %q, want the old run pruned and the kept and new ones left", removed, data)
+	}
+}
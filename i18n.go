@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// reportCatalogs translate the labels of the generated reports (PDF, XLSX
+// and report templates), keyed by the English label. Labels missing from a
+// catalog stay in English. The terminal output and the JSON results are not
+// translated.
+var reportCatalogs = map[string]map[string]string{
+	"de": {
+		"Storage Qualification Report":        "Speicher-Qualifizierungsbericht",
+		"Generated":                           "Erstellt",
+		"Host":                                "Host",
+		"Run ID":                              "Lauf-ID",
+		"Namespace":                           "Namensraum",
+		"Suite":                               "Suite",
+		"Tests":                               "Tests",
+		"%d (%d passed, %d failed)":           "%d (%d bestanden, %d fehlgeschlagen)",
+		"Total Duration":                      "Gesamtdauer",
+		"Score":                               "Bewertung",
+		"Grade":                               "Note",
+		"Device":                              "Gerät",
+		"Summary":                             "Zusammenfassung",
+		"Test":                                "Test",
+		"Description":                         "Beschreibung",
+		"Status":                              "Status",
+		"Duration":                            "Dauer",
+		"Lat Avg (us)":                        "Lat. Mittel (us)",
+		"IOPS per Test":                       "IOPS pro Test",
+		"Average Latency per Test":            "Mittlere Latenz pro Test",
+		"Metric":                              "Kennzahl",
+		"Read":                                "Lesen",
+		"Write":                               "Schreiben",
+		"Total":                               "Gesamt",
+		"Bandwidth (MB/s)":                    "Bandbreite (MB/s)",
+		"Completion Latency Avg (us)":         "Abschlusslatenz Mittel (us)",
+		"Completion Latency Max (us)":         "Abschlusslatenz Max. (us)",
+		"CPU User / System":                   "CPU Benutzer / System",
+		"Error":                               "Fehler",
+		"Read Completion Latency Percentiles": "Perzentile der Lese-Abschlusslatenz",
+		"Warnings":                            "Warnungen",
+		"Page %d of %d":                       "Seite %d von %d",
+		"PASSED":                              "BESTANDEN",
+		"FAILED":                              "FEHLGESCHLAGEN",
+		"Field":                               "Feld",
+		"Value":                               "Wert",
+		"Total Tests":                         "Tests gesamt",
+		"Passed":                              "Bestanden",
+		"Failed":                              "Fehlgeschlagen",
+		"Highest IOPS":                        "Höchste IOPS",
+		"Highest Bandwidth":                   "Höchste Bandbreite",
+		"Lowest Latency":                      "Niedrigste Latenz",
+		"Percentiles":                         "Perzentile",
+		"Config":                              "Konfiguration",
+		"Option":                              "Option",
+		"fio command":                         "fio-Befehl",
+		"Read IOPS":                           "IOPS Lesen",
+		"Read IOPS Min":                       "IOPS Lesen Min.",
+		"Read IOPS Max":                       "IOPS Lesen Max.",
+		"Read IOPS StdDev":                    "IOPS Lesen Std.-Abw.",
+		"Write IOPS":                          "IOPS Schreiben",
+		"Write IOPS Min":                      "IOPS Schreiben Min.",
+		"Write IOPS Max":                      "IOPS Schreiben Max.",
+		"Write IOPS StdDev":                   "IOPS Schreiben Std.-Abw.",
+		"Read MB/s":                           "MB/s Lesen",
+		"Write MB/s":                          "MB/s Schreiben",
+		"Read slat Avg (us)":                  "slat Lesen Mittel (us)",
+		"Read clat Avg (us)":                  "clat Lesen Mittel (us)",
+		"Read clat Max (us)":                  "clat Lesen Max. (us)",
+		"Read clat StdDev (us)":               "clat Lesen Std.-Abw. (us)",
+		"Read lat Avg (us)":                   "lat Lesen Mittel (us)",
+		"Write slat Avg (us)":                 "slat Schreiben Mittel (us)",
+		"Write clat Avg (us)":                 "clat Schreiben Mittel (us)",
+		"Write clat Max (us)":                 "clat Schreiben Max. (us)",
+		"Write clat StdDev (us)":              "clat Schreiben Std.-Abw. (us)",
+		"Write lat Avg (us)":                  "lat Schreiben Mittel (us)",
+		"User CPU %":                          "CPU Benutzer %",
+		"System CPU %":                        "CPU System %",
+		"Context Switches":                    "Kontextwechsel",
+	},
+	"ro": {
+		"Storage Qualification Report":        "Raport de calificare a stocării",
+		"Generated":                           "Generat",
+		"Host":                                "Gazdă",
+		"Run ID":                              "ID rulare",
+		"Namespace":                           "Spațiu de nume",
+		"Suite":                               "Suită",
+		"Tests":                               "Teste",
+		"%d (%d passed, %d failed)":           "%d (%d trecute, %d eșuate)",
+		"Total Duration":                      "Durată totală",
+		"Score":                               "Scor",
+		"Grade":                               "Calificativ",
+		"Device":                              "Dispozitiv",
+		"Summary":                             "Rezumat",
+		"Test":                                "Test",
+		"Description":                         "Descriere",
+		"Status":                              "Stare",
+		"Duration":                            "Durată",
+		"Lat Avg (us)":                        "Lat. medie (us)",
+		"IOPS per Test":                       "IOPS per test",
+		"Average Latency per Test":            "Latență medie per test",
+		"Metric":                              "Metrică",
+		"Read":                                "Citire",
+		"Write":                               "Scriere",
+		"Total":                               "Total",
+		"Bandwidth (MB/s)":                    "Lățime de bandă (MB/s)",
+		"Completion Latency Avg (us)":         "Latență de finalizare medie (us)",
+		"Completion Latency Max (us)":         "Latență de finalizare max. (us)",
+		"CPU User / System":                   "CPU utilizator / sistem",
+		"Error":                               "Eroare",
+		"Read Completion Latency Percentiles": "Percentilele latenței de finalizare la citire",
+		"Warnings":                            "Avertismente",
+		"Page %d of %d":                       "Pagina %d din %d",
+		"PASSED":                              "TRECUT",
+		"FAILED":                              "EȘUAT",
+		"Field":                               "Câmp",
+		"Value":                               "Valoare",
+		"Total Tests":                         "Total teste",
+		"Passed":                              "Trecute",
+		"Failed":                              "Eșuate",
+		"Highest IOPS":                        "IOPS maxim",
+		"Highest Bandwidth":                   "Lățime de bandă maximă",
+		"Lowest Latency":                      "Latență minimă",
+		"Percentiles":                         "Percentile",
+		"Config":                              "Configurație",
+		"Option":                              "Opțiune",
+		"fio command":                         "comandă fio",
+		"Read IOPS":                           "IOPS citire",
+		"Read IOPS Min":                       "IOPS citire min.",
+		"Read IOPS Max":                       "IOPS citire max.",
+		"Read IOPS StdDev":                    "IOPS citire abatere std.",
+		"Write IOPS":                          "IOPS scriere",
+		"Write IOPS Min":                      "IOPS scriere min.",
+		"Write IOPS Max":                      "IOPS scriere max.",
+		"Write IOPS StdDev":                   "IOPS scriere abatere std.",
+		"Read MB/s":                           "MB/s citire",
+		"Write MB/s":                          "MB/s scriere",
+		"Read slat Avg (us)":                  "slat citire medie (us)",
+		"Read clat Avg (us)":                  "clat citire medie (us)",
+		"Read clat Max (us)":                  "clat citire max. (us)",
+		"Read clat StdDev (us)":               "clat citire abatere std. (us)",
+		"Read lat Avg (us)":                   "lat citire medie (us)",
+		"Write slat Avg (us)":                 "slat scriere medie (us)",
+		"Write clat Avg (us)":                 "clat scriere medie (us)",
+		"Write clat Max (us)":                 "clat scriere max. (us)",
+		"Write clat StdDev (us)":              "clat scriere abatere std. (us)",
+		"Write lat Avg (us)":                  "lat scriere medie (us)",
+		"User CPU %":                          "CPU utilizator %",
+		"System CPU %":                        "CPU sistem %",
+		"Context Switches":                    "Comutări de context",
+	},
+}
+
+// reportLabels is the catalog of the locale set with --locale, nil for
+// English
+var reportLabels map[string]string
+
+// setReportLocale selects the language of the generated reports: en, a
+// built-in locale or a JSON file mapping English labels to translations
+func setReportLocale(locale string) error {
+	switch {
+	case locale == "" || locale == "en":
+		reportLabels = nil
+	case strings.HasSuffix(locale, ".json"):
+		data, err := os.ReadFile(locale)
+		if err != nil {
+			return fmt.Errorf("locale: %v", err)
+		}
+		var labels map[string]string
+		if err := json.Unmarshal(data, &labels); err != nil {
+			return fmt.Errorf("locale %s: %v", locale, err)
+		}
+		reportLabels = labels
+	default:
+		labels, ok := reportCatalogs[locale]
+		if !ok {
+			var locales []string
+			for name := range reportCatalogs {
+				locales = append(locales, name)
+			}
+			sort.Strings(locales)
+			return fmt.Errorf("unknown locale %q, available: en, %s or a JSON file of labels", locale, strings.Join(locales, ", "))
+		}
+		reportLabels = labels
+	}
+	return nil
+}
+
+// tr translates a report label into the selected locale
+func tr(label string) string {
+	if translated, ok := reportLabels[label]; ok {
+		return translated
+	}
+	return label
+}
+
+// localeDigitSeparators group the digits of large numbers per locale with
+// --digit-grouping locale. German and Romanian group with a point, which the
+// tables print as decimal point, so they use the apostrophe of Swiss German
+// to keep 1'234.5 apart from 1.234
+var localeDigitSeparators = map[string]string{
+	"en": ",",
+	"de": "'",
+	"ro": "'",
+}
+
+// digitSeparator groups the digits of counts like IOPS by thousands, empty
+// for no grouping
+var digitSeparator string
+
+// setDigitGrouping selects how counts are grouped: none, locale for the
+// separator of the --locale language, or the separator itself, like "'"
+func setDigitGrouping(grouping, locale string) error {
+	switch grouping {
+	case "", "none":
+		digitSeparator = ""
+	case "locale":
+		separator, ok := localeDigitSeparators[locale]
+		if !ok {
+			// Label files do not define number formats
+			separator = ","
+		}
+		digitSeparator = separator
+	default:
+		if strings.ContainsAny(grouping, "0123456789-.") {
+			return fmt.Errorf("invalid digit grouping %q, use none, locale or a separator like , or ' (decimals are printed with .)", grouping)
+		}
+		digitSeparator = grouping
+	}
+	return nil
+}
+
+// formatCount formats a count like IOPS without decimals, its digits grouped
+// by thousands as set with --digit-grouping: 1234567 becomes 1,234,567
+func formatCount(v float64) string {
+	return formatNumber(v, 0)
+}
+
+// formatNumber formats a number with the given decimals, the digits of its
+// integer part grouped as set with --digit-grouping: 1234.5 becomes 1,234.50
+func formatNumber(v float64, decimals int) string {
+	digits := fmt.Sprintf("%.*f", decimals, v)
+	if digitSeparator == "" {
+		return digits
+	}
+	sign, fraction := "", ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if point := strings.IndexByte(digits, '.'); point >= 0 {
+		digits, fraction = digits[:point], digits[point:]
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(digitSeparator)
+		}
+		b.WriteRune(digit)
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
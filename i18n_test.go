@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+// TestFormatNumberLocales expects the thousands of every locale to stay
+// apart from the decimal point
+func TestFormatNumberLocales(t *testing.T) {
+	defer func(separator string) { digitSeparator = separator }(digitSeparator)
+
+	tests := []struct {
+		grouping, locale string
+		v                float64
+		decimals         int
+		want             string
+	}{
+		{"none", "de", 1234567.891, 2, "1234567.89"},
+		{"locale", "en", 1234567.891, 2, "1,234,567.89"},
+		{"locale", "de", 1234567.891, 2, "1'234'567.89"},
+		{"locale", "ro", -1234.5, 1, "-1'234.5"},
+		{"locale", "de", 1234.5, 0, "1'234"},
+		{"locale", "de", 999.99, 2, "999.99"},
+		{" ", "en", 1234567.5, 1, "1 234 567.5"},
+	}
+	for _, tt := range tests {
+		if err := setDigitGrouping(tt.grouping, tt.locale); err != nil {
+			t.Fatal(err)
+		}
+		if got := formatNumber(tt.v, tt.decimals); got != tt.want {
+			t.Errorf("formatNumber(%v, %d) with %s %s = %q, want %q", tt.v, tt.decimals, tt.grouping, tt.locale, got, tt.want)
+		}
+	}
+
+	if err := setDigitGrouping(".", "de"); err == nil {
+		t.Errorf("setDigitGrouping(\".\") = nil, want an error")
+	}
+}
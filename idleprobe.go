@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// IdleProbe holds the latency of the target measured with ioping right
+// before and after a test, single 4k random reads while nothing else runs
+type IdleProbe struct {
+	BeforeUs float64 `json:"before_latency_us,omitempty"`
+	AfterUs  float64 `json:"after_latency_us,omitempty"`
+	// IdleUs is the lowest latency probed on the target so far in the run
+	IdleUs float64 `json:"idle_latency_us,omitempty"`
+}
+
+const (
+	// idleProbeSeconds is how long a probe sends reads
+	idleProbeSeconds = 2
+	// idleProbeFactor is how much slower than idle the target may answer
+	// before it counts as busy
+	idleProbeFactor = 2
+)
+
+// idleLatency is the lowest probed latency per target
+var idleLatency = map[string]float64{}
+
+// probeIdleLatency measures the read latency of the target of a test with
+// ioping. Targets fio has not created yet are not probed.
+func probeIdleLatency(test FioTest) (float64, error) {
+	if _, err := os.Stat(test.Filename); err != nil {
+		return 0, nil
+	}
+	probe := FioTest{Filename: test.Filename, RW: "randread", BS: "4k", Size: test.Size, Direct: 1, Runtime: idleProbeSeconds}
+	var result TestResult
+	if err := (iopingBackend{}).Run(context.Background(), probe, &result); err != nil {
+		return 0, err
+	}
+	if idle, ok := idleLatency[test.Filename]; !ok || result.ReadLatencyUs < idle {
+		idleLatency[test.Filename] = result.ReadLatencyUs
+	}
+	return result.ReadLatencyUs, nil
+}
+
+// checkIdleProbe compares the probes around a test with the idle latency of
+// its target: a slow target before the test means background work like
+// garbage collection ran during it, a slow target after the test carries
+// into the next one
+func checkIdleProbe(probe *IdleProbe, result *TestResult) {
+	probe.IdleUs = idleLatency[result.Config.Filename]
+	if probe.BeforeUs > 0 && probe.IdleUs > 0 && probe.BeforeUs > idleProbeFactor*probe.IdleUs {
+		result.warn(severityWarning, "busy_device", "read latency before the test was %.0f%s, %.1fx the idle %.0f%s, background activity may have affected the results",
+			probe.BeforeUs, usUnit(), probe.BeforeUs/probe.IdleUs, probe.IdleUs, usUnit())
+	}
+	if probe.AfterUs > 0 && probe.BeforeUs > 0 && probe.AfterUs > idleProbeFactor*probe.BeforeUs {
+		result.warn(severityNotice, "busy_device", "read latency after the test was %.0f%s, %.1fx the %.0f%s before it, the device is still busy",
+			probe.AfterUs, usUnit(), probe.AfterUs/probe.BeforeUs, probe.BeforeUs, usUnit())
+	}
+}
+
+// String describes the probes of a test
+func (p *IdleProbe) String() string {
+	label := func(us float64) string {
+		if us == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.0f%s", us, usUnit())
+	}
+	return fmt.Sprintf("before %s, after %s, idle %s", label(p.BeforeUs), label(p.AfterUs), label(p.IdleUs))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IOLogInfo describes an IO log replayed by a test
+type IOLogInfo struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	IOs    int64  `json:"ios"`
+}
+
+const (
+	blktraceMagic      = 0x65617400
+	blktraceHeaderSize = 48
+	// blktraceQueue is the action of an IO queued to the block layer, the
+	// dispatch, merge and complete events of the same IO are not counted
+	blktraceQueue = 1
+	// blktraceNotify is the category of message events like process names
+	// and timestamps, BLK_TC_NOTIFY in the upper half of the action
+	blktraceNotify = 1 << 10 << 16
+)
+
+// iologActions are the actions allowed in fio iolog version 2 and 3 files
+var iologActions = map[string]bool{
+	"add": true, "open": true, "close": true, "wait": true,
+	"read": true, "write": true, "sync": true, "datasync": true, "trim": true,
+}
+
+// inspectIOLog detects whether the file is a fio iolog or a binary blktrace
+// and validates all of its entries, so that a corrupt log is reported before
+// fio is started instead of producing a partial replay
+func inspectIOLog(path string) (*IOLogInfo, error) {
+	file, err := openArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%s: file is empty or too short", path)
+	}
+
+	info := &IOLogInfo{Path: path}
+	switch {
+	case binary.LittleEndian.Uint32(header)&0xffffff00 == blktraceMagic:
+		info.Format = "blktrace"
+		info.IOs, err = countBlktraceIOs(reader, binary.LittleEndian)
+	case binary.BigEndian.Uint32(header)&0xffffff00 == blktraceMagic:
+		info.Format = "blktrace"
+		info.IOs, err = countBlktraceIOs(reader, binary.BigEndian)
+	default:
+		info.Format, info.IOs, err = parseFioIOLog(reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if info.IOs == 0 {
+		return nil, fmt.Errorf("%s: log contains no IOs", path)
+	}
+	return info, nil
+}
+
+// countBlktraceIOs validates the events of a blktrace and counts the IOs
+// queued, a single IO is traced as several events
+func countBlktraceIOs(reader io.Reader, order binary.ByteOrder) (int64, error) {
+	var count, ios int64
+	header := make([]byte, blktraceHeaderSize)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return ios, nil
+		}
+		if err != nil {
+			return ios, fmt.Errorf("truncated blktrace event %d", count+1)
+		}
+		if order.Uint32(header)&0xffffff00 != blktraceMagic {
+			return ios, fmt.Errorf("bad blktrace magic in event %d", count+1)
+		}
+
+		// The event header is followed by pdu_len bytes of payload
+		pduLen := int64(order.Uint16(header[46:]))
+		if _, err := io.CopyN(io.Discard, reader, pduLen); err != nil {
+			return ios, fmt.Errorf("truncated blktrace payload in event %d", count+1)
+		}
+		action := order.Uint32(header[28:])
+		if action&blktraceNotify == 0 && action&0xffff == blktraceQueue {
+			ios++
+		}
+		count++
+	}
+}
+
+func parseFioIOLog(reader *bufio.Reader) (string, int64, error) {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() {
+		return "", 0, fmt.Errorf("file is empty")
+	}
+
+	// Version 3 logs have a leading timestamp on every entry
+	var format string
+	var timestamped bool
+	switch strings.TrimSpace(scanner.Text()) {
+	case "fio version 2 iolog":
+		format = "fio-iolog-v2"
+	case "fio version 3 iolog":
+		format = "fio-iolog-v3"
+		timestamped = true
+	default:
+		return "", 0, fmt.Errorf("not a blktrace or fio version 2/3 iolog file")
+	}
+
+	var ios int64
+	line := 1
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if timestamped {
+			if _, err := strconv.ParseUint(fields[0], 10, 64); err != nil {
+				return format, ios, fmt.Errorf("line %d: invalid timestamp %q", line, fields[0])
+			}
+			fields = fields[1:]
+		}
+
+		if len(fields) != 2 && len(fields) != 4 {
+			return format, ios, fmt.Errorf("line %d: expected \"file action [offset length]\"", line)
+		}
+		if !iologActions[fields[1]] {
+			return format, ios, fmt.Errorf("line %d: unknown action %q", line, fields[1])
+		}
+		if len(fields) == 4 {
+			for _, field := range fields[2:] {
+				if _, err := strconv.ParseUint(field, 10, 64); err != nil {
+					return format, ios, fmt.Errorf("line %d: invalid number %q", line, field)
+				}
+			}
+			if fields[1] != "wait" {
+				ios++
+			}
+		}
+	}
+
+	return format, ios, scanner.Err()
+}
+
+// dropZeroOptions removes the given numeric options when they are 0, fio
+// rejects them below 1
+func dropZeroOptions(args []string, options ...string) []string {
+	kept := args[:0]
+	for _, arg := range args {
+		zero := false
+		for _, option := range options {
+			zero = zero || arg == "--"+option+"=0"
+		}
+		if !zero {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// dropEmptyOptions removes "--option=" arguments that have no value
+func dropEmptyOptions(args []string) []string {
+	kept := args[:0]
+	for _, arg := range args {
+		if !strings.HasSuffix(arg, "=") {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
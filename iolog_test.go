@@ -0,0 +1,19 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestInspectBlktrace counts the IOs of a blktrace holding a process name
+// and a timestamp notify event, a plain and two FUA queued IOs and their
+// completions. Only the three queued IOs count, FUA writes included.
+func TestInspectBlktrace(t *testing.T) {
+	info, err := inspectIOLog(filepath.Join("testdata", "iolog", "fua.blktrace"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "blktrace" || info.IOs != 3 {
+		t.Errorf("inspected %s with %d IOs, want blktrace with 3", info.Format, info.IOs)
+	}
+}
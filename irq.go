@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// IRQStats attributes the interrupts raised while a test ran to the
+// device and the CPUs that handled them. Different CPU distributions of the
+// same test often explain run to run variance caused by IRQ affinity.
+type IRQStats struct {
+	Device  string      `json:"device"`
+	Vectors []IRQVector `json:"vectors"`
+	Total   uint64      `json:"total"`
+	PerSec  float64     `json:"per_second"`
+	PerCPU  []uint64    `json:"per_cpu"`
+	// CPUsUsed counts the CPUs that handled at least 1% of the interrupts
+	CPUsUsed    int     `json:"cpus_used"`
+	TopCPU      int     `json:"top_cpu"`
+	TopCPUShare float64 `json:"top_cpu_share_percent"`
+	// SoftIRQs are the softirqs of every type raised on all CPUs,
+	// BlockPerCPU the BLOCK softirqs (IO completions) per CPU
+	SoftIRQs    map[string]uint64 `json:"softirqs,omitempty"`
+	BlockPerCPU []uint64          `json:"block_softirqs_per_cpu,omitempty"`
+}
+
+// IRQVector is one interrupt line of the device
+type IRQVector struct {
+	IRQ    string `json:"irq"`
+	Name   string `json:"name"`
+	Count  uint64 `json:"count"`
+	TopCPU int    `json:"top_cpu"`
+}
+
+// irqCounters are the per-CPU counters of /proc/interrupts or
+// /proc/softirqs by line
+type irqCounters struct {
+	counts map[string][]uint64
+	names  map[string]string
+}
+
+// readIRQCounters parses /proc/interrupts or /proc/softirqs: a header of
+// CPU columns, then a line per interrupt or softirq type with one counter
+// per CPU followed by a description
+func readIRQCounters(path string) (irqCounters, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return irqCounters{}, err
+	}
+	defer f.Close()
+
+	counters := irqCounters{counts: map[string][]uint64{}, names: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return counters, fmt.Errorf("%s is empty", path)
+	}
+	cpus := len(strings.Fields(scanner.Text()))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.TrimSuffix(fields[0], ":")
+		var counts []uint64
+		for i := 1; i < len(fields) && i <= cpus; i++ {
+			count, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				break
+			}
+			counts = append(counts, count)
+		}
+		counters.counts[key] = counts
+		if len(fields) > len(counts)+1 {
+			// The last field is the name of the action, e.g. nvme0q3
+			counters.names[key] = fields[len(fields)-1]
+		}
+	}
+	return counters, scanner.Err()
+}
+
+// delta returns the per-CPU increase of a line between two snapshots
+func (c irqCounters) delta(start irqCounters, key string) []uint64 {
+	end := c.counts[key]
+	delta := make([]uint64, len(end))
+	for i, count := range end {
+		if i < len(start.counts[key]) && count >= start.counts[key][i] {
+			delta[i] = count - start.counts[key][i]
+		}
+	}
+	return delta
+}
+
+// deviceIRQs returns the interrupt lines of the controller of a disk: the
+// MSI vectors of the first device above it in sysfs that has any
+func deviceIRQs(disk string) ([]string, error) {
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/block", filepath.Base(disk), "device"))
+	if err != nil {
+		return nil, err
+	}
+	for ; path != "/" && path != "/sys"; path = filepath.Dir(path) {
+		entries, err := os.ReadDir(filepath.Join(path, "msi_irqs"))
+		if err != nil {
+			continue
+		}
+		var irqs []string
+		for _, entry := range entries {
+			irqs = append(irqs, entry.Name())
+		}
+		return irqs, nil
+	}
+	return nil, fmt.Errorf("no MSI interrupts found for %s", disk)
+}
+
+// checkIRQMatch validates --irq-match before any test runs
+func checkIRQMatch() error {
+	if _, err := regexp.Compile(opts.IRQMatch); err != nil {
+		return fmt.Errorf("invalid --irq-match: %v", err)
+	}
+	return nil
+}
+
+// irqMonitor snapshots the interrupt and softirq counters around the test
+type irqMonitor struct {
+	test      FioTest
+	device    string
+	irqs      map[string]bool
+	match     *regexp.Regexp
+	started   time.Time
+	interrupt irqCounters
+	softirq   irqCounters
+}
+
+func (m *irqMonitor) name() string {
+	return "interrupts"
+}
+
+func (m *irqMonitor) start() error {
+	device, err := resolveBlockDevice(m.test.Filename)
+	if err != nil {
+		return err
+	}
+	m.device = wholeDisk(device)
+
+	// --irq-match selects the interrupts by name where sysfs does not tell
+	// which belong to the device
+	if opts.IRQMatch != "" {
+		m.match = regexp.MustCompile(opts.IRQMatch)
+	} else {
+		irqs, err := deviceIRQs(m.device)
+		if err != nil {
+			return fmt.Errorf("%v, select them with --irq-match", err)
+		}
+		m.irqs = map[string]bool{}
+		for _, irq := range irqs {
+			m.irqs[irq] = true
+		}
+	}
+
+	if m.interrupt, err = readIRQCounters("/proc/interrupts"); err != nil {
+		return err
+	}
+	m.softirq, _ = readIRQCounters("/proc/softirqs")
+	m.started = time.Now()
+	return nil
+}
+
+func (m *irqMonitor) stop(result *TestResult) {
+	elapsed := time.Since(m.started)
+	interrupt, err := readIRQCounters("/proc/interrupts")
+	if err != nil {
+		result.warn(severityWarning, "monitor", "cannot read interrupt counters: %v", err)
+		return
+	}
+
+	stats := &IRQStats{Device: m.device}
+	for irq := range interrupt.counts {
+		if m.irqs != nil && !m.irqs[irq] || m.match != nil && !m.match.MatchString(interrupt.names[irq]) {
+			continue
+		}
+		vector := IRQVector{IRQ: irq, Name: interrupt.names[irq]}
+		delta := interrupt.delta(m.interrupt, irq)
+		for cpu, count := range delta {
+			for len(stats.PerCPU) <= cpu {
+				stats.PerCPU = append(stats.PerCPU, 0)
+			}
+			stats.PerCPU[cpu] += count
+			vector.Count += count
+			if count > delta[vector.TopCPU] {
+				vector.TopCPU = cpu
+			}
+		}
+		stats.Total += vector.Count
+		if vector.Count > 0 {
+			stats.Vectors = append(stats.Vectors, vector)
+		}
+	}
+	sort.Slice(stats.Vectors, func(i, j int) bool { return stats.Vectors[i].Count > stats.Vectors[j].Count })
+	if elapsed > 0 {
+		stats.PerSec = float64(stats.Total) / elapsed.Seconds()
+	}
+	for cpu, count := range stats.PerCPU {
+		if stats.Total > 0 && count*100 >= stats.Total {
+			stats.CPUsUsed++
+		}
+		if count > stats.PerCPU[stats.TopCPU] {
+			stats.TopCPU = cpu
+		}
+	}
+	if stats.Total > 0 {
+		stats.TopCPUShare = percent(stats.PerCPU[stats.TopCPU], stats.Total)
+	}
+
+	if softirq, err := readIRQCounters("/proc/softirqs"); err == nil && m.softirq.counts != nil {
+		stats.SoftIRQs = map[string]uint64{}
+		for kind := range softirq.counts {
+			var total uint64
+			for _, count := range softirq.delta(m.softirq, kind) {
+				total += count
+			}
+			stats.SoftIRQs[kind] = total
+		}
+		stats.BlockPerCPU = softirq.delta(m.softirq, "BLOCK")
+	}
+	result.IRQ = stats
+}
+
+// cpuDistribution describes the busiest CPUs of a per-CPU count, e.g.
+// "cpu3 61%, cpu0 22%, cpu5 17%"
+func cpuDistribution(perCPU []uint64, total uint64, top int) string {
+	if total == 0 {
+		return "-"
+	}
+	cpus := make([]int, len(perCPU))
+	for i := range cpus {
+		cpus[i] = i
+	}
+	sort.SliceStable(cpus, func(i, j int) bool { return perCPU[cpus[i]] > perCPU[cpus[j]] })
+	var parts []string
+	for _, cpu := range cpus[:min(top, len(cpus))] {
+		if perCPU[cpu] == 0 {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("cpu%d %.0f%%", cpu, percent(perCPU[cpu], total)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func displayIRQStats(stats *IRQStats) {
+	fmt.Fprintf(out, "Interrupts (%s, %d vectors)\n", stats.Device, len(stats.Vectors))
+	irqTable := tablewriter.NewWriter(out)
+	irqTable.SetHeader([]string{"Metric", "Value"})
+	configureTable(irqTable, 2)
+	irqTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	irqTable.Append([]string{"Device Interrupts", fmt.Sprintf("%d (%.0f/s)", stats.Total, stats.PerSec)})
+	irqTable.Append([]string{"CPUs Handling (>= 1%)", strconv.Itoa(stats.CPUsUsed)})
+	irqTable.Append([]string{"CPU Distribution", cpuDistribution(stats.PerCPU, stats.Total, 4)})
+	if stats.SoftIRQs != nil {
+		irqTable.Append([]string{"BLOCK Softirqs", strconv.FormatUint(stats.SoftIRQs["BLOCK"], 10)})
+		irqTable.Append([]string{"BLOCK Softirq Distribution", cpuDistribution(stats.BlockPerCPU, stats.SoftIRQs["BLOCK"], 4)})
+	}
+	irqTable.Render()
+	fmt.Fprintln(out)
+}
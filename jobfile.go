@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fioCLIOptions are the fio options that only work on the command line,
+// all others are job options and can go into a job file
+var fioCLIOptions = map[string]bool{
+	"output":          true,
+	"output-format":   true,
+	"eta":             true,
+	"eta-newline":     true,
+	"eta-interval":    true,
+	"status-interval": true,
+	"minimal":         true,
+	"terse-version":   true,
+	"append-terse":    true,
+	"debug":           true,
+	"readonly":        true,
+	"parse-only":      true,
+	"section":         true,
+	"warnings-fatal":  true,
+	"max-jobs":        true,
+	"alloc-size":      true,
+}
+
+// useJobFile reports whether the test runs from a generated job file.
+// Tests with job sections always do.
+func useJobFile(test FioTest) bool {
+	return test.JobFile || opts.JobFiles || len(test.Jobs) > 0
+}
+
+// writeJobFile writes the job options of args to a fio job file with one
+// section named after the job, and returns the options that stay on the
+// command line. Job files have no length limit and are what multi-section
+// jobs need: with sections the options of the test go into the global
+// section followed by one section per job. Every job section starts a new
+// reporting group so group_reporting does not merge the sections.
+func writeJobFile(path string, args []string, sections []FioJobSection) ([]string, error) {
+	var cli []string
+	var options []string
+	section := "job"
+	for _, arg := range args {
+		option, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch {
+		case !strings.HasPrefix(arg, "--") || fioCLIOptions[option]:
+			cli = append(cli, arg)
+		case option == "name":
+			// The section name is the job name
+			section = value
+		case strings.ContainsAny(value, "\n\r"):
+			return nil, fmt.Errorf("value of %s cannot be written to a job file", option)
+		default:
+			options = append(options, strings.TrimPrefix(arg, "--"))
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "; fio job file generated by fio-qa")
+	if len(sections) > 0 {
+		section = "global"
+	}
+	fmt.Fprintf(&buf, "[%s]\n", section)
+	for _, option := range options {
+		fmt.Fprintln(&buf, option)
+	}
+	for _, job := range sections {
+		fmt.Fprintf(&buf, "\n[%s]\n", job.Name)
+		for _, option := range job.options() {
+			fmt.Fprintln(&buf, option)
+		}
+		fmt.Fprintln(&buf, "new_group")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"fio-qa/stats"
+	"github.com/olekukonko/tablewriter"
+)
+
+// FioJobSection is one of several fio jobs a test runs at the same time,
+// e.g. a random read job next to a sequential write job. Settings left
+// empty are taken from the test.
+type FioJobSection struct {
+	Name       string `json:"name"`
+	RW         string `json:"rw,omitempty"`
+	RWMixRead  int    `json:"rwmixread,omitempty"`
+	RWMixWrite int    `json:"rwmixwrite,omitempty"`
+	BS         string `json:"bs,omitempty"`
+	IODepth    int    `json:"iodepth,omitempty"`
+	NumJobs    int    `json:"numjobs,omitempty"`
+	Offset     string `json:"offset,omitempty"`
+	// OffsetIncrement moves the start of every clone of the job by this
+	// much, so clones work on separate ranges
+	OffsetIncrement string `json:"offset_increment,omitempty"`
+	Size            string `json:"size,omitempty"`
+	// RateIOPS and Rate cap the job, Rate as bandwidth like "500m"
+	RateIOPS int    `json:"rate_iops,omitempty"`
+	Rate     string `json:"rate,omitempty"`
+	// Flow weights the job in fio's flow control, jobs with flow 7 and 3
+	// issue IOs in a 70/30 ratio
+	Flow int `json:"flow,omitempty"`
+}
+
+// JobSectionResult is the performance of one job section of a test
+type JobSectionResult struct {
+	Name           string  `json:"name"`
+	RW             string  `json:"rw"`
+	BS             string  `json:"bs"`
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	TotalIOPS      float64 `json:"total_iops"`
+	ReadBWMBps     float64 `json:"read_bw_mbps"`
+	WriteBWMBps    float64 `json:"write_bw_mbps"`
+	TotalBWMBps    float64 `json:"total_bw_mbps"`
+	ReadLatencyUs  float64 `json:"read_latency_us"`
+	WriteLatencyUs float64 `json:"write_latency_us"`
+	P99LatencyUs   float64 `json:"p99_clat_us"`
+	IOPSSharePc    float64 `json:"iops_share_percent"`
+}
+
+// checkJobSections validates the job sections of the tests before anything
+// runs
+func checkJobSections(tests []FioTest) error {
+	for _, test := range tests {
+		seen := make(map[string]bool)
+		for _, section := range test.Jobs {
+			switch {
+			case section.Name == "":
+				return fmt.Errorf("test %s: every job section needs a name", test.Name)
+			case strings.EqualFold(section.Name, "global"):
+				return fmt.Errorf("test %s: job section name %q is reserved by fio", test.Name, section.Name)
+			case strings.ContainsAny(section.Name, "[]\n\r"):
+				return fmt.Errorf("test %s: job section name %q cannot be used in a job file", test.Name, section.Name)
+			case seen[section.Name]:
+				return fmt.Errorf("test %s: duplicate job section %q", test.Name, section.Name)
+			case section.RWMixRead < 0 || section.RWMixRead > 100:
+				return fmt.Errorf("test %s: job section %s: rwmixread %d is not between 0 and 100", test.Name, section.Name, section.RWMixRead)
+			case section.RWMixWrite < 0 || section.RWMixWrite > 100:
+				return fmt.Errorf("test %s: job section %s: rwmixwrite %d is not between 0 and 100", test.Name, section.Name, section.RWMixWrite)
+			case section.RWMixRead > 0 && section.RWMixWrite > 0 && section.RWMixRead+section.RWMixWrite != 100:
+				return fmt.Errorf("test %s: job section %s: rwmixread=%d conflicts with rwmixwrite=%d", test.Name, section.Name, section.RWMixRead, section.RWMixWrite)
+			case section.IODepth < 0 || section.NumJobs < 0 || section.Flow < 0 || section.RateIOPS < 0:
+				return fmt.Errorf("test %s: job section %s: iodepth, numjobs, flow and rate_iops cannot be negative", test.Name, section.Name)
+			}
+			seen[section.Name] = true
+		}
+		if len(test.Jobs) > 0 && test.ReadIOLog != "" {
+			return fmt.Errorf("test %s: job sections cannot replay an IO log", test.Name)
+		}
+	}
+	return nil
+}
+
+// options returns the fio options the section overrides
+func (s FioJobSection) options() []string {
+	var options []string
+	if s.RW != "" {
+		options = append(options, "rw="+s.RW)
+	}
+	switch {
+	case s.RWMixRead > 0:
+		options = append(options, fmt.Sprintf("rwmixread=%d", s.RWMixRead))
+	case s.RWMixWrite > 0:
+		// Passed as the reads it leaves, so it replaces the rwmixread of
+		// the test
+		options = append(options, fmt.Sprintf("rwmixread=%d", 100-s.RWMixWrite))
+	}
+	if s.BS != "" {
+		options = append(options, "bs="+s.BS)
+	}
+	if s.IODepth > 0 {
+		options = append(options, fmt.Sprintf("iodepth=%d", s.IODepth))
+	}
+	if s.NumJobs > 0 {
+		options = append(options, fmt.Sprintf("numjobs=%d", s.NumJobs))
+	}
+	if s.Offset != "" {
+		options = append(options, "offset="+s.Offset)
+	}
+	if s.OffsetIncrement != "" {
+		options = append(options, "offset_increment="+s.OffsetIncrement)
+	}
+	if s.Size != "" {
+		options = append(options, "size="+s.Size)
+	}
+	if s.RateIOPS > 0 {
+		options = append(options, fmt.Sprintf("rate_iops=%d", s.RateIOPS))
+	}
+	if s.Rate != "" {
+		options = append(options, "rate="+s.Rate)
+	}
+	if s.Flow > 0 {
+		options = append(options, fmt.Sprintf("flow=%d", s.Flow))
+	}
+	return options
+}
+
+// apply returns the test as the section runs it
+func (s FioJobSection) apply(test FioTest) FioTest {
+	test.Name = s.Name
+	if s.RW != "" {
+		test.RW = s.RW
+	}
+	switch {
+	case s.RWMixRead > 0:
+		test.RWMixRead, test.RWMixWrite = s.RWMixRead, 0
+	case s.RWMixWrite > 0:
+		test.RWMixRead, test.RWMixWrite = 0, s.RWMixWrite
+	}
+	if s.BS != "" {
+		test.BS = s.BS
+	}
+	if s.IODepth > 0 {
+		test.IODepth = s.IODepth
+	}
+	if s.NumJobs > 0 {
+		test.NumJobs = s.NumJobs
+	}
+	if s.Offset != "" {
+		test.Offset = s.Offset
+	}
+	if s.OffsetIncrement != "" {
+		test.OffsetIncrement = s.OffsetIncrement
+	}
+	if s.Size != "" {
+		test.Size = s.Size
+	}
+	return test
+}
+
+// args returns the fio arguments of the test as the section runs them, the
+// section options replacing the ones of the test
+func (s FioJobSection) args(args []string) []string {
+	overrides := append([]string{"name=" + s.Name}, s.options()...)
+	replaced := make(map[string]bool)
+	for _, option := range overrides {
+		name, _, _ := strings.Cut(option, "=")
+		replaced[name] = true
+	}
+
+	var result []string
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !replaced[name] {
+			result = append(result, arg)
+		}
+	}
+	for _, option := range overrides {
+		result = append(result, "--"+option)
+	}
+	return result
+}
+
+// sectionJobs returns the jobs fio reported for each section. Without
+// group_reporting every clone of a section is reported separately under
+// the section name.
+func sectionJobs(sections []FioJobSection, jobs []FioJobResult) ([][]FioJobResult, []string) {
+	reported := make([][]FioJobResult, len(sections))
+	var missing []string
+	for i, section := range sections {
+		for _, job := range jobs {
+			if job.JobName == section.Name {
+				reported[i] = append(reported[i], job)
+			}
+		}
+		if len(reported[i]) == 0 {
+			missing = append(missing, section.Name)
+		}
+	}
+	return reported, missing
+}
+
+// jobSectionResult summarizes the jobs fio reported for one section
+func jobSectionResult(test FioTest, section FioJobSection, job FioJobResult) JobSectionResult {
+	test = section.apply(test)
+	r := JobSectionResult{
+		Name:           section.Name,
+		RW:             test.RW,
+		BS:             test.BS,
+		ReadIOPS:       job.Read.IOPS,
+		WriteIOPS:      job.Write.IOPS,
+		ReadBWMBps:     job.Read.BWBytes / 1024 / 1024,
+		WriteBWMBps:    job.Write.BWBytes / 1024 / 1024,
+		ReadLatencyUs:  job.Read.LatNs.Mean / 1000,
+		WriteLatencyUs: job.Write.LatNs.Mean / 1000,
+	}
+	r.TotalIOPS = r.ReadIOPS + r.WriteIOPS
+	r.TotalBWMBps = r.ReadBWMBps + r.WriteBWMBps
+	r.P99LatencyUs = math.Max(job.Read.Clat.Percentile["99.000000"], job.Write.Clat.Percentile["99.000000"]) / 1000
+	return r
+}
+
+// mergeJobs combines the jobs fio reported into one job as if fio had
+// reported them as a single group. Rates add up, latencies are weighted by
+// the IOPS of each job and percentiles are those of the mixture of the
+// latency distributions. The ranges of the per-second IOPS and bandwidth
+// samples are approximated by the sums of the ranges of the jobs.
+func mergeJobs(name string, jobs []FioJobResult) FioJobResult {
+	if len(jobs) == 1 {
+		return jobs[0]
+	}
+
+	merged := FioJobResult{JobName: name}
+	reads := make([]FioIO, len(jobs))
+	writes := make([]FioIO, len(jobs))
+	var totalIOPS float64
+	for i, job := range jobs {
+		reads[i], writes[i] = job.Read, job.Write
+		merged.UsrCPU += job.UsrCPU
+		merged.SysCPU += job.SysCPU
+		merged.Ctx += job.Ctx
+		merged.MajF += job.MajF
+		merged.MinF += job.MinF
+		merged.TotalErr += job.TotalErr
+		totalIOPS += job.Read.IOPS + job.Write.IOPS
+	}
+	merged.Read = mergeIO(reads)
+	merged.Write = mergeIO(writes)
+
+	// Queue depth and latency bins are shares of the IOs of each job
+	merged.IODepths = make(map[string]float64)
+	merged.LatBins = make(map[string]float64)
+	for _, job := range jobs {
+		weight := 1 / float64(len(jobs))
+		if totalIOPS > 0 {
+			weight = (job.Read.IOPS + job.Write.IOPS) / totalIOPS
+		}
+		for depth, pc := range job.IODepths {
+			merged.IODepths[depth] += pc * weight
+		}
+		for bin, pc := range job.LatBins {
+			merged.LatBins[bin] += pc * weight
+		}
+	}
+	return merged
+}
+
+// mergeIO combines the read or write statistics of several jobs
+func mergeIO(ios []FioIO) FioIO {
+	var merged FioIO
+	var weights []float64
+	var variance float64
+	for _, io := range ios {
+		merged.IOPS += io.IOPS
+		merged.BWBytes += io.BWBytes
+		merged.BWMean += io.BWMean
+		merged.BWMin += io.BWMin
+		merged.BWMax += io.BWMax
+		merged.IOKBytes += io.IOKBytes
+		merged.IOPSMin += io.IOPSMin
+		merged.IOPSMax += io.IOPSMax
+		merged.IOPSMean += io.IOPSMean
+		merged.Runtime = math.Max(merged.Runtime, io.Runtime)
+		// Jobs run independently, so their variances add up
+		variance += io.IOPSStddev * io.IOPSStddev
+		merged.BWDev = math.Hypot(merged.BWDev, io.BWDev)
+		weights = append(weights, io.IOPS)
+	}
+	merged.IOPSStddev = math.Sqrt(variance)
+	if merged.IOPS == 0 {
+		return merged
+	}
+
+	slats := make([]FioLatNs, len(ios))
+	lats := make([]FioLatNs, len(ios))
+	clats := make([]FioLatNs, len(ios))
+	for i, io := range ios {
+		slats[i], lats[i] = io.Slat, io.LatNs
+		clats[i] = FioLatNs(io.Clat)
+	}
+	merged.Slat = mixLatency(slats, weights)
+	merged.LatNs = mixLatency(lats, weights)
+	merged.Clat = FioClat(mixLatency(clats, weights))
+	return merged
+}
+
+// mixLatency returns the latency statistics of the mixture of the latency
+// distributions, each weighted by the IOPS of its job
+func mixLatency(lats []FioLatNs, weights []float64) FioLatNs {
+	var mixed FioLatNs
+	var total, moment float64
+	var dists []*stats.Distribution
+	var distWeights []float64
+	for i, lat := range lats {
+		w := weights[i]
+		if w == 0 {
+			continue
+		}
+		if total == 0 || lat.Min < mixed.Min {
+			mixed.Min = lat.Min
+		}
+		mixed.Max = math.Max(mixed.Max, lat.Max)
+		total += w
+		mixed.Mean += w * lat.Mean
+		moment += w * (lat.Stddev*lat.Stddev + lat.Mean*lat.Mean)
+		// The bins of json+ count IOs, so they simply add up
+		for key, count := range lat.Bins {
+			if mixed.Bins == nil {
+				mixed.Bins = make(map[string]int64)
+			}
+			mixed.Bins[key] += count
+		}
+		if len(lat.Percentile) > 0 {
+			dists = append(dists, stats.FromFioPercentiles(lat.Min, lat.Max, lat.Percentile))
+			distWeights = append(distWeights, w)
+		}
+	}
+	if total == 0 {
+		return mixed
+	}
+	mixed.Mean /= total
+	mixed.Stddev = math.Sqrt(math.Max(moment/total-mixed.Mean*mixed.Mean, 0))
+
+	// Percentiles are only known when every job reported them
+	if len(dists) == 0 || len(dists) < countPositive(weights) {
+		return mixed
+	}
+	mixed.Percentile = make(map[string]float64)
+	for key := range lats[firstPositive(weights)].Percentile {
+		p := parseFloat(key)
+		mixed.Percentile[key] = mixturePercentile(dists, distWeights, p, mixed.Min, mixed.Max)
+	}
+	return mixed
+}
+
+// mixturePercentile finds the latency below which p percent of the IOs of
+// all jobs fall by bisecting between the smallest and largest latency
+func mixturePercentile(dists []*stats.Distribution, weights []float64, p, lo, hi float64) float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	for i := 0; i < 64 && hi-lo > 1; i++ {
+		mid := (lo + hi) / 2
+		var rank float64
+		for j, d := range dists {
+			rank += weights[j] * d.Rank(mid)
+		}
+		if rank/total < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+func countPositive(values []float64) int {
+	count := 0
+	for _, v := range values {
+		if v > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func firstPositive(values []float64) int {
+	for i, v := range values {
+		if v > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// extractJobSections maps the jobs fio reported to the sections of the test
+// and returns the job of the whole test. Options overridden by a section
+// are checked against the job of that section.
+func extractJobSections(test FioTest, args []string, globalOptions map[string]string, jobs []FioJobResult, result *TestResult) FioJobResult {
+	reported, missing := sectionJobs(test.Jobs, jobs)
+	for _, name := range missing {
+		result.warn(severityWarning, "config", "fio reported no job for job section %s", name)
+	}
+
+	var sectionResults []FioJobResult
+	for i, section := range rateCapSections(test) {
+		if len(reported[i]) == 0 {
+			continue
+		}
+		job := mergeJobs(section.Name, reported[i])
+		sectionResults = append(sectionResults, job)
+		result.Jobs = append(result.Jobs, jobSectionResult(test, section, job))
+		for _, warning := range checkJobOptions(section.apply(test), section.args(args), globalOptions, reported[i][0]) {
+			result.warn(severityWarning, "config", "job section %s: %s", section.Name, warning)
+		}
+	}
+
+	var totalIOPS float64
+	for _, r := range result.Jobs {
+		totalIOPS += r.TotalIOPS
+	}
+	for i := range result.Jobs {
+		if totalIOPS > 0 {
+			result.Jobs[i].IOPSSharePc = 100 * result.Jobs[i].TotalIOPS / totalIOPS
+		}
+	}
+	if len(sectionResults) == 0 {
+		return mergeJobs(test.Name, jobs)
+	}
+	return mergeJobs(test.Name, sectionResults)
+}
+
+func displayJobSections(sections []JobSectionResult) {
+	fmt.Fprintf(out, "Job Sections (latency in %s)\n", usUnit())
+	jobTable := tablewriter.NewWriter(out)
+	jobTable.SetHeader([]string{"Job", "Workload", "IOPS", "MB/s", "Lat Avg", "p99", "Share"})
+	configureTable(jobTable, 7)
+	jobTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, s := range sections {
+		// Reads and writes are weighted by their IOPS
+		var latency float64
+		if s.TotalIOPS > 0 {
+			latency = (s.ReadLatencyUs*s.ReadIOPS + s.WriteLatencyUs*s.WriteIOPS) / s.TotalIOPS
+		}
+		jobTable.Append([]string{
+			s.Name,
+			s.RW + " " + s.BS,
+			formatCount(s.TotalIOPS),
+			fmt.Sprintf("%.2f", s.TotalBWMBps),
+			fmt.Sprintf("%.2f", latency),
+			fmt.Sprintf("%.2f", s.P99LatencyUs),
+			fmt.Sprintf("%.1f%%", s.IOPSSharePc),
+		})
+	}
+	jobTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LaydownInfo records the laydown of the files of a many-file dataset,
+// timed apart from the measurement since it takes long on NAS targets
+type LaydownInfo struct {
+	Files   int     `json:"files"`
+	Bytes   int64   `json:"bytes"`
+	Seconds float64 `json:"seconds"`
+	MBps    float64 `json:"mbps,omitempty"`
+}
+
+// needsLaydown tells whether the files of a test are laid out before it
+// runs. Metadata engines create their files as the measurement, and replays
+// and job sections bring their own files.
+func needsLaydown(test FioTest) bool {
+	return test.NrFiles > 1 && !isMetadataTest(test) && test.ReadIOLog == "" && len(test.Jobs) == 0
+}
+
+// layDownFiles runs only the setup phase of fio for the test, which creates
+// the files and writes them where they need data, and times it. Files fio
+// laid out before are kept as they are.
+func layDownFiles(ctx context.Context, test FioTest) (*LaydownInfo, error) {
+	jobs := max(test.NumJobs, 1)
+	info := &LaydownInfo{Files: test.NrFiles * jobs}
+	if size := parseSize(test.FileSize); size > 0 {
+		info.Bytes = size * int64(test.NrFiles*jobs)
+	} else {
+		info.Bytes = parseSize(test.Size) * int64(jobs)
+	}
+
+	fmt.Fprintf(out, "Laying out %d files of %s\n", info.Files, test.Name)
+	args, _ := adaptFioArgs(test, append(buildFioCommand(test), "--create_only=1"))
+	start := time.Now()
+	output, err := runFio(ctx, fioCommand(test, args), nil)
+	if err != nil {
+		return nil, fmt.Errorf("laydown failed: %v: %s", err, output)
+	}
+	info.Seconds = time.Since(start).Seconds()
+	if info.Seconds > 0 {
+		info.MBps = float64(info.Bytes) / mebibyte / info.Seconds
+	}
+	return info, nil
+}
+
+func (l *LaydownInfo) String() string {
+	return fmt.Sprintf("%s files, %.1f GiB in %s (%.2f MB/s)", formatCount(float64(l.Files)),
+		float64(l.Bytes)/(1<<30), time.Duration(l.Seconds*float64(time.Second)).Round(time.Millisecond), l.MBps)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Configuration layers, from the lowest to the highest precedence. Every
+// layer sets the defaults of the options by their flag names, the command
+// line overrides them all.
+const (
+	systemConfigFile  = "/etc/fio-qa/config.json"
+	userConfigFile    = "fio-qa/config.json"
+	projectConfigFile = "fio-qa.json"
+)
+
+// configFile is a configuration layer that was found and applied
+type configFile struct {
+	Path string
+	data []byte
+}
+
+// configFiles are the configuration layers of the run, lowest first
+var configFiles []configFile
+
+// configLayers returns the paths of the configuration layers in the order
+// they are applied. The user layer is under $XDG_CONFIG_HOME, ~/.config on
+// Linux, and the project layer in the working directory.
+func configLayers() []string {
+	layers := []string{systemConfigFile}
+	if dir, err := os.UserConfigDir(); err == nil {
+		layers = append(layers, filepath.Join(dir, userConfigFile))
+	}
+	return append(layers, projectConfigFile)
+}
+
+// loadConfigLayers applies the configuration layers that exist to the
+// flags, before the command line is parsed
+func loadConfigLayers(flags *flag.FlagSet) error {
+	for _, path := range configLayers() {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(flags, path, data); err != nil {
+			return err
+		}
+		configFiles = append(configFiles, configFile{Path: path, data: data})
+	}
+	return nil
+}
+
+// applyConfig sets the flags named in a configuration file. Values are
+// given like on the command line, as strings, numbers or booleans; options
+// that can be given several times, like label, take a list that replaces
+// the list of the layers before.
+func applyConfig(flags *flag.FlagSet, path string, data []byte) error {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown option %q", path, name)
+		}
+		list, ok := values[name].([]interface{})
+		if !ok {
+			list = []interface{}{values[name]}
+		}
+		if values, ok := flags.Lookup(name).Value.(*stringList); ok {
+			*values = nil
+		}
+		for _, value := range list {
+			var text string
+			switch value := value.(type) {
+			case string:
+				text = value
+			case float64:
+				text = strconv.FormatFloat(value, 'f', -1, 64)
+			case bool:
+				text = strconv.FormatBool(value)
+			default:
+				return fmt.Errorf("%s: %s must be a string, number or boolean", path, name)
+			}
+			if err := flags.Set(name, text); err != nil {
+				return fmt.Errorf("%s: invalid value %q for %s: %v", path, text, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// holdLists empties the lists the configuration layers set and returns
+// them, so the command line replaces them instead of adding to them
+func holdLists(flags *flag.FlagSet) map[string]stringList {
+	held := map[string]stringList{}
+	flags.VisitAll(func(f *flag.Flag) {
+		if list, ok := f.Value.(*stringList); ok && len(*list) > 0 {
+			held[f.Name] = *list
+			*list = nil
+		}
+	})
+	return held
+}
+
+// restoreLists gives the lists the command line did not set the values of
+// the configuration layers again
+func restoreLists(flags *flag.FlagSet, held map[string]stringList) {
+	for name, values := range held {
+		if list := flags.Lookup(name).Value.(*stringList); len(*list) == 0 {
+			*list = values
+		}
+	}
+}
+
+// parseLayered applies the configuration layers and then the command line
+// arguments to the flags
+func parseLayered(flags *flag.FlagSet, args []string) error {
+	if err := loadConfigLayers(flags); err != nil {
+		return err
+	}
+	held := holdLists(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	restoreLists(flags, held)
+	return nil
+}
+
+// configPaths lists the configuration layers of the run
+func configPaths() []string {
+	var paths []string
+	for _, file := range configFiles {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// TestParseLayeredLists gives list options in the user and the project
+// layer and on the command line and expects every layer to replace the
+// lists of those before it
+func TestParseLayeredLists(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user layer is under $XDG_CONFIG_HOME on Linux only")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	writeFile := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(filepath.Join(dir, "config", userConfigFile), `{"fio-bin": ["/a/fio"], "label": ["lab=row-3", "rack=2"]}`)
+	writeFile(filepath.Join(dir, "project", projectConfigFile), `{"fio-bin": ["/b/fio"]}`)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(dir, "project")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func() { configFiles = nil }()
+
+	tests := []struct {
+		args    []string
+		fioBins []string
+		labels  []string
+	}{
+		{nil, []string{"/b/fio"}, []string{"lab=row-3", "rack=2"}},
+		{[]string{"--fio-bin", "/c/fio", "--fio-bin", "/d/fio"}, []string{"/c/fio", "/d/fio"}, []string{"lab=row-3", "rack=2"}},
+		{[]string{"--label", "firmware=1.2.3"}, []string{"/b/fio"}, []string{"firmware=1.2.3"}},
+	}
+	for _, tt := range tests {
+		configFiles = nil
+		var fioBins, labels stringList
+		flags := flag.NewFlagSet("fio-qa", flag.ContinueOnError)
+		flags.Var(&fioBins, "fio-bin", "")
+		flags.Var(&labels, "label", "")
+		if err := parseLayered(flags, tt.args); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual([]string(fioBins), tt.fioBins) {
+			t.Errorf("%v: fio-bin = %v, want %v", tt.args, fioBins, tt.fioBins)
+		}
+		if !reflect.DeepEqual([]string(labels), tt.labels) {
+			t.Errorf("%v: label = %v, want %v", tt.args, labels, tt.labels)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"fio-qa/runner"
+)
+
+// liteOutputLimit is the amount of fio console output kept with --lite, only
+// needed to report why fio failed
+const liteOutputLimit = 64 * 1024
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	data  []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append(b.data[:0], b.data[len(b.data)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+// runFio runs fio until it exits or the context is cancelled and returns
+// its console output, which is also passed to watch when it is not nil.
+// With --lite only the end of the output is kept in memory.
+func runFio(ctx context.Context, cmd *exec.Cmd, watch io.Writer) ([]byte, error) {
+	var output io.Writer
+	var buf bytes.Buffer
+	tail := &tailBuffer{limit: liteOutputLimit}
+	if opts.Lite {
+		output = tail
+	} else {
+		output = &buf
+	}
+	if watch != nil {
+		output = io.MultiWriter(output, watch)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := runner.RunCommand(ctx, cmd)
+	if opts.Lite {
+		return tail.data, err
+	}
+	return buf.Bytes(), err
+}
+
+// compact drops the raw fio data of a result that was already reported, so
+// long suites on small systems do not accumulate it. Only the headline
+// metrics remain for the summary and the results file.
+func (r *TestResult) compact() {
+	r.FioJob = nil
+	r.DiskUtil = nil
+	r.FioArgs = nil
+	r.HistBins = [2][]int64{}
+}
+
+// displayLiteResult shows a test result on a single line
+func displayLiteResult(result TestResult, index, total int) {
+	line := fmt.Sprintf("[%d/%d] %s %s", index, total, result.TestName, result.Status)
+	if result.Status == "PASSED" {
+		line += fmt.Sprintf(" iops=%.0f bw=%.2fMB/s lat=%.2f%s", result.TotalIOPS, result.TotalBWMBps, result.AvgLatencyUs, usUnit())
+	}
+	if len(result.Warnings) > 0 {
+		line += fmt.Sprintf(" warnings=%d", len(result.Warnings))
+	}
+	if result.Error != nil {
+		line += " error=" + strings.ReplaceAll(result.Error.Error(), "\n", " ")
+	}
+	fmt.Fprintln(out, line)
+}
+
+// displayLiteSummary shows the summary of all tests on a single line
+func displayLiteSummary(results []TestResult) {
+	passed, warnings := 0, 0
+	var duration time.Duration
+	for _, r := range results {
+		if r.Status == "PASSED" {
+			passed++
+		}
+		warnings += len(r.Warnings)
+		duration += r.Duration
+	}
+	fmt.Fprintf(out, "passed=%d failed=%d warnings=%d duration=%s\n", passed, len(results)-passed, warnings, duration.Round(time.Second))
+}
+
+// streamResult sends the JSON result of a test to --stream-results as soon
+// as the test finished: POSTed to an http(s) URL or appended as one line to
+// a file
+func streamResult(result TestResult) error {
+	streamed := buildJSONTestResult(result)
+	streamed.RunID = runID
+	streamed.Namespace = namespace
+	data, err := json.Marshal(streamed)
+	if err != nil {
+		return err
+	}
+	data = exportPrecision.apply(data)
+
+	target := opts.StreamResults
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Run-ID", runID)
+		if namespace != "" {
+			req.Header.Set("X-Namespace", namespace)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s returned %s", target, resp.Status)
+		}
+		return nil
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
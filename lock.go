@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// heldLocks are the device locks of this instance, held until it exits
+var heldLocks []*os.File
+
+// lockTargets takes an exclusive lock for every device the tests run on, so
+// that a second instance started against the same device by accident fails
+// instead of skewing the results of both. File targets lock the device of
+// their file system where it can be resolved.
+func lockTargets(tests []FioTest) error {
+	if opts.NoLock {
+		return nil
+	}
+	locked := map[string]bool{}
+	for _, test := range tests {
+		target := lockTarget(testTarget(test))
+		if locked[target] {
+			continue
+		}
+		locked[target] = true
+
+		name := "fio-qa-" + strings.Trim(strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(target), "_") + ".lock"
+		lock, err := lockFile(filepath.Join(os.TempDir(), name))
+		if err != nil {
+			return fmt.Errorf("%s is in use by another fio-qa instance (%v), use --no-lock to run anyway", target, err)
+		}
+		heldLocks = append(heldLocks, lock)
+	}
+	return nil
+}
+
+// lockTarget returns the device of a test target, or the target itself when
+// its device cannot be resolved
+func lockTarget(filename string) string {
+	if metadata, err := targetDevice(filename); err == nil && metadata.Device != "" {
+		return metadata.Device
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filename
+}
+
+// releaseLocks releases the device locks of this instance
+func releaseLocks() {
+	for _, lock := range heldLocks {
+		unlockFile(lock)
+	}
+	heldLocks = nil
+}
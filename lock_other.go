@@ -0,0 +1,30 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lockFile creates the lock file exclusively. Without flock a crashed
+// instance leaves the file behind, the error names it so it can be removed.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		owner, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("locked by pid %s, remove %s if it is no longer running", strings.TrimSpace(string(owner)), path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
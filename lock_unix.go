@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockFile takes an flock on the file, which the kernel releases when the
+// process exits, so crashed instances never leave a stale lock behind
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		owner, _ := os.ReadFile(path)
+		file.Close()
+		return nil, fmt.Errorf("locked by pid %s", strings.TrimSpace(string(owner)))
+	}
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	file.Close()
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"fio-qa/runner"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -16,10 +21,34 @@ import (
 type FioTest struct {
 	Name           string `json:"name"`
 	Description    string `json:"description"`
+	Suite          string `json:"suite,omitempty"`
+	Template       string `json:"template,omitempty"`
 	Filename       string `json:"filename"`
 	Size           string `json:"size"`
+	Directory      string `json:"directory,omitempty"`
+	NrFiles        int    `json:"nrfiles,omitempty"`
+	FileSize       string `json:"filesize,omitempty"`
+	OpenFiles      int    `json:"openfiles,omitempty"`
+	FileServiceType string `json:"file_service_type,omitempty"`
+	Offset         string `json:"offset,omitempty"`
+	OffsetIncrement string `json:"offset_increment,omitempty"`
+	BlockAlign     string `json:"blockalign,omitempty"`
+	Atomic         bool   `json:"atomic,omitempty"`
+	IOMem          string `json:"iomem,omitempty"`
+	Mem            string `json:"mem,omitempty"`
+	LockMem        string `json:"lockmem,omitempty"`
+	Region         *RegionConfig `json:"region,omitempty"`
+	NVMeController string `json:"nvme_controller,omitempty"`
+	NVMeNSIDs      []int  `json:"nvme_nsids,omitempty"`
+	NVMeNamespace  *NVMeNamespace `json:"-"`
+	NoisyNeighbor  *NoisyNeighborConfig `json:"noisy_neighbor,omitempty"`
+	NoisyNeighborStage *NoisyNeighborStage `json:"-"`
+	PolledComparison bool `json:"polled_comparison,omitempty"`
+	CompletionStage *CompletionStage `json:"-"`
 	Direct         int    `json:"direct"`
 	RW             string `json:"rw"`
+	RWMixRead      int    `json:"rwmixread,omitempty"`
+	RWMixWrite     int    `json:"rwmixwrite,omitempty"`
 	BS             string `json:"bs"`
 	IOEngine       string `json:"ioengine"`
 	IODepth        int    `json:"iodepth"`
@@ -27,92 +56,65 @@ type FioTest struct {
 	TimeBased      bool   `json:"time_based"`
 	GroupReporting bool   `json:"group_reporting"`
 	Runtime        int    `json:"runtime"`
+	ExpectedDuration string `json:"expected_duration,omitempty"`
 	EtaNewline     int    `json:"eta_newline"`
+	ReadIOLog      string `json:"read_iolog,omitempty"`
+	ReplayRedirect string `json:"replay_redirect,omitempty"`
+	ReplayNoStall  bool   `json:"replay_no_stall,omitempty"`
+	CaptureIOLog   bool   `json:"capture_iolog,omitempty"`
+	HistLog        bool   `json:"hist_log,omitempty"`
+	WriteCliff     bool   `json:"write_cliff,omitempty"`
+	Score          *ScoreConfig `json:"score,omitempty"`
+	Blktrace       bool   `json:"blktrace,omitempty"`
+	EBPF           bool   `json:"ebpf,omitempty"`
+	CPUProfile     bool   `json:"cpu_profile,omitempty"`
+	Interrupts     bool   `json:"interrupts,omitempty"`
+	CooldownSeconds int    `json:"cooldown_seconds,omitempty"`
+	CooldownTemp   float64 `json:"cooldown_temp_c,omitempty"`
+	ResetDevice    string `json:"reset_device,omitempty"`
+	FillLevels     []int  `json:"fill_levels,omitempty"`
+	FillLevel      int    `json:"fill_level,omitempty"`
+	FillOf         string `json:"-"`
+	FioBinary      *FioBinary `json:"-"`
+	FioBinaryOf    string     `json:"-"`
+	Experiment     *ExperimentStage `json:"-"`
+	Backend        string     `json:"backend,omitempty"`
+	BackendCommand []string   `json:"backend_command,omitempty"`
+	Endurance      *EnduranceConfig `json:"endurance,omitempty"`
+	Retention      *RetentionConfig `json:"retention,omitempty"`
+	RetentionPhase string           `json:"-"`
+	SLO            *SLOConfig `json:"slo,omitempty"`
+	Percentiles    []float64  `json:"percentiles,omitempty"`
+	JSONPlus       bool       `json:"json_plus,omitempty"`
+	Comparators    []ComparatorConfig `json:"comparators,omitempty"`
+	Baseline       []BaselineConfig   `json:"baseline,omitempty"`
+	Fault          *FaultConfig       `json:"fault,omitempty"`
+	Tuning         map[string]string  `json:"tuning,omitempty"`
+	JobFile        bool               `json:"job_file,omitempty"`
+	Jobs           []FioJobSection    `json:"jobs,omitempty"`
 }
 
 // TestCases represents the structure of the JSON file
 type TestCases struct {
+	Name  string    `json:"name,omitempty"`
 	Tests []FioTest `json:"tests"`
+	// Highlights replace the default highlights of the summary
+	Highlights []HighlightConfig `json:"highlights,omitempty"`
+	// RateCap caps the bandwidth of every test, like 200M
+	RateCap string `json:"rate_cap,omitempty"`
 }
 
-// FioJobResult represents the result of a single fio job
-type FioJobResult struct {
-	JobName   string     `json:"jobname"`
-	Read      FioIO      `json:"read"`
-	Write     FioIO      `json:"write"`
-	Sync      FioSync    `json:"sync"`
-	UsrCPU    float64    `json:"usr_cpu"`
-	SysCPU    float64    `json:"sys_cpu"`
-	Ctx       int64      `json:"ctx"`
-	MajF      int64      `json:"majf"`
-	MinF      int64      `json:"minf"`
-	IODepths  map[string]float64 `json:"iodepth_level"`
-	LatBins   map[string]float64 `json:"latency_ns"`
-}
-
-// FioIO represents read or write statistics
-type FioIO struct {
-	IOPS          float64   `json:"iops"`
-	BWBytes       float64   `json:"bw_bytes"`      // Bandwidth in bytes/sec
-	BWMean        float64   `json:"bw_mean"`       // Bandwidth mean in KiB/s
-	BWMin         float64   `json:"bw_min"`        // Bandwidth min in KiB/s
-	BWMax         float64   `json:"bw_max"`        // Bandwidth max in KiB/s
-	BWDev         float64   `json:"bw_dev"`        // Bandwidth deviation in KiB/s
-	IOKBytes      float64   `json:"io_kbytes"`
-	Runtime       float64   `json:"runtime"`
-	Slat          FioLatNs  `json:"slat_ns"`
-	Clat          FioClat   `json:"clat_ns"`
-	LatNs         FioLatNs  `json:"lat_ns"`
-	IOPSMin       float64   `json:"iops_min"`
-	IOPSMax       float64   `json:"iops_max"`
-	IOPSMean      float64   `json:"iops_mean"`
-	IOPSStddev    float64   `json:"iops_stddev"`
-}
-
-// FioLatNs represents latency in nanoseconds
-type FioLatNs struct {
-	Min        float64 `json:"min"`
-	Max        float64 `json:"max"`
-	Mean       float64 `json:"mean"`
-	Stddev     float64 `json:"stddev"`
-	Percentile map[string]float64 `json:"percentile"`
-}
-
-// FioClat represents completion latency
-type FioClat struct {
-	Min        float64 `json:"min"`
-	Max        float64 `json:"max"`
-	Mean       float64 `json:"mean"`
-	Stddev     float64 `json:"stddev"`
-	Percentile map[string]float64 `json:"percentile"`
-}
-
-// FioSync represents sync statistics
-type FioSync struct {
-	LatNs FioLatNs `json:"lat_ns"`
-}
-
-// FioDiskUtil represents disk utilization statistics
-type FioDiskUtil struct {
-	Name        string  `json:"name"`
-	ReadIOs     int64   `json:"read_ios"`
-	WriteIOs    int64   `json:"write_ios"`
-	ReadSectors int64   `json:"read_sectors"`
-	WriteSectors int64  `json:"write_sectors"`
-	ReadMerges  int64   `json:"read_merges"`
-	WriteMerges int64   `json:"write_merges"`
-	ReadTicks   int64   `json:"read_ticks"`
-	WriteTicks  int64   `json:"write_ticks"`
-	InQueue     int64   `json:"in_queue"`
-	Util        float64 `json:"util"`
-}
-
-// FioOutput represents the complete fio JSON output
-type FioOutput struct {
-	FioVersion string         `json:"fio version"`
-	Jobs       []FioJobResult `json:"jobs"`
-	DiskUtil   []FioDiskUtil  `json:"disk_util"`
-}
+// The JSON output of fio, parsed by the runner package so that the command
+// and applications embedding fio-qa read the same numbers from it
+type (
+	FioOutput    = runner.Output
+	FioJobResult = runner.JobResult
+	FioIO        = runner.IO
+	FioLatNs     = runner.LatNs
+	FioClat      = runner.Clat
+	FioSync      = runner.Sync
+	FioDiskUtil  = runner.DiskUtil
+)
 
 // TestResult stores the parsed results from a test
 type TestResult struct {
@@ -130,57 +132,618 @@ type TestResult struct {
 	Duration       time.Duration
 	Status         string
 	Error          error
+	Cancelled      bool
 	FioJob         *FioJobResult
 	DiskUtil       []FioDiskUtil
+	Replay         *IOLogInfo
+	Artifacts      []string
+	Config         FioTest
+	FioArgs        []string
+	Warnings       []Warning
+	Power          *PowerStats
+	Blktrace       *BlktraceSummary
+	BPF            *BPFLatency
+	Dmesg          []DmesgFinding
+	Device         *DeviceMetadata
+	Cooldown       *CooldownInfo
+	IdleProbe      *IdleProbe
+	GCSettle       *GCSettle
+	Reset          *ResetInfo
+	Fill           *FillInfo
+	Endurance      *EnduranceSummary
+	Retention      *RetentionResult
+	Metadata       *MetadataResult
+	Laydown        *LaydownInfo
+	SLO            *SLOResult
+	Heatmap        *LatencyHeatmap
+	HistBins       [2][]int64
+	Percentiles    []PercentileValue
+	Baseline       []BaselineSnapshot
+	Anomalies      []Anomaly
+	Fault          *FaultInfo
+	HostCeiling    *HostCeiling
+	Capacity       *CapacityMetrics
+	Cost           *CostMetrics
+	WriteCliff     *WriteCliff
+	CPU            *CPUProfile
+	IRQ            *IRQStats
+	Tuning         []QueueSetting
+	FioArgChanges  []FioArgChange
+	JobFile        string
+	Jobs           []JobSectionResult
+	Region         *RegionInfo
+	NVMe           *NVMeNamespace
+	NoisyNeighbor  *NoisyNeighborStage
+	// WallTime includes the cooldown, reset and preconditioning of the test
+	WallTime       time.Duration
+	Verdicts       []MetricVerdict
+	Suspect        *SuspectResult
+	Memory         *MemoryBackend
+	CompletionStats map[string]float64
+}
+
+// RunInfo holds information about the environment the tests were run in
+type RunInfo struct {
+	ID           string
+	Namespace    string
+	Timestamp    string
+	TempDir      string
+	ArtifactsDir string
+	Container    *ContainerInfo
+	Suite        string
+	ShuffleSeed  *int64
+	Campaign     string
+	Experiment     *Experiment
+	ExperimentSeed int64
+	Labels         map[string]string
 }
 
 func main() {
-	fmt.Println("=== FIO Disk Performance Testing Tool ===")
-	fmt.Println()
+	// Subcommands like validate replace the test run
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
 
-	// Check if fio is installed
-	if !checkFioInstalled() {
-		fmt.Println("Error: fio is not installed or not in PATH")
-		fmt.Println("Please install fio before running this tool")
-		os.Exit(1)
+	parseOptions()
+	if opts.JSON {
+		out = os.Stderr
+	}
+	if err := openProgress(opts.ProgressJSON); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	setTableWidth()
+	columns, err := parseSummaryColumns(opts.SummaryColumns)
+	if err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := setReportLocale(opts.Locale); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := setDigitGrouping(opts.DigitGrouping, opts.Locale); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkResultsName(opts.ResultsName); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if exportPrecision, err = parseJSONPrecision(opts.JSONPrecision); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if opts.AnomalyRuns < 1 {
+		fatal(exitUsage, "--anomaly-runs must be at least 1")
+	}
+	reportTemplates, err := loadReportTemplates(opts.ReportTemplates)
+	if err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if opts.SheetsID != "" {
+		if sheetsAccount, err = loadServiceAccount(opts.SheetsCredentials); err != nil {
+			fatal(exitUsage, "%v", err)
+		}
 	}
 
-	// Load test cases
-	testCases, err := loadTestCases("fio-testcases.json")
+	fmt.Fprintln(out, "=== FIO Disk Performance Testing Tool ===")
+	fmt.Fprintln(out)
+
+	now := time.Now()
+	if err := startRunID(now); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := startNamespace(); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	run := RunInfo{
+		ID:        runID,
+		Namespace: namespace,
+		Timestamp: now.Format("2006-01-02-150405"),
+	}
+	startAudit(now, run)
+	if run.Labels, err = parseLabels(opts.Labels); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if len(configFiles) > 0 {
+		fmt.Fprintf(out, "Config: %s\n", strings.Join(configPaths(), ", "))
+	}
+	if run.Namespace != "" {
+		fmt.Fprintf(out, "Namespace: %s\n", run.Namespace)
+	}
+	if len(run.Labels) > 0 {
+		fmt.Fprintf(out, "Labels: %s\n", formatLabels(run.Labels))
+	}
+	fmt.Fprintf(out, "Run ID: %s\n\n", run.ID)
+	campaign, err := openCampaign(opts.Campaign)
+	if err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if campaign != nil {
+		run.Campaign = campaign.Name
+	}
+	run.ArtifactsDir = filepath.Join(opts.ArtifactsDir, run.Namespace, run.Timestamp+"-"+run.ID)
+	if opts.Containerize != "" {
+		// Run fio from the container image instead of the host
+		container, err := inspectContainer(opts.Containerize)
+		if err != nil {
+			fatal(exitEnvironment, "cannot use container image %s: %v", opts.Containerize, err)
+		}
+		run.Container = container
+		fmt.Fprintf(out, "Running %s in container %s (%s)\n", container.FioVersion, container.Image, container.Digest)
+		fmt.Fprintln(out)
+	} else if len(opts.FioBins) == 0 && !checkFioInstalled() {
+		// Check if fio is installed
+		fatal(exitEnvironment, "fio is not installed or not in PATH, please install fio before running this tool")
+	}
+	if _, err := exec.LookPath("ioping"); err != nil && opts.IdleProbe {
+		fatal(exitEnvironment, "--idle-probe needs ioping: %v", err)
+	} else if err != nil && opts.GCSettle {
+		fatal(exitEnvironment, "--gc-settle needs ioping: %v", err)
+	}
+	// Every test runs once per fio binary given with --fio-bin
+	fioBinaries, err := inspectFioBinaries(opts.FioBins)
 	if err != nil {
-		fmt.Printf("Error loading test cases: %v\n", err)
-		os.Exit(1)
+		fatal(exitEnvironment, "%v", err)
+	}
+	for _, binary := range fioBinaries {
+		fmt.Fprintf(out, "fio binary %s: %s\n", binary.Path, binary.Version)
 	}
 
-	fmt.Printf("Loaded %d test cases\n", len(testCases.Tests))
-	fmt.Println()
+	// Load test cases, from all suites of a manifest with --suite or a
+	// built-in profile with --profile
+	var testCases *TestCases
+	if opts.Suite != "" && opts.Profile != "" {
+		fatal(exitUsage, "--suite and --profile cannot be combined")
+	}
+	if opts.Suite != "" {
+		testCases, err = loadSuiteManifest(opts.Suite)
+	} else if opts.Profile != "" {
+		testCases, err = loadProfile(opts.Profile, opts.Target)
+	} else {
+		testCases, err = loadTestCases("fio-testcases.json")
+	}
+	if err != nil {
+		fatal(exitUsage, "loading test cases: %v", err)
+	}
+	run.Suite = testCases.Name
+	if len(testCases.Highlights) > 0 {
+		if err := checkHighlights(testCases.Highlights); err != nil {
+			fatal(exitUsage, "%v", err)
+		}
+		highlights = testCases.Highlights
+	}
+	// Tests of the same name would overwrite each other's output
+	for _, rename := range uniqueTestNames(testCases.Tests) {
+		fmt.Fprintf(out, "Warning: %s\n", rename)
+	}
 
-	// Run all tests and collect results
+	// An experiment runs the tests once per variant and repetition
+	experiment, err := loadExperiment(opts.Experiment)
+	if err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if experiment != nil {
+		run.Experiment = experiment
+		run.ExperimentSeed = opts.Seed
+		if run.ExperimentSeed == 0 {
+			run.ExperimentSeed = time.Now().UnixNano()
+		}
+		testCases.Tests, err = experiment.expand(testCases.Tests, run.ExperimentSeed)
+		if err != nil {
+			fatal(exitUsage, "%v", err)
+		}
+		fmt.Fprintf(out, "Experiment %s: %d variants, %d repetitions, variant order seed %d (repeat with --seed %d)\n",
+			experiment.Name, len(experiment.Variants), experiment.Repetitions, run.ExperimentSeed, run.ExperimentSeed)
+	}
+	if err := checkResetDevice(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkSLOs(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkExpectedDurations(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkSectorOptions(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkMemoryOptions(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkPercentiles(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkComparators(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkBaselines(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkFaults(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkTuning(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkScores(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkJobSections(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkRegions(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkNVMe(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkBackends(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkNoisyNeighbors(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkPolledComparisons(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	// Shared storage is protected by a rate limit on every test
+	if err := resolveRateCap(opts.RateCap, testCases.RateCap); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := checkRateCap(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if rateCap > 0 {
+		fmt.Fprintf(out, "Rate cap: %s per test\n", formatRate(rateCap))
+	}
+	if err := checkIRQMatch(); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	if err := loadCalibration(opts.Calibration); err != nil {
+		fatal(exitUsage, "loading host ceiling: %v", err)
+	}
+	if err := loadCostModel(opts.CostModel); err != nil {
+		fatal(exitUsage, "loading cost model: %v", err)
+	}
+
+	fmt.Fprintf(out, "Loaded %d test cases\n", len(testCases.Tests))
+
+	// Run the tests in random order to reveal order-dependent effects
+	if opts.Shuffle {
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		shuffleTests(testCases.Tests, seed)
+		run.ShuffleSeed = &seed
+		fmt.Fprintf(out, "Shuffled test order with seed %d (repeat with --shuffle --seed %d)\n", seed, seed)
+	}
+
+	// Tests on an NVMe controller run once per namespace
+	testCases.Tests, err = expandNVMeNamespaces(testCases.Tests)
+	if err != nil {
+		fatal(exitEnvironment, "%v", err)
+	}
+
+	// Noisy neighbor tests run as one stage per aggressor load
+	testCases.Tests = expandNoisyNeighbors(testCases.Tests)
+
+	// Polled comparisons run the job with interrupt and polled completion
+	testCases.Tests = expandPolledComparisons(testCases.Tests)
+
+	// Tests with fill levels run as one stage per level, kept together
+	testCases.Tests, err = expandFillLevels(testCases.Tests)
+	if err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+	testCases.Tests = expandFioBinaries(testCases.Tests, fioBinaries)
+	if err := checkTestNames(testCases.Tests); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+
+	// Devices holding data are only read from
+	if opts.ReadOnly {
+		total := len(testCases.Tests)
+		testCases.Tests = skipWritingTests(testCases.Tests)
+		if len(testCases.Tests) == 0 {
+			fatal(exitUsage, "--read-only skipped all %d tests, none of them only reads", total)
+		}
+	}
+	fmt.Fprintln(out)
+
+	if opts.Check {
+		checkTests(testCases.Tests)
+		return
+	}
+
+	// Keep other instances off the devices and out of the temporary files
+	if err := lockTargets(testCases.Tests); err != nil {
+		fatal(exitEnvironment, "%v", err)
+	}
+	if err := createRunTempDir(&run); err != nil {
+		fatal(exitEnvironment, "%v", err)
+	}
+
+	// Run all tests and collect results, an interrupt cancels the running
+	// test and keeps the results so far
+	ctx := interruptContext()
 	var results []TestResult
+	session := campaign.startSession(run, testCases.Tests)
+	emitProgress(ProgressEvent{Event: eventSuiteStarted, Total: len(testCases.Tests)})
+	eta := newETAEstimator(testCases.Tests)
 	for i, test := range testCases.Tests {
-		fmt.Printf("[%d/%d] Running test: %s\n", i+1, len(testCases.Tests), test.Description)
-		fmt.Println(strings.Repeat("=", 80))
+		if ctx.Err() != nil {
+			fmt.Fprintf(out, "Interrupted, %d of %d tests were not run\n", len(testCases.Tests)-i, len(testCases.Tests))
+			auditEvent(auditInterrupted, "", fmt.Sprintf("%d of %d tests were not run", len(testCases.Tests)-i, len(testCases.Tests)))
+			break
+		}
+		started := time.Now()
+		left, known := eta.remaining(testCases.Tests[i:])
+		if !opts.Lite {
+			if known {
+				fmt.Fprintf(out, "[%d/%d] Running test: %s (%s)\n", i+1, len(testCases.Tests), test.Description, etaLabel(left))
+			} else {
+				fmt.Fprintf(out, "[%d/%d] Running test: %s\n", i+1, len(testCases.Tests), test.Description)
+			}
+			fmt.Fprintln(out, strings.Repeat("=", 80))
+		}
+		startedEvent := ProgressEvent{Event: eventTestStarted, Index: i + 1, Total: len(testCases.Tests), TestName: test.Name, Description: test.Description}
+		if known {
+			startedEvent.RemainingSeconds = left.Seconds()
+		}
+		emitProgress(startedEvent)
+		auditEvent(auditTestStarted, test.Name, "")
 
-		result := runTest(test)
-		results = append(results, result)
+		// Let the device cool down from the previous test
+		var pause *CooldownInfo
+		if i > 0 {
+			pause = cooldown(test)
+		}
+
+		// Probe how responsive the device is around the test, write-heavy
+		// tests need the idle latency to settle to afterwards
+		settle := opts.GCSettle && writeHeavy(test)
+		var probe *IdleProbe
+		var probeErr error
+		if opts.IdleProbe || settle {
+			probe = &IdleProbe{}
+			probe.BeforeUs, probeErr = probeIdleLatency(test)
+		}
+
+		var result TestResult
+		if test.Endurance != nil {
+			result = runEndurance(ctx, test, run)
+		} else if test.Retention != nil {
+			result = runRetention(ctx, test, run)
+		} else {
+			result = runTest(ctx, test, run)
+		}
+		result.Cooldown = pause
+		result.Capacity = capacityMetrics(result)
+		result.Cost = costMetrics(result)
+		if probe != nil {
+			if probeErr == nil {
+				probe.AfterUs, probeErr = probeIdleLatency(test)
+			}
+			if probeErr != nil {
+				result.warn(severityWarning, "monitor", "idle latency probe failed: %v", probeErr)
+			}
+			checkIdleProbe(probe, &result)
+			result.IdleProbe = probe
+		}
+
+		// Wait for the garbage collection the writes left behind
+		if settle && !result.Cancelled {
+			gc, err := settleGC(test)
+			if err != nil {
+				result.warn(severityWarning, "monitor", "cannot wait for the device to settle: %v", err)
+			} else if gc.TimedOut {
+				result.warn(severityWarning, "busy_device", "device did not settle below %.0f%s within %s, the next test may be affected", gc.ThresholdUs, usUnit(), opts.GCSettleMax)
+			}
+			result.GCSettle = gc
+		}
+		if pause != nil && pause.TargetTemp > 0 && pause.EndTemp == 0 {
+			result.warn(severityWarning, "monitor", "device temperature unavailable, the cooldown only waited %.0fs", pause.Seconds)
+		} else if pause != nil && pause.TimedOut {
+			result.warn(severityWarning, "thermal_throttle", "device still at %.0f%s after cooling down for %.0fs, above the %.0f%s target", pause.EndTemp, celsiusUnit(), pause.Seconds, pause.TargetTemp, celsiusUnit())
+		}
+		if opts.AnomalyAlerts {
+			detectAnomalies(&result)
+		}
+		result.WallTime = time.Since(started)
+		eta.finished(test, result.WallTime)
+		auditEvent(auditTestFinished, test.Name, result.Status)
+		emitProgress(testFinishedEvent(result, i+1, len(testCases.Tests)))
+		if err := appendHistory(newHistoryRecord(historyResult, run, result)); err != nil {
+			fmt.Fprintf(out, "Warning: failed to record the result in history: %v\n", err)
+		}
 
 		// Display individual test result
-		displayTestResult(result)
-		fmt.Println()
+		if opts.Lite {
+			displayLiteResult(result, i+1, len(testCases.Tests))
+		} else {
+			displayTestResult(result)
+			fmt.Fprintln(out)
+		}
+
+		if opts.StreamResults != "" {
+			if err := streamResult(result); err != nil {
+				fmt.Fprintf(out, "Warning: failed to stream the result of %s: %v\n", test.Name, err)
+			}
+		}
+		session.record(result)
+		if opts.Lite {
+			result.compact()
+		}
+		results = append(results, result)
 	}
 
 	// Display summary of all tests
-	displaySummary(results)
+	if opts.Lite {
+		displayLiteSummary(results)
+	} else {
+		displaySummary(results, columns)
+	}
+
+	if ctx.Err() != nil && len(results) == len(testCases.Tests) {
+		auditEvent(auditInterrupted, "", "the last test was cancelled")
+	}
+	finishAudit(results)
+	jsonResults := buildJSONResults(results, run)
+	jsonResults.Interrupted = ctx.Err() != nil
+	exitCode := exitOK
+	if jsonResults.Summary.Failed > 0 || jsonResults.Interrupted {
+		exitCode = exitTestsFailed
+	}
+
+	// The devices are free for other instances again
+	os.RemoveAll(run.TempDir)
+	releaseLocks()
+
+	if err := campaign.finish(session); err != nil {
+		fmt.Fprintf(out, "Warning: Failed to save the campaign: %v\n", err)
+		exitCode = exitOutput
+	} else if campaign != nil && opts.Lite {
+		fmt.Fprintf(out, "Campaign %s: session %d saved to %s\n", campaign.Name, len(campaign.Sessions), campaign.path)
+	} else if campaign != nil {
+		displayCampaign(campaign)
+	}
 
 	// Save results to JSON file with timestamp
-	timestamp := time.Now().Format("2006-01-02-150405")
-	filename := fmt.Sprintf("test_results-%s.json", timestamp)
-	err = saveResultsToJSON(results, filename)
+	filename, err := saveResults(run, ".json", func(file string) error { return saveResultsToJSON(jsonResults, file) })
 	if err != nil {
-		fmt.Printf("Warning: Failed to save results to JSON: %v\n", err)
+		fmt.Fprintf(out, "Warning: Failed to save results to JSON: %v\n", err)
+		filename = ""
+		exitCode = exitOutput
 	} else {
-		fmt.Printf("\nResults saved to: %s\n", filename)
+		fmt.Fprintf(out, "\nResults saved to: %s\n", filename)
 	}
+	if opts.Proto {
+		protoFile := strings.TrimSuffix(filename, ".json") + ".pb"
+		if filename == "" {
+			protoFile = resultsName(run) + "-" + run.ID + ".pb"
+		}
+		if err := saveResultsToProto(jsonResults, protoFile); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to save results to protobuf: %v\n", err)
+			exitCode = exitOutput
+		} else {
+			fmt.Fprintf(out, "Results saved to: %s\n", protoFile)
+		}
+	}
+	if opts.XLSX {
+		xlsxFile := strings.TrimSuffix(filename, ".json") + ".xlsx"
+		if filename == "" {
+			xlsxFile = resultsName(run) + "-" + run.ID + ".xlsx"
+		}
+		if err := saveResultsToXLSX(jsonResults, xlsxFile); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to save results to XLSX: %v\n", err)
+			exitCode = exitOutput
+		} else {
+			fmt.Fprintf(out, "Results saved to: %s\n", xlsxFile)
+		}
+	}
+	if opts.PDF != "" {
+		if err := saveResultsToPDF(jsonResults, opts.PDF); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to save the PDF report: %v\n", err)
+			exitCode = exitOutput
+		} else {
+			fmt.Fprintf(out, "Report saved to: %s\n", opts.PDF)
+		}
+	}
+	for _, tmpl := range reportTemplates {
+		resultsFile := filename
+		if resultsFile == "" {
+			resultsFile = resultsName(run) + "-" + run.ID + ".json"
+		}
+		reportFile := reportFilename(resultsFile, tmpl.path)
+		if err := tmpl.render(newReportData(jsonResults, filename), reportFile); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to render the report template %s: %v\n", tmpl.path, err)
+			exitCode = exitOutput
+		} else {
+			fmt.Fprintf(out, "Report saved to: %s\n", reportFile)
+		}
+	}
+
+	if sheetsAccount != nil {
+		if err := appendToSheet(results, run, opts.SheetsID, opts.SheetsRange); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to append the results to the Google Sheet: %v\n", err)
+			exitCode = exitOutput
+		} else {
+			fmt.Fprintf(out, "Results appended to the Google Sheet %s (%s)\n", opts.SheetsID, opts.SheetsRange)
+		}
+	}
+
+	emitProgress(ProgressEvent{
+		Event:       eventSuiteFinished,
+		Total:       jsonResults.Summary.TotalTests,
+		Passed:      &jsonResults.Summary.Passed,
+		Failed:      &jsonResults.Summary.Failed,
+		Warnings:    &jsonResults.Summary.Warnings,
+		ResultsFile: filename,
+		ExitCode:    &exitCode,
+	})
+	writeReport(JSONReport{
+		JSONResults: &jsonResults,
+		ResultsFile: filename,
+		ExitCode:    exitCode,
+	})
+	os.Exit(exitCode)
+}
+
+// checkTests shows the fio commands that would be run without running them
+func checkTests(tests []FioTest) {
+	planned := make([]JSONPlannedTest, 0, len(tests))
+	for i, test := range tests {
+		// The command runTest would start, adapted to the installed fio and
+		// wrapped into the container
+		fioArgs, changes := adaptFioArgs(test, buildFioCommand(test))
+		args := fioCommand(test, append(fioArgs, "--output-format="+fioOutputFormat(test))).Args
+		if backend := backends[test.Backend]; backend != nil {
+			args = backend.Command(test)
+			changes = nil
+		}
+		fmt.Fprintf(out, "[%d/%d] Would run test: %s\n", i+1, len(tests), test.Description)
+		fmt.Fprintf(out, "  %s\n", strings.Join(args, " "))
+		for _, change := range changes {
+			if change.Replacement != "" {
+				fmt.Fprintf(out, "  %s is %s, using %s\n", change.Arg, change.Reason, change.Replacement)
+			} else {
+				fmt.Fprintf(out, "  %s is %s and would be left out\n", change.Arg, change.Reason)
+			}
+		}
+		if err := checkTarget(test.Filename); err != nil {
+			fatal(exitUsage, "test %s: %v", test.Name, err)
+		}
+		if test.ReadIOLog != "" {
+			if _, err := inspectIOLog(test.ReadIOLog); err != nil {
+				fatal(exitUsage, "test %s: invalid replay log: %v", test.Name, err)
+			}
+		}
+
+		planned = append(planned, JSONPlannedTest{
+			TestName:    test.Name,
+			Description: test.Description,
+			Command:     args,
+		})
+	}
+
+	writeReport(JSONReport{PlannedTests: planned, ExitCode: exitOK})
 }
 
 func checkFioInstalled() bool {
@@ -194,6 +757,7 @@ func loadTestCases(filename string) (*TestCases, error) {
 	if err != nil {
 		return nil, err
 	}
+	auditConfigFile(filename, data)
 
 	var testCases TestCases
 	err = json.Unmarshal(data, &testCases)
@@ -204,69 +768,405 @@ func loadTestCases(filename string) (*TestCases, error) {
 	return &testCases, nil
 }
 
-func runTest(test FioTest) TestResult {
+func runTest(ctx context.Context, test FioTest, run RunInfo) TestResult {
 	result := TestResult{
 		TestName:    test.Name,
 		Description: test.Description,
 		Status:      "FAILED",
+		Config:      test,
+		NVMe:        test.NVMeNamespace,
+	}
+	if test.NoisyNeighborStage != nil {
+		stage := *test.NoisyNeighborStage
+		result.NoisyNeighbor = &stage
+	}
+
+	if err := checkTarget(test.Filename); err != nil {
+		result.Error = err
+		return result
+	}
+	if isMetadataTest(test) {
+		if err := checkMetadataTest(test); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+	if engine := platformIOEngine(test.IOEngine); engine != test.IOEngine && test.IOEngine != "" {
+		result.warn(severityNotice, "config", "ioengine %s is not available on %s, using %s", test.IOEngine, runtime.GOOS, engine)
+	}
+
+	result.Device, _ = targetDevice(testTarget(test))
+
+	// Direct IO has to be aligned to the sectors of the device
+	test, err := alignToSectors(test, &result)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.Config = test
+	result.Memory = memoryBackend(test)
+	if err := checkPolling(test, result.Device); err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Start from a cold cache so earlier tests do not affect this one
+	if opts.DropCaches {
+		if err := dropCaches(); err != nil {
+			result.warn(severityWarning, "cache_pollution", "cannot drop caches before the test: %v", err)
+		}
+	}
+
+	// Start from a known device state
+	if test.ResetDevice != "" {
+		reset, err := resetDevice(test)
+		if err != nil {
+			result.Error = fmt.Errorf("device reset failed: %v", err)
+			return result
+		}
+		result.Reset = reset
+	}
+
+	// Fill the target up to the level of a fill level stage
+	if test.FillLevel > 0 {
+		fill, err := fillTarget(ctx, test)
+		if err != nil {
+			result.Error = err
+			if ctx.Err() != nil {
+				result.cancel()
+			}
+			return result
+		}
+		result.Fill = fill
+	}
+
+	// Limit the test to its region of the target
+	if test.Region != nil {
+		region, err := resolveRegion(test)
+		if err != nil {
+			result.Error = fmt.Errorf("cannot resolve region: %v", err)
+			return result
+		}
+		result.Region = region
+		test.Offset = strconv.FormatInt(region.Offset, 10)
+		test.Size = strconv.FormatInt(region.Size, 10)
+	}
+
+	// Set the queue parameters of the tuning for this test only
+	if len(test.Tuning) > 0 {
+		device, settings, err := applyTuning(test)
+		if err != nil {
+			result.Error = fmt.Errorf("tuning failed: %v", err)
+			return result
+		}
+		result.Tuning = settings
+		defer func() {
+			if err := restoreTuning(device, settings); err != nil {
+				fmt.Fprintf(out, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	// Set up the variant of an experiment for this test
+	if stage := test.Experiment; stage != nil {
+		if err := runVariantCommands(stage.variant.Setup); err != nil {
+			result.Error = fmt.Errorf("experiment variant %s setup failed: %v", stage.Variant, err)
+			return result
+		}
+		defer func() {
+			if err := runVariantCommands(stage.variant.Teardown); err != nil {
+				fmt.Fprintf(out, "Warning: experiment variant %s teardown failed: %v\n", stage.Variant, err)
+			}
+		}()
+	}
+
+	// Run on a device-mapper target injecting faults over the real device
+	if test.Fault != nil {
+		fault, err := setupFault(test, run)
+		if err != nil {
+			result.Error = fmt.Errorf("fault injection setup failed: %v", err)
+			return result
+		}
+		result.Fault = fault
+		test.Filename = fault.Mapper
+		defer func() {
+			if err := removeFault(fault); err != nil {
+				fmt.Fprintf(out, "Warning: cannot remove %s: %v\n", fault.Mapper, err)
+			}
+		}()
+	}
+
+	// Other generators than fio measure the test on their own
+	if backend := backends[test.Backend]; backend != nil {
+		runBackend(ctx, backend, test, run, &result)
+		return result
+	}
+
+	// Lay out the files of a many-file dataset before the measurement, so
+	// the time it takes is reported on its own
+	if needsLaydown(test) {
+		laydown, err := layDownFiles(ctx, test)
+		if err != nil {
+			result.Error = err
+			if ctx.Err() != nil {
+				result.cancel()
+			}
+			return result
+		}
+		result.Laydown = laydown
 	}
 
 	start := time.Now()
 
+	// Validate the replay log before handing it to fio
+	if test.ReadIOLog != "" {
+		replay, err := inspectIOLog(test.ReadIOLog)
+		if err != nil {
+			result.Error = fmt.Errorf("invalid replay log: %v", err)
+			return result
+		}
+		result.Replay = replay
+		// fio only replays plain logs
+		if test.ReadIOLog, err = decompressArtifact(test.ReadIOLog, run.TempDir); err != nil {
+			result.Error = fmt.Errorf("cannot decompress replay log: %v", err)
+			return result
+		}
+	}
+
 	// Build fio command
 	args := buildFioCommand(test)
+	var files []string
+
+	// Capture the IO pattern into the artifact bundle for later replay
+	var iologFile string
+	if test.CaptureIOLog || opts.CaptureIOLog {
+		path, err := artifactPath(run, test, "fio.iolog")
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create artifact directory: %v", err)
+			return result
+		}
+		iologFile = path
+		args = append(args, fmt.Sprintf("--write_iolog=%s", iologFile))
+		files = append(files, iologFile)
+	}
+
+	// Log completion latency histograms per interval for the heatmap
+	var histPrefix string
+	if test.HistLog || opts.HistLog {
+		path, err := artifactPath(run, test, "fio")
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create artifact directory: %v", err)
+			return result
+		}
+		histPrefix = path
+		args = append(args, fmt.Sprintf("--write_hist_log=%s", histPrefix), fmt.Sprintf("--log_hist_msec=%d", opts.HistLogMsec))
+		files = append(files, histPrefix)
+	}
+
+	// Log the write bandwidth per interval to find the write cliff
+	var bwPrefix string
+	if writeCliffEnabled(test) {
+		path, err := artifactPath(run, test, "fio")
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create artifact directory: %v", err)
+			return result
+		}
+		bwPrefix = path
+		args = append(args, fmt.Sprintf("--write_bw_log=%s", bwPrefix), fmt.Sprintf("--log_avg_msec=%d", writeCliffLogMsec))
+		files = append(files, bwPrefix)
+	}
+
+	// Leave out or translate what the installed fio does not support
+	args, changes := adaptFioArgs(test, args)
+	for _, change := range changes {
+		if change.Replacement != "" {
+			result.warn(severityNotice, "config", "%s is %s, using %s", change.Arg, change.Reason, change.Replacement)
+		} else {
+			result.warn(severityWarning, "config", "%s is %s and was left out", change.Arg, change.Reason)
+		}
+	}
+	result.FioArgChanges = changes
+	result.FioArgs = append([]string(nil), args...)
+
+	// Pass the job options in a job file kept with the artifacts
+	var jobFile string
+	if useJobFile(test) {
+		path, err := artifactPath(run, test, sanitizeName(test.Name)+".fio")
+		if err == nil {
+			args, err = writeJobFile(path, args, rateCapSections(test))
+		}
+		if err != nil {
+			result.Error = fmt.Errorf("failed to write job file: %v", err)
+			return result
+		}
+		jobFile = path
+		result.JobFile = path
+		result.Artifacts = append(result.Artifacts, path)
+		files = append(files, path)
+	}
 
 	// Create temporary file for JSON output
-	tmpFile := fmt.Sprintf("/tmp/fio_output_%s_%d.json", test.Name, time.Now().Unix())
-	args = append(args, "--output-format=json", fmt.Sprintf("--output=%s", tmpFile))
+	tmp, err := os.CreateTemp(run.TempDir, fmt.Sprintf("fio_output_%s_*.json", sanitizeName(test.Name)))
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create temporary file: %v", err)
+		return result
+	}
+	tmp.Close()
+	tmpFile := tmp.Name()
+	args = append(args, "--output-format="+fioOutputFormat(test), fmt.Sprintf("--output=%s", tmpFile))
+	files = append(files, tmpFile)
+
+	// Observers follow the test through the status lines of fio, which it
+	// only prints on a terminal unless told otherwise
+	var watch io.Writer
+	if runner.Observed() {
+		args = append(args, "--eta=always")
+		watch = &runner.StatusWatcher{Test: test.Name, Emit: emitProgress}
+	}
+	if jobFile != "" {
+		args = append(args, jobFile)
+	}
 
-	// Run fio command
-	cmd := exec.Command("fio", args...)
-	output, err := cmd.CombinedOutput()
+	// Run fio command while the monitors collect system data
+	monitors := startMonitors(newMonitors(test, run), &result)
+	cmd := fioCommand(test, args, files...)
+	output, err := runFio(ctx, cmd, watch)
+	stopMonitors(monitors, &result)
 
 	result.Duration = time.Since(start)
 
-	if err != nil {
+	if iologFile != "" {
+		result.Artifacts = append(result.Artifacts, compressArtifacts(collectArtifacts(iologFile), &result)...)
+	}
+	if histPrefix != "" && err == nil {
+		collectHistLogs(histPrefix, &result)
+	}
+	if bwPrefix != "" && err == nil {
+		collectBWLogs(bwPrefix, &result)
+	}
+
+	// An interrupted fio still writes the results of the part that ran
+	if err != nil && ctx.Err() == nil {
 		result.Error = fmt.Errorf("fio command failed: %v\nOutput: %s", err, string(output))
+		if opts.DebugOnFailure {
+			captureFioDebug(test, run, args, files, &result)
+		}
 		return result
 	}
 
+	// Keep the raw output compressed in the artifact bundle, the results
+	// are parsed from the archived copy
+	outputFile := tmpFile
+	if opts.CompressArtifacts {
+		path, err := artifactPath(run, test, "fio-output.json.gz")
+		if err == nil {
+			err = compressFileTo(tmpFile, path)
+		}
+		if err != nil {
+			result.warn(severityWarning, "monitor", "cannot keep the fio output in the artifact bundle: %v", err)
+		} else {
+			outputFile = path
+			result.Artifacts = append(result.Artifacts, path)
+		}
+	}
+
 	// Parse JSON output
-	fioOutput, err := parseFioOutput(tmpFile)
+	fioOutput, err := parseFioOutput(outputFile)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to parse fio output: %v", err)
+		if ctx.Err() != nil {
+			result.cancel()
+		}
 		return result
 	}
+	for _, line := range fioOutput.Stray {
+		severity := severityNotice
+		if strings.Contains(strings.ToLower(line), "error") {
+			severity = severityWarning
+		}
+		result.warn(severity, "fio_output", "%s", line)
+	}
 
 	// Extract metrics
 	if len(fioOutput.Jobs) > 0 {
 		job := fioOutput.Jobs[0]
+		if len(test.Jobs) > 0 {
+			// Sections run at the same time and add up to the test
+			job = extractJobSections(test, result.FioArgs, fioOutput.GlobalOptions, fioOutput.Jobs, &result)
+		}
+		if result.NoisyNeighbor != nil {
+			result.NoisyNeighbor.measure(result.Jobs)
+		}
 
 		result.ReadIOPS = job.Read.IOPS
 		result.WriteIOPS = job.Write.IOPS
-		result.TotalIOPS = result.ReadIOPS + result.WriteIOPS
 
 		result.ReadBWMBps = float64(job.Read.BWBytes) / 1024 / 1024
 		result.WriteBWMBps = float64(job.Write.BWBytes) / 1024 / 1024
-		result.TotalBWMBps = result.ReadBWMBps + result.WriteBWMBps
 
 		// Convert latency from ns to us
 		result.ReadLatencyUs = job.Read.LatNs.Mean / 1000
 		result.WriteLatencyUs = job.Write.LatNs.Mean / 1000
-
-		if result.ReadIOPS > 0 && result.WriteIOPS > 0 {
-			result.AvgLatencyUs = (result.ReadLatencyUs + result.WriteLatencyUs) / 2
-		} else if result.ReadIOPS > 0 {
-			result.AvgLatencyUs = result.ReadLatencyUs
-		} else {
-			result.AvgLatencyUs = result.WriteLatencyUs
-		}
+		result.sumDirections()
 
 		// Store full job result and disk util
 		result.FioJob = &job
 		result.DiskUtil = fioOutput.DiskUtil
+		if isMetadataTest(test) {
+			result.Metadata = metadataResult(test, result)
+		}
+		if test.CompletionStage != nil {
+			result.CompletionStats = completionStats(&job)
+		}
+
+		if result.Power != nil {
+			result.Power.updateEfficiency(result)
+		}
+		if result.BPF != nil {
+			result.BPF.compare(result)
+		}
+		if result.CPU != nil {
+			result.CPU.analyze(&result)
+		}
+		if result.Fault != nil {
+			result.Fault.IOErrors = job.TotalErr
+		}
+
+		// Warn about options fio ignored or adjusted, sections are checked
+		// on their own
+		if len(test.Jobs) == 0 {
+			for _, warning := range checkJobOptions(test, result.FioArgs, fioOutput.GlobalOptions, job) {
+				result.warn(severityWarning, "config", "%s", warning)
+			}
+		}
+		analyzeResult(test, &result)
+		checkDuration(test, &result)
+		evaluateSLO(test, &result)
+		compareCalibration(&result)
+		computePercentiles(test, &result)
+
+		if ctx.Err() != nil {
+			result.cancel()
+			os.Remove(tmpFile)
+			return result
+		}
 
 		result.Status = "PASSED"
+
+		// fio may complete while the kernel reported errors for the device
+		if critical := criticalDmesgFindings(result.Dmesg); critical > 0 && opts.DmesgFail {
+			result.Status = "FAILED"
+			result.Error = fmt.Errorf("kernel log reported %d critical errors during the test", critical)
+		}
+
+		// Statistical baselines from the history store
+		evaluateBaselines(test, &result)
+
+		// Custom pass criteria of the test
+		result.Verdicts = thresholdVerdicts(test, result)
+		if result.Status == "PASSED" {
+			applyComparators(test, &result)
+		}
 	}
 
 	// Clean up temp file
@@ -275,14 +1175,43 @@ func runTest(test FioTest) TestResult {
 	return result
 }
 
+// fioPath is the fio binary a test runs with, the one given with --fio-bin
+// or fio from PATH
+func fioPath(test FioTest) string {
+	if test.FioBinary != nil {
+		return test.FioBinary.Path
+	}
+	return "fio"
+}
+
+// fioCommand returns the command running fio with the given arguments,
+// either directly on the host or inside the configured container image
+func fioCommand(test FioTest, args []string, files ...string) *exec.Cmd {
+	if opts.Containerize == "" {
+		return exec.Command(fioPath(test), args...)
+	}
+
+	runArgs := append(containerArgs(test, files...), fioPath(test))
+	return exec.Command(opts.ContainerRuntime, append(runArgs, args...)...)
+}
+
+// fioOutputFormat is the output format fio reports a test in, json+ adds
+// the full latency histograms for the extreme percentiles
+func fioOutputFormat(test FioTest) string {
+	if test.JSONPlus {
+		return "json+"
+	}
+	return "json"
+}
+
 func buildFioCommand(test FioTest) []string {
 	args := []string{
-		fmt.Sprintf("--filename=%s", test.Filename),
+		fmt.Sprintf("--filename=%s", fioFilename(test.Filename)),
 		fmt.Sprintf("--size=%s", test.Size),
 		fmt.Sprintf("--direct=%d", test.Direct),
 		fmt.Sprintf("--rw=%s", test.RW),
 		fmt.Sprintf("--bs=%s", test.BS),
-		fmt.Sprintf("--ioengine=%s", test.IOEngine),
+		fmt.Sprintf("--ioengine=%s", platformIOEngine(test.IOEngine)),
 		fmt.Sprintf("--iodepth=%d", test.IODepth),
 		fmt.Sprintf("--numjobs=%d", test.NumJobs),
 		fmt.Sprintf("--name=%s", test.Name),
@@ -290,91 +1219,276 @@ func buildFioCommand(test FioTest) []string {
 		fmt.Sprintf("--eta-newline=%d", test.EtaNewline),
 	}
 
+	if test.RWMixRead > 0 || test.RWMixWrite > 0 {
+		args = append(args, fmt.Sprintf("--rwmixread=%d", readMix(test)))
+	}
+
+	if test.Offset != "" {
+		args = append(args, fmt.Sprintf("--offset=%s", test.Offset))
+	}
+
+	if test.OffsetIncrement != "" {
+		args = append(args, fmt.Sprintf("--offset_increment=%s", test.OffsetIncrement))
+	}
+
+	if test.BlockAlign != "" {
+		args = append(args, fmt.Sprintf("--blockalign=%s", test.BlockAlign))
+	}
+
+	if test.Atomic {
+		args = append(args, "--atomic=1")
+	}
+
+	if test.CompletionStage != nil && test.CompletionStage.Mode == completionPolled {
+		args = append(args, "--hipri")
+	}
+
+	if test.IOMem != "" {
+		args = append(args, fmt.Sprintf("--iomem=%s", test.IOMem))
+	}
+
+	if test.LockMem != "" {
+		args = append(args, fmt.Sprintf("--lockmem=%s", test.LockMem))
+	}
+
 	if test.TimeBased {
 		args = append(args, "--time_based")
 	}
 
+	// Many-file datasets, fio names the files in the directory when no
+	// filename is given and splits the size between them without filesize
+	if test.Directory != "" {
+		args = append(args, fmt.Sprintf("--directory=%s", test.Directory))
+	}
+	if test.NrFiles > 0 {
+		args = append(args, fmt.Sprintf("--nrfiles=%d", test.NrFiles))
+	}
+	fileSize := test.FileSize
+	if isMetadataTest(test) {
+		fileSize = metadataFileSize(test)
+	}
+	if fileSize != "" {
+		args = append(args, fmt.Sprintf("--filesize=%s", fileSize))
+	}
+	if test.OpenFiles > 0 {
+		args = append(args, fmt.Sprintf("--openfiles=%d", test.OpenFiles))
+	}
+	if test.FileServiceType != "" {
+		args = append(args, fmt.Sprintf("--file_service_type=%s", test.FileServiceType))
+	}
+	// Metadata engines do not use the size of the test
+	if test.Filename == "" || isMetadataTest(test) {
+		args = dropEmptyOptions(args)
+	}
+
 	if test.GroupReporting {
 		args = append(args, "--group_reporting")
 	}
 
+	args = append(args, rateCapArgs(test)...)
+
+	args = append(args, retentionArgs(test)...)
+
+	// Injected IO errors are counted instead of stopping fio
+	if test.Fault != nil && test.Fault.injectsErrors() {
+		args = append(args, "--continue_on_error=all")
+	}
+
+	if test.ReadIOLog != "" {
+		args = append(args, fmt.Sprintf("--read_iolog=%s", test.ReadIOLog))
+		if test.ReplayRedirect != "" {
+			args = append(args, fmt.Sprintf("--replay_redirect=%s", test.ReplayRedirect))
+		}
+		if test.ReplayNoStall {
+			args = append(args, "--replay_no_stall=1")
+		}
+		// The IO pattern comes from the log, so workload options may be left out
+		args = dropEmptyOptions(args)
+		args = dropZeroOptions(args, "iodepth", "numjobs")
+	}
+
 	return args
 }
 
+// parseFioOutput parses the fio JSON output in a file, which may be gzip
+// compressed, see runner.ParseOutput
 func parseFioOutput(filename string) (*FioOutput, error) {
-	data, err := os.ReadFile(filename)
+	file, err := openArtifact(filename)
 	if err != nil {
 		return nil, err
 	}
-
-	var fioOutput FioOutput
-	err = json.Unmarshal(data, &fioOutput)
-	if err != nil {
-		return nil, err
-	}
-
-	return &fioOutput, nil
+	defer file.Close()
+	return runner.ParseOutput(file)
 }
 
 func displayTestResult(result TestResult) {
 	if result.Status == "FAILED" {
-		table := tablewriter.NewWriter(os.Stdout)
+		table := tablewriter.NewWriter(out)
 		table.SetHeader([]string{"Metric", "Value"})
 		configureTable(table, 2)
-		table.Append([]string{"Status", fmt.Sprintf("❌ %s", result.Status)})
+		table.Append([]string{"Status", statusLabel(result.Status)})
 		if result.Error != nil {
 			table.Append([]string{"Error", result.Error.Error()})
 		}
 		table.Render()
+		fmt.Fprintln(out)
+		if len(result.Verdicts) > 0 {
+			displayVerdicts(result.Verdicts)
+		}
+		if len(result.Warnings) > 0 {
+			displayWarnings(result.Warnings)
+		}
+		if len(result.Dmesg) > 0 {
+			displayDmesg(result.Dmesg)
+		}
 		return
 	}
 
 	job := result.FioJob
 
 	// Test Info
-	fmt.Println("Test Information")
-	infoTable := tablewriter.NewWriter(os.Stdout)
+	fmt.Fprintln(out, "Test Information")
+	infoTable := tablewriter.NewWriter(out)
 	infoTable.SetHeader([]string{"Metric", "Value"})
 	configureTable(infoTable, 2)
-	infoTable.Append([]string{"Status", "✅ PASSED"})
+	infoTable.Append([]string{"Status", statusLabel(result.Status)})
 	infoTable.Append([]string{"Test Name", result.TestName})
 	infoTable.Append([]string{"Description", result.Description})
 	infoTable.Append([]string{"Duration", result.Duration.Round(time.Second).String()})
+	if result.Config.ExpectedDuration != "" {
+		infoTable.Append([]string{"Expected Duration", result.Config.ExpectedDuration})
+	}
+	if result.Memory != nil {
+		infoTable.Append([]string{"Memory Backend", result.Memory.String()})
+	}
+	if result.Config.Backend != "" {
+		infoTable.Append([]string{"Backend", result.Config.Backend})
+	}
+	if result.Device != nil {
+		infoTable.Append([]string{"Device", result.Device.String()})
+	}
+	if result.NVMe != nil {
+		infoTable.Append([]string{"NVMe Namespace", fmt.Sprintf("%s namespace %d (%s)", result.NVMe.Controller, result.NVMe.NSID, result.NVMe.Device)})
+	}
+	if result.Cooldown != nil {
+		infoTable.Append([]string{"Cooldown Before Test", result.Cooldown.String()})
+	}
+	if result.IdleProbe != nil {
+		infoTable.Append([]string{"Idle Latency Probe", result.IdleProbe.String()})
+	}
+	if result.GCSettle != nil {
+		infoTable.Append([]string{"GC Settling After Test", result.GCSettle.String()})
+	}
+	if result.Reset != nil {
+		infoTable.Append([]string{"Device Reset", fmt.Sprintf("%s (%.0fs)", result.Reset.Method, result.Reset.Seconds)})
+	}
+	if result.Fill != nil {
+		infoTable.Append([]string{"Fill Level", fmt.Sprintf("%d%% (%.1f GB)", result.Fill.LevelPercent, float64(result.Fill.FilledBytes)/1e9)})
+	}
+	if result.Region != nil {
+		infoTable.Append([]string{"Region", result.Region.String()})
+	}
+	if n := result.NoisyNeighbor; n != nil {
+		infoTable.Append([]string{"Noisy Neighbor", fmt.Sprintf("aggressor %s, victim p99 %.2f %s", n.label(), n.VictimP99Us, usUnit())})
+	}
+	if e := result.Endurance; e != nil {
+		infoTable.Append([]string{"Endurance", fmt.Sprintf("%d checkpoints over %s, IOPS %+.1f%%", e.Checkpoints, e.Elapsed, e.IOPSChangePc)})
+		if e.StartSMART != nil && e.EndSMART != nil {
+			infoTable.Append([]string{"Wear (Percentage Used)", fmt.Sprintf("%.0f%% to %.0f%%", e.StartSMART.PercentageUsed, e.EndSMART.PercentageUsed)})
+		}
+	}
+	if result.Retention != nil {
+		infoTable.Append([]string{"Retention", result.Retention.String()})
+	}
+	if result.Metadata != nil {
+		infoTable.Append([]string{"Metadata", result.Metadata.String()})
+	}
+	if result.Laydown != nil {
+		infoTable.Append([]string{"Laydown", result.Laydown.String()})
+	}
+	if len(result.Tuning) > 0 {
+		infoTable.Append([]string{"Queue Tuning", tuningSummary(result.Tuning)})
+	}
+	if result.Fault != nil {
+		infoTable.Append([]string{"Fault Injection", result.Fault.String()})
+	}
+	if result.SLO != nil {
+		infoTable.Append([]string{"Latency SLO", result.SLO.String()})
+	}
+	if result.HostCeiling != nil {
+		infoTable.Append([]string{"Host Ceiling", result.HostCeiling.String()})
+	}
+	if result.Capacity != nil {
+		infoTable.Append([]string{"Per Capacity", result.Capacity.String()})
+	}
+	if result.Cost != nil {
+		infoTable.Append([]string{"Cost", result.Cost.String()})
+	}
+	if result.WriteCliff != nil {
+		infoTable.Append([]string{"Write Cliff", result.WriteCliff.String()})
+	}
+	for _, baseline := range result.Baseline {
+		infoTable.Append([]string{"Baseline", baseline.String()})
+	}
+	for _, anomaly := range result.Anomalies {
+		infoTable.Append([]string{"Anomaly", anomaly.String()})
+	}
+	for _, artifact := range result.Artifacts {
+		infoTable.Append([]string{"Artifact", artifact})
+	}
+	if result.Replay != nil {
+		infoTable.Append([]string{"Replay Log", fmt.Sprintf("%s (%s, %d IOs)", result.Replay.Path, result.Replay.Format, result.Replay.IOs)})
+	}
 	infoTable.Render()
-	fmt.Println()
+	fmt.Fprintln(out)
+
+	// Effective configuration
+	displayConfig(result)
+
+	// Caveats that do not fail the test
+	if len(result.Warnings) > 0 {
+		displayWarnings(result.Warnings)
+	}
+
+	// Metrics against the thresholds of the test
+	if len(result.Verdicts) > 0 {
+		displayVerdicts(result.Verdicts)
+	}
 
 	// IOPS Statistics
-	fmt.Println("IOPS Statistics")
-	iopsTable := tablewriter.NewWriter(os.Stdout)
+	fmt.Fprintln(out, "IOPS Statistics")
+	iopsTable := tablewriter.NewWriter(out)
 	iopsTable.SetHeader([]string{"", "Read", "Write", "Total"})
 	configureTable(iopsTable, 4)
-	iopsTable.Append([]string{"IOPS", fmt.Sprintf("%.0f", result.ReadIOPS), fmt.Sprintf("%.0f", result.WriteIOPS), fmt.Sprintf("%.0f", result.TotalIOPS)})
+	iopsTable.Rich([]string{"IOPS", formatCount(result.ReadIOPS), formatCount(result.WriteIOPS), formatCount(result.TotalIOPS)},
+		[]tablewriter.Colors{{}, {}, {}, verdictColor(verdictOf(result.Verdicts, "iops"))})
 	if job != nil {
-		iopsTable.Append([]string{"IOPS Min", fmt.Sprintf("%.0f", job.Read.IOPSMin), fmt.Sprintf("%.0f", job.Write.IOPSMin), "-"})
-		iopsTable.Append([]string{"IOPS Max", fmt.Sprintf("%.0f", job.Read.IOPSMax), fmt.Sprintf("%.0f", job.Write.IOPSMax), "-"})
-		iopsTable.Append([]string{"IOPS Avg", fmt.Sprintf("%.0f", job.Read.IOPSMean), fmt.Sprintf("%.0f", job.Write.IOPSMean), "-"})
-		iopsTable.Append([]string{"IOPS StdDev", fmt.Sprintf("%.0f", job.Read.IOPSStddev), fmt.Sprintf("%.0f", job.Write.IOPSStddev), "-"})
+		iopsTable.Append([]string{"IOPS Min", formatCount(job.Read.IOPSMin), formatCount(job.Write.IOPSMin), "-"})
+		iopsTable.Append([]string{"IOPS Max", formatCount(job.Read.IOPSMax), formatCount(job.Write.IOPSMax), "-"})
+		iopsTable.Append([]string{"IOPS Avg", formatCount(job.Read.IOPSMean), formatCount(job.Write.IOPSMean), "-"})
+		iopsTable.Append([]string{"IOPS StdDev", formatCount(job.Read.IOPSStddev), formatCount(job.Write.IOPSStddev), "-"})
 	}
 	iopsTable.Render()
-	fmt.Println()
+	fmt.Fprintln(out)
 
 	// Bandwidth Statistics
-	fmt.Println("Bandwidth Statistics")
-	bwTable := tablewriter.NewWriter(os.Stdout)
+	fmt.Fprintln(out, "Bandwidth Statistics")
+	bwTable := tablewriter.NewWriter(out)
 	bwTable.SetHeader([]string{"", "Read (MB/s)", "Write (MB/s)", "Total (MB/s)"})
 	configureTable(bwTable, 4)
-	bwTable.Append([]string{"Bandwidth", fmt.Sprintf("%.2f", result.ReadBWMBps), fmt.Sprintf("%.2f", result.WriteBWMBps), fmt.Sprintf("%.2f", result.TotalBWMBps)})
+	bwTable.Rich([]string{"Bandwidth", fmt.Sprintf("%.2f", result.ReadBWMBps), fmt.Sprintf("%.2f", result.WriteBWMBps), fmt.Sprintf("%.2f", result.TotalBWMBps)},
+		[]tablewriter.Colors{{}, {}, {}, verdictColor(verdictOf(result.Verdicts, "bw"))})
 	if job != nil {
 		bwTable.Append([]string{"BW Min", fmt.Sprintf("%.2f", job.Read.BWMin/1024), fmt.Sprintf("%.2f", job.Write.BWMin/1024), "-"})
 		bwTable.Append([]string{"BW Max", fmt.Sprintf("%.2f", job.Read.BWMax/1024), fmt.Sprintf("%.2f", job.Write.BWMax/1024), "-"})
 		bwTable.Append([]string{"BW Avg", fmt.Sprintf("%.2f", job.Read.BWMean/1024), fmt.Sprintf("%.2f", job.Write.BWMean/1024), "-"})
 	}
 	bwTable.Render()
-	fmt.Println()
+	fmt.Fprintln(out)
 
 	// Latency Statistics
-	fmt.Println("Latency Statistics (microseconds)")
-	latTable := tablewriter.NewWriter(os.Stdout)
+	fmt.Fprintln(out, "Latency Statistics (microseconds)")
+	latTable := tablewriter.NewWriter(out)
 	latTable.SetHeader([]string{"", "Read", "Write"})
 	configureTable(latTable, 3)
 	if job != nil {
@@ -394,13 +1508,18 @@ func displayTestResult(result TestResult) {
 		latTable.Append([]string{"Total Lat StdDev", fmt.Sprintf("%.2f", job.Read.LatNs.Stddev/1000), fmt.Sprintf("%.2f", job.Write.LatNs.Stddev/1000)})
 	}
 	latTable.Render()
-	fmt.Println()
+	fmt.Fprintln(out)
+
+	// Performance of every job section of the test
+	if len(result.Jobs) > 0 {
+		displayJobSections(result.Jobs)
+	}
 
 	// Completion Latency Percentiles
 	if job != nil && len(job.Read.Clat.Percentile) > 0 {
-		fmt.Println("Completion Latency Percentiles (microseconds) - Read")
-		percTable := tablewriter.NewWriter(os.Stdout)
-		percTable.SetHeader([]string{"Percentile", "Latency (μs)"})
+		fmt.Fprintln(out, "Completion Latency Percentiles (microseconds) - Read")
+		percTable := tablewriter.NewWriter(out)
+		percTable.SetHeader([]string{"Percentile", "Latency (" + usUnit() + ")"})
 		configureTable(percTable, 2)
 
 		// Sort and display key percentiles
@@ -413,13 +1532,21 @@ func displayTestResult(result TestResult) {
 			}
 		}
 		percTable.Render()
-		fmt.Println()
+		fmt.Fprintln(out)
+	}
+
+	// Percentiles requested with --percentiles
+	if len(result.Percentiles) > 0 {
+		displayPercentiles(result.Percentiles)
 	}
 
+	// Latency over time from the histogram logs
+	displayHeatmap(result.Heatmap)
+
 	// CPU Usage
 	if job != nil {
-		fmt.Println("CPU Usage")
-		cpuTable := tablewriter.NewWriter(os.Stdout)
+		fmt.Fprintln(out, "CPU Usage")
+		cpuTable := tablewriter.NewWriter(out)
 		cpuTable.SetHeader([]string{"Metric", "Value"})
 		configureTable(cpuTable, 2)
 		cpuTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
@@ -429,13 +1556,43 @@ func displayTestResult(result TestResult) {
 		cpuTable.Append([]string{"Major Faults", fmt.Sprintf("%d", job.MajF)})
 		cpuTable.Append([]string{"Minor Faults", fmt.Sprintf("%d", job.MinF)})
 		cpuTable.Render()
-		fmt.Println()
+		fmt.Fprintln(out)
+	}
+
+	// Kernel log messages logged during the test
+	if len(result.Dmesg) > 0 {
+		displayDmesg(result.Dmesg)
+	}
+
+	// Block layer latency breakdown
+	if result.Blktrace != nil {
+		displayBlktrace(result.Blktrace)
+	}
+
+	// Kernel vs device latency attribution
+	if result.BPF != nil {
+		displayBPFLatency(result.BPF)
+	}
+
+	// Host CPU use and CPU-bound detection
+	if result.CPU != nil {
+		displayCPUProfile(result.CPU)
+	}
+
+	// Interrupts of the device and the CPUs handling them
+	if result.IRQ != nil {
+		displayIRQStats(result.IRQ)
+	}
+
+	// Power Consumption
+	if result.Power != nil {
+		displayPower(result.Power)
 	}
 
 	// Disk Utilization
 	if len(result.DiskUtil) > 0 {
-		fmt.Println("Disk Utilization")
-		diskTable := tablewriter.NewWriter(os.Stdout)
+		fmt.Fprintln(out, "Disk Utilization")
+		diskTable := tablewriter.NewWriter(out)
 		diskTable.SetHeader([]string{"Device", "Rd IOPS", "Wr IOPS", "Rd Sectors", "Wr Sectors", "Util%"})
 		configureTable(diskTable, 6)
 		diskTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
@@ -450,7 +1607,7 @@ func displayTestResult(result TestResult) {
 			})
 		}
 		diskTable.Render()
-		fmt.Println()
+		fmt.Fprintln(out)
 	}
 }
 
@@ -472,45 +1629,51 @@ func configureTable(table *tablewriter.Table, colCount int) {
 	table.SetBorder(true)
 	table.SetRowLine(true)
 	table.SetAutoWrapText(false)
-	table.SetColumnSeparator("│")
-	table.SetCenterSeparator("┼")
-	table.SetRowSeparator("─")
-
-	// Set fixed column widths to ensure all tables have identical total width
-	// Target: all tables aligned at ~115 characters total width
-	// Each cell format: │ space content space │
-	switch colCount {
-	case 2: // Test Info, CPU Usage, Percentiles
-		table.SetColMinWidth(0, 40)
-		table.SetColMinWidth(1, 66)
-		// Total: 40 + 66 + 9 separators ≈ 115 chars
-	case 3: // Latency (3 cols), Highlights
-		table.SetColMinWidth(0, 40)
-		table.SetColMinWidth(1, 32)
-		table.SetColMinWidth(2, 31)
-		// Total: 40 + 32 + 31 + 4 separators = 113 chars (aligned with 2-col tables)
-	case 4: // IOPS, Bandwidth
-		table.SetColMinWidth(0, 40)
-		table.SetColMinWidth(1, 20)
-		table.SetColMinWidth(2, 20)
-		table.SetColMinWidth(3, 20)
-		// Total: 40 + 20*3 + 15 separators ≈ 115 chars
-	case 6: // Details, Disk Utilization
-		table.SetColMinWidth(0, 40)
-		table.SetColMinWidth(1, 11)
-		table.SetColMinWidth(2, 11)
-		table.SetColMinWidth(3, 11)
-		table.SetColMinWidth(4, 11)
-		table.SetColMinWidth(5, 10)
-		// Total: 40 + 11*4 + 10 + 21 separators ≈ 115 chars (aligned with CPU table)
+	setTableSeparators(table)
+
+	// All tables share the same total width, see columnWidths
+	widest := 0
+	for i, width := range columnWidths(colCount) {
+		table.SetColMinWidth(i, width)
+		if width > widest {
+			widest = width
+		}
+	}
+
+	// Narrow tables wrap long values instead of overflowing the terminal
+	if tableWidth < defaultTableWidth {
+		table.SetAutoWrapText(true)
+		table.SetColWidth(widest)
 	}
 }
 
 // JSONResults represents the complete test results in JSON format
 type JSONResults struct {
+	RunID              string                 `json:"run_id,omitempty"`
+	Namespace          string                 `json:"namespace,omitempty"`
 	Summary            JSONSummary            `json:"summary"`
+	Interrupted        bool                   `json:"interrupted,omitempty"`
 	TestResults        []JSONTestResult       `json:"test_results"`
 	PerformanceHighlights JSONPerformanceHighlights `json:"performance_highlights"`
+	Score              *JSONScore             `json:"score,omitempty"`
+	ArtifactsDir       string                 `json:"artifacts_dir,omitempty"`
+	Container          *ContainerInfo         `json:"container,omitempty"`
+	Suite              string                 `json:"suite,omitempty"`
+	Suites             []JSONSuiteSummary     `json:"suites,omitempty"`
+	NVMeControllers    []NVMeRollup           `json:"nvme_controllers,omitempty"`
+	ShuffleSeed        *int64                 `json:"shuffle_seed,omitempty"`
+	Campaign           string                 `json:"campaign,omitempty"`
+	Labels             map[string]string      `json:"labels,omitempty"`
+	Units              map[string]string      `json:"units,omitempty"`
+	DeviceGroups       []DeviceGroup          `json:"device_groups,omitempty"`
+	Experiment         *ExperimentReport      `json:"experiment,omitempty"`
+	Audit              *AuditTrail            `json:"audit,omitempty"`
+	ConfigHash         string                 `json:"config_hash,omitempty"`
+	RateCap            int64                  `json:"rate_cap_bytes,omitempty"`
+	ReadOnly           bool                   `json:"read_only,omitempty"`
+	SkippedTests       []SkippedTest          `json:"skipped_tests,omitempty"`
+	CompletionComparisons []CompletionComparison `json:"completion_comparisons,omitempty"`
+	SignOff            *SignOff               `json:"sign_off,omitempty"`
 }
 
 // JSONSummary represents the overall summary statistics
@@ -518,6 +1681,8 @@ type JSONSummary struct {
 	TotalTests    int    `json:"total_tests"`
 	Passed        int    `json:"passed"`
 	Failed        int    `json:"failed"`
+	Warnings      int    `json:"warnings"`
+	Skipped       int    `json:"skipped,omitempty"`
 	TotalDuration string `json:"total_duration"`
 }
 
@@ -526,16 +1691,67 @@ type JSONTestResult struct {
 	TestName       string                `json:"test_name"`
 	Description    string                `json:"description"`
 	Status         string                `json:"status"`
+	Cancelled      bool                  `json:"cancelled,omitempty"`
 	Duration       string                `json:"duration"`
 	IOPS           float64               `json:"iops"`
 	BandwidthMBps  float64               `json:"bandwidth_mbps"`
 	LatencyUs      float64               `json:"latency_us"`
+	BaseUnits      *BaseUnits            `json:"base_units,omitempty"`
 	IOPSStats      JSONIOPSStats         `json:"iops_stats"`
 	BandwidthStats JSONBandwidthStats    `json:"bandwidth_stats"`
 	LatencyStats   JSONLatencyStats      `json:"latency_stats"`
 	Percentiles    JSONPercentiles       `json:"latency_percentiles,omitempty"`
 	CPUUsage       JSONCPUUsage          `json:"cpu_usage,omitempty"`
 	DiskUtil       []JSONDiskUtil        `json:"disk_utilization,omitempty"`
+	Replay         *IOLogInfo            `json:"replay,omitempty"`
+	Artifacts      []string              `json:"artifacts,omitempty"`
+	Config         FioTest               `json:"config"`
+	ConfigHash     string                `json:"config_hash,omitempty"`
+	FioArgs        []string              `json:"fio_args,omitempty"`
+	Warnings       []Warning             `json:"warnings,omitempty"`
+	Power          *PowerStats           `json:"power,omitempty"`
+	Blktrace       *BlktraceSummary      `json:"blktrace,omitempty"`
+	BPF            *BPFLatency           `json:"ebpf_latency,omitempty"`
+	Dmesg          []DmesgFinding        `json:"dmesg_findings,omitempty"`
+	Device         *DeviceMetadata       `json:"device,omitempty"`
+	Cooldown       *CooldownInfo         `json:"cooldown,omitempty"`
+	IdleProbe      *IdleProbe            `json:"idle_probe,omitempty"`
+	GCSettle       *GCSettle             `json:"gc_settle,omitempty"`
+	Reset          *ResetInfo            `json:"reset,omitempty"`
+	Fill           *FillInfo             `json:"fill,omitempty"`
+	Endurance      *EnduranceSummary     `json:"endurance,omitempty"`
+	Retention      *RetentionResult      `json:"retention,omitempty"`
+	Metadata       *MetadataResult       `json:"metadata,omitempty"`
+	Laydown        *LaydownInfo          `json:"laydown,omitempty"`
+	SLO            *SLOResult            `json:"slo,omitempty"`
+	Heatmap        *LatencyHeatmap       `json:"latency_heatmap,omitempty"`
+	CustomPercentiles []PercentileValue  `json:"custom_percentiles,omitempty"`
+	Baseline       []BaselineSnapshot    `json:"baseline,omitempty"`
+	Anomalies      []Anomaly             `json:"anomalies,omitempty"`
+	Fault          *FaultInfo            `json:"fault_injection,omitempty"`
+	HostCeiling    *HostCeiling          `json:"host_ceiling,omitempty"`
+	Capacity       *CapacityMetrics      `json:"capacity,omitempty"`
+	Cost           *CostMetrics          `json:"cost,omitempty"`
+	WriteCliff     *WriteCliff           `json:"write_cliff,omitempty"`
+	CPU            *CPUProfile           `json:"cpu_profile,omitempty"`
+	IRQ            *IRQStats             `json:"interrupts,omitempty"`
+	Tuning         []QueueSetting        `json:"tuning,omitempty"`
+	FioArgChanges  []FioArgChange        `json:"fio_arg_changes,omitempty"`
+	JobFile        string                `json:"job_file,omitempty"`
+	Jobs           []JobSectionResult    `json:"jobs,omitempty"`
+	Region         *RegionInfo           `json:"region,omitempty"`
+	NVMe           *NVMeNamespace        `json:"nvme_namespace,omitempty"`
+	NoisyNeighbor  *NoisyNeighborStage   `json:"noisy_neighbor,omitempty"`
+	WallSeconds    float64               `json:"wall_seconds,omitempty"`
+	IODepths       map[string]float64    `json:"iodepth_distribution,omitempty"`
+	Verdicts       []MetricVerdict       `json:"metric_verdicts,omitempty"`
+	Suspect        *SuspectResult        `json:"suspect,omitempty"`
+	Memory         *MemoryBackend        `json:"memory_backend,omitempty"`
+	Completion     *CompletionStage      `json:"completion_mode,omitempty"`
+	FioBinary      *FioBinary            `json:"fio_binary,omitempty"`
+	Experiment     *ExperimentStage      `json:"experiment,omitempty"`
+	RunID          string                `json:"run_id,omitempty"`
+	Namespace      string                `json:"namespace,omitempty"`
 	Error          string                `json:"error,omitempty"`
 }
 
@@ -636,19 +1852,30 @@ type JSONPerformanceHighlights struct {
 	HighestIOPS      JSONHighlight `json:"highest_iops"`
 	HighestBandwidth JSONHighlight `json:"highest_bandwidth"`
 	LowestLatency    JSONHighlight `json:"lowest_latency"`
+	// The worst performers and the tests that failed or passed with
+	// warnings
+	LowestIOPS        *JSONHighlight    `json:"lowest_iops,omitempty"`
+	HighestP99Latency *JSONHighlight    `json:"highest_p99_latency,omitempty"`
+	HighestCV         *JSONHighlight    `json:"highest_cv,omitempty"`
+	ProblemTests      []JSONProblemTest `json:"problem_tests,omitempty"`
+	// Highlights are the highlights of the summary, the defaults or those
+	// of the test cases
+	Highlights []JSONHighlight `json:"highlights,omitempty"`
 }
 
 // JSONHighlight represents a single performance highlight
 type JSONHighlight struct {
+	Label    string  `json:"label,omitempty"`
 	TestName string  `json:"test_name"`
 	Value    float64 `json:"value"`
 	Unit     string  `json:"unit"`
 }
 
-func saveResultsToJSON(results []TestResult, filename string) error {
+func buildJSONResults(results []TestResult, run RunInfo) JSONResults {
 	// Calculate summary statistics
 	passed := 0
 	failed := 0
+	warnings := 0
 	var totalDuration time.Duration
 
 	for _, r := range results {
@@ -657,6 +1884,7 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 		} else {
 			failed++
 		}
+		warnings += len(r.Warnings)
 		totalDuration += r.Duration
 	}
 
@@ -667,7 +1895,8 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 	minLatency.AvgLatencyUs = 999999999
 
 	for _, r := range results {
-		if r.Status == "PASSED" {
+		// File operations are not comparable with IO
+		if r.Status == "PASSED" && r.Metadata == nil {
 			if r.TotalIOPS > maxIOPS.TotalIOPS {
 				maxIOPS = r
 			}
@@ -682,10 +1911,14 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 
 	// Build JSON structure
 	jsonResults := JSONResults{
+		RunID: run.ID,
+		Namespace: run.Namespace,
 		Summary: JSONSummary{
 			TotalTests:    len(results),
 			Passed:        passed,
 			Failed:        failed,
+			Warnings:      warnings,
+			Skipped:       len(skippedTests),
 			TotalDuration: totalDuration.String(),
 		},
 		TestResults: make([]JSONTestResult, 0, len(results)),
@@ -706,156 +1939,242 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 				Unit:     "μs",
 			},
 		},
+		Score:       computeScore(results),
+		Container:   run.Container,
+		Suite:       run.Suite,
+		Suites:      summarizeSuites(results),
+		NVMeControllers: nvmeRollups(results),
+		ShuffleSeed: run.ShuffleSeed,
+		Campaign:    run.Campaign,
+		Labels:      run.Labels,
+		DeviceGroups: deviceGroups(results),
+		Experiment:  experimentReport(run, results),
+		Audit:       audit,
+		ConfigHash:  runConfigHash(results),
+		RateCap:     rateCap,
+		ReadOnly:    opts.ReadOnly,
+		SkippedTests: skippedTests,
+		CompletionComparisons: completionComparisons(results),
+		SignOff:     &SignOff{Status: signOffDraft},
+	}
+	worstHighlights(&jsonResults.PerformanceHighlights, results)
+	if opts.StrictUnits {
+		jsonResults.Units = resultUnits
+	}
+
+	// Only reference the artifact bundle when something was stored in it
+	if _, err := os.Stat(run.ArtifactsDir); err == nil {
+		jsonResults.ArtifactsDir = run.ArtifactsDir
 	}
 
 	// Add test results
 	for _, r := range results {
-		testResult := JSONTestResult{
-			TestName:      r.TestName,
-			Description:   r.Description,
-			Status:        r.Status,
-			Duration:      r.Duration.Round(time.Second).String(),
-			IOPS:          r.TotalIOPS,
-			BandwidthMBps: r.TotalBWMBps,
-			LatencyUs:     r.AvgLatencyUs,
-		}
-
-		// Populate IOPS stats
-		if r.FioJob != nil {
-			testResult.IOPSStats = JSONIOPSStats{
-				Read: JSONIOPSDetail{
-					IOPS:   r.ReadIOPS,
-					Min:    r.FioJob.Read.IOPSMin,
-					Max:    r.FioJob.Read.IOPSMax,
-					Avg:    r.FioJob.Read.IOPSMean,
-					StdDev: r.FioJob.Read.IOPSStddev,
+		jsonResults.TestResults = append(jsonResults.TestResults, buildJSONTestResult(r))
+	}
+
+	return jsonResults
+}
+
+// buildJSONTestResult converts the result of a single test
+func buildJSONTestResult(r TestResult) JSONTestResult {
+	testResult := JSONTestResult{
+		TestName:      r.TestName,
+		Description:   r.Description,
+		Status:        r.Status,
+		Cancelled:     r.Cancelled,
+		Duration:      r.Duration.Round(time.Second).String(),
+		IOPS:          r.TotalIOPS,
+		BandwidthMBps: r.TotalBWMBps,
+		LatencyUs:     r.AvgLatencyUs,
+		Replay:        r.Replay,
+		Artifacts:     r.Artifacts,
+		Config:        r.Config,
+		ConfigHash:    configHash(r.Config),
+		FioArgs:       r.FioArgs,
+		Warnings:      r.Warnings,
+		Power:         r.Power,
+		Blktrace:      r.Blktrace,
+		BPF:           r.BPF,
+		Dmesg:         r.Dmesg,
+		Device:        r.Device,
+		Cooldown:      r.Cooldown,
+		IdleProbe:     r.IdleProbe,
+		GCSettle:      r.GCSettle,
+		Reset:         r.Reset,
+		Fill:          r.Fill,
+		Endurance:     r.Endurance,
+		Retention:     r.Retention,
+		Metadata:      r.Metadata,
+		Laydown:       r.Laydown,
+		SLO:           r.SLO,
+		Heatmap:       r.Heatmap,
+		CustomPercentiles: r.Percentiles,
+		Baseline:      r.Baseline,
+		Anomalies:     r.Anomalies,
+		Fault:         r.Fault,
+		HostCeiling:   r.HostCeiling,
+		Capacity:      r.Capacity,
+		Cost:          r.Cost,
+		WriteCliff:    r.WriteCliff,
+		CPU:           r.CPU,
+		IRQ:           r.IRQ,
+		Tuning:        r.Tuning,
+		FioArgChanges: r.FioArgChanges,
+		JobFile:       r.JobFile,
+		Jobs:          r.Jobs,
+		Region:        r.Region,
+		NVMe:          r.NVMe,
+		NoisyNeighbor: r.NoisyNeighbor,
+		WallSeconds:   r.WallTime.Seconds(),
+		Verdicts:      r.Verdicts,
+		Suspect:       r.Suspect,
+		Memory:        r.Memory,
+		Completion:    r.Config.CompletionStage,
+		FioBinary:     r.Config.FioBinary,
+		Experiment:    r.Config.Experiment,
+	}
+	if opts.StrictUnits {
+		testResult.BaseUnits = baseUnits(r)
+	}
+
+	// Populate IOPS stats
+	if r.FioJob != nil {
+		testResult.IOPSStats = JSONIOPSStats{
+			Read: JSONIOPSDetail{
+				IOPS:   r.ReadIOPS,
+				Min:    r.FioJob.Read.IOPSMin,
+				Max:    r.FioJob.Read.IOPSMax,
+				Avg:    r.FioJob.Read.IOPSMean,
+				StdDev: r.FioJob.Read.IOPSStddev,
+			},
+			Write: JSONIOPSDetail{
+				IOPS:   r.WriteIOPS,
+				Min:    r.FioJob.Write.IOPSMin,
+				Max:    r.FioJob.Write.IOPSMax,
+				Avg:    r.FioJob.Write.IOPSMean,
+				StdDev: r.FioJob.Write.IOPSStddev,
+			},
+			Total: r.TotalIOPS,
+		}
+		testResult.IODepths = r.FioJob.IODepths
+
+		// Populate Bandwidth stats
+		testResult.BandwidthStats = JSONBandwidthStats{
+			Read: JSONBandwidthDetail{
+				BandwidthMBps: r.ReadBWMBps,
+				Min:           r.FioJob.Read.BWMin / 1024,
+				Max:           r.FioJob.Read.BWMax / 1024,
+				Avg:           r.FioJob.Read.BWMean / 1024,
+			},
+			Write: JSONBandwidthDetail{
+				BandwidthMBps: r.WriteBWMBps,
+				Min:           r.FioJob.Write.BWMin / 1024,
+				Max:           r.FioJob.Write.BWMax / 1024,
+				Avg:           r.FioJob.Write.BWMean / 1024,
+			},
+			Total: r.TotalBWMBps,
+		}
+
+		// Populate Latency stats (convert from ns to us)
+		testResult.LatencyStats = JSONLatencyStats{
+			Read: JSONLatencyDetail{
+				SubmissionLat: JSONLatencyMetric{
+					Min:    r.FioJob.Read.Slat.Min / 1000,
+					Max:    r.FioJob.Read.Slat.Max / 1000,
+					Avg:    r.FioJob.Read.Slat.Mean / 1000,
+					StdDev: r.FioJob.Read.Slat.Stddev / 1000,
 				},
-				Write: JSONIOPSDetail{
-					IOPS:   r.WriteIOPS,
-					Min:    r.FioJob.Write.IOPSMin,
-					Max:    r.FioJob.Write.IOPSMax,
-					Avg:    r.FioJob.Write.IOPSMean,
-					StdDev: r.FioJob.Write.IOPSStddev,
+				CompletionLat: JSONLatencyMetric{
+					Min:    r.FioJob.Read.Clat.Min / 1000,
+					Max:    r.FioJob.Read.Clat.Max / 1000,
+					Avg:    r.FioJob.Read.Clat.Mean / 1000,
+					StdDev: r.FioJob.Read.Clat.Stddev / 1000,
 				},
-				Total: r.TotalIOPS,
-			}
-
-			// Populate Bandwidth stats
-			testResult.BandwidthStats = JSONBandwidthStats{
-				Read: JSONBandwidthDetail{
-					BandwidthMBps: r.ReadBWMBps,
-					Min:           r.FioJob.Read.BWMin / 1024,
-					Max:           r.FioJob.Read.BWMax / 1024,
-					Avg:           r.FioJob.Read.BWMean / 1024,
+				TotalLat: JSONLatencyMetric{
+					Min:    r.FioJob.Read.LatNs.Min / 1000,
+					Max:    r.FioJob.Read.LatNs.Max / 1000,
+					Avg:    r.FioJob.Read.LatNs.Mean / 1000,
+					StdDev: r.FioJob.Read.LatNs.Stddev / 1000,
 				},
-				Write: JSONBandwidthDetail{
-					BandwidthMBps: r.WriteBWMBps,
-					Min:           r.FioJob.Write.BWMin / 1024,
-					Max:           r.FioJob.Write.BWMax / 1024,
-					Avg:           r.FioJob.Write.BWMean / 1024,
+			},
+			Write: JSONLatencyDetail{
+				SubmissionLat: JSONLatencyMetric{
+					Min:    r.FioJob.Write.Slat.Min / 1000,
+					Max:    r.FioJob.Write.Slat.Max / 1000,
+					Avg:    r.FioJob.Write.Slat.Mean / 1000,
+					StdDev: r.FioJob.Write.Slat.Stddev / 1000,
 				},
-				Total: r.TotalBWMBps,
-			}
-
-			// Populate Latency stats (convert from ns to us)
-			testResult.LatencyStats = JSONLatencyStats{
-				Read: JSONLatencyDetail{
-					SubmissionLat: JSONLatencyMetric{
-						Min:    r.FioJob.Read.Slat.Min / 1000,
-						Max:    r.FioJob.Read.Slat.Max / 1000,
-						Avg:    r.FioJob.Read.Slat.Mean / 1000,
-						StdDev: r.FioJob.Read.Slat.Stddev / 1000,
-					},
-					CompletionLat: JSONLatencyMetric{
-						Min:    r.FioJob.Read.Clat.Min / 1000,
-						Max:    r.FioJob.Read.Clat.Max / 1000,
-						Avg:    r.FioJob.Read.Clat.Mean / 1000,
-						StdDev: r.FioJob.Read.Clat.Stddev / 1000,
-					},
-					TotalLat: JSONLatencyMetric{
-						Min:    r.FioJob.Read.LatNs.Min / 1000,
-						Max:    r.FioJob.Read.LatNs.Max / 1000,
-						Avg:    r.FioJob.Read.LatNs.Mean / 1000,
-						StdDev: r.FioJob.Read.LatNs.Stddev / 1000,
-					},
+				CompletionLat: JSONLatencyMetric{
+					Min:    r.FioJob.Write.Clat.Min / 1000,
+					Max:    r.FioJob.Write.Clat.Max / 1000,
+					Avg:    r.FioJob.Write.Clat.Mean / 1000,
+					StdDev: r.FioJob.Write.Clat.Stddev / 1000,
 				},
-				Write: JSONLatencyDetail{
-					SubmissionLat: JSONLatencyMetric{
-						Min:    r.FioJob.Write.Slat.Min / 1000,
-						Max:    r.FioJob.Write.Slat.Max / 1000,
-						Avg:    r.FioJob.Write.Slat.Mean / 1000,
-						StdDev: r.FioJob.Write.Slat.Stddev / 1000,
-					},
-					CompletionLat: JSONLatencyMetric{
-						Min:    r.FioJob.Write.Clat.Min / 1000,
-						Max:    r.FioJob.Write.Clat.Max / 1000,
-						Avg:    r.FioJob.Write.Clat.Mean / 1000,
-						StdDev: r.FioJob.Write.Clat.Stddev / 1000,
-					},
-					TotalLat: JSONLatencyMetric{
-						Min:    r.FioJob.Write.LatNs.Min / 1000,
-						Max:    r.FioJob.Write.LatNs.Max / 1000,
-						Avg:    r.FioJob.Write.LatNs.Mean / 1000,
-						StdDev: r.FioJob.Write.LatNs.Stddev / 1000,
-					},
+				TotalLat: JSONLatencyMetric{
+					Min:    r.FioJob.Write.LatNs.Min / 1000,
+					Max:    r.FioJob.Write.LatNs.Max / 1000,
+					Avg:    r.FioJob.Write.LatNs.Mean / 1000,
+					StdDev: r.FioJob.Write.LatNs.Stddev / 1000,
 				},
-			}
-
-			// Populate percentiles (convert from ns to us)
-			if len(r.FioJob.Read.Clat.Percentile) > 0 {
-				testResult.Percentiles = JSONPercentiles{
-					P1:     getPercentile(r.FioJob.Read.Clat.Percentile, "1.000000") / 1000,
-					P5:     getPercentile(r.FioJob.Read.Clat.Percentile, "5.000000") / 1000,
-					P10:    getPercentile(r.FioJob.Read.Clat.Percentile, "10.000000") / 1000,
-					P20:    getPercentile(r.FioJob.Read.Clat.Percentile, "20.000000") / 1000,
-					P30:    getPercentile(r.FioJob.Read.Clat.Percentile, "30.000000") / 1000,
-					P40:    getPercentile(r.FioJob.Read.Clat.Percentile, "40.000000") / 1000,
-					P50:    getPercentile(r.FioJob.Read.Clat.Percentile, "50.000000") / 1000,
-					P60:    getPercentile(r.FioJob.Read.Clat.Percentile, "60.000000") / 1000,
-					P70:    getPercentile(r.FioJob.Read.Clat.Percentile, "70.000000") / 1000,
-					P80:    getPercentile(r.FioJob.Read.Clat.Percentile, "80.000000") / 1000,
-					P90:    getPercentile(r.FioJob.Read.Clat.Percentile, "90.000000") / 1000,
-					P95:    getPercentile(r.FioJob.Read.Clat.Percentile, "95.000000") / 1000,
-					P99:    getPercentile(r.FioJob.Read.Clat.Percentile, "99.000000") / 1000,
-					P99_5:  getPercentile(r.FioJob.Read.Clat.Percentile, "99.500000") / 1000,
-					P99_9:  getPercentile(r.FioJob.Read.Clat.Percentile, "99.900000") / 1000,
-					P99_95: getPercentile(r.FioJob.Read.Clat.Percentile, "99.950000") / 1000,
-					P99_99: getPercentile(r.FioJob.Read.Clat.Percentile, "99.990000") / 1000,
-				}
-			}
+			},
+		}
 
-			// Populate CPU usage
-			testResult.CPUUsage = JSONCPUUsage{
-				UserCPU:         r.FioJob.UsrCPU,
-				SystemCPU:       r.FioJob.SysCPU,
-				ContextSwitches: r.FioJob.Ctx,
-				MajorFaults:     r.FioJob.MajF,
-				MinorFaults:     r.FioJob.MinF,
+		// Populate percentiles (convert from ns to us)
+		if len(r.FioJob.Read.Clat.Percentile) > 0 {
+			testResult.Percentiles = JSONPercentiles{
+				P1:     getPercentile(r.FioJob.Read.Clat.Percentile, "1.000000") / 1000,
+				P5:     getPercentile(r.FioJob.Read.Clat.Percentile, "5.000000") / 1000,
+				P10:    getPercentile(r.FioJob.Read.Clat.Percentile, "10.000000") / 1000,
+				P20:    getPercentile(r.FioJob.Read.Clat.Percentile, "20.000000") / 1000,
+				P30:    getPercentile(r.FioJob.Read.Clat.Percentile, "30.000000") / 1000,
+				P40:    getPercentile(r.FioJob.Read.Clat.Percentile, "40.000000") / 1000,
+				P50:    getPercentile(r.FioJob.Read.Clat.Percentile, "50.000000") / 1000,
+				P60:    getPercentile(r.FioJob.Read.Clat.Percentile, "60.000000") / 1000,
+				P70:    getPercentile(r.FioJob.Read.Clat.Percentile, "70.000000") / 1000,
+				P80:    getPercentile(r.FioJob.Read.Clat.Percentile, "80.000000") / 1000,
+				P90:    getPercentile(r.FioJob.Read.Clat.Percentile, "90.000000") / 1000,
+				P95:    getPercentile(r.FioJob.Read.Clat.Percentile, "95.000000") / 1000,
+				P99:    getPercentile(r.FioJob.Read.Clat.Percentile, "99.000000") / 1000,
+				P99_5:  getPercentile(r.FioJob.Read.Clat.Percentile, "99.500000") / 1000,
+				P99_9:  getPercentile(r.FioJob.Read.Clat.Percentile, "99.900000") / 1000,
+				P99_95: getPercentile(r.FioJob.Read.Clat.Percentile, "99.950000") / 1000,
+				P99_99: getPercentile(r.FioJob.Read.Clat.Percentile, "99.990000") / 1000,
 			}
 		}
 
-		// Populate disk utilization
-		if len(r.DiskUtil) > 0 {
-			testResult.DiskUtil = make([]JSONDiskUtil, 0, len(r.DiskUtil))
-			for _, disk := range r.DiskUtil {
-				testResult.DiskUtil = append(testResult.DiskUtil, JSONDiskUtil{
-					Device:       disk.Name,
-					ReadIOs:      disk.ReadIOs,
-					WriteIOs:     disk.WriteIOs,
-					ReadSectors:  disk.ReadSectors,
-					WriteSectors: disk.WriteSectors,
-					Utilization:  disk.Util,
-				})
-			}
+		// Populate CPU usage
+		testResult.CPUUsage = JSONCPUUsage{
+			UserCPU:         r.FioJob.UsrCPU,
+			SystemCPU:       r.FioJob.SysCPU,
+			ContextSwitches: r.FioJob.Ctx,
+			MajorFaults:     r.FioJob.MajF,
+			MinorFaults:     r.FioJob.MinF,
 		}
+	}
 
-		if r.Error != nil {
-			testResult.Error = r.Error.Error()
+	// Populate disk utilization
+	if len(r.DiskUtil) > 0 {
+		testResult.DiskUtil = make([]JSONDiskUtil, 0, len(r.DiskUtil))
+		for _, disk := range r.DiskUtil {
+			testResult.DiskUtil = append(testResult.DiskUtil, JSONDiskUtil{
+				Device:       disk.Name,
+				ReadIOs:      disk.ReadIOs,
+				WriteIOs:     disk.WriteIOs,
+				ReadSectors:  disk.ReadSectors,
+				WriteSectors: disk.WriteSectors,
+				Utilization:  disk.Util,
+			})
 		}
+	}
 
-		jsonResults.TestResults = append(jsonResults.TestResults, testResult)
+	if r.Error != nil {
+		testResult.Error = r.Error.Error()
 	}
 
+	return testResult
+}
+
+func saveResultsToJSON(jsonResults JSONResults, filename string) error {
 	// Marshal to JSON with pretty printing
 	jsonData, err := json.MarshalIndent(jsonResults, "", "  ")
 	if err != nil {
@@ -863,7 +2182,7 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 	}
 
 	// Write to file
-	err = os.WriteFile(filename, jsonData, 0644)
+	err = os.WriteFile(filename, exportPrecision.apply(jsonData), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write JSON file: %v", err)
 	}
@@ -871,16 +2190,17 @@ func saveResultsToJSON(results []TestResult, filename string) error {
 	return nil
 }
 
-func displaySummary(results []TestResult) {
-	fmt.Println()
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println("=== OVERALL SUMMARY ===")
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println()
+func displaySummary(results []TestResult, columns []string) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	fmt.Fprintln(out, "=== OVERALL SUMMARY ===")
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	fmt.Fprintln(out)
 
 	// Summary statistics
 	passed := 0
 	failed := 0
+	warnings := 0
 	var totalDuration time.Duration
 
 	for _, r := range results {
@@ -889,160 +2209,81 @@ func displaySummary(results []TestResult) {
 		} else {
 			failed++
 		}
+		warnings += len(r.Warnings)
 		totalDuration += r.Duration
 	}
 
 	// Display statistics
-	statsTable := tablewriter.NewWriter(os.Stdout)
+	statsTable := tablewriter.NewWriter(out)
 	statsTable.SetHeader([]string{"Metric", "Value"})
 	configureTable(statsTable, 2)
 	statsTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
-	statsTable.SetHeaderColor(
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgGreenColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgGreenColor},
-	)
+	setHeaderColor(statsTable, 2, tablewriter.FgGreenColor)
 	statsTable.Append([]string{"Total Tests", strconv.Itoa(len(results))})
 	statsTable.Append([]string{"Passed", strconv.Itoa(passed)})
 	statsTable.Append([]string{"Failed", strconv.Itoa(failed)})
+	statsTable.Append([]string{"Warnings", strconv.Itoa(warnings)})
+	if len(skippedTests) > 0 {
+		statsTable.Append([]string{"Skipped (read-only)", strconv.Itoa(len(skippedTests))})
+	}
 	statsTable.Append([]string{"Total Duration", totalDuration.String()})
 	statsTable.Render()
 
-	fmt.Println()
-
-	// Detailed results table
-	detailsTable := tablewriter.NewWriter(os.Stdout)
-	detailsTable.SetHeader([]string{
-		"Test Name",
-		"Status",
-		"IOPS",
-		"BW (MB/s)",
-		"Lat (μs)",
-		"Duration",
-	})
-	// Configure manually instead of using configureTable to have different widths than Disk Utilization
-	detailsTable.SetBorder(true)
-	detailsTable.SetRowLine(true)
-	detailsTable.SetAutoWrapText(false)
-	detailsTable.SetColumnSeparator("│")
-	detailsTable.SetCenterSeparator("┼")
-	detailsTable.SetRowSeparator("─")
-	detailsTable.SetColMinWidth(0, 40)
-	detailsTable.SetColMinWidth(1, 11)
-	detailsTable.SetColMinWidth(2, 11)
-	detailsTable.SetColMinWidth(3, 11)
-	detailsTable.SetColMinWidth(4, 11)
-	detailsTable.SetColMinWidth(5, 10)
-	detailsTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
-	detailsTable.SetHeaderColor(
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor},
-	)
+	fmt.Fprintln(out)
 
-	for _, r := range results {
-		status := "❌"
-		if r.Status == "PASSED" {
-			status = "✅"
-		}
+	// Performance of tests run at several fill levels
+	displayFillLevels(results)
 
-		iops := "-"
-		bw := "-"
-		lat := "-"
+	// Victim latency of noisy neighbor tests by aggressor load
+	displayNoisyNeighbors(results)
 
-		if r.Status == "PASSED" {
-			iops = fmt.Sprintf("%.0f", r.TotalIOPS)
-			bw = fmt.Sprintf("%.2f", r.TotalBWMBps)
-			lat = fmt.Sprintf("%.2f", r.AvgLatencyUs)
-		}
-
-		detailsTable.Append([]string{
-			r.TestName,
-			status,
-			iops,
-			bw,
-			lat,
-			r.Duration.Round(time.Second).String(),
-		})
-	}
+	// Latency of polled comparisons by completion mode
+	displayCompletionComparisons(results)
 
-	detailsTable.Render()
+	// File operations of metadata tests
+	displayMetadata(results)
 
-	// Performance summary
-	fmt.Println()
-	fmt.Println("=== Performance Highlights ===")
+	// Namespaces of the same NVMe controller together
+	if rollups := nvmeRollups(results); len(rollups) > 0 {
+		displayNVMeRollups(rollups)
+	}
 
-	var maxIOPS, maxBW, minLatency TestResult
-	maxIOPS.TotalIOPS = 0
-	maxBW.TotalBWMBps = 0
-	minLatency.AvgLatencyUs = 999999999
+	// Workloads by device when the tests ran on several devices
+	displayDeviceMatrix(results)
 
-	for _, r := range results {
-		if r.Status == "PASSED" {
-			if r.TotalIOPS > maxIOPS.TotalIOPS {
-				maxIOPS = r
-			}
-			if r.TotalBWMBps > maxBW.TotalBWMBps {
-				maxBW = r
-			}
-			if r.AvgLatencyUs < minLatency.AvgLatencyUs && r.AvgLatencyUs > 0 {
-				minLatency = r
-			}
-		}
-	}
+	// Statistics per drive model and firmware of mixed populations, and
+	// what their performance costs
+	groups := deviceGroups(results)
+	displayDeviceGroups(groups)
+	displayCostComparison(groups)
 
-	highlightsTable := tablewriter.NewWriter(os.Stdout)
-	highlightsTable.SetHeader([]string{"Category", "Test", "Value"})
-	configureTable(highlightsTable, 3)
-	highlightsTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
-	highlightsTable.SetHeaderColor(
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgMagentaColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgMagentaColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgMagentaColor},
-	)
+	// Tests by fio binary when running with several --fio-bin
+	displayFioBinaries(results)
 
-	if maxIOPS.TotalIOPS > 0 {
-		testName := maxIOPS.TestName
-		if len(testName) > 32 {
-			testName = testName[:32]
-		}
-		highlightsTable.Append([]string{
-			"Highest IOPS",
-			testName,
-			fmt.Sprintf("%.0f", maxIOPS.TotalIOPS),
-		})
-	}
+	// Variants of an experiment against the control
+	displayExperiment(results)
 
-	if maxBW.TotalBWMBps > 0 {
-		testName := maxBW.TestName
-		if len(testName) > 32 {
-			testName = testName[:32]
-		}
-		highlightsTable.Append([]string{
-			"Highest Bandwidth",
-			testName,
-			fmt.Sprintf("%.2f MB/s", maxBW.TotalBWMBps),
-		})
+	// Results per suite when running a suite manifest
+	if suites := summarizeSuites(results); len(suites) > 0 {
+		displaySuites(suites)
 	}
 
-	if minLatency.AvgLatencyUs < 999999999 {
-		testName := minLatency.TestName
-		if len(testName) > 32 {
-			testName = testName[:32]
-		}
-		highlightsTable.Append([]string{
-			"Lowest Latency",
-			testName,
-			fmt.Sprintf("%.2f μs", minLatency.AvgLatencyUs),
-		})
+	// Weighted suite score, when tests define score references
+	if score := computeScore(results); score != nil {
+		displayScore(score)
 	}
 
-	highlightsTable.Render()
+	// Detailed results table
+	displayResultsTable(results, columns)
 
-	fmt.Println()
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println("Testing completed!")
-	fmt.Println(strings.Repeat("=", 80))
+	// Performance summary
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "=== Performance Highlights ===")
+	displayHighlights(results)
+	displayProblemTests(results)
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	fmt.Fprintln(out, "Testing completed!")
+	fmt.Fprintln(out, strings.Repeat("=", 80))
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeJSONPlusFixture writes a json+ output of jobs jobs and clients
+// client stats like a distributed run reports them, all with bins
+// completion latency bins. Like fio every key is on a line of its own.
+// Returns the size of the file.
+func writeJSONPlusFixture(tb testing.TB, path string, jobs, clients, bins int) int64 {
+	tb.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	writeJobs := func(jobs int) {
+		for j := 0; j < jobs; j++ {
+			if j > 0 {
+				w.WriteString(",\n")
+			}
+			fmt.Fprintf(w, "{\n\"jobname\" : \"job%d\",\n\"read\" : {\n\"iops\" : 1000,\n\"bw_bytes\" : 4096000,\n\"runtime\" : 10000,\n", j)
+			w.WriteString("\"clat_ns\" : {\n\"min\" : 1000,\n\"max\" : 900000,\n\"mean\" : 80000,\n\"stddev\" : 2000,\n")
+			w.WriteString("\"percentile\" : {\n\"50.000000\" : 80000,\n\"99.000000\" : 120000,\n\"99.900000\" : 200000\n},\n\"bins\" : {\n")
+			for i := 0; i < bins; i++ {
+				if i > 0 {
+					w.WriteString(",\n")
+				}
+				fmt.Fprintf(w, "\"%d\" : %d", 1000+i*64, i%97+1)
+			}
+			w.WriteString("\n}\n}\n},\n\"write\" : {\n\"iops\" : 0\n},\n\"usr_cpu\" : 1.5,\n\"sys_cpu\" : 3.2\n}")
+		}
+	}
+	w.WriteString("{\n\"fio version\" : \"fio-3.36\",\n\"jobs\" : [\n")
+	writeJobs(jobs)
+	w.WriteString("\n],\n\"client_stats\" : [\n")
+	writeJobs(clients)
+	w.WriteString("\n]\n}\n")
+	if err := w.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return info.Size()
+}
+
+// peakHeap runs fn and returns the highest heap in use above the heap
+// before it, sampled every millisecond
+func peakHeap(fn func()) uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapInuse, stats.HeapInuse
+
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var stats runtime.MemStats
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				peak = max(peak, stats.HeapInuse)
+			}
+		}
+	}()
+	fn()
+	close(done)
+	<-sampled
+	return peak - base
+}
+
+// BenchmarkParseFioOutput decodes json+ outputs of 16 jobs with growing
+// client stats and reports the peak heap next to the allocations. Streamed,
+// the peak stays at what the jobs kept take however large the file grows,
+// as the file is never held in memory as a whole and the client stats are
+// skipped; the unmarshal baseline decoding the file in one piece grows with
+// the file.
+func BenchmarkParseFioOutput(b *testing.B) {
+	for _, clients := range []int{0, 128, 512} {
+		path := filepath.Join(b.TempDir(), "fio_output.json")
+		size := writeJSONPlusFixture(b, path, 16, clients, 4096)
+		b.Run(fmt.Sprintf("clients=%d/stream", clients), func(b *testing.B) {
+			benchmarkParse(b, size, func() (*FioOutput, error) { return parseFioOutput(path) })
+		})
+		b.Run(fmt.Sprintf("clients=%d/unmarshal", clients), func(b *testing.B) {
+			benchmarkParse(b, size, func() (*FioOutput, error) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				var output FioOutput
+				return &output, json.Unmarshal(data, &output)
+			})
+		})
+	}
+}
+
+// benchmarkParse runs parse b.N times and reports its peak heap and the
+// size of the file parsed
+func benchmarkParse(b *testing.B, size int64, parse func() (*FioOutput, error)) {
+	b.ReportAllocs()
+	b.SetBytes(size)
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		peak = max(peak, peakHeap(func() {
+			output, err := parse()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(output.Jobs) != 16 {
+				b.Fatalf("parsed %d jobs, want 16", len(output.Jobs))
+			}
+		}))
+	}
+	b.ReportMetric(float64(peak)/1024/1024, "peak-heap-MB")
+	b.ReportMetric(float64(size)/1024/1024, "file-MB")
+}
+
+// TestParseFioOutputStrayText parses fio outputs with messages before the
+// JSON document, between the documents of status intervals and after them
+func TestParseFioOutputStrayText(t *testing.T) {
+	const (
+		note  = "note: both iodepth >= 1 and synchronous I/O engine are selected, queue depth will be capped at 1"
+		ioErr = "fio: pid=4711, err=5/file:io_u.c:1845, func=io_u error, error=Input/output error"
+		hash  = "fio: file hash not empty on exit"
+	)
+	tests := []struct {
+		file  string
+		stray []string
+	}{
+		{"warning-before.json", []string{note}},
+		{"warning-between.json", []string{ioErr}},
+		{"warning-after.json", []string{hash}},
+		{"warnings-everywhere.json", []string{note, ioErr, hash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			output, err := parseFioOutput(filepath.Join("testdata", "stray", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output.FioVersion != "fio-3.36" {
+				t.Errorf("fio version = %q, want fio-3.36", output.FioVersion)
+			}
+			// The last document holds the totals of the run
+			if len(output.Jobs) != 1 || output.Jobs[0].Read.IOPS != 1000 || output.Jobs[0].Read.Runtime != 10000 {
+				t.Errorf("jobs = %+v, want the job of the last document with 1000 IOPS over 10000 ms", output.Jobs)
+			}
+			if !reflect.DeepEqual(output.Stray, tt.stray) {
+				t.Errorf("stray lines = %q, want %q", output.Stray, tt.stray)
+			}
+		})
+	}
+
+	_, err := parseFioOutput(filepath.Join("testdata", "stray", "no-document.json"))
+	if err == nil || !strings.Contains(err.Error(), "no JSON document") || !strings.Contains(err.Error(), ioErr) {
+		t.Errorf("output without a document: error = %v, want no JSON document with the messages", err)
+	}
+}
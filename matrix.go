@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// matrixOutlierPercent is how much worse than the median of its row a device
+// has to be to stand out in the device matrix
+const matrixOutlierPercent = 10
+
+// matrixMetric is a metric shown in the device matrix
+type matrixMetric struct {
+	name           string
+	value          func(TestResult) float64
+	format         func(float64) string
+	higherIsBetter bool
+}
+
+// matrixMetrics are the metrics of the device matrix, named once the units
+// are known
+func matrixMetrics() []matrixMetric {
+	decimals := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	return []matrixMetric{
+		{"IOPS", func(r TestResult) float64 { return r.TotalIOPS }, formatCount, true},
+		{"BW (MB/s)", func(r TestResult) float64 { return r.TotalBWMBps }, decimals, true},
+		{"p99 Lat (" + usUnit() + ")", p99LatencyUs, decimals, false},
+	}
+}
+
+// deviceMatrix holds the passed results of a suite by workload and device
+type deviceMatrix struct {
+	workloads []string
+	devices   []string
+	cells     map[string]map[string]TestResult
+}
+
+// matrixWorkload names the workload of a test, the same on every device it
+// runs on: its template, or its IO pattern when it has none
+func matrixWorkload(test FioTest) string {
+	if test.Template != "" {
+		return test.Template
+	}
+	workload := test.RW
+	if test.RWMixRead > 0 || test.RWMixWrite > 0 {
+		workload += fmt.Sprintf("%d", readMix(test))
+	}
+	workload += fmt.Sprintf(" %s qd%d", test.BS, test.IODepth)
+	if test.NumJobs > 1 {
+		workload += fmt.Sprintf("x%d", test.NumJobs)
+	}
+	if test.FillLevel > 0 {
+		workload += fmt.Sprintf(" @%d%%", test.FillLevel)
+	}
+	return workload
+}
+
+// buildDeviceMatrix arranges the passed results by workload and device, nil
+// unless they ran on at least two devices. A workload running more than once
+// on a device keeps its first result.
+func buildDeviceMatrix(results []TestResult) *deviceMatrix {
+	matrix := &deviceMatrix{cells: map[string]map[string]TestResult{}}
+	seen := map[string]bool{}
+	for _, r := range results {
+		if r.Status != "PASSED" || r.Device == nil {
+			continue
+		}
+		workload := matrixWorkload(r.Config)
+		row, ok := matrix.cells[workload]
+		if !ok {
+			row = map[string]TestResult{}
+			matrix.cells[workload] = row
+			matrix.workloads = append(matrix.workloads, workload)
+		}
+		if _, ok := row[r.Device.Device]; !ok {
+			row[r.Device.Device] = r
+		}
+		if !seen[r.Device.Device] {
+			seen[r.Device.Device] = true
+			matrix.devices = append(matrix.devices, r.Device.Device)
+		}
+	}
+	if len(matrix.devices) < 2 {
+		return nil
+	}
+	sort.Slice(matrix.devices, func(i, j int) bool { return naturalLess(matrix.devices[i], matrix.devices[j]) })
+	return matrix
+}
+
+// median returns the median of values, which it sorts
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	m := values[len(values)/2]
+	if len(values)%2 == 0 {
+		m = (values[len(values)/2-1] + m) / 2
+	}
+	return m
+}
+
+// displayDeviceMatrix shows a table of workloads by devices for every key
+// metric, with the minimum, median and maximum of each row. Devices worse than
+// the median of a row by more than matrixOutlierPercent are highlighted and
+// listed, so the one slow drive of an enclosure stands out. Wide matrices are
+// split into several tables of as many devices as fit the table width.
+func displayDeviceMatrix(results []TestResult) {
+	matrix := buildDeviceMatrix(results)
+	if matrix == nil {
+		return
+	}
+
+	labelWidth := len("Workload")
+	for _, workload := range matrix.workloads {
+		if len(workload) > labelWidth {
+			labelWidth = len(workload)
+		}
+	}
+	// Metrics take up to 8 characters, like 12345.67 or 1234567
+	cellWidth := 8
+	for _, device := range matrix.devices {
+		if len(filepath.Base(device)) > cellWidth {
+			cellWidth = len(filepath.Base(device))
+		}
+	}
+	perTable := (tableWidth - labelWidth - 4 - 3*(cellWidth+3)) / (cellWidth + 3)
+	if perTable < 1 {
+		perTable = 1
+	}
+
+	for _, metric := range matrixMetrics() {
+		fmt.Fprintf(out, "Device Matrix: %s\n", metric.name)
+		var outliers []string
+		for start := 0; start < len(matrix.devices); start += perTable {
+			end := start + perTable
+			if end > len(matrix.devices) {
+				end = len(matrix.devices)
+			}
+			devices := matrix.devices[start:end]
+			if start > 0 {
+				fmt.Fprintln(out)
+			}
+
+			// Device names keep their case, so the header is formatted here
+			header := []string{"WORKLOAD"}
+			for _, device := range devices {
+				header = append(header, filepath.Base(device))
+			}
+			header = append(header, "MIN", "MEDIAN", "MAX")
+			matrixTable := tablewriter.NewWriter(out)
+			matrixTable.SetHeader(header)
+			matrixTable.SetBorder(true)
+			matrixTable.SetRowLine(true)
+			matrixTable.SetAutoWrapText(false)
+			matrixTable.SetAutoFormatHeaders(false)
+			setTableSeparators(matrixTable)
+			alignment := []int{tablewriter.ALIGN_LEFT}
+			for i := 1; i < len(header); i++ {
+				alignment = append(alignment, tablewriter.ALIGN_RIGHT)
+			}
+			matrixTable.SetColumnAlignment(alignment)
+
+			for _, workload := range matrix.workloads {
+				var values []float64
+				for _, r := range matrix.cells[workload] {
+					values = append(values, metric.value(r))
+				}
+				mid := median(values)
+
+				row := []string{workload}
+				colors := []tablewriter.Colors{{}}
+				for _, device := range devices {
+					r, ok := matrix.cells[workload][device]
+					if !ok {
+						row = append(row, "-")
+						colors = append(colors, tablewriter.Colors{})
+						continue
+					}
+					value := metric.value(r)
+					row = append(row, metric.format(value))
+					worse := 0.0
+					if mid > 0 {
+						worse = 100 * (mid - value) / mid
+						if !metric.higherIsBetter {
+							worse = -worse
+						}
+					}
+					if worse > matrixOutlierPercent {
+						colors = append(colors, verdictColor(verdictFail))
+						outliers = append(outliers, fmt.Sprintf("%s on %s (%.1f%% worse than the median)", filepath.Base(device), workload, worse))
+					} else {
+						colors = append(colors, tablewriter.Colors{})
+					}
+				}
+				row = append(row, metric.format(values[0]), metric.format(mid), metric.format(values[len(values)-1]))
+				colors = append(colors, tablewriter.Colors{}, tablewriter.Colors{}, tablewriter.Colors{})
+				matrixTable.Rich(row, colors)
+			}
+			matrixTable.Render()
+		}
+		if len(outliers) > 0 {
+			fmt.Fprintf(out, "Outliers: %s\n", strings.Join(outliers, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MemoryBackend records how fio allocated its IO buffers and the hugepages
+// of the host when the test started
+type MemoryBackend struct {
+	IOMem          string `json:"iomem,omitempty"`
+	LockMem        string `json:"lockmem,omitempty"`
+	HugepageKB     int64  `json:"hugepage_kb,omitempty"`
+	HugepagesTotal int64  `json:"hugepages_total,omitempty"`
+	HugepagesFree  int64  `json:"hugepages_free,omitempty"`
+}
+
+// ioMemTypes are the values of fio's iomem option, those using hugepages
+// are true
+var ioMemTypes = map[string]bool{
+	"malloc":     false,
+	"shm":        false,
+	"shmhuge":    true,
+	"mmap":       false,
+	"mmaphuge":   true,
+	"mmapshared": false,
+	"cudamalloc": false,
+}
+
+// ioMemType returns the type of an iomem value without the file of mmap
+// types, like mmaphuge for mmaphuge:/hugepages/fio
+func ioMemType(iomem string) string {
+	memType, _, _ := strings.Cut(iomem, ":")
+	return memType
+}
+
+// hugepages returns the size of a hugepage in KiB and the total and free
+// number of them from /proc/meminfo
+func hugepages() (sizeKB, total, free int64, err error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("hugepages are not available on this system")
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		number, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		switch name {
+		case "Hugepagesize":
+			sizeKB = number
+		case "HugePages_Total":
+			total = number
+		case "HugePages_Free":
+			free = number
+		}
+	}
+	return sizeKB, total, free, nil
+}
+
+// bufferBytes estimates the IO buffers of a test, fio allocates the largest
+// block size times the queue depth for every job
+func bufferBytes(test FioTest) int64 {
+	largest := int64(0)
+	for _, bs := range blockSizes(test) {
+		largest = max(largest, bs.size)
+	}
+	return largest * int64(max(test.IODepth, 1)) * int64(testJobs(test))
+}
+
+// checkMemoryOptions validates the memory backends of the tests before any
+// test runs, tests using hugepages need enough of them to be free
+func checkMemoryOptions(tests []FioTest) error {
+	for _, test := range tests {
+		if test.LockMem != "" && parseSize(test.LockMem) <= 0 {
+			return fmt.Errorf("test %s: invalid lockmem %q", test.Name, test.LockMem)
+		}
+		if test.IOMem == "" {
+			continue
+		}
+		memType := ioMemType(test.IOMem)
+		huge, ok := ioMemTypes[memType]
+		if !ok {
+			return fmt.Errorf("test %s: unknown iomem %q, available: malloc, shm, shmhuge, mmap, mmaphuge, mmapshared, cudamalloc", test.Name, memType)
+		}
+		if memType == "mmaphuge" {
+			_, file, _ := strings.Cut(test.IOMem, ":")
+			if file == "" {
+				return fmt.Errorf("test %s: iomem=mmaphuge needs a file on a hugetlbfs mount, like mmaphuge:/hugepages/fio", test.Name)
+			}
+			if _, err := os.Stat(filepath.Dir(file)); err != nil {
+				return fmt.Errorf("test %s: iomem %s: %v", test.Name, test.IOMem, err)
+			}
+		}
+		if !huge {
+			continue
+		}
+		sizeKB, _, free, err := hugepages()
+		if err != nil {
+			return fmt.Errorf("test %s: iomem=%s: %v", test.Name, memType, err)
+		}
+		if needed := bufferBytes(test); free*sizeKB*1024 < needed {
+			return fmt.Errorf("test %s: iomem=%s needs %.1f MB of hugepages for its buffers but only %d hugepages of %d KiB are free, reserve more with vm.nr_hugepages",
+				test.Name, memType, float64(needed)/1024/1024, free, sizeKB)
+		}
+	}
+	return nil
+}
+
+// memoryBackend captures the memory backend of a test, nil for tests using
+// fio's default
+func memoryBackend(test FioTest) *MemoryBackend {
+	if test.IOMem == "" && test.LockMem == "" {
+		return nil
+	}
+	backend := &MemoryBackend{IOMem: test.IOMem, LockMem: test.LockMem}
+	backend.HugepageKB, backend.HugepagesTotal, backend.HugepagesFree, _ = hugepages()
+	return backend
+}
+
+// String describes the memory backend in one line for the result tables
+func (m *MemoryBackend) String() string {
+	parts := []string{}
+	if m.IOMem != "" {
+		parts = append(parts, "iomem="+m.IOMem)
+	}
+	if m.LockMem != "" {
+		parts = append(parts, "lockmem="+m.LockMem)
+	}
+	if m.HugepageKB > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d hugepages of %d KiB free", m.HugepagesFree, m.HugepagesTotal, m.HugepageKB))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// metadataEngines are the fio engines that time file operations instead of
+// IO, by the operation they perform on every file
+var metadataEngines = map[string]string{
+	"filecreate": "create",
+	"filestat":   "stat",
+	"filedelete": "delete",
+}
+
+// MetadataResult is the rate and latency of the file operations of a
+// metadata test
+type MetadataResult struct {
+	Operation    string  `json:"operation"`
+	Files        int     `json:"files"`
+	OpsPerSec    float64 `json:"ops_per_sec"`
+	AvgLatencyUs float64 `json:"avg_latency_us"`
+	P99LatencyUs float64 `json:"p99_latency_us"`
+}
+
+// isMetadataTest tells whether a test runs one of the metadata engines
+func isMetadataTest(test FioTest) bool {
+	_, ok := metadataEngines[test.IOEngine]
+	return ok
+}
+
+// testTarget returns the file or device a test runs on, the directory of
+// metadata tests that leave the file names to fio
+func testTarget(test FioTest) string {
+	if test.Filename == "" && test.Directory != "" {
+		return test.Directory
+	}
+	return test.Filename
+}
+
+// checkMetadataTest rejects metadata tests without files to operate on
+func checkMetadataTest(test FioTest) error {
+	if test.Directory == "" && test.Filename == "" {
+		return fmt.Errorf("the %s engine needs a directory or filename", test.IOEngine)
+	}
+	if test.NrFiles <= 0 {
+		return fmt.Errorf("the %s engine needs nrfiles", test.IOEngine)
+	}
+	return nil
+}
+
+// metadataFileSize is the size of every file of a metadata test, one block
+// unless the test sets filesize, so every file is one IO of fio
+func metadataFileSize(test FioTest) string {
+	if test.FileSize != "" {
+		return test.FileSize
+	}
+	return test.BS
+}
+
+// metadataResult turns the IO stats of a metadata test into file
+// operations. The engines record the time of every file operation as the
+// completion latency of the direction of the test.
+func metadataResult(test FioTest, r TestResult) *MetadataResult {
+	if r.FioJob == nil {
+		return nil
+	}
+	io := r.FioJob.Read
+	if r.WriteIOPS > r.ReadIOPS {
+		io = r.FioJob.Write
+	}
+	metadata := &MetadataResult{
+		Operation:    metadataEngines[test.IOEngine],
+		Files:        test.NrFiles * max(test.NumJobs, 1),
+		OpsPerSec:    r.TotalIOPS,
+		AvgLatencyUs: io.Clat.Mean / 1000,
+		P99LatencyUs: p99LatencyUs(r),
+	}
+	// Files of several blocks take several IOs each
+	if bs, size := parseSize(test.BS), parseSize(metadataFileSize(test)); bs > 0 && size > bs {
+		metadata.OpsPerSec *= float64(bs) / float64(size)
+	}
+	return metadata
+}
+
+func (m *MetadataResult) String() string {
+	return fmt.Sprintf("%s %ss/s over %s files, avg %.2f%s, p99 %.2f%s", formatCount(m.OpsPerSec), m.Operation,
+		formatCount(float64(m.Files)), m.AvgLatencyUs, usUnit(), m.P99LatencyUs, usUnit())
+}
+
+// displayMetadata shows the file operations of the metadata tests apart
+// from the block workloads, whose IOPS they are not comparable with
+func displayMetadata(results []TestResult) {
+	var metadata []TestResult
+	for _, r := range results {
+		if r.Metadata != nil && r.Status == "PASSED" {
+			metadata = append(metadata, r)
+		}
+	}
+	if len(metadata) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "Metadata Operations")
+	metadataTable := tablewriter.NewWriter(out)
+	metadataTable.SetHeader([]string{"Test", "Operation", "Files", "Ops/s", "Lat (" + usUnit() + ")", "p99 (" + usUnit() + ")"})
+	configureTable(metadataTable, 6)
+	metadataTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, r := range metadata {
+		metadataTable.Append([]string{
+			r.TestName,
+			r.Metadata.Operation,
+			formatCount(float64(r.Metadata.Files)),
+			formatCount(r.Metadata.OpsPerSec),
+			fmt.Sprintf("%.2f", r.Metadata.AvgLatencyUs),
+			fmt.Sprintf("%.2f", r.Metadata.P99LatencyUs),
+		})
+	}
+	metadataTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,55 @@
+package main
+
+// monitor collects data about the system while a test runs
+type monitor interface {
+	// name identifies the monitor in warnings
+	name() string
+	// start begins collecting right before fio is started
+	start() error
+	// stop ends collecting once fio has exited and stores the data in the result
+	stop(result *TestResult)
+}
+
+// newMonitors returns the monitors enabled for the test
+func newMonitors(test FioTest, run RunInfo) []monitor {
+	var monitors []monitor
+	if opts.Dmesg {
+		monitors = append(monitors, &dmesgMonitor{})
+	}
+	if opts.Power != "" {
+		monitors = append(monitors, newPowerMonitor(opts.Power, opts.PowerInterval))
+	}
+	if test.Blktrace || opts.Blktrace {
+		monitors = append(monitors, &blktraceMonitor{test: test, run: run})
+	}
+	if test.EBPF || opts.EBPF {
+		monitors = append(monitors, &bpfMonitor{test: test})
+	}
+	if test.CPUProfile || opts.CPUProfile {
+		monitors = append(monitors, &cpuMonitor{})
+	}
+	if test.Interrupts || opts.Interrupts {
+		monitors = append(monitors, &irqMonitor{test: test})
+	}
+	return monitors
+}
+
+// startMonitors starts the monitors, dropping the ones that fail to start
+// with a warning on the result instead of failing the test
+func startMonitors(monitors []monitor, result *TestResult) []monitor {
+	var started []monitor
+	for _, m := range monitors {
+		if err := m.start(); err != nil {
+			result.warn(severityWarning, "monitor", "%s monitor disabled: %v", m.name(), err)
+			continue
+		}
+		started = append(started, m)
+	}
+	return started
+}
+
+func stopMonitors(monitors []monitor, result *TestResult) {
+	for _, m := range monitors {
+		m.stop(result)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// testNameKey is what a test name must be unique as: besides the results
+// and the history store it names the fio job, the temporary output and the
+// artifact files, so names that only differ in the characters sanitized
+// out of file names collide as well
+func testNameKey(name string) string {
+	return sanitizeName(name)
+}
+
+// uniqueTestNames renames the tests whose names an earlier test already
+// uses by appending _2, _3 and so on, skipping names other tests use, and
+// returns a note on every rename
+func uniqueTestNames(tests []FioTest) []string {
+	used := map[string]bool{}
+	for _, test := range tests {
+		used[testNameKey(test.Name)] = true
+	}
+	seen := map[string]bool{}
+	var renames []string
+	for i := range tests {
+		key := testNameKey(tests[i].Name)
+		if !seen[key] {
+			seen[key] = true
+			continue
+		}
+		name := tests[i].Name
+		for n := 2; ; n++ {
+			name = tests[i].Name + "_" + strconv.Itoa(n)
+			if !used[testNameKey(name)] {
+				break
+			}
+		}
+		used[testNameKey(name)] = true
+		seen[testNameKey(name)] = true
+		renames = append(renames, fmt.Sprintf("test %d is named %q like an earlier test, renamed to %q", i+1, tests[i].Name, name))
+		tests[i].Name = name
+	}
+	return renames
+}
+
+// duplicateTestNames returns the names used by more than one test
+func duplicateTestNames(names []string) []string {
+	count := map[string]int{}
+	var duplicates []string
+	for _, name := range names {
+		if count[testNameKey(name)]++; count[testNameKey(name)] == 2 {
+			duplicates = append(duplicates, name)
+		}
+	}
+	return duplicates
+}
+
+// checkTestNames rejects tests whose names collide after they were expanded
+// into stages, like a test named after the fill level stage of another
+func checkTestNames(tests []FioTest) error {
+	names := make([]string, len(tests))
+	for i, test := range tests {
+		names[i] = test.Name
+	}
+	if duplicates := duplicateTestNames(names); len(duplicates) > 0 {
+		return fmt.Errorf("test name %q is used more than once after expanding the stages of the tests, rename a test", duplicates[0])
+	}
+	return nil
+}
+
+// documentDuplicateNames reports the duplicate test names of a test cases
+// file for validate, fio-qa renames them when it runs the file
+func documentDuplicateNames(document interface{}) []string {
+	root, _ := document.(map[string]interface{})
+	tests, _ := root["tests"].([]interface{})
+	var names []string
+	for _, test := range tests {
+		if test, ok := test.(map[string]interface{}); ok {
+			if name, ok := test["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	var violations []string
+	for _, name := range duplicateTestNames(names) {
+		violations = append(violations, fmt.Sprintf("tests: name %q is used by more than one test", name))
+	}
+	return violations
+}
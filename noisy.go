@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// NoisyNeighborConfig describes a QoS test: a latency-sensitive victim job
+// at a fixed low rate shares the device with an aggressor job whose
+// bandwidth is raised stage by stage
+type NoisyNeighborConfig struct {
+	Victim        *FioJobSection `json:"victim,omitempty"`
+	Aggressor     *FioJobSection `json:"aggressor,omitempty"`
+	AggressorMBps []int          `json:"aggressor_mbps"`
+	// Unlimited adds a last stage with an uncapped aggressor
+	Unlimited bool `json:"unlimited,omitempty"`
+}
+
+// NoisyNeighborStage is one stage of a noisy neighbor test and what the
+// victim saw during it
+type NoisyNeighborStage struct {
+	Test               string  `json:"test"`
+	AggressorLimitMBps int     `json:"aggressor_limit_mbps,omitempty"`
+	Alone              bool    `json:"victim_alone,omitempty"`
+	Unlimited          bool    `json:"aggressor_unlimited,omitempty"`
+	AggressorBWMBps    float64 `json:"aggressor_bw_mbps"`
+	VictimIOPS         float64 `json:"victim_iops"`
+	VictimLatencyUs    float64 `json:"victim_latency_us"`
+	VictimP99Us        float64 `json:"victim_p99_clat_us"`
+}
+
+// defaultVictim and defaultAggressor are used for the settings a noisy
+// neighbor test leaves empty
+var (
+	defaultVictim    = FioJobSection{RW: "randread", BS: "4k", IODepth: 1, NumJobs: 1, RateIOPS: 1000}
+	defaultAggressor = FioJobSection{RW: "write", BS: "128k", IODepth: 32, NumJobs: 1}
+)
+
+// checkNoisyNeighbors validates the noisy neighbor tests before anything
+// runs
+func checkNoisyNeighbors(tests []FioTest) error {
+	for _, test := range tests {
+		config := test.NoisyNeighbor
+		if config == nil {
+			continue
+		}
+		if len(test.Jobs) > 0 {
+			return fmt.Errorf("test %s: noisy_neighbor sets the job sections, jobs cannot be combined with it", test.Name)
+		}
+		if len(config.AggressorMBps) == 0 && !config.Unlimited {
+			return fmt.Errorf("test %s: noisy_neighbor needs aggressor_mbps or unlimited", test.Name)
+		}
+		for _, mbps := range config.AggressorMBps {
+			if mbps <= 0 {
+				return fmt.Errorf("test %s: aggressor bandwidth %d MB/s is not positive", test.Name, mbps)
+			}
+		}
+		if config.Victim != nil && config.Victim.RateIOPS < 0 {
+			return fmt.Errorf("test %s: victim rate_iops cannot be negative", test.Name)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills the settings of a job the section leaves empty
+func (s FioJobSection) withDefaults(defaults FioJobSection) FioJobSection {
+	if s.RW == "" {
+		s.RW = defaults.RW
+	}
+	if s.BS == "" {
+		s.BS = defaults.BS
+	}
+	if s.IODepth == 0 {
+		s.IODepth = defaults.IODepth
+	}
+	if s.NumJobs == 0 {
+		s.NumJobs = defaults.NumJobs
+	}
+	if s.RateIOPS == 0 {
+		s.RateIOPS = defaults.RateIOPS
+	}
+	return s
+}
+
+// expandNoisyNeighbors replaces every noisy neighbor test by its stages:
+// the victim alone, then the victim next to the aggressor at every
+// bandwidth limit and, if set, without a limit
+func expandNoisyNeighbors(tests []FioTest) []FioTest {
+	var expanded []FioTest
+	for _, test := range tests {
+		config := test.NoisyNeighbor
+		if config == nil {
+			expanded = append(expanded, test)
+			continue
+		}
+
+		victim := defaultVictim
+		if config.Victim != nil {
+			victim = config.Victim.withDefaults(defaultVictim)
+		}
+		victim.Name = "victim"
+		aggressor := defaultAggressor
+		if config.Aggressor != nil {
+			aggressor = config.Aggressor.withDefaults(defaultAggressor)
+		}
+		aggressor.Name = "aggressor"
+
+		stage := func(suffix, description string, info NoisyNeighborStage, jobs ...FioJobSection) FioTest {
+			s := test
+			s.Name = test.Name + "_" + suffix
+			s.Description = fmt.Sprintf("%s (%s)", test.Description, description)
+			s.Jobs = jobs
+			info.Test = test.Name
+			s.NoisyNeighborStage = &info
+			return s
+		}
+
+		expanded = append(expanded, stage("alone", "victim alone", NoisyNeighborStage{Alone: true}, victim))
+		for _, mbps := range config.AggressorMBps {
+			limited := aggressor
+			limited.Rate = fmt.Sprintf("%dm", mbps)
+			expanded = append(expanded, stage(fmt.Sprintf("aggressor%dmbps", mbps), fmt.Sprintf("aggressor at %d MB/s", mbps),
+				NoisyNeighborStage{AggressorLimitMBps: mbps}, victim, limited))
+		}
+		if config.Unlimited {
+			expanded = append(expanded, stage("aggressor_unlimited", "unlimited aggressor",
+				NoisyNeighborStage{Unlimited: true}, victim, aggressor))
+		}
+	}
+	return expanded
+}
+
+// measure records what the victim and the aggressor achieved in the stage
+func (s *NoisyNeighborStage) measure(jobs []JobSectionResult) {
+	for _, job := range jobs {
+		switch job.Name {
+		case "victim":
+			s.VictimIOPS = job.TotalIOPS
+			if job.TotalIOPS > 0 {
+				s.VictimLatencyUs = (job.ReadLatencyUs*job.ReadIOPS + job.WriteLatencyUs*job.WriteIOPS) / job.TotalIOPS
+			}
+			s.VictimP99Us = job.P99LatencyUs
+		case "aggressor":
+			s.AggressorBWMBps = job.TotalBWMBps
+		}
+	}
+}
+
+// label describes the aggressor of the stage
+func (s *NoisyNeighborStage) label() string {
+	switch {
+	case s.Alone:
+		return "none"
+	case s.Unlimited:
+		return "unlimited"
+	default:
+		return strconv.Itoa(s.AggressorLimitMBps) + " MB/s"
+	}
+}
+
+// displayNoisyNeighbors shows the victim latency of every noisy neighbor
+// test against the load of the aggressor
+func displayNoisyNeighbors(results []TestResult) {
+	var tests []string
+	stages := map[string][]TestResult{}
+	for _, r := range results {
+		if r.NoisyNeighbor == nil {
+			continue
+		}
+		if _, ok := stages[r.NoisyNeighbor.Test]; !ok {
+			tests = append(tests, r.NoisyNeighbor.Test)
+		}
+		stages[r.NoisyNeighbor.Test] = append(stages[r.NoisyNeighbor.Test], r)
+	}
+
+	for _, test := range tests {
+		// The victim alone is the reference of the other stages
+		var aloneP99 float64
+		for _, r := range stages[test] {
+			if r.NoisyNeighbor.Alone && r.Status == "PASSED" {
+				aloneP99 = r.NoisyNeighbor.VictimP99Us
+			}
+		}
+
+		fmt.Fprintf(out, "Victim Latency vs Aggressor Load: %s (latency in %s)\n", test, usUnit())
+		qosTable := tablewriter.NewWriter(out)
+		qosTable.SetHeader([]string{"Aggressor Cap", "Aggr MB/s", "Victim IOPS", "Lat Avg", "p99", "p99 Change"})
+		configureTable(qosTable, 6)
+		qosTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+		for _, r := range stages[test] {
+			stage := r.NoisyNeighbor
+			row := []string{stage.label(), "-", "-", "-", "-", "-"}
+			if r.Status == "PASSED" {
+				row[1] = fmt.Sprintf("%.2f", stage.AggressorBWMBps)
+				row[2] = formatCount(stage.VictimIOPS)
+				row[3] = fmt.Sprintf("%.2f", stage.VictimLatencyUs)
+				row[4] = fmt.Sprintf("%.2f", stage.VictimP99Us)
+				if aloneP99 > 0 && !stage.Alone {
+					row[5] = fmt.Sprintf("%+.1f%%", 100*(stage.VictimP99Us-aloneP99)/aloneP99)
+				}
+			}
+			qosTable.Append(row)
+		}
+		qosTable.Render()
+		fmt.Fprintln(out)
+	}
+}
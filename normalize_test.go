@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestNormalizeFioVersions parses the output of the same random read job
+// written by several fio versions and expects identical nanoseconds, bytes
+// and latency bins from all of them
+func TestNormalizeFioVersions(t *testing.T) {
+	bins := map[string]float64{
+		"10000": 0.01, "20000": 0.5, "50000": 10, "100000": 80,
+		"250000": 9, "500000": 0.4, "750000": 0.05, "1000000": 0.04,
+	}
+	tests := []struct {
+		file string
+		p50  float64
+		p99  float64
+	}{
+		{"fio-2.0.json", 80000, 120000},
+		{"fio-2.21.json", 80000, 120000},
+		{"fio-3.0.json", 80000, 120000},
+		{"fio-3.36.json", 80000, 120000},
+		// Estimated from the buckets, the upper bound of the bucket
+		// holding the percentile
+		{"fio-3.36-clat-percentiles-off.json", 100000, 250000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			output, err := parseFioOutput(filepath.Join("testdata", "normalize", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(output.Jobs) != 1 {
+				t.Fatalf("parsed %d jobs, want 1", len(output.Jobs))
+			}
+			job := output.Jobs[0]
+			read := job.Read
+			for _, check := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"read.iops", read.IOPS, 1000},
+				{"read.bw_bytes", read.BWBytes, 4096000},
+				{"read.io_kbytes", read.IOKBytes, 40000},
+				{"read.slat_ns.mean", read.Slat.Mean, 2000},
+				{"read.clat_ns.min", read.Clat.Min, 10000},
+				{"read.clat_ns.max", read.Clat.Max, 900000},
+				{"read.clat_ns.mean", read.Clat.Mean, 80500},
+				{"read.clat_ns.stddev", read.Clat.Stddev, 12250},
+				{"read.clat_ns.p50", read.Clat.Percentile["50.000000"], tt.p50},
+				{"read.clat_ns.p99", read.Clat.Percentile["99.000000"], tt.p99},
+				{"read.lat_ns.mean", read.LatNs.Mean, 82500},
+				{"usr_cpu", job.UsrCPU, 1.5},
+			} {
+				if check.got != check.want {
+					t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+				}
+			}
+			if !reflect.DeepEqual(job.LatBins, bins) {
+				t.Errorf("latency bins = %v, want %v", job.LatBins, bins)
+			}
+			if len(job.Write.Clat.Percentile) != 0 {
+				t.Errorf("write percentiles = %v, want none for a job without writes", job.Write.Clat.Percentile)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// NVMeNamespace is a namespace of an NVMe controller a test ran on
+type NVMeNamespace struct {
+	Test       string `json:"test,omitempty"`
+	Controller string `json:"controller"`
+	Device     string `json:"device"`
+	NSID       int    `json:"nsid"`
+	SizeBytes  int64  `json:"size_bytes,omitempty"`
+	BlockSize  int64  `json:"logical_block_size,omitempty"`
+}
+
+// NVMeRollup combines the results of a test over the namespaces of a
+// controller. The namespaces are tested one after another, so the sums are
+// what the namespaces deliver on their own, not at the same time.
+type NVMeRollup struct {
+	Controller      string  `json:"controller"`
+	Test            string  `json:"test"`
+	Namespaces      int     `json:"namespaces"`
+	Passed          int     `json:"passed"`
+	TotalIOPS       float64 `json:"total_iops"`
+	TotalBWMBps     float64 `json:"total_bw_mbps"`
+	AvgLatencyUs    float64 `json:"avg_latency_us"`
+	MaxP99LatencyUs float64 `json:"max_p99_latency_us"`
+	IOPSSpreadPc    float64 `json:"iops_spread_percent"`
+}
+
+var nvmeControllerPattern = regexp.MustCompile(`^nvme\d+$`)
+
+// nvmeNamespacePattern matches the namespaces below an NVMe controller in
+// sysfs, including the hidden per-path devices of native multipathing
+var nvmeNamespacePattern = regexp.MustCompile(`^nvme(\d+)(?:c\d+)?n(\d+)$`)
+
+// nvmeControllerName accepts a controller as "nvme0" or "/dev/nvme0"
+func nvmeControllerName(controller string) string {
+	return strings.TrimPrefix(controller, "/dev/")
+}
+
+// checkNVMe validates the NVMe controllers of the tests before anything runs
+func checkNVMe(tests []FioTest) error {
+	for _, test := range tests {
+		if test.NVMeController == "" {
+			if len(test.NVMeNSIDs) > 0 {
+				return fmt.Errorf("test %s: nvme_nsids needs nvme_controller", test.Name)
+			}
+			continue
+		}
+		if !nvmeControllerPattern.MatchString(nvmeControllerName(test.NVMeController)) {
+			return fmt.Errorf("test %s: %q is not an NVMe controller like nvme0", test.Name, test.NVMeController)
+		}
+		if test.Filename != "" {
+			return fmt.Errorf("test %s: filename and nvme_controller cannot be combined, the namespaces are the targets", test.Name)
+		}
+	}
+	return nil
+}
+
+// expandNVMeNamespaces replaces every test with an NVMe controller by one
+// test per namespace of the controller, or per namespace listed in
+// nvme_nsids
+func expandNVMeNamespaces(tests []FioTest) ([]FioTest, error) {
+	var expanded []FioTest
+	for _, test := range tests {
+		if test.NVMeController == "" {
+			expanded = append(expanded, test)
+			continue
+		}
+
+		controller := nvmeControllerName(test.NVMeController)
+		namespaces, err := nvmeNamespaces(controller)
+		if err != nil {
+			return nil, fmt.Errorf("test %s: %v", test.Name, err)
+		}
+		selected := namespaces
+		if len(test.NVMeNSIDs) > 0 {
+			selected = nil
+			for _, nsid := range test.NVMeNSIDs {
+				found := false
+				for _, namespace := range namespaces {
+					if namespace.NSID == nsid {
+						selected = append(selected, namespace)
+						found = true
+					}
+				}
+				if !found {
+					return nil, fmt.Errorf("test %s: %s has no namespace %d", test.Name, controller, nsid)
+				}
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("test %s: %s has no namespaces", test.Name, controller)
+		}
+
+		for _, namespace := range selected {
+			namespace := namespace
+			namespace.Test = test.Name
+			stage := test
+			stage.Name = fmt.Sprintf("%s_ns%d", test.Name, namespace.NSID)
+			stage.Description = fmt.Sprintf("%s (%s namespace %d)", test.Description, controller, namespace.NSID)
+			stage.Filename = namespace.Device
+			stage.NVMeNamespace = &namespace
+			expanded = append(expanded, stage)
+		}
+	}
+	return expanded, nil
+}
+
+// nvmeRollups combines the results of the namespaces per controller and
+// test, in the order they ran
+func nvmeRollups(results []TestResult) []NVMeRollup {
+	var rollups []NVMeRollup
+	index := map[[2]string]int{}
+	var minIOPS, maxIOPS []float64
+	for _, r := range results {
+		namespace := r.NVMe
+		if namespace == nil {
+			continue
+		}
+		key := [2]string{namespace.Controller, namespace.Test}
+		i, ok := index[key]
+		if !ok {
+			i = len(rollups)
+			index[key] = i
+			rollups = append(rollups, NVMeRollup{Controller: namespace.Controller, Test: namespace.Test})
+			minIOPS = append(minIOPS, 0)
+			maxIOPS = append(maxIOPS, 0)
+		}
+		rollup := &rollups[i]
+		rollup.Namespaces++
+		if r.Status != "PASSED" {
+			continue
+		}
+		rollup.Passed++
+		rollup.TotalIOPS += r.TotalIOPS
+		rollup.TotalBWMBps += r.TotalBWMBps
+		// Weighted by IOPS, divided by the total below
+		rollup.AvgLatencyUs += r.AvgLatencyUs * r.TotalIOPS
+		if p99 := p99LatencyUs(r); p99 > rollup.MaxP99LatencyUs {
+			rollup.MaxP99LatencyUs = p99
+		}
+		if rollup.Passed == 1 || r.TotalIOPS < minIOPS[i] {
+			minIOPS[i] = r.TotalIOPS
+		}
+		if r.TotalIOPS > maxIOPS[i] {
+			maxIOPS[i] = r.TotalIOPS
+		}
+	}
+
+	for i := range rollups {
+		if rollups[i].TotalIOPS > 0 {
+			rollups[i].AvgLatencyUs /= rollups[i].TotalIOPS
+		}
+		if maxIOPS[i] > 0 {
+			rollups[i].IOPSSpreadPc = 100 * (maxIOPS[i] - minIOPS[i]) / maxIOPS[i]
+		}
+	}
+	return rollups
+}
+
+// displayNVMeRollups shows the namespaces of every controller together
+func displayNVMeRollups(rollups []NVMeRollup) {
+	fmt.Fprintf(out, "NVMe Controller Roll-up (sums of the namespaces tested one after another, latency in %s)\n", usUnit())
+	rollupTable := tablewriter.NewWriter(out)
+	rollupTable.SetHeader([]string{"Controller", "Test", "Passed", "IOPS", "MB/s", "Lat Avg", "Spread"})
+	configureTable(rollupTable, 7)
+	rollupTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, rollup := range rollups {
+		rollupTable.Append([]string{
+			rollup.Controller,
+			rollup.Test,
+			fmt.Sprintf("%d/%d", rollup.Passed, rollup.Namespaces),
+			formatCount(rollup.TotalIOPS),
+			fmt.Sprintf("%.2f", rollup.TotalBWMBps),
+			fmt.Sprintf("%.2f", rollup.AvgLatencyUs),
+			fmt.Sprintf("%.1f%%", rollup.IOPSSpreadPc),
+		})
+	}
+	rollupTable.Render()
+	fmt.Fprintln(out)
+}
+
+// runNVMeNamespaces lists the namespaces of NVMe controllers
+func runNVMeNamespaces(args []string) int {
+	flags := flag.NewFlagSet("nvme-namespaces", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		return usageError("no NVMe controller given, e.g. nvme0")
+	}
+
+	namespacesTable := tablewriter.NewWriter(out)
+	namespacesTable.SetHeader([]string{"Controller", "Namespace", "NSID", "Size (GB)", "Block Size"})
+	configureTable(namespacesTable, 5)
+	namespacesTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, arg := range flags.Args() {
+		controller := nvmeControllerName(arg)
+		if !nvmeControllerPattern.MatchString(controller) {
+			return usageError("%q is not an NVMe controller like nvme0", arg)
+		}
+		namespaces, err := nvmeNamespaces(controller)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitEnvironment
+		}
+		for _, namespace := range namespaces {
+			namespacesTable.Append([]string{
+				controller,
+				namespace.Device,
+				strconv.Itoa(namespace.NSID),
+				fmt.Sprintf("%.1f", float64(namespace.SizeBytes)/1e9),
+				strconv.FormatInt(namespace.BlockSize, 10),
+			})
+		}
+	}
+	namespacesTable.Render()
+	return exitOK
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options holds the command line options of the tool
+type Options struct {
+	Containerize        string
+	ContainerRuntime    string
+	JSON                bool
+	Check               bool
+	ArtifactsDir        string
+	CaptureIOLog        bool
+	Power               string
+	PowerInterval       time.Duration
+	Blktrace            bool
+	BlktraceMaxDuration time.Duration
+	BlktraceMaxSize     int64
+	EBPF                bool
+	CPUProfile          bool
+	Interrupts          bool
+	IRQMatch            string
+	Dmesg               bool
+	DmesgFail           bool
+	CVThreshold         float64
+	ASCII               bool
+	NoColor             bool
+	Width               int
+	SummaryColumns      string
+	ProgressJSON        string
+	DropCaches          bool
+	Lite                bool
+	StreamResults       string
+	Suite               string
+	Shuffle             bool
+	Seed                int64
+	CooldownSeconds     int
+	CooldownTemp        float64
+	CooldownMax         time.Duration
+	AllowDestructive    bool
+	ResetTimeout        time.Duration
+	SanitizeController  bool
+	History             string
+	SLO                 string
+	HistLog             bool
+	HistLogMsec         int
+	Percentiles         string
+	Proto               bool
+	XLSX                bool
+	PDF                 string
+	ReportTemplates     string
+	Locale              string
+	NoLock              bool
+	RunID               string
+	Namespace           string
+	Calibration         string
+	JobFiles            bool
+	Campaign            string
+	FioBins             stringList
+	IdleProbe           bool
+	GCSettle            bool
+	GCSettleUs          float64
+	GCSettleMax         time.Duration
+	Experiment          string
+	Labels              stringList
+	AnomalyAlerts       bool
+	AnomalyRuns         int
+	CompressArtifacts   bool
+	DigitGrouping       string
+	StrictUnits         bool
+	JSONPrecision       string
+	DebugOnFailure      bool
+	CostModel           string
+	Profile             string
+	Target              string
+	WriteCliff          bool
+	ResultsName         string
+	RateCap             string
+	ReadOnly            bool
+	SheetsID            string
+	SheetsRange         string
+	SheetsCredentials   string
+}
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// opts contains the options parsed from the command line
+var opts Options
+
+// parseOptions applies the configuration layers and the command line to
+// the options
+func parseOptions() {
+	defineOptions()
+	if err := parseLayered(flag.CommandLine, os.Args[1:]); err != nil {
+		fatal(exitUsage, "%v", err)
+	}
+}
+
+// defineOptions defines the flags of the options
+func defineOptions() {
+	flag.Usage = usage
+	flag.StringVar(&opts.Containerize, "containerize", "", "run fio inside the given container image with the test targets passed through")
+	flag.StringVar(&opts.ContainerRuntime, "container-runtime", "docker", "container runtime used with --containerize (docker or podman)")
+	flag.StringVar(&opts.ResultsName, "results-name", "", "name of the results files, with the tokens {hostname}, {runid}, {timestamp}, {date}, {time}, {namespace}, {suite}, {user} and {label:key}, like results-{hostname}-{runid}-{date}.json (default: test_results-{timestamp}.json)")
+	flag.StringVar(&opts.RateCap, "rate-cap", "", "bandwidth no test may exceed, like 200M, injected as fio rate limits split between the jobs of every test; tests that cannot be capped are refused")
+	flag.BoolVar(&opts.ReadOnly, "read-only", false, "skip the tests that would write to their target, for non-destructive checks of devices holding data")
+	flag.StringVar(&opts.SheetsID, "sheets-id", "", "ID of a Google Sheet to append a summary row per test of the run to, like a qualification tracker")
+	flag.StringVar(&opts.SheetsRange, "sheets-range", "Sheet1", "sheet or range of --sheets-id the rows are appended below, like Results!A1")
+	flag.StringVar(&opts.SheetsCredentials, "sheets-credentials", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "service account key file for --sheets-id, the spreadsheet must be shared with its client_email (default: GOOGLE_APPLICATION_CREDENTIALS)")
+	flag.BoolVar(&opts.JSON, "json", false, "print a single JSON report on stdout, human readable output goes to stderr")
+	flag.BoolVar(&opts.Check, "check", false, "validate the setup and show the tests that would run without running them")
+	flag.StringVar(&opts.ArtifactsDir, "artifacts-dir", "artifacts", "directory holding the per-run artifact bundles")
+	flag.BoolVar(&opts.CompressArtifacts, "compress-artifacts", false, "keep the raw fio output in the artifact bundle and gzip it, the captured iologs and the blktrace traces")
+	flag.BoolVar(&opts.DebugOnFailure, "debug-on-failure", true, "re-run tests fio failed on once with --debug=io,process and keep the output in the artifact bundle")
+	flag.BoolVar(&opts.CaptureIOLog, "capture-iolog", false, "capture a fio iolog of every test into the artifact bundle")
+	flag.StringVar(&opts.Power, "power", "", "measure power draw during tests from rapl, ipmi or pdu:<url>")
+	flag.DurationVar(&opts.PowerInterval, "power-interval", time.Second, "interval between power samples")
+	flag.BoolVar(&opts.Blktrace, "blktrace", false, "trace the block layer of every test target with blktrace")
+	flag.DurationVar(&opts.BlktraceMaxDuration, "blktrace-max-duration", time.Minute, "maximum duration of a blktrace capture")
+	flag.Int64Var(&opts.BlktraceMaxSize, "blktrace-max-size", 256, "maximum size of a blktrace capture in MB")
+	flag.BoolVar(&opts.EBPF, "ebpf", false, "attribute latency to the block layer and the device with bpftrace")
+	flag.BoolVar(&opts.CPUProfile, "cpu-profile", false, "sample the host CPU use during every test and flag results limited by the CPU")
+	flag.BoolVar(&opts.Interrupts, "interrupts", false, "count the interrupts of the test device and the softirqs per CPU during every test")
+	flag.StringVar(&opts.IRQMatch, "irq-match", "", "regular expression selecting the interrupts of the test device by name, e.g. nvme0q (default: the MSI vectors of the device in sysfs)")
+	flag.BoolVar(&opts.Dmesg, "dmesg", true, "scan the kernel log for IO errors, resets and timeouts during each test")
+	flag.BoolVar(&opts.DmesgFail, "dmesg-fail", true, "fail tests during which the kernel log reported critical errors")
+	flag.Float64Var(&opts.CVThreshold, "cv-threshold", 15, "warn when the IOPS coefficient of variation of a test exceeds this percentage")
+	flag.BoolVar(&opts.ASCII, "ascii", false, "draw tables with plain ASCII characters instead of box-drawing characters and emoji")
+	_, noColor := os.LookupEnv("NO_COLOR")
+	flag.BoolVar(&opts.NoColor, "no-color", noColor, "disable colors and emoji in the output, also enabled by the NO_COLOR environment variable")
+	flag.IntVar(&opts.Width, "width", 0, "total width of the tables (default: terminal width, 113 when not writing to a terminal)")
+	flag.StringVar(&opts.SummaryColumns, "summary-columns", defaultSummaryColumns, "comma separated columns of the summary table: status, iops, read_iops, write_iops, bw, read_bw, write_bw, lat, p99, cv, slo_burn, device, warnings, suite, duration")
+	flag.StringVar(&opts.ProgressJSON, "progress-json", "", "write NDJSON progress events to - (stdout), fd:N or a file")
+	flag.BoolVar(&opts.DropCaches, "drop-caches", false, "drop the page cache before each test (drop_caches on Linux, purge on macOS, needs root)")
+	flag.BoolVar(&opts.Lite, "lite", false, "low footprint mode for small systems: one line per test instead of tables, raw fio data is not kept")
+	flag.StringVar(&opts.StreamResults, "stream-results", "", "send the JSON result of each test as soon as it finished to an http(s) URL or append it to a file")
+	flag.StringVar(&opts.Profile, "profile", "", "run a built-in profile instead of fio-testcases.json: qd1-latency")
+	flag.StringVar(&opts.Target, "target", defaultProfileTarget, "file or device the tests of --profile run on")
+	flag.StringVar(&opts.Suite, "suite", "", "run the test case files referenced by a suite manifest instead of fio-testcases.json")
+	flag.BoolVar(&opts.Shuffle, "shuffle", false, "run the tests in random order, the seed is printed and stored in the results")
+	flag.Int64Var(&opts.Seed, "seed", 0, "seed for --shuffle to repeat the order of an earlier run (default: random)")
+	flag.IntVar(&opts.CooldownSeconds, "cooldown-seconds", 0, "pause between tests, overridden by cooldown_seconds of a test")
+	flag.Float64Var(&opts.CooldownTemp, "cooldown-temp", 0, "after the pause also wait until the device is at most this warm in °C, overridden by cooldown_temp_c of a test")
+	flag.DurationVar(&opts.CooldownMax, "cooldown-max", 10*time.Minute, "maximum time to wait for --cooldown-temp")
+	flag.BoolVar(&opts.AllowDestructive, "allow-destructive", false, "allow tests to erase their target device with reset_device")
+	flag.DurationVar(&opts.ResetTimeout, "reset-timeout", 2*time.Hour, "maximum time to wait for an NVMe sanitize to complete")
+	flag.BoolVar(&opts.SanitizeController, "sanitize-controller", false, "allow nvme-sanitize on controllers with several namespaces, erasing all of them")
+	flag.StringVar(&opts.History, "history", "fio-qa-history.ndjson", "history store collecting the results of every run, empty to disable")
+	flag.StringVar(&opts.SLO, "slo", "", "latency objective like 99.9%<2ms for tests without slo, reported with its violation and error budget burn rate")
+	flag.BoolVar(&opts.HistLog, "hist-log", false, "log completion latency histograms of every test into the artifact bundle and build a latency heatmap")
+	flag.IntVar(&opts.HistLogMsec, "hist-log-msec", 1000, "interval of the latency histograms in milliseconds")
+	flag.BoolVar(&opts.WriteCliff, "write-cliff", false, "look for the SLC cache write cliff in the bandwidth logs of sequential write tests")
+	flag.StringVar(&opts.Percentiles, "percentiles", "", "comma separated completion latency percentiles to report, e.g. 99.999,97.5, overridden by percentiles of a test")
+	flag.BoolVar(&opts.Proto, "proto", false, "also save the results in the protobuf encoding of proto/results.proto")
+	flag.StringVar(&opts.PDF, "pdf", "", "also write a paginated PDF report with a cover page, summary and a section with charts per test to this file")
+	flag.StringVar(&opts.JSONPrecision, "json-precision", "full", "decimals of the numbers in the JSON results, --json and --stream-results: full, a number, or field=decimals rules like 2,iops=0,*_ns=full")
+	flag.BoolVar(&opts.StrictUnits, "strict-units", false, "also carry the metrics of every test in bytes per second and nanoseconds in the JSON results, and name the unit of every field")
+	flag.StringVar(&opts.DigitGrouping, "digit-grouping", "none", "thousands separator of IOPS in tables and reports: none, locale for the one of --locale, or a separator like , or '")
+	flag.StringVar(&opts.Locale, "locale", "en", "language of the PDF, XLSX and template reports: en, de, ro or a JSON file mapping the English labels to translations")
+	flag.StringVar(&opts.ReportTemplates, "report-template", "", "comma separated Go template files rendered with the results into reports next to the results file")
+	flag.BoolVar(&opts.XLSX, "xlsx", false, "also save the results as an Excel workbook with summary, test, percentile and config sheets")
+	flag.BoolVar(&opts.NoLock, "no-lock", false, "run even if another fio-qa instance holds the lock of a target device")
+	flag.StringVar(&opts.RunID, "run-id", "", "run ID assigned by an orchestrator, used in all outputs (default: a new ULID)")
+	flag.StringVar(&opts.Namespace, "namespace", "", "project or team the run belongs to, kept apart from other namespaces in the history store, artifacts and results")
+	flag.StringVar(&opts.CostModel, "cost-model", "", "JSON file with the prices of the drives, results are related to them per 100k IOPS and per GB/s")
+	flag.StringVar(&opts.Calibration, "calibration", defaultCalibrationFile, "host ceiling measured with \"fio-qa calibrate\", results are shown as a percentage of it when the file exists")
+	flag.BoolVar(&opts.JobFiles, "job-files", false, "run fio from a job file generated per test and kept in the artifact bundle instead of passing all options on the command line")
+	flag.StringVar(&opts.Campaign, "campaign", "", "add the run as a session to a burn-in campaign spanning many runs, kept in fio-qa-campaign-<name>.json or the given .json file")
+	flag.BoolVar(&opts.IdleProbe, "idle-probe", false, "probe the read latency of the target with ioping before and after each test to detect background activity like garbage collection")
+	flag.BoolVar(&opts.GCSettle, "gc-settle", false, "after write-heavy tests wait until the read latency of the target settled, probed with ioping")
+	flag.Float64Var(&opts.GCSettleUs, "gc-settle-us", 0, "read latency in microseconds to settle below with --gc-settle, default 1.5 times the idle latency of the target")
+	flag.DurationVar(&opts.GCSettleMax, "gc-settle-max", 5*time.Minute, "maximum time to wait with --gc-settle")
+	flag.StringVar(&opts.Experiment, "experiment", "", "experiment file with variants like schedulers or mount options, every test runs per variant and repetition and the summary compares the variants")
+	flag.BoolVar(&opts.AnomalyAlerts, "anomaly-alerts", false, "warn when IOPS or p99 latency of a test deviated from its moving average in the history store for --anomaly-runs runs in a row, for scheduled runs")
+	flag.IntVar(&opts.AnomalyRuns, "anomaly-runs", 3, "runs in a row that must deviate before --anomaly-alerts warns")
+	flag.Var(&opts.Labels, "label", "key=value label of the run, like firmware=1.2.3, kept in the results and the history store for \"fio-qa results query\", can be given several times")
+	flag.Var(&opts.FioBins, "fio-bin", "fio binary to run the tests with instead of fio from PATH, given several times every test runs with each binary and the summary compares them")
+}
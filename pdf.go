@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// The report is written as a plain PDF 1.4 document using the standard
+// Helvetica fonts every viewer has, so no fonts are embedded. Charts are
+// drawn as vector bars directly in the page content.
+
+// Page geometry in points, A4 portrait
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+)
+
+const pdfReportTitle = "Storage Qualification Report"
+
+// pdfReport lays out text, tables and charts top down over pages
+type pdfReport struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+// newPage starts a page and moves to its top
+func (p *pdfReport) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// need starts a new page unless height points are left on the current one
+func (p *pdfReport) need(height float64) {
+	if p.page == nil || p.y-height < pdfMargin+20 {
+		p.newPage()
+	}
+}
+
+// text draws a line of text with its baseline at y
+func (p *pdfReport) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// line writes a line of text and moves down
+func (p *pdfReport) line(size float64, bold bool, s string) {
+	p.need(size * 1.5)
+	p.y -= size * 1.4
+	p.text(pdfMargin, p.y, size, bold, truncateText(s, size, pdfPageWidth-2*pdfMargin))
+}
+
+// heading writes a section heading with a rule below it
+func (p *pdfReport) heading(s string) {
+	p.need(60)
+	p.y -= 10
+	p.line(14, true, s)
+	p.y -= 4
+	fmt.Fprintf(p.page, "0.6 G 0.5 w %d %.2f m %d %.2f l S 0 G\n", pdfMargin, p.y, pdfPageWidth-pdfMargin, p.y)
+	p.y -= 6
+}
+
+// table writes rows of cells into columns of the given widths, the first
+// row in bold
+func (p *pdfReport) table(widths []float64, rows [][]string) {
+	for i, row := range rows {
+		p.need(14)
+		p.y -= 13
+		if i == 0 {
+			fmt.Fprintf(p.page, "0.9 g %d %.2f %d 13 re f 0 g\n", pdfMargin, p.y-3, pdfPageWidth-2*pdfMargin)
+		}
+		x := float64(pdfMargin) + 2
+		for c, cell := range row {
+			p.text(x, p.y, 8.5, i == 0, truncateText(cell, 8.5, widths[c]-4))
+			x += widths[c]
+		}
+	}
+	p.y -= 6
+}
+
+// barChart draws a labelled horizontal bar for every value
+func (p *pdfReport) barChart(title, unit string, labels []string, values []float64) {
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	if max <= 0 {
+		return
+	}
+
+	const barHeight, labelWidth, valueWidth = 11, 150, 80
+	p.need(float64(len(values))*(barHeight+3) + 30)
+	p.y -= 16
+	p.text(pdfMargin, p.y, 9.5, true, title)
+	p.y -= 4
+	width := float64(pdfPageWidth-2*pdfMargin) - labelWidth - valueWidth
+	for i, v := range values {
+		p.y -= barHeight + 3
+		p.text(pdfMargin, p.y+2, 8, false, truncateText(labels[i], 8, labelWidth-6))
+		fmt.Fprintf(p.page, "0.26 0.45 0.76 rg %.2f %.2f %.2f %d re f 0 g\n", float64(pdfMargin)+labelWidth, p.y, width*v/max, barHeight)
+		p.text(float64(pdfMargin)+labelWidth+width*v/max+4, p.y+2, 8, false, fmt.Sprintf("%.2f %s", v, unit))
+	}
+	p.y -= 8
+}
+
+// pdfLetters are the letters of the report locales missing from
+// WinAnsiEncoding, written without their diacritics
+var pdfLetters = map[rune]byte{
+	'ă': 'a', 'Ă': 'A', 'ș': 's', 'Ș': 'S', 'ş': 's', 'Ş': 'S', 'ț': 't', 'Ț': 'T', 'ţ': 't', 'Ţ': 'T',
+}
+
+// pdfString escapes text for a PDF string in WinAnsiEncoding, characters
+// outside of it are replaced
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case pdfLetters[r] != 0:
+			b.WriteByte(pdfLetters[r])
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == 'μ':
+			// The Greek mu of the unit labels, WinAnsi only has the micro sign
+			b.WriteByte(0xb5)
+		case r >= 32 && r < 127 || r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// truncateText shortens text to about the given width, estimating the
+// average Helvetica character at half the font size
+func truncateText(s string, size, width float64) string {
+	max := int(width / (size * 0.52))
+	runes := []rune(s)
+	if len(runes) <= max || max < 4 {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// writePDF assembles the pages into a PDF document, numbering the pages
+func (p *pdfReport) writePDF(w io.Writer, title string) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+
+	// Objects 1 to 5 are fixed, every page then takes two: the page and
+	// its content stream
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	var kids []string
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Title (%s) /Producer (fio-qa) /CreationDate (D:%s) >>", pdfString(title), time.Now().UTC().Format("20060102150405Z"))
+	for i, page := range p.pages {
+		fmt.Fprintf(page, "BT /F1 8 Tf %d %d Td (%s) Tj ET\n", pdfPageWidth-pdfMargin-50, pdfMargin-20, pdfString(fmt.Sprintf(tr("Page %d of %d"), i+1, len(p.pages))))
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+2*i)
+		object("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// buildPDFReport lays out the report: a cover page with the metadata of
+// the run, a summary of all tests and a section per test
+func buildPDFReport(results JSONResults) *pdfReport {
+	p := &pdfReport{}
+
+	// Cover page
+	p.newPage()
+	p.y -= 180
+	p.line(24, true, tr(pdfReportTitle))
+	p.y -= 10
+	hostname, _ := os.Hostname()
+	cover := [][]string{
+		{tr("Generated"), time.Now().Format("2006-01-02 15:04 MST")},
+		{tr("Host"), hostname},
+		{tr("Run ID"), results.RunID},
+		{tr("Namespace"), results.Namespace},
+		{tr("Suite"), results.Suite},
+		{tr("Tests"), fmt.Sprintf(tr("%d (%d passed, %d failed)"), results.Summary.TotalTests, results.Summary.Passed, results.Summary.Failed)},
+		{tr("Total Duration"), results.Summary.TotalDuration},
+	}
+	if results.Score != nil {
+		cover = append(cover, []string{tr("Score"), fmt.Sprintf("%.1f (%s)", results.Score.Score, results.Score.Grade)})
+	}
+	seen := map[string]bool{}
+	for _, r := range results.TestResults {
+		if r.Device == nil || seen[r.Device.Device] {
+			continue
+		}
+		seen[r.Device.Device] = true
+		device := r.Device.Device
+		if r.Device.Model != "" {
+			device += ": " + strings.TrimSpace(r.Device.Model+" "+r.Device.Firmware)
+		}
+		if r.Device.Serial != "" {
+			device += ", S/N " + r.Device.Serial
+		}
+		cover = append(cover, []string{tr("Device"), device})
+	}
+	for _, row := range cover {
+		if row[1] == "" {
+			continue
+		}
+		p.y -= 18
+		p.text(pdfMargin, p.y, 11, true, row[0])
+		p.text(pdfMargin+110, p.y, 11, false, truncateText(row[1], 11, pdfPageWidth-2*pdfMargin-110))
+	}
+
+	// Summary of all tests
+	p.newPage()
+	p.heading(tr("Summary"))
+	rows := [][]string{{tr("Test"), tr("Status"), "IOPS", "MB/s", tr("Lat Avg (us)"), "p99 (us)"}}
+	var names []string
+	var iops, latency []float64
+	for _, r := range results.TestResults {
+		rows = append(rows, []string{r.TestName, tr(r.Status), formatCount(r.IOPS), fmt.Sprintf("%.2f", r.BandwidthMBps),
+			fmt.Sprintf("%.2f", r.LatencyUs), fmt.Sprintf("%.2f", r.Percentiles.P99)})
+		names = append(names, r.TestName)
+		iops = append(iops, r.IOPS)
+		latency = append(latency, r.LatencyUs)
+	}
+	p.table([]float64{195, 60, 65, 60, 60, 55}, rows)
+	p.barChart(tr("IOPS per Test"), "IOPS", names, iops)
+	p.barChart(tr("Average Latency per Test"), "us", names, latency)
+
+	// One section per test
+	for _, r := range results.TestResults {
+		p.heading(r.TestName)
+		if r.Description != "" {
+			p.line(9.5, false, r.Description)
+		}
+		p.y -= 4
+		metrics := [][]string{
+			{tr("Metric"), tr("Read"), tr("Write"), tr("Total")},
+			{tr("Status"), tr(r.Status), "", ""},
+			{tr("Duration"), r.Duration, "", ""},
+			{"IOPS", formatCount(r.IOPSStats.Read.IOPS), formatCount(r.IOPSStats.Write.IOPS), formatCount(r.IOPS)},
+			{tr("Bandwidth (MB/s)"), fmt.Sprintf("%.2f", r.BandwidthStats.Read.BandwidthMBps), fmt.Sprintf("%.2f", r.BandwidthStats.Write.BandwidthMBps), fmt.Sprintf("%.2f", r.BandwidthMBps)},
+			{tr("Completion Latency Avg (us)"), fmt.Sprintf("%.2f", r.LatencyStats.Read.CompletionLat.Avg), fmt.Sprintf("%.2f", r.LatencyStats.Write.CompletionLat.Avg), fmt.Sprintf("%.2f", r.LatencyUs)},
+			{tr("Completion Latency Max (us)"), fmt.Sprintf("%.2f", r.LatencyStats.Read.CompletionLat.Max), fmt.Sprintf("%.2f", r.LatencyStats.Write.CompletionLat.Max), ""},
+			{tr("CPU User / System"), fmt.Sprintf("%.1f%% / %.1f%%", r.CPUUsage.UserCPU, r.CPUUsage.SystemCPU), "", ""},
+		}
+		if r.Error != "" {
+			metrics = append(metrics, []string{tr("Error"), r.Error, "", ""})
+		}
+		p.table([]float64{175, 110, 110, 100}, metrics)
+
+		percentiles := r.Percentiles
+		p.barChart(tr("Read Completion Latency Percentiles"), "us",
+			[]string{"p50", "p90", "p95", "p99", "p99.9", "p99.99"},
+			[]float64{percentiles.P50, percentiles.P90, percentiles.P95, percentiles.P99, percentiles.P99_9, percentiles.P99_99})
+
+		if len(r.Warnings) > 0 {
+			p.y -= 4
+			p.line(9.5, true, tr("Warnings"))
+			for _, warning := range r.Warnings {
+				p.line(8.5, false, fmt.Sprintf("%s [%s] %s", warning.Severity, warning.Category, warning.Message))
+			}
+		}
+	}
+	return p
+}
+
+// saveResultsToPDF saves the results as a PDF report
+func saveResultsToPDF(results JSONResults, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := buildPDFReport(results).writePDF(f, tr(pdfReportTitle)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write PDF report: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"fio-qa/stats"
+)
+
+// PercentileValue is a completion latency percentile requested with
+// --percentiles or the percentiles of a test. Source is "histogram" when it
+// was computed from the histogram logs and "interpolated" when it was
+// interpolated from the percentile table of fio.
+type PercentileValue struct {
+	Percent float64 `json:"percent"`
+	ReadUs  float64 `json:"read_us,omitempty"`
+	WriteUs float64 `json:"write_us,omitempty"`
+	Source  string  `json:"source"`
+}
+
+// parsePercentiles parses a comma separated list of percentiles
+func parsePercentiles(spec string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(strings.TrimPrefix(field, "p"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q", field)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// testPercentiles returns the percentiles requested for a test, the ones of
+// --percentiles by default
+func testPercentiles(test FioTest) ([]float64, error) {
+	if len(test.Percentiles) > 0 {
+		return test.Percentiles, nil
+	}
+	return parsePercentiles(opts.Percentiles)
+}
+
+// checkPercentiles validates the requested percentiles before any test runs
+func checkPercentiles(tests []FioTest) error {
+	for _, test := range tests {
+		percentiles, err := testPercentiles(test)
+		if err != nil {
+			return err
+		}
+		for _, p := range percentiles {
+			if p < 0 || p > 100 {
+				return fmt.Errorf("test %s: percentile %g is not between 0 and 100", test.Name, p)
+			}
+		}
+	}
+	return nil
+}
+
+// computePercentiles computes the requested percentiles of a test, from the
+// full histograms when histogram logs were written
+func computePercentiles(test FioTest, result *TestResult) {
+	percentiles, err := testPercentiles(test)
+	if err != nil || len(percentiles) == 0 || result.FioJob == nil {
+		return
+	}
+
+	source := "interpolated"
+	read := percentileFunc(result.FioJob.Read, result.HistBins[0])
+	write := percentileFunc(result.FioJob.Write, result.HistBins[1])
+	hasBins := func(io FioIO, bins []int64) bool { return len(bins) > 0 || len(io.Clat.Bins) > 0 }
+	if (result.ReadIOPS == 0 || hasBins(result.FioJob.Read, result.HistBins[0])) && (result.WriteIOPS == 0 || hasBins(result.FioJob.Write, result.HistBins[1])) {
+		source = "histogram"
+	}
+
+	for _, p := range percentiles {
+		value := PercentileValue{Percent: p, Source: source}
+		if result.ReadIOPS > 0 && read != nil {
+			value.ReadUs = read(p) / 1000
+		}
+		if result.WriteIOPS > 0 && write != nil {
+			value.WriteUs = write(p) / 1000
+		}
+		result.Percentiles = append(result.Percentiles, value)
+	}
+}
+
+// percentileFunc returns the percentile function of one direction in ns,
+// from the histogram logs, the bins of a json+ output or the percentiles of
+// fio
+func percentileFunc(io FioIO, bins []int64) func(float64) float64 {
+	if len(bins) > 0 {
+		return stats.FromFioBins(bins).Percentile
+	}
+	if len(io.Clat.Bins) > 0 {
+		return stats.FromFioJSONBins(io.Clat.Bins).Percentile
+	}
+	if len(io.Clat.Percentile) > 0 {
+		return stats.FromFioPercentiles(io.Clat.Min, io.Clat.Max, io.Clat.Percentile).Percentile
+	}
+	return nil
+}
+
+func displayPercentiles(percentiles []PercentileValue) {
+	fmt.Fprintf(out, "Requested Latency Percentiles (microseconds, %s)\n", percentiles[0].Source)
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Percentile", "Read", "Write"})
+	configureTable(table, 3)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, p := range percentiles {
+		table.Append([]string{"p" + strconv.FormatFloat(p.Percent, 'f', -1, 64), percentileCell(p.ReadUs), percentileCell(p.WriteUs)})
+	}
+	table.Render()
+	fmt.Fprintln(out)
+}
+
+func percentileCell(us float64) string {
+	if us == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", us)
+}
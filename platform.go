@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// defaultIOEngines is the asynchronous engine used on each OS when a test
+// does not set one, or sets one that is not available on the OS
+var defaultIOEngines = map[string]string{
+	"linux":   "libaio",
+	"windows": "windowsaio",
+	"darwin":  "posixaio",
+}
+
+// linuxIOEngines are the fio engines only available on Linux
+var linuxIOEngines = map[string]bool{
+	"libaio":       true,
+	"io_uring":     true,
+	"io_uring_cmd": true,
+	"sg":           true,
+	"splice":       true,
+	"e4defrag":     true,
+}
+
+// platformIOEngine returns the engine to use on this OS for the engine set
+// by a test, so the same suite runs unchanged on every OS
+func platformIOEngine(engine string) string {
+	fallback, ok := defaultIOEngines[runtime.GOOS]
+	if !ok {
+		fallback = "psync"
+	}
+	if engine == "" || (linuxIOEngines[engine] && runtime.GOOS != "linux") {
+		return fallback
+	}
+	return engine
+}
+
+var (
+	// windowsDevicePattern matches raw Windows devices like \\.\PhysicalDrive2
+	// or \\.\D:
+	windowsDevicePattern = regexp.MustCompile(`(?i)^\\\\\.\\(PhysicalDrive\d+|[a-z]:)$`)
+	// windowsDrivePattern matches a bare drive letter like D:
+	windowsDrivePattern = regexp.MustCompile(`(?i)^[a-z]:$`)
+)
+
+// isWindowsDevice reports whether the target is a Windows drive or volume
+func isWindowsDevice(target string) bool {
+	return windowsDevicePattern.MatchString(target) || windowsDrivePattern.MatchString(target)
+}
+
+// fioFilename returns the target in the form fio expects. On Windows a bare
+// drive letter is opened as the raw volume and colons are escaped, since
+// fio uses them to separate multiple files.
+func fioFilename(target string) string {
+	if runtime.GOOS != "windows" {
+		return target
+	}
+	if windowsDrivePattern.MatchString(target) {
+		target = `\\.\` + target
+	}
+	return strings.ReplaceAll(target, ":", `\:`)
+}
+
+// checkTarget rejects targets that cannot be used on this OS
+func checkTarget(target string) error {
+	if isWindowsDevice(target) && runtime.GOOS != "windows" {
+		return fmt.Errorf("%s is a Windows device and cannot be used on %s", target, runtime.GOOS)
+	}
+	if strings.HasPrefix(target, "/dev/") && runtime.GOOS == "windows" {
+		return fmt.Errorf("%s is a Unix device and cannot be used on windows", target)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Completion modes of the stages of a polled comparison
+const (
+	completionInterrupt = "interrupt"
+	completionPolled    = "polled"
+)
+
+// pollingEngines are the ioengines that can poll for completions with hipri
+var pollingEngines = map[string]bool{
+	"io_uring": true,
+	"pvsync2":  true,
+}
+
+// CompletionStage is one stage of a polled comparison, the test it belongs
+// to and how its IOs completed
+type CompletionStage struct {
+	Test string `json:"test"`
+	Mode string `json:"mode"`
+}
+
+// CompletionDelta is a metric of a polled comparison in both modes, the
+// delta is the change of polled over interrupt completion in percent
+type CompletionDelta struct {
+	Metric    string  `json:"metric"`
+	Interrupt float64 `json:"interrupt"`
+	Polled    float64 `json:"polled"`
+	DeltaPc   float64 `json:"delta_percent"`
+}
+
+// CompletionComparison compares the interrupt and polled stages of a test
+type CompletionComparison struct {
+	Test    string            `json:"test"`
+	Metrics []CompletionDelta `json:"metrics"`
+}
+
+// completionPercentiles are the completion latency percentiles compared,
+// by the key fio reports them with
+var completionPercentiles = []struct {
+	label string
+	key   string
+}{
+	{"p50", "50.000000"},
+	{"p90", "90.000000"},
+	{"p99", "99.000000"},
+	{"p99.9", "99.900000"},
+	{"p99.99", "99.990000"},
+}
+
+// checkPolledComparisons validates the polled comparisons before any test
+// runs, polling needs an engine that supports hipri on Linux
+func checkPolledComparisons(tests []FioTest) error {
+	for _, test := range tests {
+		if !test.PolledComparison {
+			continue
+		}
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("test %s: polled_comparison needs Linux", test.Name)
+		}
+		if !pollingEngines[test.IOEngine] || test.Backend != "" {
+			return fmt.Errorf("test %s: polled_comparison needs ioengine io_uring or pvsync2, not %q", test.Name, test.IOEngine)
+		}
+		if test.Direct != 1 {
+			return fmt.Errorf("test %s: polled_comparison needs direct=1, buffered IO is not polled", test.Name)
+		}
+		if len(test.Jobs) > 0 || test.NoisyNeighbor != nil {
+			return fmt.Errorf("test %s: polled_comparison cannot be combined with job sections", test.Name)
+		}
+	}
+	return nil
+}
+
+// expandPolledComparisons replaces every test with polled_comparison by an
+// interrupt stage and a polled stage running the same job
+func expandPolledComparisons(tests []FioTest) []FioTest {
+	var expanded []FioTest
+	for _, test := range tests {
+		if !test.PolledComparison {
+			expanded = append(expanded, test)
+			continue
+		}
+		for _, mode := range []string{completionInterrupt, completionPolled} {
+			stage := test
+			stage.Name = test.Name + "_" + mode
+			stage.Description = fmt.Sprintf("%s (%s completion)", test.Description, mode)
+			stage.CompletionStage = &CompletionStage{Test: test.Name, Mode: mode}
+			expanded = append(expanded, stage)
+		}
+	}
+	return expanded
+}
+
+// checkPolling makes sure the device of a polled stage has poll queues,
+// without them the IOs of io_uring fail
+func checkPolling(test FioTest, device *DeviceMetadata) error {
+	if test.CompletionStage == nil || test.CompletionStage.Mode != completionPolled || device == nil {
+		return nil
+	}
+	if value, err := readQueueParam(device.Device, "io_poll"); err == nil && value == "0" {
+		return fmt.Errorf("%s does not poll for completions (queue/io_poll is 0), NVMe devices need poll queues from nvme.poll_queues", device.Device)
+	}
+	return nil
+}
+
+// completionStats records the metrics of a polled comparison stage that
+// come from the fio job, so they outlive the compaction of --lite: the
+// completion latency percentiles in microseconds, of the slower of reads and
+// writes, and the CPU of the fio threads. Percentiles fio did not report
+// are left out.
+func completionStats(job *FioJobResult) map[string]float64 {
+	stats := map[string]float64{"cpu_percent": job.UsrCPU + job.SysCPU}
+	for _, p := range completionPercentiles {
+		if clat := max(getPercentile(job.Read.Clat.Percentile, p.key), getPercentile(job.Write.Clat.Percentile, p.key)); clat > 0 {
+			stats[p.label+"_clat_us"] = clat / 1000
+		}
+	}
+	return stats
+}
+
+// completionComparisons compares the passed interrupt and polled stages of
+// the tests run with polled_comparison
+func completionComparisons(results []TestResult) []CompletionComparison {
+	var tests []string
+	stages := map[string]map[string]TestResult{}
+	for _, r := range results {
+		stage := r.Config.CompletionStage
+		if stage == nil || r.Status != "PASSED" {
+			continue
+		}
+		if stages[stage.Test] == nil {
+			tests = append(tests, stage.Test)
+			stages[stage.Test] = map[string]TestResult{}
+		}
+		stages[stage.Test][stage.Mode] = r
+	}
+
+	var comparisons []CompletionComparison
+	for _, test := range tests {
+		interrupt, ok := stages[test][completionInterrupt]
+		polled, ok2 := stages[test][completionPolled]
+		if !ok || !ok2 {
+			continue
+		}
+		comparison := CompletionComparison{Test: test}
+		add := func(metric string, a, b float64) {
+			delta := CompletionDelta{Metric: metric, Interrupt: a, Polled: b}
+			if a > 0 {
+				delta.DeltaPc = 100 * (b - a) / a
+			}
+			comparison.Metrics = append(comparison.Metrics, delta)
+		}
+		add("iops", interrupt.TotalIOPS, polled.TotalIOPS)
+		add("lat_us", interrupt.AvgLatencyUs, polled.AvgLatencyUs)
+		// Metrics missing in either stage are not compared
+		var metrics []string
+		for _, p := range completionPercentiles {
+			metrics = append(metrics, p.label+"_clat_us")
+		}
+		for _, metric := range append(metrics, "cpu_percent") {
+			a, ok := interrupt.CompletionStats[metric]
+			b, ok2 := polled.CompletionStats[metric]
+			if ok && ok2 {
+				add(metric, a, b)
+			}
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons
+}
+
+// completionMetricLabels are the names of the compared metrics in the table
+var completionMetricLabels = map[string]string{
+	"iops":        "IOPS",
+	"lat_us":      "Avg Lat",
+	"cpu_percent": "CPU (usr+sys %)",
+}
+
+// displayCompletionComparisons shows the latency of the tests run with
+// polled_comparison under interrupt and polled completion
+func displayCompletionComparisons(results []TestResult) {
+	for _, comparison := range completionComparisons(results) {
+		fmt.Fprintf(out, "Interrupt vs Polled Completion: %s\n", comparison.Test)
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"Metric", "Interrupt", "Polled", "Delta"})
+		configureTable(table, 4)
+		table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+		for _, metric := range comparison.Metrics {
+			label := completionMetricLabels[metric.Metric]
+			if label == "" {
+				// Latency percentiles, like p99_clat_us
+				label = metric.Metric[:len(metric.Metric)-len("_clat_us")] + " Clat (" + usUnit() + ")"
+			} else if metric.Metric == "lat_us" {
+				label += " (" + usUnit() + ")"
+			}
+			value := func(v float64) string {
+				if metric.Metric == "iops" {
+					return formatCount(v)
+				}
+				return fmt.Sprintf("%.2f", v)
+			}
+			table.Append([]string{label, value(metric.Interrupt), value(metric.Polled), fmt.Sprintf("%+.1f%%", metric.DeltaPc)})
+		}
+		table.Render()
+		fmt.Fprintln(out)
+	}
+}
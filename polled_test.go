@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+// TestCompletionComparisonsCompacted compares the stages of a polled
+// comparison after --lite compacted the interrupt stage, which drops the fio
+// job but keeps the stats recorded from it
+func TestCompletionComparisonsCompacted(t *testing.T) {
+	stage := func(mode string, iops, p99, cpu float64) TestResult {
+		job := &FioJobResult{UsrCPU: cpu / 2, SysCPU: cpu / 2}
+		job.Read.Clat.Percentile = map[string]float64{"99.000000": p99 * 1000}
+		return TestResult{
+			Status:          "PASSED",
+			Config:          FioTest{CompletionStage: &CompletionStage{Test: "randread", Mode: mode}},
+			TotalIOPS:       iops,
+			FioJob:          job,
+			CompletionStats: completionStats(job),
+		}
+	}
+	interrupt := stage(completionInterrupt, 1000, 80, 20)
+	interrupt.compact()
+	polled := stage(completionPolled, 1500, 40, 30)
+
+	comparisons := completionComparisons([]TestResult{interrupt, polled})
+	if len(comparisons) != 1 || comparisons[0].Test != "randread" {
+		t.Fatalf("comparisons = %+v, want one of randread", comparisons)
+	}
+	metrics := map[string]CompletionDelta{}
+	for _, metric := range comparisons[0].Metrics {
+		metrics[metric.Metric] = metric
+	}
+	for _, want := range []CompletionDelta{
+		{Metric: "iops", Interrupt: 1000, Polled: 1500, DeltaPc: 50},
+		{Metric: "p99_clat_us", Interrupt: 80, Polled: 40, DeltaPc: -50},
+		{Metric: "cpu_percent", Interrupt: 20, Polled: 30, DeltaPc: 50},
+	} {
+		if got := metrics[want.Metric]; got != want {
+			t.Errorf("%s = %+v, want %+v", want.Metric, got, want)
+		}
+	}
+	// fio reported no p50 in either stage, it is not compared as 0
+	if got, ok := metrics["p50_clat_us"]; ok {
+		t.Errorf("p50_clat_us = %+v, want no comparison of a percentile fio did not report", got)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// PowerStats contains the power draw measured while a test ran
+type PowerStats struct {
+	Source       string  `json:"source"`
+	Samples      int     `json:"samples"`
+	AvgWatts     float64 `json:"avg_watts"`
+	MaxWatts     float64 `json:"max_watts"`
+	EnergyJoules float64 `json:"energy_joules"`
+	IOPSPerWatt  float64 `json:"iops_per_watt"`
+	MBpsPerWatt  float64 `json:"mbps_per_watt"`
+}
+
+// powerReader returns the current power draw in watts
+type powerReader interface {
+	init() error
+	read() (float64, error)
+}
+
+// powerMonitor samples a power reader at a fixed interval while fio runs
+// and integrates the samples into the consumed energy
+type powerMonitor struct {
+	source   string
+	interval time.Duration
+	reader   powerReader
+	done     chan struct{}
+	stats    chan PowerStats
+}
+
+func newPowerMonitor(source string, interval time.Duration) *powerMonitor {
+	m := &powerMonitor{source: source, interval: interval}
+	switch {
+	case source == "rapl":
+		m.reader = &raplReader{}
+	case source == "ipmi":
+		m.reader = &ipmiReader{}
+	case strings.HasPrefix(source, "pdu:"):
+		m.reader = &pduReader{url: strings.TrimPrefix(source, "pdu:")}
+	}
+	return m
+}
+
+func (m *powerMonitor) name() string {
+	return "power"
+}
+
+func (m *powerMonitor) start() error {
+	if m.reader == nil {
+		return fmt.Errorf("unknown power source %q (use rapl, ipmi or pdu:<url>)", m.source)
+	}
+	if err := m.reader.init(); err != nil {
+		return err
+	}
+
+	m.done = make(chan struct{})
+	m.stats = make(chan PowerStats, 1)
+	go m.sample()
+	return nil
+}
+
+func (m *powerMonitor) sample() {
+	stats := PowerStats{Source: m.source}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	begin := time.Now()
+	last := begin
+	for {
+		select {
+		case <-m.done:
+			if seconds := last.Sub(begin).Seconds(); seconds > 0 {
+				stats.AvgWatts = stats.EnergyJoules / seconds
+			}
+			m.stats <- stats
+			return
+		case now := <-ticker.C:
+			watts, err := m.reader.read()
+			if err != nil {
+				continue
+			}
+			stats.Samples++
+			stats.EnergyJoules += watts * now.Sub(last).Seconds()
+			if watts > stats.MaxWatts {
+				stats.MaxWatts = watts
+			}
+			last = now
+		}
+	}
+}
+
+func (m *powerMonitor) stop(result *TestResult) {
+	close(m.done)
+	stats := <-m.stats
+	if stats.Samples == 0 {
+		result.warn(severityWarning, "monitor", "no power samples were collected from %s", m.source)
+		return
+	}
+	result.Power = &stats
+}
+
+// updateEfficiency computes the performance per watt once the test
+// metrics are known
+func (p *PowerStats) updateEfficiency(result TestResult) {
+	if p.AvgWatts > 0 {
+		p.IOPSPerWatt = result.TotalIOPS / p.AvgWatts
+		p.MBpsPerWatt = result.TotalBWMBps / p.AvgWatts
+	}
+}
+
+// raplReader derives the CPU package power from the RAPL energy counters
+type raplReader struct {
+	domains []string
+	energy  map[string]float64
+	last    time.Time
+}
+
+func (r *raplReader) init() error {
+	matches, _ := filepath.Glob("/sys/class/powercap/intel-rapl:*")
+	for _, match := range matches {
+		// Only the package domains, subdomains are already included in them
+		if strings.Count(filepath.Base(match), ":") == 1 {
+			r.domains = append(r.domains, match)
+		}
+	}
+	if len(r.domains) == 0 {
+		return fmt.Errorf("no RAPL domains found in /sys/class/powercap")
+	}
+
+	r.energy = make(map[string]float64)
+	for _, domain := range r.domains {
+		energy, err := readSysfsFloat(filepath.Join(domain, "energy_uj"))
+		if err != nil {
+			return err
+		}
+		r.energy[domain] = energy
+	}
+	r.last = time.Now()
+	return nil
+}
+
+func (r *raplReader) read() (float64, error) {
+	now := time.Now()
+	var joules float64
+	for _, domain := range r.domains {
+		energy, err := readSysfsFloat(filepath.Join(domain, "energy_uj"))
+		if err != nil {
+			return 0, err
+		}
+		delta := energy - r.energy[domain]
+		if delta < 0 {
+			// The counter wrapped around
+			maxRange, _ := readSysfsFloat(filepath.Join(domain, "max_energy_range_uj"))
+			delta += maxRange
+		}
+		r.energy[domain] = energy
+		joules += delta / 1e6
+	}
+
+	elapsed := now.Sub(r.last).Seconds()
+	r.last = now
+	if elapsed <= 0 {
+		return 0, fmt.Errorf("no time elapsed since last RAPL reading")
+	}
+	return joules / elapsed, nil
+}
+
+// ipmiReader reads the system power draw through the BMC with DCMI
+type ipmiReader struct{}
+
+var ipmiPowerPattern = regexp.MustCompile(`Instantaneous power reading:\s+([0-9.]+)\s+Watts`)
+
+func (r *ipmiReader) init() error {
+	_, err := r.read()
+	return err
+}
+
+func (r *ipmiReader) read() (float64, error) {
+	output, err := exec.Command("ipmitool", "dcmi", "power", "reading").Output()
+	if err != nil {
+		return 0, fmt.Errorf("ipmitool failed: %v", err)
+	}
+	match := ipmiPowerPattern.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("no power reading in ipmitool output")
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// pduReader reads the outlet power from a PDU HTTP API. The response must
+// be either a plain number or a JSON object with a "watts" or "power" field.
+type pduReader struct {
+	url string
+}
+
+var pduClient = &http.Client{Timeout: 2 * time.Second}
+
+func (r *pduReader) init() error {
+	_, err := r.read()
+	return err
+}
+
+func (r *pduReader) read() (float64, error) {
+	resp, err := pduClient.Get(r.url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("PDU returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return 0, err
+	}
+	if watts, err := strconv.ParseFloat(strings.TrimSpace(string(body)), 64); err == nil {
+		return watts, nil
+	}
+
+	var reading map[string]interface{}
+	if err := json.Unmarshal(body, &reading); err != nil {
+		return 0, fmt.Errorf("cannot parse PDU response: %v", err)
+	}
+	for _, field := range []string{"watts", "power"} {
+		if watts, ok := reading[field].(float64); ok {
+			return watts, nil
+		}
+	}
+	return 0, fmt.Errorf("no watts or power field in PDU response")
+}
+
+func readSysfsFloat(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+func displayPower(power *PowerStats) {
+	fmt.Fprintln(out, "Power Consumption")
+	powerTable := tablewriter.NewWriter(out)
+	powerTable.SetHeader([]string{"Metric", "Value"})
+	configureTable(powerTable, 2)
+	powerTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	powerTable.Append([]string{"Source", power.Source})
+	powerTable.Append([]string{"Samples", strconv.Itoa(power.Samples)})
+	powerTable.Append([]string{"Average Power", fmt.Sprintf("%.2f W", power.AvgWatts)})
+	powerTable.Append([]string{"Peak Power", fmt.Sprintf("%.2f W", power.MaxWatts)})
+	powerTable.Append([]string{"Energy", fmt.Sprintf("%.2f J", power.EnergyJoules)})
+	powerTable.Append([]string{"IOPS per Watt", fmt.Sprintf("%.2f", power.IOPSPerWatt)})
+	powerTable.Append([]string{"MB/s per Watt", fmt.Sprintf("%.2f", power.MBpsPerWatt)})
+	powerTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// jsonPrecision is the rounding of the fractional numbers in the JSON
+// exports set with --json-precision. Without any rule numbers keep the
+// full precision of a float64.
+type jsonPrecision struct {
+	// decimals applies to fields no rule names, -1 for full precision
+	decimals int
+	rules    []precisionRule
+}
+
+// precisionRule rounds the fields matching a pattern like "iops" or "*_us"
+type precisionRule struct {
+	pattern  string
+	decimals int
+}
+
+// exportPrecision is the rounding of the JSON exports
+var exportPrecision = jsonPrecision{decimals: -1}
+
+// parseJSONPrecision parses --json-precision: "full", a number of decimals
+// for all fields, or comma-separated field=decimals rules with an optional
+// default, like "3,iops=0,*_ns=0,p99=full". Rules name the JSON field a
+// number belongs to, the numbers of arrays belong to the field of the array.
+func parseJSONPrecision(spec string) (jsonPrecision, error) {
+	precision := jsonPrecision{decimals: -1}
+	parseDecimals := func(value string) (int, error) {
+		if value == "full" {
+			return -1, nil
+		}
+		decimals, err := strconv.Atoi(value)
+		if err != nil || decimals < 0 || decimals > 15 {
+			return 0, fmt.Errorf("invalid JSON precision %q, use full or 0 to 15 decimals", value)
+		}
+		return decimals, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, value, isRule := strings.Cut(part, "=")
+		if !isRule {
+			decimals, err := parseDecimals(part)
+			if err != nil {
+				return precision, err
+			}
+			precision.decimals = decimals
+			continue
+		}
+		if _, err := path.Match(field, ""); err != nil || field == "" {
+			return precision, fmt.Errorf("invalid JSON precision field %q", field)
+		}
+		decimals, err := parseDecimals(value)
+		if err != nil {
+			return precision, err
+		}
+		precision.rules = append(precision.rules, precisionRule{field, decimals})
+	}
+	return precision, nil
+}
+
+// fieldDecimals returns the decimals of a field, the first matching rule
+// wins
+func (p jsonPrecision) fieldDecimals(field string) int {
+	for _, rule := range p.rules {
+		if ok, _ := path.Match(rule.pattern, field); ok {
+			return rule.decimals
+		}
+	}
+	return p.decimals
+}
+
+// apply rounds the fractional numbers of an encoded JSON document, keeping
+// its layout and field order. Integers and strings are left alone.
+func (p jsonPrecision) apply(data []byte) []byte {
+	if p.decimals < 0 && len(p.rules) == 0 {
+		return data
+	}
+	var b bytes.Buffer
+	b.Grow(len(data))
+	// keys holds the field of every open object or array, so numbers in
+	// arrays belong to the field of the array
+	var keys []string
+	key := ""
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			str := data[i:min(end, len(data))]
+			// A string followed by a colon is a field name
+			next := end
+			for next < len(data) && (data[next] == ' ' || data[next] == '\n' || data[next] == '\t' || data[next] == '\r') {
+				next++
+			}
+			if next < len(data) && data[next] == ':' {
+				key, _ = strconv.Unquote(string(str))
+			}
+			b.Write(str)
+			i = end
+		case c == '{' || c == '[':
+			keys = append(keys, key)
+			b.WriteByte(c)
+			i++
+		case c == '}' || c == ']':
+			if len(keys) > 0 {
+				key = keys[len(keys)-1]
+				keys = keys[:len(keys)-1]
+			}
+			b.WriteByte(c)
+			i++
+		case c == '-' || c >= '0' && c <= '9':
+			end := i
+			for end < len(data) && strings.IndexByte("+-.eE0123456789", data[end]) >= 0 {
+				end++
+			}
+			b.WriteString(p.round(string(data[i:end]), key))
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.Bytes()
+}
+
+// round rounds a number of a field to its decimals, integers stay as they
+// are
+func (p jsonPrecision) round(number, field string) string {
+	if !strings.ContainsAny(number, ".eE") {
+		return number
+	}
+	decimals := p.fieldDecimals(field)
+	v, err := strconv.ParseFloat(number, 64)
+	if decimals < 0 || err != nil {
+		return number
+	}
+	scale := math.Pow(10, float64(decimals))
+	rounded := math.Round(v*scale) / scale
+	if math.IsInf(rounded*scale, 0) || math.IsNaN(rounded) {
+		return number
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultProfileTarget is where profiles run without --target
+const defaultProfileTarget = "fio-qa-profile.dat"
+
+// workloadProfiles are built-in suites selected with --profile instead of a
+// test case file, for qualification artifacts that are always measured the
+// same way. Their tests name a workload template and run on --target.
+var workloadProfiles = map[string]TestCases{
+	"qd1-latency": {
+		Name: "QD1 Latency Characterization",
+		Tests: []FioTest{
+			{Name: "qd1_randread_4k", Template: "qd1-read-latency"},
+			{Name: "qd1_randwrite_4k", Template: "qd1-write-latency"},
+		},
+	},
+}
+
+// loadProfile returns the tests of a built-in profile on the target
+func loadProfile(name, target string) (*TestCases, error) {
+	profile, ok := workloadProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(workloadProfiles))
+		for known := range workloadProfiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile %q (available: %v)", name, names)
+	}
+	testCases := &TestCases{Name: profile.Name}
+	for _, ref := range profile.Tests {
+		test := workloadTemplates[ref.Template]
+		test.Name = ref.Name
+		test.Template = ref.Template
+		test.Filename = target
+		test.Percentiles = append([]float64(nil), test.Percentiles...)
+		testCases.Tests = append(testCases.Tests, test)
+	}
+	return testCases, nil
+}
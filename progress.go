@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"fio-qa/runner"
+)
+
+// ProgressEvent is one line of the NDJSON progress stream enabled with
+// --progress-json, letting orchestrators follow a run without parsing the
+// human readable tables. Observers of the runner package receive the same
+// events.
+type ProgressEvent = runner.Event
+
+// Progress event names
+const (
+	eventSuiteStarted  = runner.EventSuiteStarted
+	eventTestStarted   = runner.EventTestStarted
+	eventTestProgress  = runner.EventTestProgress
+	eventTestFinished  = runner.EventTestFinished
+	eventSuiteFinished = runner.EventSuiteFinished
+)
+
+// ndjsonObserver writes every event as a single JSON line
+type ndjsonObserver struct {
+	w io.Writer
+}
+
+func (o ndjsonObserver) write(event ProgressEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	o.w.Write(append(data, '\n'))
+}
+
+func (o ndjsonObserver) OnSuiteStart(event ProgressEvent)    { o.write(event) }
+func (o ndjsonObserver) OnTestStart(event ProgressEvent)     { o.write(event) }
+func (o ndjsonObserver) OnProgress(event ProgressEvent)      { o.write(event) }
+func (o ndjsonObserver) OnTestComplete(event ProgressEvent)  { o.write(event) }
+func (o ndjsonObserver) OnSuiteComplete(event ProgressEvent) { o.write(event) }
+
+// openProgress opens the destination of the progress events: "-" for
+// stdout, "fd:N" for an inherited file descriptor or a file path
+func openProgress(target string) error {
+	var progress io.Writer
+	switch {
+	case target == "":
+		return nil
+	case target == "-":
+		if opts.JSON {
+			return fmt.Errorf("--progress-json - cannot be combined with --json, both write to stdout")
+		}
+		// Keep stdout for the events only
+		out = os.Stderr
+		progress = os.Stdout
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 3 {
+			return fmt.Errorf("invalid progress file descriptor %q, use fd:3 or higher", target)
+		}
+		progress = os.NewFile(uintptr(fd), target)
+	default:
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		progress = f
+	}
+	runner.Register(ndjsonObserver{progress})
+	return nil
+}
+
+// emitProgress passes an event of the run to the observers
+func emitProgress(event ProgressEvent) {
+	if !runner.Observed() {
+		return
+	}
+	event.Run = runID
+	event.Namespace = namespace
+	runner.Emit(event)
+}
+
+// testFinishedEvent describes a finished test
+func testFinishedEvent(result TestResult, index, total int) ProgressEvent {
+	warnings := len(result.Warnings)
+	event := ProgressEvent{
+		Event:     eventTestFinished,
+		Index:     index,
+		Total:     total,
+		TestName:  result.TestName,
+		Status:    result.Status,
+		Duration:  result.Duration.Seconds(),
+		IOPS:      result.TotalIOPS,
+		BWMBps:    result.TotalBWMBps,
+		LatencyUs: result.AvgLatencyUs,
+		Warnings:  &warnings,
+		Result:    &result,
+	}
+	if result.Error != nil {
+		event.Error = result.Error.Error()
+	}
+	return event
+}
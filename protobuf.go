@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// The results are encoded by hand following proto/results.proto, the wire
+// format is simple enough that it does not warrant a protobuf dependency.
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoWriter appends the fields of a message. Like proto3, fields with
+// default values are omitted.
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType))
+}
+
+func (w *protoWriter) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, uint64(v))
+}
+
+func (w *protoWriter) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, wireFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
+
+// protoField is a decoded field of a message
+type protoField struct {
+	Number int
+	Varint uint64
+	Bytes  []byte
+}
+
+// parseProtoFields splits a message into its fields
+func parseProtoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		data = data[n:]
+		field := protoField{Number: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			field.Varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", field.Number)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("truncated field %d", field.Number)
+			}
+			field.Varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, fmt.Errorf("truncated field %d", field.Number)
+			}
+			field.Bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", key&7, field.Number)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// encodeResultsProto encodes the results as a fioqa.v1.Results message
+func encodeResultsProto(results JSONResults) ([]byte, error) {
+	var msg protoWriter
+
+	var summary protoWriter
+	summary.int(1, int64(results.Summary.TotalTests))
+	summary.int(2, int64(results.Summary.Passed))
+	summary.int(3, int64(results.Summary.Failed))
+	summary.int(4, int64(results.Summary.Warnings))
+	summary.string(5, results.Summary.TotalDuration)
+	msg.bytes(1, summary.buf)
+
+	for _, r := range results.TestResults {
+		details, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		var test protoWriter
+		test.string(1, r.TestName)
+		test.string(2, r.Description)
+		test.string(3, r.Status)
+		test.string(4, r.Duration)
+		test.double(5, r.IOPS)
+		test.double(6, r.BandwidthMBps)
+		test.double(7, r.LatencyUs)
+		test.string(8, r.Error)
+		test.string(9, r.Config.Suite)
+		test.bytes(15, details)
+		// Empty messages still count as a repeated element
+		msg.tag(2, wireBytes)
+		msg.buf = binary.AppendUvarint(msg.buf, uint64(len(test.buf)))
+		msg.buf = append(msg.buf, test.buf...)
+	}
+
+	msg.string(3, results.Suite)
+	msg.string(4, results.RunID)
+	msg.string(5, results.Namespace)
+
+	rest := results
+	rest.Summary = JSONSummary{}
+	rest.TestResults = nil
+	details, err := json.Marshal(rest)
+	if err != nil {
+		return nil, err
+	}
+	msg.bytes(15, details)
+	return msg.buf, nil
+}
+
+// decodeResultsProto decodes a fioqa.v1.Results message. The details are
+// preferred over the typed fields, which only fill in results encoded by
+// other tools without details.
+func decodeResultsProto(data []byte) (JSONResults, error) {
+	var results JSONResults
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return results, err
+	}
+
+	// Details first, so the typed fields below take precedence for the
+	// summary and the tests
+	for _, field := range fields {
+		if field.Number == 15 {
+			if err := json.Unmarshal(field.Bytes, &results); err != nil {
+				return results, fmt.Errorf("invalid details_json: %v", err)
+			}
+		}
+	}
+	results.TestResults = nil
+
+	for _, field := range fields {
+		switch field.Number {
+		case 1:
+			summaryFields, err := parseProtoFields(field.Bytes)
+			if err != nil {
+				return results, err
+			}
+			for _, f := range summaryFields {
+				switch f.Number {
+				case 1:
+					results.Summary.TotalTests = int(f.Varint)
+				case 2:
+					results.Summary.Passed = int(f.Varint)
+				case 3:
+					results.Summary.Failed = int(f.Varint)
+				case 4:
+					results.Summary.Warnings = int(f.Varint)
+				case 5:
+					results.Summary.TotalDuration = string(f.Bytes)
+				}
+			}
+		case 2:
+			test, err := decodeTestResultProto(field.Bytes)
+			if err != nil {
+				return results, err
+			}
+			results.TestResults = append(results.TestResults, test)
+		case 3:
+			results.Suite = string(field.Bytes)
+		case 4:
+			results.RunID = string(field.Bytes)
+		case 5:
+			results.Namespace = string(field.Bytes)
+		}
+	}
+	return results, nil
+}
+
+func decodeTestResultProto(data []byte) (JSONTestResult, error) {
+	var test JSONTestResult
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return test, err
+	}
+	for _, f := range fields {
+		if f.Number == 15 {
+			if err := json.Unmarshal(f.Bytes, &test); err != nil {
+				return test, fmt.Errorf("invalid test details_json: %v", err)
+			}
+			return test, nil
+		}
+	}
+
+	for _, f := range fields {
+		switch f.Number {
+		case 1:
+			test.TestName = string(f.Bytes)
+		case 2:
+			test.Description = string(f.Bytes)
+		case 3:
+			test.Status = string(f.Bytes)
+		case 4:
+			test.Duration = string(f.Bytes)
+		case 5:
+			test.IOPS = math.Float64frombits(f.Varint)
+		case 6:
+			test.BandwidthMBps = math.Float64frombits(f.Varint)
+		case 7:
+			test.LatencyUs = math.Float64frombits(f.Varint)
+		case 8:
+			test.Error = string(f.Bytes)
+		case 9:
+			test.Config.Suite = string(f.Bytes)
+		}
+	}
+	return test, nil
+}
+
+// saveResultsToProto writes the protobuf encoding of the results
+func saveResultsToProto(results JSONResults, filename string) error {
+	data, err := encodeResultsProto(results)
+	if err != nil {
+		return fmt.Errorf("failed to encode protobuf: %v", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// readResultsFile reads a results file in JSON or protobuf, reporting
+// which of the two it was
+func readResultsFile(path string) (JSONResults, bool, error) {
+	data, err := readArtifact(path)
+	if err != nil {
+		return JSONResults{}, false, err
+	}
+
+	var results JSONResults
+	isJSON := strings.HasPrefix(strings.TrimSpace(string(data[:min(len(data), 16)])), "{")
+	if isJSON {
+		err = json.Unmarshal(data, &results)
+	} else {
+		results, err = decodeResultsProto(data)
+	}
+	if err != nil {
+		return JSONResults{}, false, fmt.Errorf("%s: %v", path, err)
+	}
+	return results, isJSON, nil
+}
+
+// runConvert converts results files between JSON and protobuf. The format
+// of the input is detected, the output is the other format unless --to is
+// set.
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := flags.String("to", "", "output format: json, proto, xlsx or pdf (default: json or proto, whichever the input is not in)")
+	locale := flags.String("locale", "en", "language of xlsx and pdf output: en, de, ro or a JSON file of labels")
+	grouping := flags.String("digit-grouping", "none", "thousands separator of IOPS in pdf output: none, locale or a separator like ,")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := setReportLocale(*locale); err != nil {
+		return usageError("%v", err)
+	}
+	if err := setDigitGrouping(*grouping, *locale); err != nil {
+		return usageError("%v", err)
+	}
+	if flags.NArg() != 2 {
+		return usageError("usage: fio-qa convert [--to json|proto|xlsx|pdf] input output")
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	results, isJSON, err := readResultsFile(input)
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	format := *to
+	if format == "" {
+		format = "proto"
+		if !isJSON {
+			format = "json"
+		}
+	}
+	switch format {
+	case "json":
+		err = saveResultsToJSON(results, output)
+	case "proto":
+		err = saveResultsToProto(results, output)
+	case "xlsx":
+		err = saveResultsToXLSX(results, output)
+	case "pdf":
+		err = saveResultsToPDF(results, output)
+	default:
+		return usageError("unknown format %q", format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitOutput
+	}
+	fmt.Printf("%s: %d test results written as %s\n", output, len(results.TestResults), format)
+	return exitOK
+}
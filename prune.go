@@ -0,0 +1,367 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides which runs "fio-qa prune" keeps in the history
+// store and the artifact directory
+type RetentionPolicy struct {
+	// KeepRuns is how many of the latest runs are kept per device and
+	// namespace, 0 keeps all
+	KeepRuns int
+	// KeepFailures keeps runs with a failed test for this long, even when
+	// they are beyond KeepRuns
+	KeepFailures time.Duration
+	// CompressAfter packs the artifact bundles of kept runs older than this
+	// into a .tar.gz, 0 never compresses
+	CompressAfter time.Duration
+}
+
+// retainedRun collects what the history store knows about a run
+type retainedRun struct {
+	id     string
+	time   time.Time
+	failed bool
+	// approved runs were signed off and are never pruned
+	approved bool
+	devices  map[string]bool
+}
+
+// pruneStats counts what a prune did
+type pruneStats struct {
+	runs       int
+	records    int
+	removed    int
+	compressed int
+	freedBytes int64
+}
+
+// artifactTimestampLayout is the start of the run in the name of its
+// artifact bundle, followed by the run ID, see RunInfo.ArtifactsDir
+const artifactTimestampLayout = "2006-01-02-150405"
+
+// runPrune removes old runs from the history store and the artifact
+// directory and compresses the artifacts of older kept runs
+func runPrune(args []string) int {
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	history := flags.String("history", "fio-qa-history.ndjson", "history store to prune, empty to only compress artifacts")
+	artifactsDir := flags.String("artifacts-dir", "artifacts", "directory holding the per-run artifact bundles")
+	var policy RetentionPolicy
+	flags.IntVar(&policy.KeepRuns, "keep-runs", 0, "latest runs to keep per device and namespace, 0 keeps all")
+	flags.DurationVar(&policy.KeepFailures, "keep-failures", 90*24*time.Hour, "keep runs with a failed test this long even beyond --keep-runs")
+	flags.DurationVar(&policy.CompressAfter, "compress-after", 0, "compress the artifact bundles of runs older than this into .tar.gz files (default: never)")
+	dryRun := flags.Bool("dry-run", false, "only show what would be pruned")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if policy.KeepRuns < 0 || policy.KeepFailures < 0 || policy.CompressAfter < 0 {
+		return usageError("--keep-runs, --keep-failures and --compress-after must not be negative")
+	}
+
+	var stats pruneStats
+	pruned := map[string]bool{}
+	if *history != "" {
+		runs, err := historyRuns(*history)
+		if err != nil && !os.IsNotExist(err) {
+			return usageError("%v", err)
+		}
+		pruned = policy.prunedRuns(runs, time.Now())
+		stats.runs = len(pruned)
+		if len(pruned) > 0 {
+			if stats.records, err = pruneHistory(*history, pruned, *dryRun); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitEnvironment
+			}
+		}
+	}
+	if err := policy.pruneArtifacts(*artifactsDir, pruned, *dryRun, &stats); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitEnvironment
+	}
+
+	verb := "Pruned"
+	if *dryRun {
+		verb = "Would prune"
+	}
+	fmt.Fprintf(out, "%s %d runs (%d history records), removed %d and compressed %d artifact bundles, %.1f MB freed\n",
+		verb, stats.runs, stats.records, stats.removed, stats.compressed, float64(stats.freedBytes)/1024/1024)
+	return exitOK
+}
+
+// historyRuns reads the runs of the history store with the devices they
+// tested
+func historyRuns(path string) (map[string]*retainedRun, error) {
+	runs := map[string]*retainedRun{}
+	err := scanHistory(path, HistoryFilter{}, func(record HistoryRecord) error {
+		if record.Run == "" {
+			return nil
+		}
+		run := runs[record.Run]
+		if run == nil {
+			run = &retainedRun{id: record.Run, devices: map[string]bool{}}
+			runs[record.Run] = run
+		}
+		if record.Kind == historySignOff {
+			// The approval is no result of the run on a drive
+			run.approved = run.approved || record.Status == signOffApproved
+			return nil
+		}
+		if record.Time.After(run.time) {
+			run.time = record.Time
+		}
+		if record.Kind == historyResult && record.Status != "" && record.Status != "PASSED" {
+			run.failed = true
+		}
+		// Drives are told apart by serial number, like in campaigns
+		device := ""
+		if record.Device != nil {
+			device = record.Device.Serial
+			if device == "" {
+				device = record.Device.Model + " " + record.Device.Device
+			}
+		}
+		run.devices[record.Namespace+"\x00"+device] = true
+		return nil
+	})
+	return runs, err
+}
+
+// prunedRuns returns the runs the policy drops: runs that are not among the
+// latest KeepRuns of any of their devices, unless they failed within
+// KeepFailures
+func (p RetentionPolicy) prunedRuns(runs map[string]*retainedRun, now time.Time) map[string]bool {
+	pruned := map[string]bool{}
+	if p.KeepRuns == 0 {
+		return pruned
+	}
+	perDevice := map[string][]*retainedRun{}
+	for _, run := range runs {
+		for device := range run.devices {
+			perDevice[device] = append(perDevice[device], run)
+		}
+	}
+	kept := map[string]bool{}
+	for _, deviceRuns := range perDevice {
+		sort.Slice(deviceRuns, func(i, j int) bool { return deviceRuns[i].time.After(deviceRuns[j].time) })
+		for i, run := range deviceRuns {
+			if i < p.KeepRuns || run.failed && now.Sub(run.time) < p.KeepFailures {
+				kept[run.id] = true
+			}
+		}
+	}
+	for id, run := range runs {
+		if !kept[id] && !run.approved {
+			pruned[id] = true
+		}
+	}
+	return pruned
+}
+
+// pruneHistory rewrites the history store without the records of the pruned
+// runs. Kept lines are copied as they are, the new store replaces the old
+// one by renaming, so readers never see a partial file. The store is locked
+// throughout, so runs cannot append records the rename would drop.
+func pruneHistory(path string, pruned map[string]bool, dryRun bool) (int, error) {
+	if !dryRun {
+		lock, err := lockHistory(path)
+		if err != nil {
+			return 0, err
+		}
+		defer unlockFile(lock)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	var writer *bufio.Writer
+	var tmp *os.File
+	if !dryRun {
+		if tmp, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".prune-*"); err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		writer = bufio.NewWriter(tmp)
+	}
+
+	removed := 0
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record struct {
+			Run string `json:"run"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return 0, fmt.Errorf("%s: invalid record: %v", path, err)
+		}
+		if pruned[record.Run] {
+			removed++
+			continue
+		}
+		if writer != nil {
+			writer.Write(line)
+			writer.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return removed, nil
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if info, err := in.Stat(); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return removed, os.Rename(tmp.Name(), path)
+}
+
+// pruneArtifacts removes the artifact bundles of pruned runs and compresses
+// those of kept runs older than CompressAfter. Bundles are named
+// <timestamp>-<run ID>, below a directory per namespace or directly in the
+// artifact directory.
+func (p RetentionPolicy) pruneArtifacts(dir string, pruned map[string]bool, dryRun bool, stats *pruneStats) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		started, runID, ok := parseBundleName(entry.Name())
+		if !ok {
+			if entry.IsDir() {
+				// The bundles of a namespace
+				if err := p.pruneArtifacts(path, pruned, dryRun, stats); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		size := pathSize(path)
+		switch {
+		case pruned[runID]:
+			stats.removed++
+			stats.freedBytes += size
+			if !dryRun {
+				if err := os.RemoveAll(path); err != nil {
+					return err
+				}
+			}
+		case entry.IsDir() && p.CompressAfter > 0 && time.Since(started) > p.CompressAfter:
+			stats.compressed++
+			if dryRun {
+				continue
+			}
+			if err := compressBundle(path); err != nil {
+				return err
+			}
+			stats.freedBytes += size - pathSize(path+".tar.gz")
+		}
+	}
+	return nil
+}
+
+// parseBundleName splits the name of an artifact bundle, a directory or its
+// compressed archive, into the start of the run and its ID
+func parseBundleName(name string) (time.Time, string, bool) {
+	name = strings.TrimSuffix(name, ".tar.gz")
+	if len(name) <= len(artifactTimestampLayout)+1 || name[len(artifactTimestampLayout)] != '-' {
+		return time.Time{}, "", false
+	}
+	started, err := time.ParseInLocation(artifactTimestampLayout, name[:len(artifactTimestampLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return started, name[len(artifactTimestampLayout)+1:], true
+}
+
+// compressBundle packs an artifact bundle into <bundle>.tar.gz next to it
+// and removes the directory once the archive is complete
+func compressBundle(dir string) error {
+	archive := dir + ".tar.gz"
+	f, err := os.Create(archive + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive + ".tmp")
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(dir)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(base, path)
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("compressing %s: %v", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(archive+".tmp", archive); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// pathSize returns the size of a file or of all files below a directory
+func pathSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
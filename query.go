@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// queryMetric is a metric of the history records "results query" can show
+type queryMetric struct {
+	header func() string
+	value  func(HistoryRecord) float64
+}
+
+// queryMetrics are the metrics selected with --metric
+var queryMetrics = map[string]queryMetric{
+	"iops":     {func() string { return "IOPS" }, func(r HistoryRecord) float64 { return r.IOPS }},
+	"bw":       {func() string { return "BW (MB/s)" }, func(r HistoryRecord) float64 { return r.BWMBps }},
+	"lat":      {func() string { return "Lat (" + usUnit() + ")" }, func(r HistoryRecord) float64 { return r.LatencyUs }},
+	"p99":      {func() string { return "p99 (" + usUnit() + ")" }, func(r HistoryRecord) float64 { return r.P99LatencyUs }},
+	"duration": {func() string { return "Duration (s)" }, func(r HistoryRecord) float64 { return r.DurationSeconds }},
+}
+
+// parseLabels parses key=value labels given with --label
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	parsed := map[string]string{}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// recordLabel returns the value of a label of a history record. Labels given
+// to the run take precedence over the fields of the record and its device,
+// so firmware=1.2.3 matches without labeling every run.
+func recordLabel(record HistoryRecord, key string) (string, bool) {
+	if value, ok := record.Labels[key]; ok {
+		return value, true
+	}
+	switch key {
+	case "run":
+		return record.Run, true
+	case "namespace":
+		return record.Namespace, true
+	case "suite":
+		return record.Suite, true
+	case "status":
+		return record.Status, true
+	}
+	if record.Device == nil {
+		return "", false
+	}
+	switch key {
+	case "device":
+		return record.Device.Device, true
+	case "model":
+		return record.Device.Model, true
+	case "serial":
+		return record.Device.Serial, true
+	case "firmware":
+		return record.Device.Firmware, true
+	}
+	return "", false
+}
+
+// runResults runs the subcommands working on the results in the history
+// store
+func runResults(args []string) int {
+	if len(args) == 0 || args[0] != "query" {
+		return usageError("usage: fio-qa results query [flags]")
+	}
+	return runQuery(args[1:])
+}
+
+// runQuery lists the results in the history store matching labels, a test
+// and a time range, as a table, CSV or JSON
+func runQuery(args []string) int {
+	flags := flag.NewFlagSet("results query", flag.ContinueOnError)
+	history := flags.String("history", "fio-qa-history.ndjson", "history store to read")
+	var labels stringList
+	flags.Var(&labels, "label", "only show results with this key=value label, like firmware=1.2.3, given several times all must match")
+	test := flags.String("test", "", "only show this test")
+	ns := flags.String("namespace", "", "only show results of this namespace (default: all namespaces)")
+	since := flags.Duration("since", 0, "how far back to look (default: the whole history)")
+	metric := flags.String("metric", "iops,bw,lat,p99", "comma-separated metrics to show: iops, bw, lat, p99, duration")
+	format := flags.String("format", "table", "output format: table, csv or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	want, err := parseLabels(labels)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	var metrics []queryMetric
+	var metricNames []string
+	for _, name := range strings.Split(*metric, ",") {
+		m, ok := queryMetrics[strings.TrimSpace(name)]
+		if !ok {
+			return usageError("unknown metric %q, use iops, bw, lat, p99 or duration", name)
+		}
+		metrics = append(metrics, m)
+		metricNames = append(metricNames, strings.TrimSpace(name))
+	}
+	if *format != "table" && *format != "csv" && *format != "json" {
+		return usageError("unknown format %q, use table, csv or json", *format)
+	}
+
+	filter := HistoryFilter{Kind: historyResult, Test: *test, Namespace: *ns}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+	var records []HistoryRecord
+	err = scanHistory(*history, filter, func(record HistoryRecord) error {
+		for key, value := range want {
+			if actual, ok := recordLabel(record, key); !ok || actual != value {
+				return nil
+			}
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	switch *format {
+	case "json":
+		if records == nil {
+			records = []HistoryRecord{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(records); err != nil {
+			return usageError("%v", err)
+		}
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write(append([]string{"time", "run", "namespace", "test", "status", "labels"}, metricNames...))
+		for _, record := range records {
+			row := []string{record.Time.Format(time.RFC3339), record.Run, record.Namespace, record.Test, record.Status, formatLabels(record.Labels)}
+			for _, m := range metrics {
+				row = append(row, fmt.Sprintf("%.2f", m.value(record)))
+			}
+			writer.Write(row)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return usageError("%v", err)
+		}
+	default:
+		if len(records) == 0 {
+			fmt.Fprintf(out, "No results in %s match the query\n", *history)
+			return exitOK
+		}
+		displayQuery(records, metrics)
+	}
+	return exitOK
+}
+
+// displayQuery shows the matching results with the mean, minimum and
+// maximum of every metric over the passed ones
+func displayQuery(records []HistoryRecord, metrics []queryMetric) {
+	table := tablewriter.NewWriter(out)
+	header := []string{"Test", "Time", "Status"}
+	alignment := []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT}
+	for _, m := range metrics {
+		header = append(header, m.header())
+		alignment = append(alignment, tablewriter.ALIGN_RIGHT)
+	}
+	table.SetHeader(header)
+	configureTable(table, len(header))
+	table.SetColumnAlignment(alignment)
+
+	passed := 0
+	sums := make([]float64, len(metrics))
+	mins := make([]float64, len(metrics))
+	maxs := make([]float64, len(metrics))
+	for _, record := range records {
+		test := record.Test
+		if record.Namespace != "" {
+			test = record.Namespace + "/" + test
+		}
+		row := []string{test, record.Time.Local().Format("2006-01-02 15:04"), record.Status}
+		for _, m := range metrics {
+			row = append(row, fmt.Sprintf("%.2f", m.value(record)))
+		}
+		table.Append(row)
+		if record.Status != "PASSED" {
+			continue
+		}
+		passed++
+		for i, m := range metrics {
+			value := m.value(record)
+			sums[i] += value
+			if passed == 1 || value < mins[i] {
+				mins[i] = value
+			}
+			maxs[i] = max(maxs[i], value)
+		}
+	}
+	table.Render()
+	if passed == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%d of %d results passed, mean (min-max):\n", passed, len(records))
+	for i, m := range metrics {
+		fmt.Fprintf(out, "  %s: %.2f (%.2f-%.2f)\n", m.header(), sums[i]/float64(passed), mins[i], maxs[i])
+	}
+}
+
+// formatLabels joins labels sorted by key, like "firmware=1.2.3 host=a"
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,140 @@
+package main
+
+import "fmt"
+
+// rateCap is the bandwidth in bytes per second no test of the run may
+// exceed, 0 when the run is not capped
+var rateCap int64
+
+// resolveRateCap sets the rate cap of the run, the lower of the one given
+// with --rate-cap and the one of the test cases
+func resolveRateCap(option, testCases string) error {
+	for _, value := range []string{option, testCases} {
+		if value == "" {
+			continue
+		}
+		limit := parseSize(value)
+		if limit <= 0 {
+			return fmt.Errorf("invalid rate cap %q, use a bandwidth in bytes per second like 200M", value)
+		}
+		if rateCap == 0 || limit < rateCap {
+			rateCap = limit
+		}
+	}
+	return nil
+}
+
+// lowerRateCap returns the lower of two rate caps, an empty cap does not
+// limit
+func lowerRateCap(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "" || parseSize(a) <= parseSize(b):
+		return a
+	}
+	return b
+}
+
+// checkRateCap refuses the tests the rate cap cannot be enforced on, before
+// anything runs
+func checkRateCap(tests []FioTest) error {
+	if rateCap == 0 {
+		return nil
+	}
+	for _, test := range tests {
+		switch {
+		case test.Backend != "":
+			return fmt.Errorf("test %s: the %s backend cannot be rate limited, refusing to run it under a rate cap", test.Name, test.Backend)
+		case test.NoisyNeighbor != nil:
+			return fmt.Errorf("test %s: noisy neighbor tests set the rates of their jobs, refusing to run it under a rate cap", test.Name)
+		}
+		for _, section := range test.Jobs {
+			if section.Rate != "" {
+				return fmt.Errorf("test %s: job section %s sets its own rate, refusing to run it under a rate cap", test.Name, section.Name)
+			}
+		}
+		if rateCap/int64(testJobs(test)) < 1024 {
+			return fmt.Errorf("test %s: the rate cap leaves less than 1 KiB/s to each of its %d jobs", test.Name, testJobs(test))
+		}
+	}
+	return nil
+}
+
+// testJobs returns the number of fio jobs of a test, every one of them is
+// rate limited on its own
+func testJobs(test FioTest) int {
+	jobs := max(test.NumJobs, 1)
+	if len(test.Jobs) == 0 {
+		return jobs
+	}
+	total := 0
+	for _, section := range test.Jobs {
+		if section.NumJobs > 0 {
+			total += section.NumJobs
+		} else {
+			total += jobs
+		}
+	}
+	return total
+}
+
+// mixedWorkloads are the fio workloads both reading and writing
+var mixedWorkloads = map[string]bool{
+	"rw":        true,
+	"readwrite": true,
+	"randrw":    true,
+}
+
+// readMix returns the percentage of reads of a mixed workload the way fio
+// takes it: rwmixread, else what rwmixwrite leaves, else half
+func readMix(test FioTest) int {
+	switch {
+	case test.RWMixRead > 0:
+		return test.RWMixRead
+	case test.RWMixWrite > 0:
+		return 100 - test.RWMixWrite
+	}
+	return 50
+}
+
+// rateCapArgs returns the fio rate limit keeping a test under the rate cap.
+// fio limits every job and every direction on its own, so the cap is split
+// between the jobs and, for mixed workloads, between reads and writes by
+// their mix. Job sections get their own limit from rateCapSections.
+func rateCapArgs(test FioTest) []string {
+	if rateCap == 0 {
+		return nil
+	}
+	return []string{"--rate=" + cappedRate(test, rateCap/int64(testJobs(test)))}
+}
+
+// rateCapSections returns the job sections of a test limited to their share
+// of the rate cap, split by the mix each section runs with
+func rateCapSections(test FioTest) []FioJobSection {
+	if rateCap == 0 || len(test.Jobs) == 0 {
+		return test.Jobs
+	}
+	share := rateCap / int64(testJobs(test))
+	sections := make([]FioJobSection, len(test.Jobs))
+	for i, section := range test.Jobs {
+		section.Rate = cappedRate(section.apply(test), share)
+		sections[i] = section
+	}
+	return sections
+}
+
+// cappedRate returns the fio rate of a job limited to share bytes per second
+func cappedRate(test FioTest, share int64) string {
+	if !mixedWorkloads[test.RW] {
+		return fmt.Sprintf("%d", share)
+	}
+	read := max(share*int64(readMix(test))/100, 1)
+	write := max(share-read, 1)
+	return fmt.Sprintf("%d,%d", read, write)
+}
+
+// formatRate formats a bandwidth in bytes per second
+func formatRate(rate int64) string {
+	return fmt.Sprintf("%.1f MB/s", float64(rate)/1024/1024)
+}
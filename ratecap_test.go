@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestRateCapMix expects the cap to be split by the mix fio runs every job
+// with: the one of its section, else the test, rwmixwrite when rwmixread is
+// not set
+func TestRateCapMix(t *testing.T) {
+	defer func(limit int64) { rateCap = limit }(rateCap)
+	rateCap = 1000
+
+	tests := []struct {
+		name     string
+		test     string
+		args     []string
+		sections []string
+	}{
+		{"read", `{"rw": "randread"}`, []string{"--rate=1000"}, nil},
+		{"default mix", `{"rw": "randrw"}`, []string{"--rate=500,500"}, nil},
+		{"rwmixread", `{"rw": "randrw", "rwmixread": 70}`, []string{"--rate=700,300"}, nil},
+		{"rwmixwrite", `{"rw": "randrw", "rwmixwrite": 20}`, []string{"--rate=800,200"}, nil},
+		{"rwmixwrite over template", `{"template": "oltp-4k-mixed", "numjobs": 1, "rwmixwrite": 10}`, []string{"--rate=900,100"}, nil},
+		{"sections", `{"rw": "randrw", "rwmixwrite": 40, "numjobs": 1, "jobs": [
+			{"name": "a"},
+			{"name": "b", "rwmixread": 90},
+			{"name": "c", "rwmixwrite": 25},
+			{"name": "d", "rw": "write"}]}`,
+			[]string{"--rate=150,100"}, []string{"150,100", "225,25", "187,63", "250"}},
+	}
+	for _, tt := range tests {
+		var test FioTest
+		if err := json.Unmarshal([]byte(tt.test), &test); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := rateCapArgs(test); !reflect.DeepEqual(got, tt.args) {
+			t.Errorf("%s: rateCapArgs = %v, want %v", tt.name, got, tt.args)
+		}
+		var rates []string
+		for _, section := range rateCapSections(test) {
+			rates = append(rates, section.Rate)
+		}
+		if !reflect.DeepEqual(rates, tt.sections) {
+			t.Errorf("%s: section rates = %v, want %v", tt.name, rates, tt.sections)
+		}
+	}
+}
+
+// TestRWMixConflict expects rwmixread and rwmixwrite that do not add up to
+// 100 to be refused
+func TestRWMixConflict(t *testing.T) {
+	var test FioTest
+	if err := json.Unmarshal([]byte(`{"name": "mix", "rw": "randrw", "rwmixread": 70, "rwmixwrite": 70}`), &test); err == nil {
+		t.Errorf("Unmarshal = nil, want an error")
+	}
+	if err := json.Unmarshal([]byte(`{"name": "mix", "rw": "randrw", "rwmixread": 70, "rwmixwrite": 30}`), &test); err != nil {
+		t.Errorf("Unmarshal = %v, want nil", err)
+	}
+}
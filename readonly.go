@@ -0,0 +1,71 @@
+package main
+
+import "fmt"
+
+// SkippedTest is a test left out of the run, with the reason
+type SkippedTest struct {
+	TestName string `json:"test_name"`
+	Reason   string `json:"reason"`
+}
+
+// skippedTests are the tests --read-only left out of the run
+var skippedTests []SkippedTest
+
+// readOnlyWorkloads are the fio workloads that only read
+var readOnlyWorkloads = map[string]bool{
+	"read":     true,
+	"randread": true,
+}
+
+// writeReason tells why a test would write to its target, empty for tests
+// that only read
+func writeReason(test FioTest) string {
+	switch {
+	case test.ResetDevice != "":
+		return fmt.Sprintf("it resets the device with %s", test.ResetDevice)
+	case test.FillLevel > 0:
+		return fmt.Sprintf("it fills the device to %d%%", test.FillLevel)
+	case test.Retention != nil:
+		return "it writes the data the retention test verifies"
+	case isMetadataTest(test):
+		return fmt.Sprintf("the %s workload creates files", test.IOEngine)
+	case test.Backend == "command":
+		return "the command backend may write"
+	case test.ReadIOLog != "":
+		return "the replayed IO log may write"
+	case needsLaydown(test):
+		return fmt.Sprintf("it lays out its %d files with --create_only", test.NrFiles)
+	}
+	if len(test.Jobs) == 0 {
+		if !readOnlyWorkloads[test.RW] {
+			return fmt.Sprintf("rw=%s writes", test.RW)
+		}
+		return ""
+	}
+	for _, section := range test.Jobs {
+		rw := section.RW
+		if rw == "" {
+			rw = test.RW
+		}
+		if !readOnlyWorkloads[rw] {
+			return fmt.Sprintf("job section %s writes with rw=%s", section.Name, rw)
+		}
+	}
+	return ""
+}
+
+// skipWritingTests leaves out the tests that would write to their target,
+// so a suite can be pointed at devices holding data
+func skipWritingTests(tests []FioTest) []FioTest {
+	var kept []FioTest
+	for _, test := range tests {
+		reason := writeReason(test)
+		if reason == "" {
+			kept = append(kept, test)
+			continue
+		}
+		skippedTests = append(skippedTests, SkippedTest{TestName: test.Name, Reason: reason})
+		fmt.Fprintf(out, "Skipping test %s: %s (--read-only)\n", test.Name, reason)
+	}
+	return kept
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// regionZones are the named regions of a target, as percentages of its
+// capacity. On hard disks the lowest LBAs are on the outer tracks, which
+// pass under the head fastest.
+var regionZones = map[string][2]float64{
+	"outer":  {0, 10},
+	"middle": {45, 55},
+	"inner":  {90, 100},
+}
+
+// regionAlignment is the alignment of the start and end of a region, a
+// multiple of every common block size
+const regionAlignment = 1024 * 1024
+
+// RegionConfig selects the part of the target a test runs on, either a
+// named zone, a percentage range of the capacity or a range of logical
+// blocks. The end of the ranges is exclusive.
+type RegionConfig struct {
+	Zone         string  `json:"zone,omitempty"`
+	StartPercent float64 `json:"start_percent,omitempty"`
+	EndPercent   float64 `json:"end_percent,omitempty"`
+	StartLBA     int64   `json:"start_lba,omitempty"`
+	EndLBA       int64   `json:"end_lba,omitempty"`
+}
+
+// RegionInfo is the region of the target a test ran on
+type RegionInfo struct {
+	Zone         string  `json:"zone,omitempty"`
+	StartPercent float64 `json:"start_percent"`
+	EndPercent   float64 `json:"end_percent"`
+	Offset       int64   `json:"offset_bytes"`
+	Size         int64   `json:"size_bytes"`
+	StartLBA     int64   `json:"start_lba"`
+	EndLBA       int64   `json:"end_lba"`
+	BlockSize    int64   `json:"logical_block_size"`
+	Capacity     int64   `json:"capacity_bytes"`
+}
+
+// checkRegions validates the regions of the tests before anything runs
+func checkRegions(tests []FioTest) error {
+	for _, test := range tests {
+		region := test.Region
+		if region == nil {
+			continue
+		}
+		if test.Offset != "" {
+			return fmt.Errorf("test %s: region and offset cannot be combined", test.Name)
+		}
+
+		kinds := 0
+		if region.Zone != "" {
+			kinds++
+			if _, ok := regionZones[region.Zone]; !ok {
+				return fmt.Errorf("test %s: unknown region zone %q, available: outer, middle, inner", test.Name, region.Zone)
+			}
+		}
+		if region.StartPercent != 0 || region.EndPercent != 0 {
+			kinds++
+			if region.StartPercent < 0 || region.EndPercent > 100 || region.StartPercent >= region.EndPercent {
+				return fmt.Errorf("test %s: region %.1f%% to %.1f%% is not a range within 0%% to 100%%", test.Name, region.StartPercent, region.EndPercent)
+			}
+		}
+		if region.StartLBA != 0 || region.EndLBA != 0 {
+			kinds++
+			if region.StartLBA < 0 || region.StartLBA >= region.EndLBA {
+				return fmt.Errorf("test %s: region LBA %d to %d is not a range", test.Name, region.StartLBA, region.EndLBA)
+			}
+		}
+		if kinds != 1 {
+			return fmt.Errorf("test %s: region needs exactly one of zone, start_percent/end_percent or start_lba/end_lba", test.Name)
+		}
+	}
+	return nil
+}
+
+// resolveRegion converts the region of the test to an offset and size on
+// the target, aligned to regionAlignment
+func resolveRegion(test FioTest) (*RegionInfo, error) {
+	capacity, err := targetCapacity(test)
+	if err != nil {
+		return nil, err
+	}
+	info := &RegionInfo{Zone: test.Region.Zone, Capacity: capacity, BlockSize: logicalBlockSize(test.Filename)}
+
+	var start, end int64
+	switch {
+	case test.Region.Zone != "":
+		zone := regionZones[test.Region.Zone]
+		start = int64(float64(capacity) * zone[0] / 100)
+		end = int64(float64(capacity) * zone[1] / 100)
+	case test.Region.EndPercent > 0:
+		start = int64(float64(capacity) * test.Region.StartPercent / 100)
+		end = int64(float64(capacity) * test.Region.EndPercent / 100)
+	default:
+		start = test.Region.StartLBA * info.BlockSize
+		end = test.Region.EndLBA * info.BlockSize
+		if end > capacity {
+			return nil, fmt.Errorf("LBA %d is beyond the %d blocks of %s", test.Region.EndLBA, capacity/info.BlockSize, test.Filename)
+		}
+	}
+
+	// Round inwards so the region never extends past the requested range
+	start = (start + regionAlignment - 1) / regionAlignment * regionAlignment
+	end = end / regionAlignment * regionAlignment
+	if end <= start {
+		return nil, fmt.Errorf("region of %s is smaller than %d bytes", test.Filename, regionAlignment)
+	}
+
+	info.Offset = start
+	info.Size = end - start
+	info.StartLBA = start / info.BlockSize
+	info.EndLBA = end / info.BlockSize
+	info.StartPercent = 100 * float64(start) / float64(capacity)
+	info.EndPercent = 100 * float64(end) / float64(capacity)
+	return info, nil
+}
+
+// logicalBlockSize returns the logical block size of the disk holding the
+// target, or 512 bytes if it is not known
+func logicalBlockSize(filename string) int64 {
+	if device, err := resolveBlockDevice(filename); err == nil {
+		if value, err := readQueueParam(wholeDisk(device), "logical_block_size"); err == nil {
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+				return size
+			}
+		}
+	}
+	return 512
+}
+
+func (r *RegionInfo) String() string {
+	name := "custom"
+	if r.Zone != "" {
+		name = r.Zone
+	}
+	return fmt.Sprintf("%s, %.1f%% to %.1f%% (LBA %d to %d, %.1f GB)",
+		name, r.StartPercent, r.EndPercent, r.StartLBA, r.EndLBA, float64(r.Size)/1e9)
+}
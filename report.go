@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes are part of the command line contract and documented in the
+// README, do not change their meaning
+const (
+	exitOK          = 0 // all tests passed (or the check succeeded)
+	exitTestsFailed = 1 // at least one test failed
+	exitUsage       = 2 // invalid options or test cases
+	exitEnvironment = 3 // fio or the container image cannot be used
+	exitOutput      = 4 // the results could not be saved
+)
+
+// out receives all human readable output. With --json it is redirected to
+// stderr so that stdout only carries the JSON report.
+var out io.Writer = os.Stdout
+
+// JSONReport is the single document written to stdout with --json
+type JSONReport struct {
+	*JSONResults
+	CheckMode    bool              `json:"check_mode"`
+	PlannedTests []JSONPlannedTest `json:"planned_tests,omitempty"`
+	ResultsFile  string            `json:"results_file,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ExitCode     int               `json:"exit_code"`
+}
+
+// JSONPlannedTest describes a test that would be run, reported in check mode
+type JSONPlannedTest struct {
+	TestName    string   `json:"test_name"`
+	Description string   `json:"description"`
+	Command     []string `json:"command"`
+}
+
+// writeReport prints the report to stdout when --json is enabled
+func writeReport(report JSONReport) {
+	if !opts.JSON {
+		return
+	}
+
+	report.CheckMode = opts.Check
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON report: %v\n", err)
+		return
+	}
+	fmt.Println(string(exportPrecision.apply(jsonData)))
+}
+
+// fatal reports an error that prevents the tests from running and exits
+func fatal(code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	releaseLocks()
+	fmt.Fprintf(out, "Error: %s\n", msg)
+	emitProgress(ProgressEvent{Event: eventSuiteFinished, Error: msg, ExitCode: &code})
+	writeReport(JSONReport{Error: msg, ExitCode: code})
+	os.Exit(code)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportData is the data model of report templates: the results file, as
+// JSONResults with its Go field names, plus when and where the report was
+// made
+type ReportData struct {
+	JSONResults
+	Generated   time.Time
+	Hostname    string
+	ResultsFile string
+}
+
+// reportTemplate is a parsed report template, HTML templates escape the
+// values they insert
+type reportTemplate struct {
+	path    string
+	execute func(w io.Writer, data ReportData) error
+}
+
+// reportFuncs are the functions available to report templates besides the
+// builtins of text/template
+var reportFuncs = map[string]interface{}{
+	// f formats a number with the given decimals: {{f 2 .IOPS}}
+	"f": func(decimals int, v float64) string { return fmt.Sprintf("%.*f", decimals, v) },
+	// n formats a count with its digits grouped per --digit-grouping: {{n .IOPS}}
+	"n": formatCount,
+	// pad pads text to a width for aligned text reports, negative pads left
+	"pad": func(width int, s string) string { return fmt.Sprintf("%*s", -width, s) },
+	// md escapes the pipes of text in Markdown table cells
+	"md": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	// t translates a label into the locale set with --locale
+	"t":     tr,
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// loadReportTemplate parses a template file. Files named .html or .htm,
+// optionally followed by .tmpl, are HTML templates.
+func loadReportTemplate(path string) (*reportTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	switch filepath.Ext(strings.TrimSuffix(name, ".tmpl")) {
+	case ".html", ".htm":
+		tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(reportFuncs)).Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return &reportTemplate{path, func(w io.Writer, data ReportData) error { return tmpl.Execute(w, data) }}, nil
+	default:
+		tmpl, err := template.New(name).Funcs(template.FuncMap(reportFuncs)).Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return &reportTemplate{path, func(w io.Writer, data ReportData) error { return tmpl.Execute(w, data) }}, nil
+	}
+}
+
+// loadReportTemplates parses the comma separated templates of
+// --report-template, so mistakes are reported before the tests run
+func loadReportTemplates(list string) ([]*reportTemplate, error) {
+	var templates []*reportTemplate
+	for _, path := range strings.Split(list, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		tmpl, err := loadReportTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("report template: %v", err)
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+// render executes the template into a file, which is only created if the
+// template succeeds
+func (t *reportTemplate) render(data ReportData, filename string) error {
+	var buf bytes.Buffer
+	if err := t.execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// reportFilename names the report of a template after the results file:
+// signoff.md.tmpl next to test_results-<timestamp>.json becomes
+// test_results-<timestamp>-signoff.md
+func reportFilename(resultsFile, templatePath string) string {
+	return strings.TrimSuffix(resultsFile, ".json") + "-" + strings.TrimSuffix(filepath.Base(templatePath), ".tmpl")
+}
+
+func newReportData(results JSONResults, resultsFile string) ReportData {
+	hostname, _ := os.Hostname()
+	return ReportData{JSONResults: results, Generated: time.Now(), Hostname: hostname, ResultsFile: resultsFile}
+}
+
+// runReport renders a template with an existing results file
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	templatePath := flags.String("template", "", "Go template file of the report")
+	output := flags.String("o", "", "file to write the report to (default: stdout)")
+	locale := flags.String("locale", "en", "language of the labels translated with t: en, de, ro or a JSON file of labels")
+	grouping := flags.String("digit-grouping", "none", "thousands separator of the counts formatted with n: none, locale or a separator like ,")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := setReportLocale(*locale); err != nil {
+		return usageError("%v", err)
+	}
+	if err := setDigitGrouping(*grouping, *locale); err != nil {
+		return usageError("%v", err)
+	}
+	if *templatePath == "" || flags.NArg() != 1 {
+		return usageError("usage: fio-qa report --template report.md.tmpl [-o report.md] results.json")
+	}
+
+	tmpl, err := loadReportTemplate(*templatePath)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	results, _, err := readResultsFile(flags.Arg(0))
+	if err != nil {
+		return usageError("%v", err)
+	}
+	data := newReportData(results, flags.Arg(0))
+	if *output != "" {
+		err = tmpl.render(data, *output)
+	} else {
+		err = tmpl.execute(os.Stdout, data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitOutput
+	}
+	return exitOK
+}
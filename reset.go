@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResetInfo records the device reset done before a test
+type ResetInfo struct {
+	Method  string  `json:"method"`
+	Seconds float64 `json:"seconds"`
+}
+
+// resetMethods erase the whole test target, bringing SSDs back to a
+// fresh-out-of-box state
+var resetMethods = map[string]func(device string) error{
+	"nvme-format":   nvmeFormat,
+	"nvme-sanitize": nvmeSanitize,
+	"blkdiscard": func(device string) error {
+		return runResetCommand("blkdiscard", device)
+	},
+	"secure-erase": hdparmSecureErase,
+}
+
+// checkResetDevice validates the reset_device settings of the tests before
+// anything runs. Resets destroy all data on the target, so they need
+// --allow-destructive.
+func checkResetDevice(tests []FioTest) error {
+	for _, test := range tests {
+		if test.ResetDevice == "" {
+			continue
+		}
+		if _, ok := resetMethods[test.ResetDevice]; !ok {
+			var names []string
+			for name := range resetMethods {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("test %s: unknown reset_device %q, available: %s", test.Name, test.ResetDevice, strings.Join(names, ", "))
+		}
+		if !opts.AllowDestructive {
+			return fmt.Errorf("test %s: reset_device %s erases %s, pass --allow-destructive to allow it", test.Name, test.ResetDevice, test.Filename)
+		}
+		if test.ResetDevice == "nvme-sanitize" && !opts.SanitizeController {
+			if err := checkSanitizeScope(test); err != nil {
+				return fmt.Errorf("test %s: %v", test.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSanitizeScope makes sure an NVMe sanitize only erases the test
+// target. Sanitize works on the whole controller, so the target has to be
+// its only namespace.
+func checkSanitizeScope(test FioTest) error {
+	controller := nvmeControllerName(test.NVMeController)
+	if controller == "" {
+		match := nvmeNamespacePattern.FindStringSubmatch(filepath.Base(test.Filename))
+		if match == nil {
+			return fmt.Errorf("nvme-sanitize erases a whole NVMe controller, %s is no NVMe namespace", test.Filename)
+		}
+		controller = "nvme" + match[1]
+	}
+	namespaces, err := nvmeNamespaces(controller)
+	if err != nil {
+		return fmt.Errorf("nvme-sanitize erases every namespace of %s, which cannot be listed (%v), pass --sanitize-controller to erase them all", controller, err)
+	}
+	if len(namespaces) > 1 {
+		return fmt.Errorf("nvme-sanitize erases all %d namespaces of %s, not only the test target, pass --sanitize-controller to erase them all", len(namespaces), controller)
+	}
+	return nil
+}
+
+// resetDevice erases the test target with the method of the test
+func resetDevice(test FioTest) (*ResetInfo, error) {
+	info, err := os.Stat(test.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeDevice == 0 {
+		return nil, fmt.Errorf("%s is not a block device", test.Filename)
+	}
+
+	fmt.Fprintf(out, "Resetting %s with %s\n", test.Filename, test.ResetDevice)
+	start := time.Now()
+	if err := resetMethods[test.ResetDevice](test.Filename); err != nil {
+		return nil, err
+	}
+	return &ResetInfo{Method: test.ResetDevice, Seconds: time.Since(start).Seconds()}, nil
+}
+
+func runResetCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", name, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// nvmeFormat formats the namespace with a user data erase
+func nvmeFormat(device string) error {
+	return runResetCommand("nvme", "format", device, "--ses=1", "--force")
+}
+
+// nvmeSanitize starts a block erase sanitize and waits for it to complete,
+// the drive keeps sanitizing in the background after the command returns
+func nvmeSanitize(device string) error {
+	if err := runResetCommand("nvme", "sanitize", device, "--sanact=2"); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(opts.ResetTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(5 * time.Second)
+		output, err := exec.Command("nvme", "sanitize-log", device, "--output-format=json").Output()
+		if err != nil {
+			return fmt.Errorf("nvme sanitize-log failed: %v", err)
+		}
+		status, err := sanitizeStatus(output)
+		if err != nil {
+			return err
+		}
+		// The low bits of SSTAT: 1 completed, 2 in progress, 3 failed
+		switch status & 0x7 {
+		case 1:
+			return nil
+		case 3:
+			return fmt.Errorf("sanitize of %s failed", device)
+		}
+	}
+	return fmt.Errorf("sanitize of %s did not complete within %s", device, opts.ResetTimeout)
+}
+
+// sanitizeLog is the part of the JSON sanitize log fio-qa reads
+type sanitizeLog struct {
+	SStat *int `json:"sstat"`
+}
+
+// sanitizeStatus extracts SSTAT from the JSON sanitize log. Recent nvme-cli
+// versions key the log by controller name, older ones write it flat.
+func sanitizeStatus(output []byte) (int, error) {
+	var flat sanitizeLog
+	if err := json.Unmarshal(output, &flat); err != nil {
+		return 0, fmt.Errorf("cannot parse sanitize log: %v", err)
+	}
+	if flat.SStat != nil {
+		return *flat.SStat, nil
+	}
+	var logs map[string]sanitizeLog
+	if err := json.Unmarshal(output, &logs); err != nil {
+		return 0, fmt.Errorf("cannot parse sanitize log: %v", err)
+	}
+	for _, log := range logs {
+		if log.SStat != nil {
+			return *log.SStat, nil
+		}
+	}
+	return 0, fmt.Errorf("no sstat in sanitize log")
+}
+
+// hdparmSecureErase runs an ATA secure erase, which needs a temporary user
+// password set right before. A successful erase clears the password, when
+// the erase fails security is disabled again so the drive is not left
+// locked with it.
+func hdparmSecureErase(device string) error {
+	const password = "fio-qa"
+	if err := runResetCommand("hdparm", "--user-master", "u", "--security-set-pass", password, device); err != nil {
+		return err
+	}
+	err := runResetCommand("hdparm", "--user-master", "u", "--security-erase", password, device)
+	if err == nil {
+		return nil
+	}
+	if disableErr := runResetCommand("hdparm", "--user-master", "u", "--security-disable", password, device); disableErr != nil {
+		return fmt.Errorf("%v, and the drive is still locked with the password %q: %v", err, password, disableErr)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestSanitizeStatus reads SSTAT from the sanitize logs of recent nvme-cli
+// versions, keyed by controller, and of older ones, flat
+func TestSanitizeStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		log  string
+		want int
+	}{
+		{"keyed", `{"nvme0": {"sprog": 65535, "sstat": 257, "cdw10_info": 2}}`, 257},
+		{"flat", `{"sprog": 65535, "sstat": 258, "cdw10_info": 2}`, 258},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := sanitizeStatus([]byte(tt.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.want {
+				t.Errorf("sstat = %d, want %d", status, tt.want)
+			}
+		})
+	}
+	if _, err := sanitizeStatus([]byte(`{"nvme0": {"sprog": 65535}}`)); err == nil {
+		t.Error("a log without sstat was accepted")
+	}
+}
+
+// TestSecureEraseFailure fails the erase of a fake hdparm and expects the
+// password to be removed again
+func TestSecureEraseFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake hdparm is a shell script")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\ncase \"$*\" in *--security-erase*) echo 'SECURITY_ERASE: Input/output error' >&2; exit 5 ;; esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "hdparm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := hdparmSecureErase("/dev/sdz"); err == nil || !strings.Contains(err.Error(), "Input/output error") {
+		t.Fatalf("error = %v, want the failed erase", err)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--user-master u --security-set-pass fio-qa /dev/sdz\n" +
+		"--user-master u --security-erase fio-qa /dev/sdz\n" +
+		"--user-master u --security-disable fio-qa /dev/sdz\n"
+	if string(data) != want {
+		t.Errorf("hdparm calls:\n%s\nwant:\n%s", data, want)
+	}
+}
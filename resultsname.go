@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// resultsNameToken matches the {token} and {label:key} placeholders of a
+// --results-name pattern
+var resultsNameToken = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+
+// resultsNameTokens expand the tokens of a --results-name pattern for a run
+var resultsNameTokens = map[string]func(run RunInfo, arg string) string{
+	"hostname": func(RunInfo, string) string {
+		hostname, _ := os.Hostname()
+		return hostname
+	},
+	"runid":     func(run RunInfo, _ string) string { return run.ID },
+	"timestamp": func(run RunInfo, _ string) string { return run.Timestamp },
+	"date":      func(run RunInfo, _ string) string { return runTime(run).Format("2006-01-02") },
+	"time":      func(run RunInfo, _ string) string { return runTime(run).Format("150405") },
+	"namespace": func(run RunInfo, _ string) string { return run.Namespace },
+	"suite":     func(run RunInfo, _ string) string { return run.Suite },
+	"user": func(RunInfo, string) string {
+		if current, err := user.Current(); err == nil {
+			return current.Username
+		}
+		return os.Getenv("USER")
+	},
+	"label": func(run RunInfo, key string) string { return run.Labels[key] },
+}
+
+// runTime returns the start of a run from its timestamp
+func runTime(run RunInfo) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02-150405", run.Timestamp, time.Local)
+	return t
+}
+
+// checkResultsName validates a --results-name pattern before the run
+func checkResultsName(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	for _, match := range resultsNameToken.FindAllStringSubmatch(pattern, -1) {
+		if resultsNameTokens[match[1]] == nil {
+			return fmt.Errorf("--results-name: unknown token %s, available: {hostname}, {runid}, {timestamp}, {date}, {time}, {namespace}, {suite}, {user}, {label:key}", match[0])
+		}
+		if (match[1] == "label") != (match[2] != "") {
+			return fmt.Errorf("--results-name: %s, only label takes a key, like {label:firmware}", match[0])
+		}
+	}
+	if base := strings.TrimSuffix(pattern, ".json"); base == "" || strings.HasSuffix(base, "/") {
+		return fmt.Errorf("--results-name: %q names no file", pattern)
+	}
+	return nil
+}
+
+// resultsName returns the name of the results files of a run without the
+// extension: the --results-name pattern with its tokens expanded, or
+// test_results-<timestamp> prefixed with the namespace. Token values are
+// made safe for file names, directories of the pattern are kept.
+func resultsName(run RunInfo) string {
+	if opts.ResultsName == "" {
+		prefix := "test_results"
+		if run.Namespace != "" {
+			prefix += "-" + run.Namespace
+		}
+		return prefix + "-" + run.Timestamp
+	}
+	name := resultsNameToken.ReplaceAllStringFunc(opts.ResultsName, func(token string) string {
+		match := resultsNameToken.FindStringSubmatch(token)
+		return sanitizeName(resultsNameTokens[match[1]](run, match[2]))
+	})
+	return strings.TrimSuffix(name, ".json")
+}
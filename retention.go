@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultRetentionVerify is the fio verify method of retention checks
+// without one
+const defaultRetentionVerify = "crc32c"
+
+// Phases of a retention check, the stage of a phase is only run by
+// runRetention
+const (
+	retentionWrite    = "write"
+	retentionPressure = "pressure"
+	retentionVerify   = "verify"
+)
+
+// RetentionConfig turns a test into a data retention or read disturb spot
+// check: its target is written once with a verifiable pattern, left alone
+// or read continuously for the wait, and then verified
+type RetentionConfig struct {
+	Wait string `json:"wait"`
+	// ReadPressure reads the target with the workload of the test during
+	// the wait instead of leaving it idle, for read disturb
+	ReadPressure bool `json:"read_pressure,omitempty"`
+	// Verify is the fio verify method of the pattern, crc32c by default
+	Verify string `json:"verify,omitempty"`
+}
+
+// RetentionResult is the outcome of a retention check
+type RetentionResult struct {
+	Verify            string  `json:"verify"`
+	WrittenBytes      int64   `json:"written_bytes"`
+	WaitSeconds       float64 `json:"wait_seconds"`
+	ReadPressure      bool    `json:"read_pressure"`
+	PressureReadBytes int64   `json:"pressure_read_bytes,omitempty"`
+	VerifiedBytes     int64   `json:"verified_bytes"`
+	VerifyErrors      int64   `json:"verify_errors"`
+}
+
+// verifyMethod returns the verify method of the check
+func (c *RetentionConfig) verifyMethod() string {
+	if c.Verify == "" {
+		return defaultRetentionVerify
+	}
+	return c.Verify
+}
+
+// retentionArgs returns the fio options of the write and verify phases. The
+// verify phase reads back what the write phase wrote and counts mismatches
+// instead of stopping at the first one.
+func retentionArgs(test FioTest) []string {
+	switch test.RetentionPhase {
+	case retentionWrite:
+		return []string{"--verify=" + test.Retention.verifyMethod(), "--do_verify=0"}
+	case retentionVerify:
+		return []string{"--verify=" + test.Retention.verifyMethod(), "--verify_only", "--continue_on_error=verify"}
+	}
+	return nil
+}
+
+// runRetention runs the phases of a retention check. The pattern is written
+// and verified by a single job sequentially over the size of the test, so
+// every block is checked exactly once.
+func runRetention(ctx context.Context, test FioTest, run RunInfo) TestResult {
+	result := TestResult{
+		TestName:    test.Name,
+		Description: test.Description,
+		Status:      "FAILED",
+		Config:      test,
+	}
+	wait, err := time.ParseDuration(test.Retention.Wait)
+	if err != nil || wait < 0 {
+		result.Error = fmt.Errorf("invalid retention wait %q", test.Retention.Wait)
+		return result
+	}
+
+	start := time.Now()
+	summary := &RetentionResult{Verify: test.Retention.verifyMethod(), ReadPressure: test.Retention.ReadPressure}
+	var artifacts []string
+	var warnings []Warning
+	seen := map[Warning]bool{}
+	phase := func(name string, stage FioTest) TestResult {
+		stage.Name = fmt.Sprintf("%s_%s", test.Name, name)
+		stage.RetentionPhase = name
+		if name != retentionWrite {
+			// Only the write starts from a reset or filled device
+			stage.ResetDevice = ""
+			stage.FillLevel = 0
+		}
+		fmt.Fprintf(out, "Retention check %s: %s\n", test.Name, name)
+		r := runTest(ctx, stage, run)
+		artifacts = append(artifacts, r.Artifacts...)
+		for _, warning := range r.Warnings {
+			if !seen[warning] {
+				seen[warning] = true
+				warnings = append(warnings, warning)
+			}
+		}
+		return r
+	}
+	sequential := test
+	sequential.RW = "write"
+	sequential.NumJobs = 1
+	sequential.TimeBased = false
+	sequential.Runtime = 0
+
+	last := phase(retentionWrite, sequential)
+	if last.FioJob != nil {
+		summary.WrittenBytes = int64(last.FioJob.Write.IOKBytes * 1024)
+	}
+	if last.Status == "PASSED" {
+		waitStart := time.Now()
+		if test.Retention.ReadPressure {
+			pressure := test
+			pressure.RW = "randread"
+			pressure.TimeBased = true
+			pressure.Runtime = int(wait.Seconds())
+			last = phase(retentionPressure, pressure)
+			if last.FioJob != nil {
+				summary.PressureReadBytes = int64(last.FioJob.Read.IOKBytes * 1024)
+			}
+		} else {
+			fmt.Fprintf(out, "Retention check %s: waiting %s\n", test.Name, wait)
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+		summary.WaitSeconds = time.Since(waitStart).Seconds()
+	}
+	if last.Status == "PASSED" && ctx.Err() == nil {
+		verify := sequential
+		verify.RW = "read"
+		last = phase(retentionVerify, verify)
+		if last.FioJob != nil {
+			summary.VerifiedBytes = int64(last.FioJob.Read.IOKBytes * 1024)
+			summary.VerifyErrors = last.FioJob.TotalErr
+		}
+	}
+
+	// The result of the last phase stands for the whole check
+	result = last
+	result.TestName = test.Name
+	result.Description = test.Description
+	result.Config = test
+	result.Duration = time.Since(start)
+	result.Artifacts = artifacts
+	result.Warnings = warnings
+	result.Retention = summary
+	switch {
+	case ctx.Err() != nil && !result.Cancelled:
+		result.cancel()
+	case result.Status == "PASSED" && summary.VerifyErrors > 0:
+		result.Status = "FAILED"
+		result.Error = fmt.Errorf("%d verify errors after %s", summary.VerifyErrors, wait)
+	}
+	return result
+}
+
+func (r *RetentionResult) String() string {
+	s := fmt.Sprintf("%d verify errors in %.1f GiB after %s", r.VerifyErrors,
+		float64(r.VerifiedBytes)/(1<<30), (time.Duration(r.WaitSeconds) * time.Second).String())
+	if r.ReadPressure {
+		s += fmt.Sprintf(" of read pressure (%.1f GiB read)", float64(r.PressureReadBytes)/(1<<30))
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// runID identifies the current run in everything sent to other systems:
+// progress events, streamed results, the history store and the results
+var runID string
+
+// namespace is the project or team the run belongs to, set with --namespace.
+// Runs of different namespaces share the history store and the artifacts
+// directory without mixing their results.
+var namespace string
+
+// runIDPattern restricts run IDs given with --run-id to characters that are
+// safe in file names on every platform
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// startRunID sets the run ID from --run-id, or generates a new one
+func startRunID(now time.Time) error {
+	if opts.RunID == "" {
+		runID = newRunID(now)
+		return nil
+	}
+	if !runIDPattern.MatchString(opts.RunID) {
+		return fmt.Errorf("invalid --run-id %q, use up to 128 letters, digits, '.', '_' and '-'", opts.RunID)
+	}
+	runID = opts.RunID
+	return nil
+}
+
+// startNamespace sets the namespace from --namespace, it is used in file
+// names like run IDs
+func startNamespace() error {
+	if opts.Namespace != "" && !runIDPattern.MatchString(opts.Namespace) {
+		return fmt.Errorf("invalid --namespace %q, use up to 128 letters, digits, '.', '_' and '-'", opts.Namespace)
+	}
+	namespace = opts.Namespace
+	return nil
+}
+
+// crockfordBase32 is the alphabet of ULIDs
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newRunID returns a ULID: 48 bits of milliseconds followed by 80 random
+// bits, so run IDs are unique across instances and sort by start time
+func newRunID(now time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:], uint16(uint64(now.UnixMilli())>>32))
+	binary.BigEndian.PutUint32(id[2:], uint32(now.UnixMilli()))
+	rand.Read(id[6:])
+
+	// 128 bits as 26 base32 characters, the first one holding 3 bits
+	var text [26]byte
+	hi := binary.BigEndian.Uint64(id[0:])
+	lo := binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		text[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(text[:])
+}
+
+// createRunTempDir creates the directory holding the temporary files of a
+// run, named after the run so that concurrent instances never share files
+func createRunTempDir(run *RunInfo) error {
+	dir, err := os.MkdirTemp("", fmt.Sprintf("fio-qa-%s-", run.ID))
+	if err != nil {
+		return fmt.Errorf("cannot create temporary directory: %v", err)
+	}
+	run.TempDir = dir
+	return nil
+}
+
+// saveResults saves the results of a run with the given writer to
+// test_results-<timestamp><ext>, or the name of --results-name. The file of
+// another run of the same name is never replaced, the run ID is appended to
+// the name instead.
+func saveResults(run RunInfo, ext string, write func(filename string) error) (string, error) {
+	name := resultsName(run)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", err
+	}
+	filename := name + ext
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		filename = fmt.Sprintf("%s-%s%s", name, run.ID, ext)
+		file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	if err := write(filename); err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// StopGrace is how long an interrupted fio gets to finish its jobs and
+// write its output before it is killed
+const StopGrace = 10 * time.Second
+
+// RunCommand runs a command until it exits or the context is cancelled.
+// The command is interrupted first, fio then ends its jobs early and still
+// writes the results of the part that ran, and killed when it does not exit
+// within StopGrace.
+func RunCommand(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		// Windows cannot interrupt other processes
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			cmd.Process.Kill()
+			return
+		}
+		select {
+		case <-done:
+		case <-time.After(StopGrace):
+			cmd.Process.Kill()
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	return err
+}
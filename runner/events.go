@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a progress event of a run, passed to the observers as it
+// happens. The fio-qa command writes them as the NDJSON lines of
+// --progress-json.
+type Event struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Run         string    `json:"run,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Index       int       `json:"index,omitempty"`
+	Total       int       `json:"total,omitempty"`
+	TestName    string    `json:"test_name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Duration    float64   `json:"duration_seconds,omitempty"`
+	// RemainingSeconds is the estimated time left in the run, including the
+	// test that starts
+	RemainingSeconds float64 `json:"eta_seconds,omitempty"`
+	IOPS             float64 `json:"iops,omitempty"`
+	BWMBps           float64 `json:"bw_mbps,omitempty"`
+	LatencyUs        float64 `json:"avg_latency_us,omitempty"`
+	Passed           *int    `json:"passed,omitempty"`
+	Failed           *int    `json:"failed,omitempty"`
+	Warnings         *int    `json:"warnings,omitempty"`
+	ResultsFile      string  `json:"results_file,omitempty"`
+	Error            string  `json:"error,omitempty"`
+	ExitCode         *int    `json:"exit_code,omitempty"`
+	// Percent and TestRemainingSeconds are how far fio got with the running
+	// test
+	Percent              float64 `json:"percent,omitempty"`
+	TestRemainingSeconds float64 `json:"test_eta_seconds,omitempty"`
+	// Result is the whole result of a finished test for observers, a
+	// *Result of Run or the test result of the fio-qa command. It is not
+	// part of the NDJSON stream.
+	Result any `json:"-"`
+}
+
+// Event names
+const (
+	EventSuiteStarted  = "suite_started"
+	EventTestStarted   = "test_started"
+	EventTestProgress  = "test_progress"
+	EventTestFinished  = "test_finished"
+	EventSuiteFinished = "suite_finished"
+)
+
+// Observer follows a run as it happens, so applications driving fio-qa can
+// show its live state without parsing the output
+type Observer interface {
+	OnSuiteStart(event Event)
+	OnTestStart(event Event)
+	// OnProgress receives the live metrics of the running test about once
+	// a second, as fio reports them
+	OnProgress(event Event)
+	OnTestComplete(event Event)
+	OnSuiteComplete(event Event)
+}
+
+var (
+	observersMu sync.Mutex
+	observers   []Observer
+)
+
+// Register adds an observer receiving the events of every run of the
+// process, those of Run and of the fio-qa command alike
+func Register(observer Observer) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+	observers = append(observers, observer)
+}
+
+// Observed tells whether any observer is registered, fio only reports its
+// progress when asked to
+func Observed() bool {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+	return len(observers) > 0
+}
+
+// Emit passes an event to the observers, stamped with the current time
+// unless it has one
+func Emit(event Event) {
+	observersMu.Lock()
+	registered := append([]Observer(nil), observers...)
+	observersMu.Unlock()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, observer := range registered {
+		switch event.Event {
+		case EventSuiteStarted:
+			observer.OnSuiteStart(event)
+		case EventTestStarted:
+			observer.OnTestStart(event)
+		case EventTestProgress:
+			observer.OnProgress(event)
+		case EventTestFinished:
+			observer.OnTestComplete(event)
+		case EventSuiteFinished:
+			observer.OnSuiteComplete(event)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package runner
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fio 3.0 changed the latencies of the JSON output from microseconds in
+// "slat", "clat" and "lat" to nanoseconds in "slat_ns", "clat_ns" and
+// "lat_ns", including their percentiles, and added bandwidth in bytes per
+// second as "bw_bytes" next to "bw" in KiB/s. fio 2.x also spells the
+// standard deviation "stdev", reports "io_bytes" in KiB and early versions
+// key percentiles with two decimals ("99.00") instead of six. The output of
+// every version is normalized to the units of fio 3.x while decoding, so
+// users of the output only deal with nanoseconds and bytes.
+//
+// The shares of IOs per latency bucket are reported in "latency_us" and
+// "latency_ms" by fio 2.x, fio 3.x adds "latency_ns" for the buckets below
+// a microsecond. All of them are folded into the latency bins of the job
+// keyed in nanoseconds, and outputs without completion latency percentiles,
+// like those of clat_percentiles=0, get them estimated from the buckets.
+
+// fioLegacyLat is a latency of the fio 2.x JSON output, in microseconds
+type fioLegacyLat struct {
+	Min        float64            `json:"min"`
+	Max        float64            `json:"max"`
+	Mean       float64            `json:"mean"`
+	Stdev      float64            `json:"stdev"`
+	Stddev     float64            `json:"stddev"`
+	Percentile map[string]float64 `json:"percentile"`
+}
+
+// nanoseconds converts the latency to the fio 3.x representation
+func (l *fioLegacyLat) nanoseconds() LatNs {
+	lat := LatNs{
+		Min:    l.Min * 1000,
+		Max:    l.Max * 1000,
+		Mean:   l.Mean * 1000,
+		Stddev: l.Stddev * 1000,
+	}
+	if l.Stddev == 0 {
+		lat.Stddev = l.Stdev * 1000
+	}
+	if len(l.Percentile) > 0 {
+		lat.Percentile = make(map[string]float64, len(l.Percentile))
+		for key, value := range l.Percentile {
+			lat.Percentile[percentileKey(key)] = value * 1000
+		}
+	}
+	return lat
+}
+
+// UnmarshalJSON decodes the read or write statistics of any fio version
+func (io *IO) UnmarshalJSON(data []byte) error {
+	type plain IO
+	var decoded struct {
+		plain
+		Slat    *fioLegacyLat `json:"slat"`
+		Clat    *fioLegacyLat `json:"clat"`
+		Lat     *fioLegacyLat `json:"lat"`
+		BW      float64       `json:"bw"`
+		IOBytes float64       `json:"io_bytes"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*io = IO(decoded.plain)
+
+	// The microsecond fields only exist in outputs without the nanosecond
+	// ones, so they never overwrite fio 3.x values
+	if decoded.Slat != nil {
+		io.Slat = decoded.Slat.nanoseconds()
+	}
+	if decoded.Clat != nil {
+		io.Clat = Clat(decoded.Clat.nanoseconds())
+	}
+	if decoded.Lat != nil {
+		io.LatNs = decoded.Lat.nanoseconds()
+	}
+	if io.BWBytes == 0 && decoded.BW > 0 {
+		io.BWBytes = decoded.BW * 1024
+	}
+	if io.IOKBytes == 0 && decoded.IOBytes > 0 {
+		// Before io_kbytes existed io_bytes was in KiB
+		io.IOKBytes = decoded.IOBytes
+	}
+	return nil
+}
+
+// percentileKey formats a percentile key like fio 3.x, "99.000000"
+func percentileKey(key string) string {
+	percent, err := strconv.ParseFloat(key, 64)
+	if err != nil {
+		return key
+	}
+	return strconv.FormatFloat(percent, 'f', 6, 64)
+}
+
+// fioPercentiles are the completion latency percentiles fio reports by
+// default, estimated from the latency buckets when fio reported none
+var fioPercentiles = []float64{1, 5, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 99, 99.5, 99.9, 99.95, 99.99}
+
+// UnmarshalJSON decodes a job of any fio version, folding the latency
+// buckets into nanosecond bins
+func (job *JobResult) UnmarshalJSON(data []byte) error {
+	type plain JobResult
+	var decoded struct {
+		plain
+		LatUs map[string]float64 `json:"latency_us"`
+		LatMs map[string]float64 `json:"latency_ms"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*job = JobResult(decoded.plain)
+
+	bins := map[string]float64{}
+	for _, buckets := range []struct {
+		shares map[string]float64
+		scale  int64
+	}{
+		{job.LatBins, 1},
+		{decoded.LatUs, 1000},
+		{decoded.LatMs, 1000 * 1000},
+	} {
+		for key, share := range buckets.shares {
+			// Empty buckets are left out, they differ between versions
+			if share <= 0 {
+				continue
+			}
+			prefix := ""
+			if strings.HasPrefix(key, ">=") {
+				prefix, key = ">=", key[2:]
+			}
+			bound, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				continue
+			}
+			bins[prefix+strconv.FormatInt(bound*buckets.scale, 10)] += share
+		}
+	}
+	job.LatBins = nil
+	if len(bins) > 0 {
+		job.LatBins = bins
+	}
+
+	for _, io := range []*IO{&job.Read, &job.Write} {
+		if len(io.Clat.Percentile) == 0 && io.IOKBytes > 0 {
+			io.Clat.Percentile = bucketPercentiles(job.LatBins)
+		}
+	}
+	return nil
+}
+
+// bucketPercentiles estimates the latency percentiles in nanoseconds from
+// latency bins, every percentile is the upper bound of the bucket it falls
+// into. Returns nil without bins.
+func bucketPercentiles(bins map[string]float64) map[string]float64 {
+	type bucket struct {
+		bound float64
+		share float64
+	}
+	var buckets []bucket
+	var total float64
+	for key, share := range bins {
+		bound, err := strconv.ParseFloat(strings.TrimPrefix(key, ">="), 64)
+		if err != nil {
+			continue
+		}
+		// An open bucket lies above the bucket with the same bound
+		if strings.HasPrefix(key, ">=") {
+			bound = math.Nextafter(bound, math.Inf(1))
+		}
+		buckets = append(buckets, bucket{bound, share})
+		total += share
+	}
+	if total <= 0 {
+		return nil
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].bound < buckets[j].bound })
+
+	percentiles := make(map[string]float64, len(fioPercentiles))
+	for _, p := range fioPercentiles {
+		var cumulative float64
+		for _, b := range buckets {
+			cumulative += b.share
+			if 100*cumulative/total >= p {
+				percentiles[strconv.FormatFloat(p, 'f', 6, 64)] = math.Floor(b.bound)
+				break
+			}
+		}
+	}
+	return percentiles
+}
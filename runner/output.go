@@ -0,0 +1,244 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output is the JSON output of fio
+type Output struct {
+	FioVersion    string            `json:"fio version"`
+	GlobalOptions map[string]string `json:"global options"`
+	Jobs          []JobResult       `json:"jobs"`
+	DiskUtil      []DiskUtil        `json:"disk_util"`
+	// Stray holds the lines fio wrote around the JSON document
+	Stray []string `json:"-"`
+}
+
+// JobResult is the result of a single fio job
+type JobResult struct {
+	JobName    string             `json:"jobname"`
+	Read       IO                 `json:"read"`
+	Write      IO                 `json:"write"`
+	Sync       Sync               `json:"sync"`
+	UsrCPU     float64            `json:"usr_cpu"`
+	SysCPU     float64            `json:"sys_cpu"`
+	Ctx        int64              `json:"ctx"`
+	MajF       int64              `json:"majf"`
+	MinF       int64              `json:"minf"`
+	IODepths   map[string]float64 `json:"iodepth_level"`
+	LatBins    map[string]float64 `json:"latency_ns"`
+	JobOptions map[string]string  `json:"job options"`
+	TotalErr   int64              `json:"total_err"`
+}
+
+// IO holds the read or write statistics of a job
+type IO struct {
+	IOPS       float64 `json:"iops"`
+	BWBytes    float64 `json:"bw_bytes"` // Bandwidth in bytes/sec
+	BWMean     float64 `json:"bw_mean"`  // Bandwidth mean in KiB/s
+	BWMin      float64 `json:"bw_min"`   // Bandwidth min in KiB/s
+	BWMax      float64 `json:"bw_max"`   // Bandwidth max in KiB/s
+	BWDev      float64 `json:"bw_dev"`   // Bandwidth deviation in KiB/s
+	IOKBytes   float64 `json:"io_kbytes"`
+	Runtime    float64 `json:"runtime"`
+	Slat       LatNs   `json:"slat_ns"`
+	Clat       Clat    `json:"clat_ns"`
+	LatNs      LatNs   `json:"lat_ns"`
+	IOPSMin    float64 `json:"iops_min"`
+	IOPSMax    float64 `json:"iops_max"`
+	IOPSMean   float64 `json:"iops_mean"`
+	IOPSStddev float64 `json:"iops_stddev"`
+}
+
+// LatNs is a latency in nanoseconds
+type LatNs struct {
+	Min        float64            `json:"min"`
+	Max        float64            `json:"max"`
+	Mean       float64            `json:"mean"`
+	Stddev     float64            `json:"stddev"`
+	Percentile map[string]float64 `json:"percentile"`
+	// fio only writes bins for completion latencies, the field keeps the
+	// type convertible to Clat
+	Bins map[string]int64 `json:"bins,omitempty"`
+}
+
+// Clat is the completion latency in nanoseconds
+type Clat struct {
+	Min        float64            `json:"min"`
+	Max        float64            `json:"max"`
+	Mean       float64            `json:"mean"`
+	Stddev     float64            `json:"stddev"`
+	Percentile map[string]float64 `json:"percentile"`
+	// Bins holds the full latency histogram of json+ outputs, keyed by
+	// the latency of the bin in ns
+	Bins map[string]int64 `json:"bins,omitempty"`
+}
+
+// Sync holds the sync statistics of a job
+type Sync struct {
+	LatNs LatNs `json:"lat_ns"`
+}
+
+// DiskUtil holds the utilization statistics of a disk
+type DiskUtil struct {
+	Name         string  `json:"name"`
+	ReadIOs      int64   `json:"read_ios"`
+	WriteIOs     int64   `json:"write_ios"`
+	ReadSectors  int64   `json:"read_sectors"`
+	WriteSectors int64   `json:"write_sectors"`
+	ReadMerges   int64   `json:"read_merges"`
+	WriteMerges  int64   `json:"write_merges"`
+	ReadTicks    int64   `json:"read_ticks"`
+	WriteTicks   int64   `json:"write_ticks"`
+	InQueue      int64   `json:"in_queue"`
+	Util         float64 `json:"util"`
+}
+
+// ParseOutput decodes the fio JSON output as a stream, one job at a time,
+// so that json+ outputs of many jobs with full latency bins never have to be
+// held in memory at once. Top level branches that are not used, like the
+// client stats of distributed runs, are skipped token by token. fio may
+// write warnings before the document, between documents or lines after
+// them, they are kept as stray lines. With status-interval fio writes a
+// document per interval, the last one holds the totals.
+func ParseOutput(r io.Reader) (*Output, error) {
+	var output *Output
+	var stray []string
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		first, err := skipToJSON(reader, &stray)
+		if err != nil {
+			return nil, err
+		}
+		if first == "" {
+			break
+		}
+		decoder := json.NewDecoder(io.MultiReader(strings.NewReader(first), reader))
+		if output, err = decodeDocument(decoder); err != nil {
+			return nil, err
+		}
+		// Whatever follows the document
+		reader = bufio.NewReader(io.MultiReader(decoder.Buffered(), reader))
+	}
+
+	switch {
+	case output != nil:
+		output.Stray = stray
+		return output, nil
+	case len(stray) > 0:
+		return nil, fmt.Errorf("no JSON document in fio output: %s", strings.Join(stray, "; "))
+	}
+	return nil, fmt.Errorf("fio output is empty")
+}
+
+// decodeDocument decodes one JSON document of the fio output
+func decodeDocument(decoder *json.Decoder) (*Output, error) {
+	var output Output
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case "fio version":
+			err = decoder.Decode(&output.FioVersion)
+		case "global options":
+			err = decoder.Decode(&output.GlobalOptions)
+		case "disk_util":
+			err = decoder.Decode(&output.DiskUtil)
+		case "jobs":
+			if err = expectDelim(decoder, '['); err != nil {
+				break
+			}
+			for decoder.More() && err == nil {
+				var job JobResult
+				if err = decoder.Decode(&job); err == nil {
+					output.Jobs = append(output.Jobs, job)
+				}
+			}
+			if err == nil {
+				err = expectDelim(decoder, ']')
+			}
+		default:
+			err = skipJSONValue(decoder)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// skipToJSON reads up to the line starting the next JSON document and
+// returns it, the lines before it are added to stray. Returns an empty line
+// at the end of the output.
+func skipToJSON(reader *bufio.Reader, stray *[]string) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(strings.TrimSpace(line), "{") {
+			return line, nil
+		}
+		*stray = append(*stray, strayLines(line)...)
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// strayLines returns the non-empty lines of text fio wrote outside of the
+// JSON document
+func strayLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// expectDelim reads the next token, which must be the given delimiter
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v in fio output, got %v", delim, token)
+	}
+	return nil
+}
+
+// skipJSONValue reads past the next value without keeping it
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
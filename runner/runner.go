@@ -0,0 +1,183 @@
+// Package runner runs fio jobs for applications embedding fio-qa. A run is
+// cancelled through its context: fio is interrupted, so it still reports
+// the part that ran, and the results of the jobs run so far are returned.
+// Registered observers follow the run as it happens, the fio-qa command
+// passes its events to them as well.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Job is a fio job of a run
+type Job struct {
+	Name        string
+	Description string
+	// Fio is the fio binary, fio from PATH when empty
+	Fio string
+	// Args are the options of the job like --rw=randread, without the
+	// output options
+	Args []string
+}
+
+// Result is the outcome of a job. IOPS and bandwidth are summed over the
+// reads and writes of all fio jobs, the latency is their mean weighted by
+// the IOPS.
+type Result struct {
+	Name      string
+	Duration  time.Duration
+	IOPS      float64
+	BWMBps    float64
+	LatencyUs float64
+	// Cancelled is set for the job the context was cancelled in, its
+	// metrics are those of the part that ran
+	Cancelled bool
+	// Err is set when fio failed or its output could not be read
+	Err error
+	// Output is what fio printed besides its results, like warnings
+	Output []byte
+	// Jobs are the fio jobs as fio reported them
+	Jobs []JobResult
+	// DeviceModel is the model of the drive tested, set by the fio-qa
+	// command where it is known
+	DeviceModel string
+}
+
+// P99LatencyUs returns the 99th percentile of the completion latency in
+// microseconds, of the slowest direction of the fio jobs
+func (r Result) P99LatencyUs() float64 {
+	var p99 float64
+	for _, job := range r.Jobs {
+		p99 = max(p99, job.Read.Clat.Percentile["99.000000"], job.Write.Clat.Percentile["99.000000"])
+	}
+	return p99 / 1000
+}
+
+// Run runs the jobs one after the other until all ran or the context is
+// cancelled. It returns the results of the jobs that ran, including the
+// cancelled one, and the error of the context when it was cancelled.
+func Run(ctx context.Context, jobs []Job) ([]Result, error) {
+	Emit(Event{Event: EventSuiteStarted, Total: len(jobs)})
+	var results []Result
+	passed, failed := 0, 0
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		Emit(Event{Event: EventTestStarted, Index: i + 1, Total: len(jobs), TestName: job.Name, Description: job.Description})
+		result := runJob(ctx, job)
+		results = append(results, result)
+
+		finished := Event{
+			Event:     EventTestFinished,
+			Index:     i + 1,
+			Total:     len(jobs),
+			TestName:  job.Name,
+			Status:    "PASSED",
+			Duration:  result.Duration.Seconds(),
+			IOPS:      result.IOPS,
+			BWMBps:    result.BWMBps,
+			LatencyUs: result.LatencyUs,
+			Result:    &result,
+		}
+		switch {
+		case result.Err != nil:
+			finished.Status, finished.Error = "FAILED", result.Err.Error()
+		case result.Cancelled:
+			finished.Status, finished.Error = "FAILED", "cancelled"
+		}
+		if finished.Status == "PASSED" {
+			passed++
+		} else {
+			failed++
+		}
+		Emit(finished)
+	}
+	finished := Event{Event: EventSuiteFinished, Total: len(jobs), Passed: &passed, Failed: &failed}
+	if ctx.Err() != nil {
+		finished.Error = ctx.Err().Error()
+	}
+	Emit(finished)
+	return results, ctx.Err()
+}
+
+// runJob runs a single job with its JSON output written to a temporary file
+func runJob(ctx context.Context, job Job) Result {
+	result := Result{Name: job.Name}
+	tmp, err := os.CreateTemp("", "fio-qa-*.json")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	fio := job.Fio
+	if fio == "" {
+		fio = "fio"
+	}
+	args := append(append([]string(nil), job.Args...), "--output-format=json", "--output="+tmp.Name())
+	var output bytes.Buffer
+	var stdout io.Writer = &output
+	// Observers follow the job through the status lines of fio, which it
+	// only prints on a terminal unless told otherwise
+	if Observed() {
+		args = append(args, "--eta=always")
+		stdout = io.MultiWriter(&output, &StatusWatcher{Test: job.Name})
+	}
+	cmd := exec.Command(fio, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err = RunCommand(ctx, cmd)
+	result.Duration = time.Since(start)
+	result.Output = output.Bytes()
+	result.Cancelled = ctx.Err() != nil
+	if err != nil && !result.Cancelled {
+		result.Err = fmt.Errorf("fio failed: %v", err)
+		return result
+	}
+	if err := result.read(tmp.Name()); err != nil && !result.Cancelled {
+		result.Err = err
+	}
+	return result
+}
+
+// read reads the metrics of the result from the JSON output of fio with
+// ParseOutput, like the fio-qa command does. The lines fio wrote around the
+// document are added to the output of the result.
+func (r *Result) read(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	output, err := ParseOutput(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse fio output: %v", err)
+	}
+	r.Jobs = output.Jobs
+	for _, line := range output.Stray {
+		r.Output = append(append(r.Output, line...), '\n')
+	}
+	var latency float64
+	for _, job := range output.Jobs {
+		for _, direction := range []IO{job.Read, job.Write} {
+			r.IOPS += direction.IOPS
+			r.BWMBps += direction.BWBytes / mebibyte
+			latency += direction.LatNs.Mean / 1000 * direction.IOPS
+		}
+	}
+	if r.IOPS > 0 {
+		r.LatencyUs = latency / r.IOPS
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFio is a fio writing a JSON result of 1000 read IOPS to its --output
+// file, with a warning before it. With --runtime=slow it runs until it is
+// interrupted and then reports the 500 IOPS of the part that ran, like fio.
+// With --eta=always it prints a status line, with --status-interval it
+// writes an interval document of 10 IOPS first and a warning after it.
+const fakeFio = `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+	--output=*) output="${arg#--output=}" ;;
+	--runtime=slow) slow=1 ;;
+	--status-interval=*) interval=1 ;;
+	--eta=always) echo "Jobs: 1 (f=1): [r(1)][50.0%][r=4096KiB/s][r=1024 IOPS][eta 00m:05s]" ;;
+	esac
+done
+iops=1000
+if [ -n "$slow" ]; then
+	trap 'iops=500; interrupted=1' INT
+	while [ -z "$interrupted" ]; do sleep 0.05; done
+fi
+if [ -n "$interval" ]; then
+	echo '{ "jobs" : [ { "read" : { "iops" : 10 } } ] }' > "$output"
+	echo "fio: io_u error on file" >> "$output"
+fi
+cat >> "$output" <<EOF
+note: both iodepth >= 1 and synchronous I/O engine are selected
+{
+  "jobs" : [
+    {
+      "read" : { "iops" : $iops, "bw_bytes" : 4194304, "lat_ns" : { "mean" : 80000.0 } },
+      "write" : { "iops" : 0, "bw_bytes" : 0, "lat_ns" : { "mean" : 0.0 } }
+    }
+  ]
+}
+EOF
+`
+
+func writeFakeFio(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake fio is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "fio")
+	if err := os.WriteFile(path, []byte(fakeFio), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	fio := writeFakeFio(t)
+	results, err := Run(context.Background(), []Job{
+		{Name: "a", Fio: fio, Args: []string{"--rw=randread"}},
+		{Name: "b", Fio: fio, Args: []string{"--rw=randread"}},
+		{Name: "interval", Fio: fio, Args: []string{"--rw=randread", "--status-interval=1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Cancelled || r.IOPS != 1000 || r.BWMBps != 4 || r.LatencyUs != 80 {
+			t.Errorf("result %+v, want 1000 IOPS, 4 MB/s and 80 us", r)
+		}
+	}
+	// The totals are in the last document, the warning between them is kept
+	if output := string(results[2].Output); !strings.Contains(output, "fio: io_u error on file") {
+		t.Errorf("output %q, want the warning between the documents", output)
+	}
+}
+
+// TestRunCancel cancels the second of three jobs while it runs and expects
+// the first result, the partial one of the cancelled job and no third
+func TestRunCancel(t *testing.T) {
+	fio := writeFakeFio(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	results, err := Run(ctx, []Job{
+		{Name: "fast", Fio: fio},
+		{Name: "slow", Fio: fio, Args: []string{"--runtime=slow"}},
+		{Name: "skipped", Fio: fio},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the finished and the cancelled job", len(results))
+	}
+	if r := results[0]; r.Cancelled || r.IOPS != 1000 {
+		t.Errorf("finished job %+v, want 1000 IOPS and not cancelled", r)
+	}
+	if r := results[1]; !r.Cancelled || r.Err != nil || r.IOPS != 500 {
+		t.Errorf("cancelled job %+v, want cancelled with the 500 IOPS of the part that ran", r)
+	}
+}
+
+// recorder is an observer keeping the events it receives
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) record(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) OnSuiteStart(event Event)    { r.record(event) }
+func (r *recorder) OnTestStart(event Event)     { r.record(event) }
+func (r *recorder) OnProgress(event Event)      { r.record(event) }
+func (r *recorder) OnTestComplete(event Event)  { r.record(event) }
+func (r *recorder) OnSuiteComplete(event Event) { r.record(event) }
+
+// TestRunObserver follows a run of one job with a registered observer
+func TestRunObserver(t *testing.T) {
+	fio := writeFakeFio(t)
+	observer := &recorder{}
+	Register(observer)
+	defer func() {
+		observersMu.Lock()
+		observers = nil
+		observersMu.Unlock()
+	}()
+
+	if _, err := Run(context.Background(), []Job{{Name: "a", Description: "random reads", Fio: fio}}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, event := range observer.events {
+		names = append(names, event.Event)
+	}
+	want := []string{EventSuiteStarted, EventTestStarted, EventTestProgress, EventTestFinished, EventSuiteFinished}
+	if len(names) != len(want) {
+		t.Fatalf("events = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("events = %v, want %v", names, want)
+		}
+	}
+	if progress := observer.events[2]; progress.TestName != "a" || progress.Percent != 50 || progress.IOPS != 1024 || progress.BWMBps != 4 || progress.TestRemainingSeconds != 5 {
+		t.Errorf("progress event %+v, want 50%% of a at 1024 IOPS and 4 MB/s with 5s left", progress)
+	}
+	finished := observer.events[3]
+	if result, ok := finished.Result.(*Result); !ok || finished.Status != "PASSED" || result.IOPS != 1000 {
+		t.Errorf("finished event %+v, want PASSED with the result of 1000 IOPS", finished)
+	}
+	if suite := observer.events[4]; *suite.Passed != 1 || *suite.Failed != 0 {
+		t.Errorf("suite finished with %d passed and %d failed, want 1 and 0", *suite.Passed, *suite.Failed)
+	}
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// etaGroup matches the bracketed fields of a fio status line like
+// "Jobs: 4 (f=4): [r(4)][25.0%][r=2345MiB/s][r=600k IOPS][eta 00m:45s]"
+var etaGroup = regexp.MustCompile(`\[([^\[\]]*)\]`)
+
+// StatusWatcher turns the status lines fio prints while a test runs into
+// test_progress events. It is written the output of fio run with
+// --eta=always.
+type StatusWatcher struct {
+	Test string
+	// Emit receives the events, Emit of the package when nil
+	Emit func(Event)
+	line []byte
+}
+
+func (w *StatusWatcher) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if c != '\n' && c != '\r' {
+			// Status lines are short, anything longer is not one
+			if len(w.line) < 1024 {
+				w.line = append(w.line, c)
+			}
+			continue
+		}
+		if event, ok := ParseStatusLine(string(w.line)); ok {
+			event.TestName = w.Test
+			if w.Emit != nil {
+				w.Emit(event)
+			} else {
+				Emit(event)
+			}
+		}
+		w.line = w.line[:0]
+	}
+	return len(p), nil
+}
+
+// ParseStatusLine reads the completion, bandwidth, IOPS and remaining time
+// of a fio status line, summed over both directions
+func ParseStatusLine(line string) (Event, bool) {
+	event := Event{Event: EventTestProgress}
+	if !strings.HasPrefix(strings.TrimSpace(line), "Jobs:") {
+		return event, false
+	}
+	for _, match := range etaGroup.FindAllStringSubmatch(line, -1) {
+		field := match[1]
+		switch {
+		case strings.HasSuffix(field, "%"):
+			event.Percent, _ = strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+		case strings.HasPrefix(field, "eta "):
+			event.TestRemainingSeconds = parseETADuration(strings.TrimPrefix(field, "eta "))
+		case strings.HasSuffix(field, " IOPS"):
+			for _, value := range etaValues(strings.TrimSuffix(field, " IOPS")) {
+				event.IOPS += parseETAValue(value, "", etaCountUnits)
+			}
+		case strings.Contains(field, "B/s"):
+			for _, value := range etaValues(field) {
+				event.BWMBps += parseETAValue(value, "/s", etaByteUnits)
+			}
+		}
+	}
+	return event, true
+}
+
+// etaValues splits the per direction values of a field like
+// "r=100MiB/s,w=25.0MiB/s"
+func etaValues(field string) []string {
+	var values []string
+	for _, part := range strings.Split(field, ",") {
+		if _, value, ok := strings.Cut(part, "="); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// mebibyte is the MB of all MB/s of fio-qa
+const mebibyte = 1024 * 1024
+
+var (
+	// etaCountUnits scale the IOPS of the status line
+	etaCountUnits = map[string]float64{"": 1, "k": 1e3, "M": 1e6, "G": 1e9}
+	// etaByteUnits convert the bandwidth of the status line to MB/s, the
+	// mebibytes of the results
+	etaByteUnits = map[string]float64{
+		"B": 1.0 / mebibyte, "KiB": 1.0 / 1024, "MiB": 1, "GiB": 1024, "TiB": 1024 * 1024,
+		"kB": 1e3 / mebibyte, "MB": 1e6 / mebibyte, "GB": 1e9 / mebibyte, "TB": 1e12 / mebibyte,
+	}
+)
+
+// parseETAValue parses a number with the unit fio scaled it to
+func parseETAValue(value, suffix string, units map[string]float64) float64 {
+	value = strings.TrimSuffix(strings.TrimSpace(value), suffix)
+	end := strings.LastIndexAny(value, "0123456789.") + 1
+	number, err := strconv.ParseFloat(value[:end], 64)
+	scale, ok := units[value[end:]]
+	if err != nil || !ok {
+		return 0
+	}
+	return number * scale
+}
+
+// parseETADuration parses the remaining time of fio like "01h:02m:03s"
+func parseETADuration(eta string) float64 {
+	scales := map[byte]float64{'d': 86400, 'h': 3600, 'm': 60, 's': 1}
+	seconds := 0.0
+	for _, part := range strings.Split(eta, ":") {
+		if part == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(part[:len(part)-1])
+		scale, ok := scales[part[len(part)-1]]
+		if err != nil || !ok {
+			return 0
+		}
+		seconds += float64(n) * scale
+	}
+	return seconds
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:generate sh -c "go run . schema testcases > schemas/testcases.schema.json"
+//go:generate sh -c "go run . schema suite > schemas/suite.schema.json"
+//go:generate sh -c "go run . schema results > schemas/results.schema.json"
+
+// schemaKinds are the documents with a published JSON Schema, by the root
+// type of the document
+var schemaKinds = map[string]reflect.Type{
+	"testcases": reflect.TypeOf(TestCases{}),
+	"suite":     reflect.TypeOf(SuiteManifest{}),
+	"results":   reflect.TypeOf(JSONResults{}),
+}
+
+// schemaRequired lists the properties a document must set, by type name.
+// Everything else has a default.
+var schemaRequired = map[string][]string{
+	"TestCases":     {"tests"},
+	"FioTest":       {"name"},
+	"SuiteManifest": {"suites"},
+	"SuiteRef":      {"file"},
+}
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// generateSchema derives the JSON Schema of a document from the Go types it
+// is decoded into, so the schema always matches what the tool accepts
+func generateSchema(kind string) map[string]interface{} {
+	defs := map[string]interface{}{}
+	root := schemaOf(schemaKinds[kind], defs)
+	schema := map[string]interface{}{
+		"$schema": schemaDraft,
+		"$id":     "https://github.com/ionutnechita/fio-qa/schemas/" + kind + ".schema.json",
+		"title":   "fio-qa " + kind,
+		"$ref":    root["$ref"],
+		"$defs":   defs,
+	}
+	// Documents may name their schema for editors
+	rootDef := defs[schemaKinds[kind].Name()].(map[string]interface{})
+	rootDef["properties"].(map[string]interface{})["$schema"] = map[string]interface{}{"type": "string"}
+	return schema
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns the schema of a type, adding named structs to defs
+func schemaOf(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		// Any JSON value
+		return map[string]interface{}{}
+	case t.Kind() == reflect.Ptr:
+		return nullable(schemaOf(t.Elem(), defs))
+	case t.Kind() == reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // breaks cycles
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return nullable(map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), defs)})
+	case t.Kind() == reflect.Map:
+		return nullable(map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)})
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// nullable allows null in addition to the type of the schema, as nil
+// pointers, slices and maps are encoded as null
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"]; ok {
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"$ref": ref}, map[string]interface{}{"type": "null"}}}
+	}
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []interface{}{typ, "null"}
+	}
+	return schema
+}
+
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		// Embedded structs without a name contribute their fields
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			for key, value := range structSchema(embedded, defs)["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, defs)
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if required, ok := schemaRequired[t.Name()]; ok {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// validateDocument checks a decoded JSON document against a schema produced
+// by generateSchema and returns the violations with their JSON path
+func validateDocument(schema map[string]interface{}, value interface{}) []string {
+	defs, _ := schema["$defs"].(map[string]interface{})
+	var errors []string
+	validateValue(schema, value, "$", defs, &errors)
+	return errors
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, defs map[string]interface{}, errors *[]string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		validateValue(def, value, path, defs, errors)
+		return
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		var first []string
+		for i, option := range anyOf {
+			var optionErrors []string
+			validateValue(option.(map[string]interface{}), value, path, defs, &optionErrors)
+			if len(optionErrors) == 0 {
+				return
+			}
+			if i == 0 {
+				first = optionErrors
+			}
+		}
+		*errors = append(*errors, first...)
+		return
+	}
+
+	if types, ok := schemaTypes(schema); ok && !matchesType(types, value) {
+		*errors = append(*errors, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				*errors = append(*errors, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				validateValue(property, v[key], path+"."+key, defs, errors)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*errors = append(*errors, fmt.Sprintf("%s: unknown property %q", path, key))
+				}
+			case map[string]interface{}:
+				validateValue(additional, v[key], path+"."+key, defs, errors)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), defs, errors)
+			}
+		}
+	}
+}
+
+func schemaTypes(schema map[string]interface{}) ([]string, bool) {
+	switch typ := schema["type"].(type) {
+	case string:
+		return []string{typ}, true
+	case []interface{}:
+		return schemaStrings(typ), true
+	}
+	return nil, false
+}
+
+func schemaStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var strs []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+func matchesType(types []string, value interface{}) bool {
+	for _, typ := range types {
+		switch v := value.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case float64:
+			if typ == "number" || (typ == "integer" && v == float64(int64(v))) {
+				return true
+			}
+		default:
+			if typ == jsonTypeName(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// detectKind guesses the kind of a document from its top level properties
+func detectKind(document interface{}) string {
+	root, _ := document.(map[string]interface{})
+	switch {
+	case root["tests"] != nil:
+		return "testcases"
+	case root["suites"] != nil && root["test_results"] == nil:
+		return "suite"
+	case root["test_results"] != nil:
+		return "results"
+	}
+	return ""
+}
+
+// runSchema prints the JSON Schema of a document kind
+func runSchema(args []string) int {
+	if len(args) != 1 || schemaKinds[args[0]] == nil {
+		return usageError("usage: fio-qa schema testcases|suite|results")
+	}
+	data, _ := json.MarshalIndent(generateSchema(args[0]), "", "  ")
+	fmt.Println(string(data))
+	return exitOK
+}
+
+// runValidate checks test case files, suite manifests and results files
+// against their schema, detecting the kind of each file unless --kind is set
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	kind := flags.String("kind", "", "kind of the files: testcases, suite or results (default: detected)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		return usageError("usage: fio-qa validate [--kind testcases|suite|results] file...")
+	}
+	if *kind != "" && schemaKinds[*kind] == nil {
+		return usageError("unknown kind %q", *kind)
+	}
+
+	code := exitOK
+	for _, file := range flags.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("%s: %v\n", file, err)
+			code = exitTestsFailed
+			continue
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			fmt.Printf("%s: invalid JSON: %v\n", file, err)
+			code = exitTestsFailed
+			continue
+		}
+
+		fileKind := *kind
+		if fileKind == "" {
+			fileKind = detectKind(document)
+		}
+		if fileKind == "" {
+			fmt.Printf("%s: cannot detect the kind of the file, use --kind\n", file)
+			code = exitTestsFailed
+			continue
+		}
+
+		violations := validateDocument(generateSchema(fileKind), document)
+		if fileKind == "testcases" {
+			violations = append(violations, documentDuplicateNames(document)...)
+		}
+		if len(violations) == 0 {
+			fmt.Printf("%s: valid %s\n", file, fileKind)
+			continue
+		}
+		code = exitTestsFailed
+		for _, violation := range violations {
+			fmt.Printf("%s: %s\n", file, violation)
+		}
+	}
+	return code
+}
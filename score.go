@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// ScoreConfig defines how a test contributes to the suite score
+type ScoreConfig struct {
+	Weight    float64 `json:"weight,omitempty"`
+	Metric    string  `json:"metric,omitempty"`
+	Reference float64 `json:"reference"`
+}
+
+// JSONScore represents the weighted suite score and grade
+type JSONScore struct {
+	Score float64         `json:"score"`
+	Grade string          `json:"grade"`
+	Tests []JSONTestScore `json:"tests"`
+}
+
+// JSONTestScore represents the score of a single test
+type JSONTestScore struct {
+	TestName  string  `json:"test_name"`
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Reference float64 `json:"reference"`
+	Weight    float64 `json:"weight"`
+	Score     float64 `json:"score"`
+}
+
+// grades maps the minimum suite score to a letter grade
+var grades = []struct {
+	MinScore float64
+	Grade    string
+}{
+	{90, "A"},
+	{80, "B"},
+	{70, "C"},
+	{60, "D"},
+	{0, "F"},
+}
+
+// scoreMetrics are the metrics a test can be scored on
+var scoreMetrics = []string{"iops", "bandwidth", "latency"}
+
+// checkScores validates the score metrics of the tests before anything runs
+func checkScores(tests []FioTest) error {
+	for _, test := range tests {
+		if test.Score == nil || test.Score.Metric == "" {
+			continue
+		}
+		known := false
+		for _, metric := range scoreMetrics {
+			known = known || test.Score.Metric == metric
+		}
+		if !known {
+			return fmt.Errorf("test %s: unknown score metric %q, available: %s", test.Name, test.Score.Metric, strings.Join(scoreMetrics, ", "))
+		}
+	}
+	return nil
+}
+
+// scoreMetric returns the metric a test is scored on. Without an explicit
+// metric, single job QD1 tests are scored on latency, tests with large
+// blocks on bandwidth and all other tests on IOPS.
+func scoreMetric(test FioTest) string {
+	if test.Score.Metric != "" {
+		return test.Score.Metric
+	}
+	switch {
+	case test.IODepth == 1 && test.NumJobs == 1:
+		return "latency"
+	case parseSize(test.BS) >= 64*1024:
+		return "bandwidth"
+	default:
+		return "iops"
+	}
+}
+
+// computeScore rates every test with a score reference from 0 to 100
+// against it, capped at 100, and combines them into a weighted suite score.
+// Failed tests score 0. Returns nil when no test has a score reference.
+func computeScore(results []TestResult) *JSONScore {
+	var score JSONScore
+	var totalWeight float64
+
+	for _, r := range results {
+		config := r.Config.Score
+		if config == nil || config.Reference <= 0 {
+			continue
+		}
+
+		testScore := JSONTestScore{
+			TestName:  r.TestName,
+			Metric:    scoreMetric(r.Config),
+			Reference: config.Reference,
+			Weight:    config.Weight,
+		}
+		if testScore.Weight <= 0 {
+			testScore.Weight = 1
+		}
+
+		if r.Status == "PASSED" {
+			switch testScore.Metric {
+			case "latency":
+				testScore.Value = r.AvgLatencyUs
+				if testScore.Value > 0 {
+					testScore.Score = 100 * config.Reference / testScore.Value
+				}
+			case "bandwidth":
+				testScore.Value = r.TotalBWMBps
+				testScore.Score = 100 * testScore.Value / config.Reference
+			default:
+				testScore.Value = r.TotalIOPS
+				testScore.Score = 100 * testScore.Value / config.Reference
+			}
+			if testScore.Score > 100 {
+				testScore.Score = 100
+			}
+		}
+
+		score.Tests = append(score.Tests, testScore)
+		score.Score += testScore.Score * testScore.Weight
+		totalWeight += testScore.Weight
+	}
+
+	if totalWeight == 0 {
+		return nil
+	}
+
+	score.Score /= totalWeight
+	for _, g := range grades {
+		if score.Score >= g.MinScore {
+			score.Grade = g.Grade
+			break
+		}
+	}
+	return &score
+}
+
+func displayScore(score *JSONScore) {
+	fmt.Fprintln(out, "=== Suite Score ===")
+	scoreTable := tablewriter.NewWriter(out)
+	scoreTable.SetHeader([]string{"Test", "Metric", "Value", "Reference", "Weight", "Score"})
+	configureTable(scoreTable, 6)
+	scoreTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, t := range score.Tests {
+		scoreTable.Append([]string{
+			t.TestName,
+			t.Metric,
+			fmt.Sprintf("%.2f", t.Value),
+			fmt.Sprintf("%.2f", t.Reference),
+			fmt.Sprintf("%.1f", t.Weight),
+			fmt.Sprintf("%.1f", t.Score),
+		})
+	}
+	scoreTable.SetFooter([]string{"", "", "", "", "Suite", fmt.Sprintf("%.1f (%s)", score.Score, score.Grade)})
+	scoreTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkSectorOptions validates the alignment options of the tests before
+// any test runs
+func checkSectorOptions(tests []FioTest) error {
+	for _, test := range tests {
+		if test.BlockAlign != "" && parseSize(test.BlockAlign) <= 0 {
+			return fmt.Errorf("test %s: invalid blockalign %q", test.Name, test.BlockAlign)
+		}
+		if test.Atomic && test.Direct != 1 {
+			return fmt.Errorf("test %s: atomic writes need direct=1", test.Name)
+		}
+	}
+	return nil
+}
+
+// blockSize is a block size of a test with the option it is set by
+type blockSize struct {
+	option string
+	size   int64
+}
+
+// blockSizes returns the block sizes a test and its job sections use. Lists
+// of sizes per direction and ranges are split into their sizes.
+func blockSizes(test FioTest) []blockSize {
+	var sizes []blockSize
+	add := func(option, bs string) {
+		for _, value := range strings.FieldsFunc(bs, func(r rune) bool { return r == ',' || r == '-' || r == ':' }) {
+			if size := parseSize(value); size > 0 {
+				sizes = append(sizes, blockSize{option: option, size: size})
+			}
+		}
+	}
+	add("bs="+test.BS, test.BS)
+	for _, section := range test.Jobs {
+		if section.BS != "" {
+			add(fmt.Sprintf("bs=%s of job section %s", section.BS, section.Name), section.BS)
+		}
+	}
+	return sizes
+}
+
+// alignToSectors checks the block sizes of a direct IO test against the
+// sector geometry of its device. Sizes that are not a multiple of the
+// logical sectors fail the test, the device rejects such IO; a blockalign
+// below them is raised to the logical sector size, and writes of part of a
+// physical sector are pointed out as the device has to read it first.
+func alignToSectors(test FioTest, result *TestResult) (FioTest, error) {
+	device := result.Device
+	if device == nil || device.LogicalSector <= 0 {
+		return test, nil
+	}
+	logical := int64(device.LogicalSector)
+	if test.Atomic {
+		if device.AtomicWriteMax <= 0 {
+			return test, fmt.Errorf("%s does not support atomic writes", device.Device)
+		}
+		for _, bs := range blockSizes(test) {
+			if bs.size > int64(device.AtomicWriteMax) {
+				return test, fmt.Errorf("%s exceeds the %d byte atomic writes of %s", bs.option, device.AtomicWriteMax, device.Device)
+			}
+		}
+	}
+	if test.Direct != 1 {
+		return test, nil
+	}
+
+	partial := false
+	for _, bs := range blockSizes(test) {
+		if bs.size%logical != 0 {
+			return test, fmt.Errorf("%s is not a multiple of the %d byte logical sectors of %s (%s), direct IO would fail",
+				bs.option, logical, device.Device, device.sectorFormat())
+		}
+		if device.PhysicalSector > device.LogicalSector && bs.size%int64(device.PhysicalSector) != 0 {
+			partial = true
+		}
+	}
+	if test.BlockAlign != "" && parseSize(test.BlockAlign)%logical != 0 {
+		result.warn(severityNotice, "config", "blockalign=%s is not aligned to the %d byte logical sectors of %s, using blockalign=%d",
+			test.BlockAlign, logical, device.Device, logical)
+		test.BlockAlign = strconv.FormatInt(logical, 10)
+	}
+	if partial && writeReason(test) != "" {
+		result.warn(severityWarning, "config", "writes of part of the %d byte physical sectors of %s (%s) are read-modify-write on the device",
+			device.PhysicalSector, device.Device, device.sectorFormat())
+	}
+	return test, nil
+}
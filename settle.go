@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// GCSettle records the wait after a write-heavy test until the device
+// finished the garbage collection the writes left behind
+type GCSettle struct {
+	Seconds     float64 `json:"seconds"`
+	ThresholdUs float64 `json:"threshold_latency_us"`
+	StartUs     float64 `json:"start_latency_us"`
+	EndUs       float64 `json:"end_latency_us"`
+	Probes      int     `json:"probes"`
+	// BusyMinutes is the controller busy time the device reported while
+	// settling, NVMe only
+	BusyMinutes int64 `json:"controller_busy_minutes,omitempty"`
+	TimedOut    bool  `json:"timed_out,omitempty"`
+}
+
+const (
+	// gcSettleFactor is how much slower than idle the target may answer
+	// once settled, without --gc-settle-us
+	gcSettleFactor = 1.5
+	// gcSettlePollInterval is the pause between the latency probes, so
+	// they add little load of their own
+	gcSettlePollInterval = 3 * time.Second
+)
+
+// writeHeavy reports whether a test mostly writes or trims, which leaves
+// garbage collection behind on flash devices
+func writeHeavy(test FioTest) bool {
+	switch test.RW {
+	case "write", "randwrite", "trim", "randtrim", "trimwrite":
+		return true
+	case "rw", "readwrite", "randrw":
+		// fio reads half of the IOs by default
+		return readMix(test) < 50
+	}
+	return false
+}
+
+// settleGC waits after a write-heavy test until the read latency of its
+// target dropped to --gc-settle-us, or to gcSettleFactor times its idle
+// latency, and NVMe devices stopped accumulating controller busy time. It
+// gives up after --gc-settle-max.
+func settleGC(test FioTest) (*GCSettle, error) {
+	settle := &GCSettle{ThresholdUs: opts.GCSettleUs}
+	if settle.ThresholdUs <= 0 {
+		idle := idleLatency[test.Filename]
+		if idle == 0 {
+			return nil, fmt.Errorf("the idle latency of %s is unknown, it could not be probed before the test", test.Filename)
+		}
+		settle.ThresholdUs = gcSettleFactor * idle
+	}
+
+	// Busy time only counts in minutes, it shows long background work
+	busyTime := func() int64 { return -1 }
+	if metadata, err := targetDevice(test.Filename); err == nil {
+		busyTime = func() int64 {
+			if data, err := readSMART(metadata.Device); err == nil && data.ControllerBusyMinutes > 0 {
+				return data.ControllerBusyMinutes
+			}
+			return -1
+		}
+	}
+
+	fmt.Fprintf(out, "Waiting for the device to settle below %.0f%s\n", settle.ThresholdUs, usUnit())
+	start := time.Now()
+	firstBusy := busyTime()
+	lastBusy := firstBusy
+	for {
+		latency, err := probeIdleLatency(test)
+		if err != nil {
+			return nil, err
+		}
+		settle.Probes++
+		if settle.Probes == 1 {
+			settle.StartUs = latency
+		}
+		settle.EndUs = latency
+		busy := busyTime()
+		if busy >= 0 && firstBusy >= 0 {
+			settle.BusyMinutes = busy - firstBusy
+		}
+		idle := busy == lastBusy
+		lastBusy = busy
+		if latency <= settle.ThresholdUs && idle {
+			break
+		}
+		if time.Since(start) >= opts.GCSettleMax {
+			settle.TimedOut = true
+			break
+		}
+		time.Sleep(gcSettlePollInterval)
+	}
+	settle.Seconds = time.Since(start).Seconds()
+	return settle, nil
+}
+
+// String describes the settling in one line for the result tables
+func (s *GCSettle) String() string {
+	text := fmt.Sprintf("%.0fs, %.0f%s to %.0f%s (threshold %.0f%s, %d probes)",
+		s.Seconds, s.StartUs, usUnit(), s.EndUs, usUnit(), s.ThresholdUs, usUnit(), s.Probes)
+	if s.TimedOut {
+		text += ", timed out"
+	}
+	return text
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sheetsAPI is the endpoint of the Google Sheets API
+const sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// sheetsScope is the OAuth scope allowing to edit spreadsheets
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// sheetsColumns are the columns of the rows appended to the sheet, written
+// as the header when the first row of the sheet is still empty
+var sheetsColumns = []string{
+	"Run ID", "Date", "Host", "Namespace", "Suite", "Labels", "Test", "Status",
+	"Duration (s)", "IOPS", "MB/s", "Lat Avg (us)", "p99 Lat (us)", "Warnings", "Error",
+}
+
+// ServiceAccount holds the fields of a Google service account key file
+// needed to request access tokens
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	key         *rsa.PrivateKey
+}
+
+// sheetsAccount is the service account the results are appended with, nil
+// when --sheets-id is not given
+var sheetsAccount *ServiceAccount
+
+// loadServiceAccount reads and validates a service account key file before
+// any test runs, so a bad key does not surface only after the whole run
+func loadServiceAccount(path string) (*ServiceAccount, error) {
+	if path == "" {
+		return nil, fmt.Errorf("--sheets-id needs a service account key file, give it with --sheets-credentials or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the service account key: %v", err)
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account key %s: %v", path, err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("invalid service account key %s: client_email and private_key are required", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid service account key %s: private_key is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account key %s: %v", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid service account key %s: private_key is not an RSA key", path)
+	}
+	account.key = key
+	return &account, nil
+}
+
+// token exchanges a JWT signed with the key of the service account for an
+// access token to the Sheets API
+func (a *ServiceAccount) token(client *http.Client) (string, error) {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	now := time.Now()
+	unsigned := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(map[string]interface{}{
+		"iss":   a.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.PostForm(a.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode >= 300 || reply.AccessToken == "" {
+		return "", fmt.Errorf("%s refused the service account %s: %s %s", a.TokenURI, a.ClientEmail, resp.Status, reply.Error)
+	}
+	return reply.AccessToken, nil
+}
+
+// sheetsRows returns a row per test with the summary of its result
+func sheetsRows(results []TestResult, run RunInfo) [][]interface{} {
+	hostname, _ := os.Hostname()
+	date := time.Now().Format("2006-01-02 15:04:05")
+	var labels []string
+	for key, value := range run.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+
+	var rows [][]interface{}
+	for _, r := range results {
+		errText := ""
+		if r.Error != nil {
+			errText = r.Error.Error()
+		}
+		rows = append(rows, []interface{}{
+			run.ID, date, hostname, run.Namespace, r.Config.Suite, strings.Join(labels, ", "), r.TestName, r.Status,
+			r.Duration.Round(time.Second).Seconds(), r.TotalIOPS, r.TotalBWMBps, r.AvgLatencyUs, p99LatencyUs(r), len(r.Warnings), errText,
+		})
+	}
+	return rows
+}
+
+// appendToSheet appends the summary rows of the run to the range of the
+// spreadsheet, below the rows of earlier runs. The header is written first
+// when the first row of the sheet is still empty. The values are written
+// as they are, so error messages starting with = are never run as formulas.
+func appendToSheet(results []TestResult, run RunInfo, spreadsheet, sheetRange string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	token, err := sheetsAccount.token(client)
+	if err != nil {
+		return err
+	}
+	endpoint := sheetsAPI + "/" + url.PathEscape(spreadsheet) + "/values/" + url.PathEscape(sheetRange)
+	call := func(method, target string, body []byte) ([]byte, error) {
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			var reply struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.Unmarshal(data, &reply)
+			return nil, fmt.Errorf("spreadsheet %s returned %s %s", spreadsheet, resp.Status, reply.Error.Message)
+		}
+		return data, nil
+	}
+
+	// Only the first row tells whether the header is there, the sheet name
+	// ends at the last ! since the cells of a range cannot contain one
+	sheet := sheetRange
+	if i := strings.LastIndex(sheetRange, "!"); i >= 0 {
+		sheet = sheetRange[:i]
+	}
+	data, err := call(http.MethodGet, sheetsAPI+"/"+url.PathEscape(spreadsheet)+"/values/"+url.PathEscape(quoteSheetName(sheet)+"!A1:1"), nil)
+	if err != nil {
+		return err
+	}
+	var existing struct {
+		Values [][]interface{} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return fmt.Errorf("spreadsheet %s: %v", spreadsheet, err)
+	}
+	var rows [][]interface{}
+	if len(existing.Values) == 0 {
+		header := make([]interface{}, len(sheetsColumns))
+		for i, column := range sheetsColumns {
+			header[i] = column
+		}
+		rows = append(rows, header)
+	}
+	rows = append(rows, sheetsRows(results, run)...)
+
+	body, err := json.Marshal(map[string]interface{}{"values": rows})
+	if err != nil {
+		return err
+	}
+	_, err = call(http.MethodPost, endpoint+":append?valueInputOption=RAW&insertDataOption=INSERT_ROWS", body)
+	return err
+}
+
+// quoteSheetName quotes a sheet name for A1 notation, names with spaces or
+// punctuation are only valid in single quotes, which are doubled inside.
+// Names the range already quoted are kept.
+func quoteSheetName(name string) string {
+	if len(name) >= 2 && strings.HasPrefix(name, "'") && strings.HasSuffix(name, "'") {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
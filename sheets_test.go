@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// TestQuoteSheetName expects sheet names to be quoted for A1 notation with
+// their single quotes doubled, and names already quoted to be kept
+func TestQuoteSheetName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Sheet1", "'Sheet1'"},
+		{"QA Runs", "'QA Runs'"},
+		{"Ionut's runs", "'Ionut''s runs'"},
+		{"'QA Runs'", "'QA Runs'"},
+	}
+	for _, tt := range tests {
+		if got := quoteSheetName(tt.name); got != tt.want {
+			t.Errorf("quoteSheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// Sign-off states of a run. Runs are written as drafts and only become
+// usable as a reference once someone approved them with the sign command.
+const (
+	signOffDraft    = "DRAFT"
+	signOffApproved = "APPROVED"
+)
+
+// historySignOff is the kind of the history records of approvals
+const historySignOff = "sign_off"
+
+// SignOff is the review state of a run
+type SignOff struct {
+	Status   string     `json:"status"`
+	Approver string     `json:"approver,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	Note     string     `json:"note,omitempty"`
+}
+
+// approved tells whether a results file was signed off, results written
+// before sign-offs existed are drafts
+func (r JSONResults) approved() bool {
+	return r.SignOff != nil && r.SignOff.Status == signOffApproved
+}
+
+// checkApproved refuses results files that were not signed off
+func checkApproved(path string, results JSONResults) error {
+	if !results.approved() {
+		return fmt.Errorf("%s is not approved, sign it off with fio-qa sign first", path)
+	}
+	return nil
+}
+
+// approvedRuns returns the IDs of the runs signed off in the history store
+func approvedRuns(path string) (map[string]bool, error) {
+	runs := map[string]bool{}
+	err := scanHistory(path, HistoryFilter{Kind: historySignOff}, func(record HistoryRecord) error {
+		if record.Status == signOffApproved {
+			runs[record.Run] = true
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// currentUser returns the login name of the user running fio-qa
+func currentUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// runSign approves a results file: the sign-off is written into the file
+// and recorded in the history store, so trends and baselines can be
+// limited to approved runs
+func runSign(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	approver := flags.String("approver", currentUser(), "name of the approver")
+	note := flags.String("note", "", "note stored with the sign-off")
+	history := flags.String("history", "fio-qa-history.ndjson", "history store to record the approval in, empty to disable")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		return usageError("usage: fio-qa sign [--approver name] [--note text] results.json")
+	}
+	if *approver == "" {
+		return usageError("--approver must not be empty")
+	}
+	path := flags.Arg(0)
+	results, isJSON, err := readResultsFile(path)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	if !isJSON {
+		return usageError("%s: only JSON results can be signed off, convert it first", path)
+	}
+	if results.approved() {
+		return usageError("%s was already approved by %s", path, results.SignOff.Approver)
+	}
+
+	now := time.Now()
+	results.SignOff = &SignOff{Status: signOffApproved, Approver: *approver, Time: &now, Note: *note}
+	// Written next to the file first so it is replaced at once
+	tmp := path + ".tmp"
+	if err := saveResultsToJSON(results, tmp); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitOutput
+	}
+	if strings.HasSuffix(path, ".gz") {
+		err = compressFileTo(tmp, path)
+	} else {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitOutput
+	}
+
+	if *history != "" && results.RunID != "" {
+		opts.History = *history
+		record := HistoryRecord{
+			Kind:      historySignOff,
+			Time:      now,
+			Run:       results.RunID,
+			Namespace: results.Namespace,
+			Labels:    results.Labels,
+			Status:    signOffApproved,
+			Approver:  *approver,
+		}
+		if err := appendHistory(record); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *history, err)
+			return exitOutput
+		}
+	}
+	fmt.Fprintf(out, "%s approved by %s\n", path, *approver)
+	return exitOK
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"fio-qa/stats"
+)
+
+// SLOConfig is a latency service level objective, e.g. 99.9% of IOs
+// complete within 2ms
+type SLOConfig struct {
+	Percent float64 `json:"percent"`
+	Latency string  `json:"latency"`
+}
+
+// SLOResult is the evaluation of the completion latency distribution of a
+// test against its objective. The burn rate is the violation rate relative
+// to the error budget: at 1 the budget is used up exactly, above 1 the
+// objective is missed.
+type SLOResult struct {
+	Objective   string  `json:"objective"`
+	ThresholdUs float64 `json:"threshold_us"`
+	ViolationPc float64 `json:"violation_percent"`
+	BudgetPc    float64 `json:"error_budget_percent"`
+	BurnRate    float64 `json:"burn_rate"`
+	Met         bool    `json:"met"`
+}
+
+// parseSLO parses an objective written as "99.9%<2ms"
+func parseSLO(spec string) (*SLOConfig, error) {
+	percent, latency, ok := strings.Cut(spec, "<")
+	if !ok {
+		return nil, fmt.Errorf("invalid SLO %q, expected e.g. 99.9%%<2ms", spec)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(percent), "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SLO %q: %v", spec, err)
+	}
+	slo := &SLOConfig{Percent: value, Latency: strings.TrimSpace(latency)}
+	if _, err := slo.threshold(); err != nil {
+		return nil, err
+	}
+	return slo, nil
+}
+
+// threshold validates the objective and returns its latency threshold
+func (s *SLOConfig) threshold() (time.Duration, error) {
+	if s.Percent <= 0 || s.Percent >= 100 {
+		return 0, fmt.Errorf("SLO percent must be between 0 and 100, got %g", s.Percent)
+	}
+	latency, err := time.ParseDuration(s.Latency)
+	if err != nil || latency <= 0 {
+		return 0, fmt.Errorf("invalid SLO latency %q", s.Latency)
+	}
+	return latency, nil
+}
+
+func (s *SLOConfig) String() string {
+	return fmt.Sprintf("%g%% < %s", s.Percent, s.Latency)
+}
+
+// testSLO returns the objective of a test, the one of --slo by default
+func testSLO(test FioTest) (*SLOConfig, error) {
+	if test.SLO != nil {
+		return test.SLO, nil
+	}
+	if opts.SLO != "" {
+		return parseSLO(opts.SLO)
+	}
+	return nil, nil
+}
+
+// checkSLOs validates the objectives before any test runs
+func checkSLOs(tests []FioTest) error {
+	for _, test := range tests {
+		slo, err := testSLO(test)
+		if err != nil {
+			return err
+		}
+		if slo == nil {
+			continue
+		}
+		if _, err := slo.threshold(); err != nil {
+			return fmt.Errorf("test %s: %v", test.Name, err)
+		}
+	}
+	return nil
+}
+
+// evaluateSLO estimates the share of IOs slower than the latency threshold
+// from the completion latency percentiles reported by fio, weighting reads
+// and writes by their IOPS, and warns when the objective is missed
+func evaluateSLO(test FioTest, result *TestResult) {
+	slo, err := testSLO(test)
+	if slo == nil || err != nil || result.FioJob == nil || result.TotalIOPS == 0 {
+		return
+	}
+	threshold, err := slo.threshold()
+	if err != nil {
+		return
+	}
+
+	job := result.FioJob
+	thresholdNs := float64(threshold.Nanoseconds())
+	violation := (violationPercent(job.Read.Clat, thresholdNs)*result.ReadIOPS +
+		violationPercent(job.Write.Clat, thresholdNs)*result.WriteIOPS) / result.TotalIOPS
+
+	evaluation := &SLOResult{
+		Objective:   slo.String(),
+		ThresholdUs: float64(threshold.Microseconds()),
+		ViolationPc: violation,
+		// Rounded to drop the float noise of e.g. 100 - 99.9
+		BudgetPc: math.Round((100-slo.Percent)*1e9) / 1e9,
+	}
+	evaluation.BurnRate = evaluation.ViolationPc / evaluation.BudgetPc
+	evaluation.Met = evaluation.BurnRate <= 1
+	result.SLO = evaluation
+
+	if !evaluation.Met {
+		result.warn(severityWarning, "slo", "%.3f%% of IOs exceeded %s, burning the error budget of %s at %.1fx",
+			evaluation.ViolationPc, slo.Latency, evaluation.Objective, evaluation.BurnRate)
+	}
+}
+
+// violationPercent estimates the percentage of IOs with a completion latency
+// above thresholdNs by interpolating between the reported percentiles, the
+// minimum and the maximum
+func violationPercent(clat FioClat, thresholdNs float64) float64 {
+	if thresholdNs >= clat.Max {
+		return 0
+	}
+	return 100 - stats.FromFioPercentiles(clat.Min, clat.Max, clat.Percentile).Rank(thresholdNs)
+}
+
+func (r *SLOResult) String() string {
+	state := "met"
+	if !r.Met {
+		state = "missed"
+	}
+	return fmt.Sprintf("%s: %.3f%% slower, burn rate %.2fx (%s)", r.Objective, r.ViolationPc, r.BurnRate, state)
+}
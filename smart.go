@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// SMARTData holds the health and wear counters of a device
+type SMARTData struct {
+	TemperatureC     float64 `json:"temperature_c,omitempty"`
+	PercentageUsed   float64 `json:"percentage_used"`
+	DataWrittenBytes int64   `json:"data_written_bytes,omitempty"`
+	MediaErrors      int64   `json:"media_errors"`
+	PowerOnHours     int64   `json:"power_on_hours,omitempty"`
+	// ControllerBusyMinutes grows while an NVMe controller is busy with IO,
+	// also its own background work
+	ControllerBusyMinutes int64 `json:"controller_busy_minutes,omitempty"`
+}
+
+// smartReport is the part of the JSON output of smartctl used here
+type smartReport struct {
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours int64 `json:"hours"`
+	} `json:"power_on_time"`
+	NVMe *struct {
+		PercentageUsed     float64 `json:"percentage_used"`
+		DataUnitsWritten   int64   `json:"data_units_written"`
+		MediaErrors        int64   `json:"media_errors"`
+		ControllerBusyTime int64   `json:"controller_busy_time"`
+	} `json:"nvme_smart_health_information_log"`
+	ATA *struct {
+		Table []struct {
+			ID    int     `json:"id"`
+			Value float64 `json:"value"`
+			Raw   struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// ATA attributes reporting the remaining life as a normalized value
+var ataLifeAttributes = []int{
+	231, // SSD_Life_Left
+	233, // Media_Wearout_Indicator
+	177, // Wear_Leveling_Count
+}
+
+// readSMART reads the health and wear counters of a device with smartctl
+func readSMART(device string) (*SMARTData, error) {
+	// smartctl sets bits of its exit status for device warnings even when
+	// the output is complete, so only the output is checked
+	output, err := exec.Command("smartctl", "-a", "-j", device).Output()
+	if len(output) == 0 {
+		return nil, fmt.Errorf("smartctl failed: %v", err)
+	}
+	var report smartReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("cannot parse smartctl output: %v", err)
+	}
+
+	data := &SMARTData{
+		TemperatureC: report.Temperature.Current,
+		PowerOnHours: report.PowerOnTime.Hours,
+	}
+	switch {
+	case report.NVMe != nil:
+		data.PercentageUsed = report.NVMe.PercentageUsed
+		// NVMe data units are thousands of 512 byte sectors
+		data.DataWrittenBytes = report.NVMe.DataUnitsWritten * 512000
+		data.MediaErrors = report.NVMe.MediaErrors
+		data.ControllerBusyMinutes = report.NVMe.ControllerBusyTime
+	case report.ATA != nil:
+		attributes := map[int]int{}
+		for i, attribute := range report.ATA.Table {
+			attributes[attribute.ID] = i
+		}
+		for _, id := range ataLifeAttributes {
+			if i, ok := attributes[id]; ok {
+				data.PercentageUsed = 100 - report.ATA.Table[i].Value
+				break
+			}
+		}
+		if i, ok := attributes[241]; ok { // Total_LBAs_Written
+			data.DataWrittenBytes = report.ATA.Table[i].Raw.Value * 512
+		}
+		if i, ok := attributes[187]; ok { // Reported_Uncorrect
+			data.MediaErrors = report.ATA.Table[i].Raw.Value
+		}
+	}
+	return data, nil
+}
+
+// smartctlTemperature reads the current temperature of a device with
+// smartctl, for devices that do not expose it otherwise
+func smartctlTemperature(device string) (float64, error) {
+	data, err := readSMART(device)
+	if err != nil {
+		return 0, err
+	}
+	if data.TemperatureC == 0 {
+		return 0, fmt.Errorf("%s does not report its temperature", device)
+	}
+	return data.TemperatureC, nil
+}
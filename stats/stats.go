@@ -0,0 +1,183 @@
+// Package stats computes arbitrary percentiles from the latency
+// distributions reported by fio, either the percentile table of its JSON
+// output or the bins of its histogram logs or json+ output, interpolating
+// between the known points. It also tests whether the results of repeated
+// runs differ.
+package stats
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Point is a known point of a cumulative distribution: Percent of the
+// samples are at most Value
+type Point struct {
+	Percent float64
+	Value   float64
+}
+
+// Distribution is a cumulative distribution known at a few points, like the
+// completion latency percentiles of fio
+type Distribution struct {
+	points []Point
+}
+
+// NewDistribution builds a distribution from its minimum, maximum and known
+// percentiles
+func NewDistribution(min, max float64, points []Point) *Distribution {
+	d := &Distribution{points: []Point{{0, min}}}
+	d.points = append(d.points, points...)
+	d.points = append(d.points, Point{100, max})
+	sort.SliceStable(d.points, func(i, j int) bool { return d.points[i].Percent < d.points[j].Percent })
+	return d
+}
+
+// FromFioPercentiles builds a distribution from a percentile map of the fio
+// JSON output, keyed by percent like "99.900000"
+func FromFioPercentiles(min, max float64, percentiles map[string]float64) *Distribution {
+	var points []Point
+	for key, value := range percentiles {
+		if percent, err := strconv.ParseFloat(key, 64); err == nil {
+			points = append(points, Point{percent, value})
+		}
+	}
+	return NewDistribution(min, max, points)
+}
+
+// Percentile returns the value below which p percent of the samples fall,
+// interpolating linearly between the neighbouring known points
+func (d *Distribution) Percentile(p float64) float64 {
+	if p <= 0 {
+		return d.points[0].Value
+	}
+	for i := 1; i < len(d.points); i++ {
+		lower, upper := d.points[i-1], d.points[i]
+		if p > upper.Percent {
+			continue
+		}
+		if upper.Percent == lower.Percent {
+			return upper.Value
+		}
+		return lower.Value + (upper.Value-lower.Value)*(p-lower.Percent)/(upper.Percent-lower.Percent)
+	}
+	return d.points[len(d.points)-1].Value
+}
+
+// Rank returns the percentage of samples at most value, the inverse of
+// Percentile
+func (d *Distribution) Rank(value float64) float64 {
+	if value >= d.points[len(d.points)-1].Value {
+		return 100
+	}
+	for i := 1; i < len(d.points); i++ {
+		lower, upper := d.points[i-1], d.points[i]
+		if upper.Value <= value {
+			continue
+		}
+		if value <= lower.Value {
+			return lower.Percent
+		}
+		return lower.Percent + (upper.Percent-lower.Percent)*(value-lower.Value)/(upper.Value-lower.Value)
+	}
+	return 100
+}
+
+// Histogram counts samples in bins. Bin i holds the samples between
+// Bounds[i-1] and Bounds[i], the first bin those from 0 to Bounds[0].
+type Histogram struct {
+	Bounds []float64
+	Counts []int64
+}
+
+// fio stores latencies in groups of 64 bins, every group doubling the bin
+// width of the previous one (FIO_IO_U_PLAT_BITS)
+const (
+	fioPlatBits = 6
+	fioPlatVal  = 1 << fioPlatBits
+	// fioPlatNr is the number of bins, FIO_IO_U_PLAT_NR
+	fioPlatNr = 29 * fioPlatVal
+)
+
+// FioBinValue returns the value of a bin of a fio histogram, the mean of
+// its range like plat_idx_to_val in fio
+func FioBinValue(index int) float64 {
+	if index < fioPlatVal<<1 {
+		return float64(index)
+	}
+	errorBits := uint(index>>fioPlatBits) - 1
+	base := float64(uint64(1) << (errorBits + fioPlatBits))
+	k := float64(index % fioPlatVal)
+	return base + (k+0.5)*float64(uint64(1)<<errorBits)
+}
+
+// fioBinUpper returns the upper bound of the range of a fio histogram bin
+func fioBinUpper(index int) float64 {
+	if index < fioPlatVal<<1 {
+		return float64(index + 1)
+	}
+	errorBits := uint(index>>fioPlatBits) - 1
+	return FioBinValue(index) + 0.5*float64(uint64(1)<<errorBits)
+}
+
+// FromFioBins builds a histogram from the bins of a fio histogram log
+func FromFioBins(bins []int64) Histogram {
+	h := Histogram{Bounds: make([]float64, len(bins)), Counts: append([]int64(nil), bins...)}
+	for i := range bins {
+		h.Bounds[i] = fioBinUpper(i)
+	}
+	return h
+}
+
+// FromFioJSONBins builds a histogram from the bins of the json+ output of
+// fio, which are keyed by the value of the bin instead of its index. Each
+// count goes to the bin whose range holds its key.
+func FromFioJSONBins(bins map[string]int64) Histogram {
+	counts := make([]int64, fioPlatNr)
+	last := -1
+	for key, count := range bins {
+		value, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			continue
+		}
+		index := sort.Search(fioPlatNr, func(i int) bool { return fioBinUpper(i) > value })
+		if index == fioPlatNr {
+			index--
+		}
+		counts[index] += count
+		last = max(last, index)
+	}
+	return FromFioBins(counts[:last+1])
+}
+
+// Total returns the number of samples in the histogram
+func (h Histogram) Total() int64 {
+	var total int64
+	for _, count := range h.Counts {
+		total += count
+	}
+	return total
+}
+
+// Percentile returns the value below which p percent of the samples fall,
+// assuming the samples are spread evenly within their bin
+func (h Histogram) Percentile(p float64) float64 {
+	total := h.Total()
+	if total == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(total)
+	var seen int64
+	for i, count := range h.Counts {
+		if count == 0 || float64(seen+count) < rank {
+			seen += count
+			continue
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		}
+		return lower + (h.Bounds[i]-lower)*(rank-float64(seen))/float64(count)
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
@@ -0,0 +1,106 @@
+package stats
+
+import "math"
+
+// MeanStdDev returns the mean and the sample standard deviation of values
+func MeanStdDev(values []float64) (mean, stddev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// WelchTTest returns the two-sided p-value of Welch's t-test, the
+// probability of a difference of the means of a and b at least as large as
+// observed when both samples come from distributions with the same mean.
+// Samples need two values each, otherwise the p-value is 1.
+func WelchTTest(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+	meanA, sdA := MeanStdDev(a)
+	meanB, sdB := MeanStdDev(b)
+	varA := sdA * sdA / float64(len(a))
+	varB := sdB * sdB / float64(len(b))
+	if varA+varB == 0 {
+		// Without any spread every difference is certain
+		if meanA == meanB {
+			return 1
+		}
+		return 0
+	}
+	t := (meanA - meanB) / math.Sqrt(varA+varB)
+	// Welch-Satterthwaite degrees of freedom
+	df := (varA + varB) * (varA + varB) /
+		(varA*varA/float64(len(a)-1) + varB*varB/float64(len(b)-1))
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// incompleteBeta returns the regularized incomplete beta function I_x(a, b),
+// evaluated with its continued fraction
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lbeta, _ := math.Lgamma(a + b)
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	front := math.Exp(lbeta - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly below the mean of the
+	// distribution, above it the symmetry I_x(a, b) = 1 - I_1-x(b, a) is used
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaFraction(b, a, 1-x)/b
+	}
+	return front * betaFraction(a, b, x) / a
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta
+// function with the modified Lentz method
+func betaFraction(a, b, x float64) float64 {
+	const (
+		epsilon = 1e-12
+		tiny    = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		for _, numerator := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + numerator*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + numerator/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
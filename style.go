@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/olekukonko/tablewriter"
+)
+
+// Every table has the same total width, by default 113 characters. The first
+// column holds the row labels, the other columns share the remaining width.
+const (
+	defaultTableWidth = 113
+	minTableWidth     = 60
+	labelColumnWidth  = 40
+)
+
+// tableWidth is the total width of the tables, including borders
+var tableWidth = defaultTableWidth
+
+// setTableWidth sizes the tables from --width or the width of the terminal.
+// Output that does not go to a terminal keeps the default width so logs stay
+// comparable between runs.
+func setTableWidth() {
+	width := opts.Width
+	if width == 0 {
+		width = terminalWidth(out)
+	}
+	if width == 0 {
+		width = defaultTableWidth
+	}
+	if width < minTableWidth {
+		width = minTableWidth
+	}
+	tableWidth = width
+}
+
+// columnWidths returns the content widths of the columns of a table with
+// colCount columns. Each cell is padded with a space on both sides and the
+// columns are separated by one character.
+func columnWidths(colCount int) []int {
+	content := tableWidth - 3*colCount - 1
+	widths := make([]int, colCount)
+	widths[0] = labelColumnWidth * tableWidth / defaultTableWidth
+	if colCount == 1 {
+		widths[0] = content
+		return widths
+	}
+	rest := content - widths[0]
+	for i := 1; i < colCount; i++ {
+		widths[i] = rest / (colCount - 1)
+		if i <= rest%(colCount-1) {
+			widths[i]++
+		}
+	}
+	return widths
+}
+
+// setTableSeparators draws the table with box-drawing characters, or with
+// plain ASCII characters when --ascii is set
+func setTableSeparators(table *tablewriter.Table) {
+	if opts.ASCII {
+		table.SetColumnSeparator("|")
+		table.SetCenterSeparator("+")
+		table.SetRowSeparator("-")
+		return
+	}
+	table.SetColumnSeparator("│")
+	table.SetCenterSeparator("┼")
+	table.SetRowSeparator("─")
+}
+
+// setHeaderColor colors every header cell of the table unless colors are
+// disabled
+func setHeaderColor(table *tablewriter.Table, columns int, color int) {
+	if opts.NoColor {
+		return
+	}
+	colors := make([]tablewriter.Colors, columns)
+	for i := range colors {
+		colors[i] = tablewriter.Colors{tablewriter.Bold, color}
+	}
+	table.SetHeaderColor(colors...)
+}
+
+// plainText reports whether emoji and other non-ASCII glyphs must be avoided
+func plainText() bool {
+	return opts.ASCII || opts.NoColor
+}
+
+// statusMark returns the mark shown for a test status in the summary
+func statusMark(status string) string {
+	passed := status == "PASSED"
+	switch {
+	case plainText() && passed:
+		return "OK"
+	case plainText():
+		return "FAIL"
+	case passed:
+		return "✅"
+	default:
+		return "❌"
+	}
+}
+
+// statusLabel returns a test status with its mark
+func statusLabel(status string) string {
+	if plainText() {
+		return status
+	}
+	return statusMark(status) + " " + status
+}
+
+// usUnit returns the unit symbol for microseconds
+func usUnit() string {
+	if opts.ASCII {
+		return "us"
+	}
+	return "μs"
+}
+
+// celsiusUnit returns the unit symbol for degrees Celsius
+func celsiusUnit() string {
+	if opts.ASCII {
+		return "C"
+	}
+	return "°C"
+}
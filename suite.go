@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// SuiteManifest combines the test case files of several teams into a single
+// run and report
+type SuiteManifest struct {
+	Name   string     `json:"name"`
+	Suites []SuiteRef `json:"suites"`
+	// Highlights of the run, the suites' own are added after them
+	Highlights []HighlightConfig `json:"highlights,omitempty"`
+	// RateCap caps the bandwidth of every test of every suite
+	RateCap string `json:"rate_cap,omitempty"`
+}
+
+// SuiteRef references a test case file of a manifest. Suites run in
+// ascending order, suites with the same order in the order they are listed.
+type SuiteRef struct {
+	Label string `json:"label"`
+	File  string `json:"file"`
+	Order int    `json:"order,omitempty"`
+}
+
+// JSONSuiteSummary summarizes the tests of one suite of a manifest
+type JSONSuiteSummary struct {
+	Label  string `json:"label"`
+	Tests  int    `json:"tests"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+}
+
+// loadSuiteManifest loads the test cases of all suites of a manifest, with
+// every test labeled with its suite. Test case files are relative to the
+// manifest.
+func loadSuiteManifest(filename string) (*TestCases, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	auditConfigFile(filename, data)
+
+	var manifest SuiteManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Suites) == 0 {
+		return nil, fmt.Errorf("%s does not reference any suites", filename)
+	}
+
+	suites := append([]SuiteRef(nil), manifest.Suites...)
+	sort.SliceStable(suites, func(i, j int) bool {
+		return suites[i].Order < suites[j].Order
+	})
+
+	combined := &TestCases{Name: manifest.Name, Highlights: manifest.Highlights, RateCap: manifest.RateCap}
+	highlightLabels := map[string]bool{}
+	for _, highlight := range manifest.Highlights {
+		highlightLabels[highlight.Label] = true
+	}
+	labels := map[string]bool{}
+	for _, suite := range suites {
+		if suite.Label == "" {
+			suite.Label = strings.TrimSuffix(filepath.Base(suite.File), filepath.Ext(suite.File))
+		}
+		if labels[suite.Label] {
+			return nil, fmt.Errorf("suite label %s is used more than once", suite.Label)
+		}
+		labels[suite.Label] = true
+
+		path := suite.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filename), path)
+		}
+		testCases, err := loadTestCases(path)
+		if err != nil {
+			return nil, fmt.Errorf("suite %s: %v", suite.Label, err)
+		}
+		for _, test := range testCases.Tests {
+			test.Suite = suite.Label
+			combined.Tests = append(combined.Tests, test)
+		}
+		// The suites run capped by the lowest rate cap of any of them
+		combined.RateCap = lowerRateCap(combined.RateCap, testCases.RateCap)
+		// A highlight several suites define is shown once
+		for _, highlight := range testCases.Highlights {
+			if !highlightLabels[highlight.Label] {
+				highlightLabels[highlight.Label] = true
+				combined.Highlights = append(combined.Highlights, highlight)
+			}
+		}
+	}
+	return combined, nil
+}
+
+// shuffleTests randomizes the test order with the given seed, the same seed
+// always gives the same order
+func shuffleTests(tests []FioTest, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(tests), func(i, j int) {
+		tests[i], tests[j] = tests[j], tests[i]
+	})
+}
+
+// summarizeSuites counts the results per suite, in the order the suites ran
+func summarizeSuites(results []TestResult) []JSONSuiteSummary {
+	var summaries []JSONSuiteSummary
+	index := map[string]int{}
+	for _, r := range results {
+		if r.Config.Suite == "" {
+			continue
+		}
+		i, ok := index[r.Config.Suite]
+		if !ok {
+			i = len(summaries)
+			index[r.Config.Suite] = i
+			summaries = append(summaries, JSONSuiteSummary{Label: r.Config.Suite})
+		}
+		summaries[i].Tests++
+		if r.Status == "PASSED" {
+			summaries[i].Passed++
+		} else {
+			summaries[i].Failed++
+		}
+	}
+	return summaries
+}
+
+func displaySuites(suites []JSONSuiteSummary) {
+	suitesTable := tablewriter.NewWriter(out)
+	suitesTable.SetHeader([]string{"Suite", "Tests", "Passed", "Failed"})
+	configureTable(suitesTable, 4)
+	suitesTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, suite := range suites {
+		suitesTable.Append([]string{suite.Label, strconv.Itoa(suite.Tests), strconv.Itoa(suite.Passed), strconv.Itoa(suite.Failed)})
+	}
+	suitesTable.Render()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// summaryColumn is a column of the results table shown in the summary
+type summaryColumn struct {
+	Header string
+	Align  int
+	// Value renders the cell of a test, metrics are only shown for passed tests
+	// and tests failed by their thresholds
+	Value func(r TestResult) string
+}
+
+// defaultSummaryColumns are the columns shown without --summary-columns
+const defaultSummaryColumns = "status,iops,bw,lat,duration"
+
+// summaryColumns are the columns --summary-columns can select, the test name
+// is always the first column
+var summaryColumns = map[string]summaryColumn{
+	"status": {"Status", tablewriter.ALIGN_CENTER, func(r TestResult) string {
+		return statusMark(r.Status)
+	}},
+	"suite": {"Suite", tablewriter.ALIGN_LEFT, func(r TestResult) string {
+		return r.Config.Suite
+	}},
+	"duration": {"Duration", tablewriter.ALIGN_LEFT, func(r TestResult) string {
+		return r.Duration.Round(time.Second).String()
+	}},
+	"iops":       {"IOPS", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return formatCount(r.TotalIOPS) })},
+	"read_iops":  {"Read IOPS", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return formatCount(r.ReadIOPS) })},
+	"write_iops": {"Write IOPS", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return formatCount(r.WriteIOPS) })},
+	"bw":         {"BW (MB/s)", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.2f", r.TotalBWMBps) })},
+	"read_bw":    {"Read MB/s", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.2f", r.ReadBWMBps) })},
+	"write_bw":   {"Write MB/s", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.2f", r.WriteBWMBps) })},
+	"lat":        {"Lat", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.2f", r.AvgLatencyUs) })},
+	"p99":        {"p99 Lat", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.2f", p99LatencyUs(r)) })},
+	"cv":         {"IOPS CV %", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string { return fmt.Sprintf("%.1f", iopsCV(r)) })},
+	"iops_tb": {"IOPS/TB", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string {
+		if r.Capacity == nil {
+			return "-"
+		}
+		return formatCount(r.Capacity.IOPSPerTB)
+	})},
+	"bw_tb": {"MB/s per TB", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string {
+		if r.Capacity == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", r.Capacity.MBpsPerTB)
+	})},
+	"cost_iops": {"Per 100k IOPS", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string {
+		if r.Cost == nil {
+			return "-"
+		}
+		return formatCost(r.Cost.Currency, r.Cost.Per100kIOPS)
+	})},
+	"cache": {"Write Cache", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string {
+		if r.WriteCliff == nil || !r.WriteCliff.Detected {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f GiB", float64(r.WriteCliff.CacheSizeBytes)/(1<<30))
+	})},
+	"device": {"Device", tablewriter.ALIGN_LEFT, func(r TestResult) string {
+		var devices []string
+		for _, disk := range r.DiskUtil {
+			devices = append(devices, disk.Name)
+		}
+		if len(devices) == 0 {
+			return "-"
+		}
+		return strings.Join(devices, ",")
+	}},
+	"slo_burn": {"SLO Burn", tablewriter.ALIGN_RIGHT, func(r TestResult) string {
+		if r.SLO == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2fx", r.SLO.BurnRate)
+	}},
+	"host_pct": {"% of Host", tablewriter.ALIGN_RIGHT, passedOnly(func(r TestResult) string {
+		if r.HostCeiling == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", r.HostCeiling.IOPSPc)
+	})},
+	"settle": {"GC Settle", tablewriter.ALIGN_RIGHT, func(r TestResult) string {
+		if r.GCSettle == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.0fs", r.GCSettle.Seconds)
+	}},
+	"warnings": {"Warnings", tablewriter.ALIGN_RIGHT, func(r TestResult) string {
+		return fmt.Sprintf("%d", len(r.Warnings))
+	}},
+}
+
+// passedOnly shows "-" instead of the value for tests that did not pass,
+// unless fio completed and the thresholds judged the metrics
+func passedOnly(value func(r TestResult) string) func(r TestResult) string {
+	return func(r TestResult) string {
+		if r.Status != "PASSED" && (r.FioJob == nil || len(r.Verdicts) == 0) {
+			return "-"
+		}
+		return value(r)
+	}
+}
+
+// parseSummaryColumns validates a comma separated list of summary columns
+func parseSummaryColumns(spec string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := summaryColumns[name]; !ok {
+			var names []string
+			for known := range summaryColumns {
+				names = append(names, known)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown summary column %q, available: %s", name, strings.Join(names, ", "))
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// summaryHeader returns the header of a column, with the unit where needed
+func summaryHeader(name string) string {
+	header := summaryColumns[name].Header
+	if name == "lat" || name == "p99" {
+		header += " (" + usUnit() + ")"
+	}
+	return header
+}
+
+// p99LatencyUs returns the higher of the read and write p99 completion
+// latencies
+func p99LatencyUs(r TestResult) float64 {
+	if r.FioJob == nil {
+		return 0
+	}
+	read := getPercentile(r.FioJob.Read.Clat.Percentile, "99.000000")
+	write := getPercentile(r.FioJob.Write.Clat.Percentile, "99.000000")
+	if write > read {
+		return write / 1000
+	}
+	return read / 1000
+}
+
+// iopsCV returns the highest IOPS coefficient of variation of the read and
+// write directions in percent
+func iopsCV(r TestResult) float64 {
+	if r.FioJob == nil {
+		return 0
+	}
+	cv := 0.0
+	for _, io := range []FioIO{r.FioJob.Read, r.FioJob.Write} {
+		if io.IOPSMean > 0 && 100*io.IOPSStddev/io.IOPSMean > cv {
+			cv = 100 * io.IOPSStddev / io.IOPSMean
+		}
+	}
+	return cv
+}
+
+// displayResultsTable shows one row per test with the selected columns
+func displayResultsTable(results []TestResult, columns []string) {
+	header := []string{"Test Name"}
+	align := []int{tablewriter.ALIGN_LEFT}
+	for _, name := range columns {
+		header = append(header, summaryHeader(name))
+		align = append(align, summaryColumns[name].Align)
+	}
+
+	detailsTable := tablewriter.NewWriter(out)
+	detailsTable.SetHeader(header)
+	configureTable(detailsTable, len(header))
+	detailsTable.SetColumnAlignment(align)
+	setHeaderColor(detailsTable, len(header), tablewriter.FgYellowColor)
+
+	for _, r := range results {
+		row := []string{r.TestName}
+		colors := []tablewriter.Colors{{}}
+		for _, name := range columns {
+			row = append(row, summaryColumns[name].Value(r))
+			// The iops, bw, lat and p99 columns are the metrics thresholds judge
+			colors = append(colors, verdictColor(verdictOf(r.Verdicts, name)))
+		}
+		detailsTable.Rich(row, colors)
+	}
+
+	detailsTable.Render()
+}
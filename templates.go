@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// workloadTemplates contains named workloads that test cases can reference
+// with "template", so that suites describe the intent of a test instead of
+// raw fio options. Fields set in the test case override the template.
+var workloadTemplates = map[string]FioTest{
+	"oltp-4k-mixed": {
+		Description:    "OLTP Database, 4k Random 70/30 Reads and Writes",
+		Size:           "10G",
+		Direct:         1,
+		RW:             "randrw",
+		RWMixRead:      70,
+		BS:             "4k",
+		IOEngine:       "libaio",
+		IODepth:        32,
+		NumJobs:        4,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        60,
+		EtaNewline:     1,
+	},
+	"backup-seq-write": {
+		Description:    "Backup Stream, 1M Sequential Writes",
+		Size:           "10G",
+		Direct:         1,
+		RW:             "write",
+		BS:             "1m",
+		IOEngine:       "libaio",
+		IODepth:        16,
+		NumJobs:        1,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        60,
+		EtaNewline:     1,
+	},
+	"vm-boot-storm": {
+		Description:    "VM Boot Storm, Many Concurrent 4k Random Reads",
+		Size:           "4G",
+		Direct:         1,
+		RW:             "randread",
+		BS:             "4k",
+		IOEngine:       "libaio",
+		IODepth:        4,
+		NumJobs:        16,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        60,
+		EtaNewline:     1,
+	},
+	"kafka-log-append": {
+		Description:    "Kafka Log Append, Buffered 64k Sequential Writes",
+		Size:           "10G",
+		Direct:         0,
+		RW:             "write",
+		BS:             "64k",
+		IOEngine:       "psync",
+		IODepth:        1,
+		NumJobs:        4,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        60,
+		EtaNewline:     1,
+	},
+	// The QD1 latency templates run long enough for the extreme
+	// percentiles to be backed by IOs, which json+ reports in full
+	"qd1-read-latency": {
+		Description:    "QD1 Latency, 4k Random Reads",
+		Size:           "10G",
+		Direct:         1,
+		RW:             "randread",
+		BS:             "4k",
+		IOEngine:       "libaio",
+		IODepth:        1,
+		NumJobs:        1,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        600,
+		EtaNewline:     1,
+		Percentiles:    qd1Percentiles,
+		JSONPlus:       true,
+	},
+	"qd1-write-latency": {
+		Description:    "QD1 Latency, 4k Random Writes",
+		Size:           "10G",
+		Direct:         1,
+		RW:             "randwrite",
+		BS:             "4k",
+		IOEngine:       "libaio",
+		IODepth:        1,
+		NumJobs:        1,
+		TimeBased:      true,
+		GroupReporting: true,
+		Runtime:        600,
+		EtaNewline:     1,
+		Percentiles:    qd1Percentiles,
+		JSONPlus:       true,
+	},
+}
+
+// qd1Percentiles are the percentiles of the QD1 latency templates
+var qd1Percentiles = []float64{50, 99, 99.9, 99.99, 99.999}
+
+// UnmarshalJSON applies the referenced workload template before decoding the
+// test case, so that only the fields present in the JSON override it. mem is
+// folded into iomem, which it is an alias of in fio, and rwmixwrite replaces
+// the rwmixread of the template.
+func (t *FioTest) UnmarshalJSON(data []byte) error {
+	var ref struct {
+		Template   string `json:"template"`
+		IOMem      string `json:"iomem"`
+		RWMixRead  int    `json:"rwmixread"`
+		RWMixWrite int    `json:"rwmixwrite"`
+	}
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return err
+	}
+
+	// plain has the fields of FioTest without this method
+	type plain FioTest
+	var test plain
+	if ref.Template != "" {
+		template, ok := workloadTemplates[ref.Template]
+		if !ok {
+			return fmt.Errorf("unknown workload template %q (available: %v)", ref.Template, templateNames())
+		}
+		test = plain(template)
+	}
+
+	if err := json.Unmarshal(data, &test); err != nil {
+		return err
+	}
+	if test.Mem != "" {
+		if ref.IOMem != "" && ref.IOMem != test.Mem {
+			return fmt.Errorf("test %s: mem=%s conflicts with iomem=%s, mem is an alias of iomem", test.Name, test.Mem, ref.IOMem)
+		}
+		test.IOMem, test.Mem = test.Mem, ""
+	}
+	if ref.RWMixWrite > 0 && ref.RWMixRead == 0 {
+		test.RWMixRead = 0
+	}
+	if test.RWMixRead > 0 && test.RWMixWrite > 0 && test.RWMixRead+test.RWMixWrite != 100 {
+		return fmt.Errorf("test %s: rwmixread=%d conflicts with rwmixwrite=%d", test.Name, test.RWMixRead, test.RWMixWrite)
+	}
+	*t = FioTest(test)
+	return nil
+}
+
+func templateNames() []string {
+	names := make([]string, 0, len(workloadTemplates))
+	for name := range workloadTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
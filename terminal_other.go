@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// terminalWidth falls back to the COLUMNS environment variable where the
+// terminal size cannot be queried
+func terminalWidth(w io.Writer) int {
+	columns, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return columns
+}
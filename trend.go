@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// TrendPoint aggregates the results of a test over one time window
+type TrendPoint struct {
+	Namespace     string
+	Test          string
+	WindowStart   time.Time
+	Runs          int
+	Failed        int
+	MeanIOPS      float64
+	MinIOPS       float64
+	MaxIOPS       float64
+	MeanLatencyUs float64
+	MeanP99Us     float64
+}
+
+// historyTrend aggregates the results in the history store per namespace,
+// test and window while scanning it, so memory grows with the number of windows and
+// not with the number of runs
+func historyTrend(path string, filter HistoryFilter, window time.Duration) ([]TrendPoint, error) {
+	type key struct {
+		namespace string
+		test      string
+		start     int64
+	}
+	points := map[key]*TrendPoint{}
+
+	filter.Kind = historyResult
+	err := scanHistory(path, filter, func(record HistoryRecord) error {
+		start := record.Time.Truncate(window)
+		k := key{record.Namespace, record.Test, start.Unix()}
+		point := points[k]
+		if point == nil {
+			point = &TrendPoint{Namespace: record.Namespace, Test: record.Test, WindowStart: start}
+			points[k] = point
+		}
+		if record.Status != "PASSED" {
+			point.Failed++
+			return nil
+		}
+		point.Runs++
+		if point.Runs == 1 || record.IOPS < point.MinIOPS {
+			point.MinIOPS = record.IOPS
+		}
+		if record.IOPS > point.MaxIOPS {
+			point.MaxIOPS = record.IOPS
+		}
+		// Running means keep the aggregation in constant space
+		n := float64(point.Runs)
+		point.MeanIOPS += (record.IOPS - point.MeanIOPS) / n
+		point.MeanLatencyUs += (record.LatencyUs - point.MeanLatencyUs) / n
+		point.MeanP99Us += (record.P99LatencyUs - point.MeanP99Us) / n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trend := make([]TrendPoint, 0, len(points))
+	for _, point := range points {
+		trend = append(trend, *point)
+	}
+	sort.Slice(trend, func(i, j int) bool {
+		if trend[i].Namespace != trend[j].Namespace {
+			return trend[i].Namespace < trend[j].Namespace
+		}
+		if trend[i].Test != trend[j].Test {
+			return trend[i].Test < trend[j].Test
+		}
+		return trend[i].WindowStart.Before(trend[j].WindowStart)
+	})
+	return trend, nil
+}
+
+// runTrend shows how the results of the tests developed over time
+func runTrend(args []string) int {
+	flags := flag.NewFlagSet("trend", flag.ContinueOnError)
+	history := flags.String("history", "fio-qa-history.ndjson", "history store to read")
+	test := flags.String("test", "", "only show this test")
+	ns := flags.String("namespace", "", "only show results of this namespace (default: all namespaces)")
+	since := flags.Duration("since", 30*24*time.Hour, "how far back to look")
+	window := flags.Duration("window", 24*time.Hour, "length of the aggregation windows")
+	approvedOnly := flags.Bool("approved-only", false, "only use the results of runs signed off with fio-qa sign")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *window <= 0 {
+		return usageError("--window must be positive")
+	}
+
+	filter := HistoryFilter{Since: time.Now().Add(-*since), Test: *test, Namespace: *ns}
+	if *approvedOnly {
+		runs, err := approvedRuns(*history)
+		if err != nil {
+			return usageError("%v", err)
+		}
+		filter.Runs = runs
+	}
+	trend, err := historyTrend(*history, filter, *window)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	if len(trend) == 0 {
+		fmt.Fprintf(out, "No results in %s in the last %s\n", *history, *since)
+		return exitOK
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Test", "Window", "Runs", "Failed", "IOPS (min-max)", "Lat (" + usUnit() + ")", "p99 (" + usUnit() + ")"})
+	configureTable(table, 7)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, point := range trend {
+		test := point.Test
+		if point.Namespace != "" {
+			test = point.Namespace + "/" + test
+		}
+		table.Append([]string{
+			test,
+			point.WindowStart.Local().Format("2006-01-02 15:04"),
+			strconv.Itoa(point.Runs),
+			strconv.Itoa(point.Failed),
+			fmt.Sprintf("%s (%s-%s)", formatCount(point.MeanIOPS), formatCount(point.MinIOPS), formatCount(point.MaxIOPS)),
+			fmt.Sprintf("%.2f", point.MeanLatencyUs),
+			fmt.Sprintf("%.2f", point.MeanP99Us),
+		})
+	}
+	table.Render()
+	return exitOK
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// QueueSetting is a queue parameter set for a test and its value before
+type QueueSetting struct {
+	Param    string `json:"param"`
+	Original string `json:"original"`
+	Value    string `json:"value"`
+}
+
+// checkTuning validates the tuning of the tests before anything runs
+func checkTuning(tests []FioTest) error {
+	for _, test := range tests {
+		for param := range test.Tuning {
+			if !isQueueParam(param) {
+				return fmt.Errorf("test %s: unknown tuning parameter %q, available: %s", test.Name, param, strings.Join(queueParams, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func isQueueParam(param string) bool {
+	for _, p := range queueParams {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTuning sets the queue parameters of the tuning of the test on its
+// disk. If one cannot be set the ones already set are restored.
+func applyTuning(test FioTest) (string, []QueueSetting, error) {
+	device, err := resolveBlockDevice(test.Filename)
+	if err != nil {
+		return "", nil, err
+	}
+	device = wholeDisk(device)
+
+	params := make([]string, 0, len(test.Tuning))
+	for param := range test.Tuning {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	var settings []QueueSetting
+	for _, param := range params {
+		original, err := readQueueParam(device, param)
+		if err == nil {
+			err = writeQueueParam(device, param, test.Tuning[param])
+		}
+		if err != nil {
+			restoreTuning(device, settings)
+			return "", nil, fmt.Errorf("cannot set %s of %s: %v", param, device, err)
+		}
+		// Read back what the kernel accepted, e.g. nr_requests is capped
+		value, _ := readQueueParam(device, param)
+		settings = append(settings, QueueSetting{Param: param, Original: original, Value: value})
+	}
+	return device, settings, nil
+}
+
+// restoreTuning sets the queue parameters back to their original values, in
+// reverse order
+func restoreTuning(device string, settings []QueueSetting) error {
+	var failed []string
+	for i := len(settings) - 1; i >= 0; i-- {
+		if err := writeQueueParam(device, settings[i].Param, settings[i].Original); err != nil {
+			failed = append(failed, settings[i].Param)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot restore %s of %s", strings.Join(failed, ", "), device)
+	}
+	return nil
+}
+
+// tuningSummary describes the changed parameters in one line
+func tuningSummary(settings []QueueSetting) string {
+	var parts []string
+	for _, setting := range settings {
+		parts = append(parts, fmt.Sprintf("%s %s -> %s", setting.Param, setting.Original, setting.Value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,63 @@
+package main
+
+// BaseUnits repeats the metrics of a test result in base units with
+// --strict-units, exact where fio reported them so, so consumers never have
+// to convert the MB/s and microseconds of the other fields back
+type BaseUnits struct {
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	TotalBytesPerSec float64 `json:"total_bytes_per_sec"`
+	ReadBytes        int64   `json:"read_bytes,omitempty"`
+	WriteBytes       int64   `json:"write_bytes,omitempty"`
+	ReadLatencyNs    float64 `json:"read_latency_ns"`
+	WriteLatencyNs   float64 `json:"write_latency_ns"`
+	AvgLatencyNs     float64 `json:"avg_latency_ns"`
+	P99LatencyNs     float64 `json:"p99_latency_ns"`
+	DurationNs       int64   `json:"duration_ns"`
+	// The completion latency percentiles as fio reported them
+	ReadClatPercentilesNs  map[string]float64 `json:"read_clat_percentiles_ns,omitempty"`
+	WriteClatPercentilesNs map[string]float64 `json:"write_clat_percentiles_ns,omitempty"`
+}
+
+// mebibyte is the MB of all MB/s in the results
+const mebibyte = 1024 * 1024
+
+// resultUnits names the unit of every field of the results that carries
+// one, written to the results with --strict-units
+var resultUnits = map[string]string{
+	"*iops*":              "IOs per second",
+	"*_mbps":              "MiB/s (1048576 bytes per second)",
+	"*_us":                "microseconds",
+	"latency_percentiles": "microseconds",
+	"*_bytes_per_sec":     "bytes per second",
+	"*_bytes":             "bytes",
+	"*_ns":                "nanoseconds",
+	"duration":            "Go duration, like 1m30s",
+}
+
+// baseUnits converts the metrics of a result into base units. Results of
+// fio keep its exact byte counts and nanoseconds, those of other backends
+// are converted from MB/s and microseconds.
+func baseUnits(r TestResult) *BaseUnits {
+	units := &BaseUnits{
+		ReadBytesPerSec:  r.ReadBWMBps * mebibyte,
+		WriteBytesPerSec: r.WriteBWMBps * mebibyte,
+		ReadLatencyNs:    r.ReadLatencyUs * 1000,
+		WriteLatencyNs:   r.WriteLatencyUs * 1000,
+		AvgLatencyNs:     r.AvgLatencyUs * 1000,
+		P99LatencyNs:     p99LatencyUs(r) * 1000,
+		DurationNs:       r.Duration.Nanoseconds(),
+	}
+	if job := r.FioJob; job != nil {
+		units.ReadBytesPerSec = job.Read.BWBytes
+		units.WriteBytesPerSec = job.Write.BWBytes
+		units.ReadBytes = int64(job.Read.IOKBytes) * 1024
+		units.WriteBytes = int64(job.Write.IOKBytes) * 1024
+		units.ReadLatencyNs = job.Read.LatNs.Mean
+		units.WriteLatencyNs = job.Write.LatNs.Mean
+		units.ReadClatPercentilesNs = job.Read.Clat.Percentile
+		units.WriteClatPercentilesNs = job.Write.Clat.Percentile
+	}
+	units.TotalBytesPerSec = units.ReadBytesPerSec + units.WriteBytesPerSec
+	return units
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fio-qa/compare"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// MetricVerdict is how a metric of a result compares with the limit the
+// thresholds comparator of its test sets
+type MetricVerdict struct {
+	Metric  string  `json:"metric"`
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Verdict string  `json:"verdict"`
+}
+
+// Metric verdicts, marginal metrics meet their limit by less than the
+// margin
+const (
+	verdictPass     = "pass"
+	verdictMarginal = "marginal"
+	verdictFail     = "fail"
+)
+
+// defaultMarginPercent is the margin of the thresholds without margin_percent
+const defaultMarginPercent = 10
+
+// thresholdVerdicts judges every metric the thresholds comparator of the
+// test limits, nil when the test has no thresholds
+func thresholdVerdicts(test FioTest, result TestResult) []MetricVerdict {
+	var verdicts []MetricVerdict
+	for _, config := range test.Comparators {
+		if config.Name != "thresholds" {
+			continue
+		}
+		var limits thresholdLimits
+		if compare.DecodeParams(config.Params, &limits) != nil {
+			continue
+		}
+		margin := float64(defaultMarginPercent)
+		if limits.MarginPercent != nil {
+			margin = *limits.MarginPercent
+		}
+		judge := func(metric string, value, limit float64, higherIsBetter bool) {
+			if limit <= 0 {
+				return
+			}
+			verdict := verdictPass
+			switch {
+			case higherIsBetter && value < limit, !higherIsBetter && value > limit:
+				verdict = verdictFail
+			case higherIsBetter && value < limit*(1+margin/100), !higherIsBetter && value > limit*(1-margin/100):
+				verdict = verdictMarginal
+			}
+			verdicts = append(verdicts, MetricVerdict{Metric: metric, Value: value, Limit: limit, Verdict: verdict})
+		}
+		judge("iops", result.TotalIOPS, limits.MinIOPS, true)
+		judge("bw", result.TotalBWMBps, limits.MinBWMBps, true)
+		judge("lat", result.AvgLatencyUs, limits.MaxLatencyUs, false)
+		judge("p99", p99LatencyUs(result), limits.MaxP99Us, false)
+	}
+	return verdicts
+}
+
+// verdictOf returns the verdict of a metric, empty when it has no limit
+func verdictOf(verdicts []MetricVerdict, metric string) string {
+	for _, v := range verdicts {
+		if v.Metric == metric {
+			return v.Verdict
+		}
+	}
+	return ""
+}
+
+// verdictColor returns the color of a cell showing a metric with the
+// verdict, none without a verdict or with colors disabled
+func verdictColor(verdict string) tablewriter.Colors {
+	if opts.NoColor {
+		return tablewriter.Colors{}
+	}
+	switch verdict {
+	case verdictPass:
+		return tablewriter.Colors{tablewriter.FgGreenColor}
+	case verdictMarginal:
+		return tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor}
+	case verdictFail:
+		return tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+	}
+	return tablewriter.Colors{}
+}
+
+// displayVerdicts shows the thresholds of a test and how its metrics compare
+func displayVerdicts(verdicts []MetricVerdict) {
+	names := map[string]string{
+		"iops": "IOPS (min)",
+		"bw":   "Bandwidth MB/s (min)",
+		"lat":  "Avg Latency " + usUnit() + " (max)",
+		"p99":  "p99 Latency " + usUnit() + " (max)",
+	}
+	fmt.Fprintln(out, "Thresholds")
+	verdictTable := tablewriter.NewWriter(out)
+	verdictTable.SetHeader([]string{"Metric", "Value", "Limit", "Verdict"})
+	configureTable(verdictTable, 4)
+	verdictTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	for _, v := range verdicts {
+		color := verdictColor(v.Verdict)
+		verdictTable.Rich([]string{names[v.Metric], fmt.Sprintf("%.2f", v.Value), fmt.Sprintf("%.2f", v.Limit), strings.ToUpper(v.Verdict)},
+			[]tablewriter.Colors{{}, color, {}, color})
+	}
+	verdictTable.Render()
+	fmt.Fprintln(out)
+}
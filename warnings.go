@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Warning is a caveat about a test result. Unlike failures, warnings never
+// change the status of a test.
+type Warning struct {
+	Severity string `json:"severity"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// Warning severities
+const (
+	severityNotice  = "notice"
+	severityWarning = "warning"
+)
+
+// warn adds a warning to the result
+func (r *TestResult) warn(severity, category, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Warning{
+		Severity: severity,
+		Category: category,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// analyzeResult looks for conditions that make the measurement of a passed
+// test questionable
+func analyzeResult(test FioTest, result *TestResult) {
+	job := result.FioJob
+
+	if test.Direct == 0 && result.ReadIOPS > 0 && test.ReadIOLog == "" {
+		result.warn(severityWarning, "cache_pollution", "buffered reads (direct=0) may have been served from the page cache")
+	}
+
+	for _, dir := range []struct {
+		Name string
+		IO   FioIO
+	}{{"read", job.Read}, {"write", job.Write}} {
+		if dir.IO.IOPSMean <= 0 {
+			continue
+		}
+		cv := 100 * dir.IO.IOPSStddev / dir.IO.IOPSMean
+		if cv > opts.CVThreshold {
+			result.warn(severityWarning, "high_cv", "%s IOPS coefficient of variation is %.1f%%, above %.1f%%", dir.Name, cv, opts.CVThreshold)
+		}
+	}
+
+	for _, finding := range result.Dmesg {
+		switch {
+		case finding.Category == "thermal_throttle":
+			result.warn(severityWarning, "thermal_throttle", "%s", finding.Message)
+		case finding.Severity == "critical" && !opts.DmesgFail:
+			result.warn(severityWarning, "dmesg", "%s", finding.Message)
+		case finding.Severity == "notice":
+			result.warn(severityNotice, "dmesg", "%s", finding.Message)
+		}
+	}
+}
+
+func displayWarnings(warnings []Warning) {
+	fmt.Fprintln(out, "Warnings")
+	warningsTable := tablewriter.NewWriter(out)
+	warningsTable.SetHeader([]string{"Warning", "Message"})
+	configureTable(warningsTable, 2)
+	warningsTable.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	for _, warning := range warnings {
+		warningsTable.Append([]string{fmt.Sprintf("%s (%s)", warning.Category, warning.Severity), warning.Message})
+	}
+	warningsTable.Render()
+	fmt.Fprintln(out)
+}
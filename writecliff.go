@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// writeCliffLogMsec is the interval of the bandwidth logs the write
+	// cliff is found in
+	writeCliffLogMsec = 1000
+	// writeCliffMinSamples is the fewest intervals the analysis needs
+	writeCliffMinSamples = 10
+	// writeCliffMinDrop is the drop of the bandwidth, as a fraction of the
+	// bandwidth before, that counts as a cliff
+	writeCliffMinDrop = 0.3
+)
+
+// WriteCliff is the bandwidth cliff of a sequential write test that fills
+// the SLC cache of an SSD: the drive writes at cache speed until the cache
+// is full and at the speed of its native flash afterwards. The data written
+// before the cliff estimates the size of the cache. Without a cliff
+// PreCliffMBps is the steady bandwidth of the whole test, and the cache is
+// larger than WrittenBytes or the drive has none.
+type WriteCliff struct {
+	Detected       bool    `json:"detected"`
+	IntervalMs     int64   `json:"interval_ms"`
+	WrittenBytes   int64   `json:"written_bytes"`
+	CliffSeconds   float64 `json:"cliff_seconds,omitempty"`
+	CacheSizeBytes int64   `json:"cache_size_bytes,omitempty"`
+	PreCliffMBps   float64 `json:"pre_cliff_mbps"`
+	PostCliffMBps  float64 `json:"post_cliff_mbps,omitempty"`
+	DropPercent    float64 `json:"drop_percent,omitempty"`
+}
+
+// writeCliffEnabled tells whether the write cliff of a test is analyzed,
+// for every sequential write test with --write-cliff
+func writeCliffEnabled(test FioTest) bool {
+	return test.WriteCliff || opts.WriteCliff && test.RW == "write"
+}
+
+// bwLogFiles returns the bandwidth logs fio wrote for the prefix, one per
+// job
+func bwLogFiles(prefix string) []string {
+	matches, _ := filepath.Glob(prefix + "_bw.*.log")
+	sort.Strings(matches)
+	return matches
+}
+
+// parseBWLogs sums the write bandwidth of all jobs per log interval in MB/s.
+// Each log line holds the average bandwidth of one direction of a job over
+// one interval in KiB/s: "time_ms, bandwidth, direction, block_size, ...".
+func parseBWLogs(files []string, intervalMs int64) ([]float64, error) {
+	slots := map[int64]float64{}
+	for _, file := range files {
+		f, err := openArtifact(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			fields := strings.Split(scanner.Text(), ",")
+			if len(fields) < 3 {
+				continue
+			}
+			timeMs, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: invalid time %q", file, line, fields[0])
+			}
+			kib, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: invalid bandwidth %q", file, line, fields[1])
+			}
+			if strings.TrimSpace(fields[2]) != "1" {
+				continue
+			}
+			// Intervals of the jobs are aligned to the log interval
+			slot := (timeMs + intervalMs/2) / intervalMs
+			slots[slot] += kib / 1024
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("bandwidth logs contain no write samples")
+	}
+	var last int64
+	for slot := range slots {
+		last = max(last, slot)
+	}
+	// Intervals without a sample wrote nothing
+	series := make([]float64, last)
+	for slot, mbps := range slots {
+		if slot > 0 {
+			series[slot-1] += mbps
+		}
+	}
+	return series, nil
+}
+
+// findWriteCliff splits the bandwidth series where two steady levels fit it
+// best and reports a cliff when the second level is at least
+// writeCliffMinDrop below the first
+func findWriteCliff(series []float64, intervalMs int64) (*WriteCliff, error) {
+	n := len(series)
+	if n < writeCliffMinSamples {
+		return nil, fmt.Errorf("needs at least %d s of bandwidth samples, the test wrote for %d s",
+			writeCliffMinSamples*int(intervalMs)/1000, n*int(intervalMs)/1000)
+	}
+	seconds := float64(intervalMs) / 1000
+	written := 0.0
+	for _, mbps := range series {
+		written += mbps * seconds
+	}
+	cliff := &WriteCliff{
+		IntervalMs:   intervalMs,
+		WrittenBytes: int64(written * mebibyte),
+		PreCliffMBps: median(append([]float64(nil), series...)),
+	}
+
+	// The split with the least squared error of two constant levels, with
+	// prefix sums for the means
+	sum := make([]float64, n+1)
+	sumSq := make([]float64, n+1)
+	for i, mbps := range series {
+		sum[i+1] = sum[i] + mbps
+		sumSq[i+1] = sumSq[i] + mbps*mbps
+	}
+	sse := func(from, to int) float64 {
+		s := sum[to] - sum[from]
+		return sumSq[to] - sumSq[from] - s*s/float64(to-from)
+	}
+	minSegment := max(3, n/20)
+	split, best := 0, math.Inf(1)
+	for k := minSegment; k <= n-minSegment; k++ {
+		if e := sse(0, k) + sse(k, n); e < best {
+			split, best = k, e
+		}
+	}
+
+	pre := median(append([]float64(nil), series[:split]...))
+	post := median(append([]float64(nil), series[split:]...))
+	if pre == 0 || post > pre*(1-writeCliffMinDrop) {
+		return cliff, nil
+	}
+	cliff.Detected = true
+	cliff.CliffSeconds = float64(split) * seconds
+	cliff.CacheSizeBytes = int64(sum[split] * seconds * mebibyte)
+	cliff.PreCliffMBps = pre
+	cliff.PostCliffMBps = post
+	cliff.DropPercent = (pre - post) / pre * 100
+	return cliff, nil
+}
+
+// collectBWLogs looks for the write cliff in the bandwidth logs of a test
+// and keeps the logs in the artifact bundle
+func collectBWLogs(prefix string, result *TestResult) {
+	files := bwLogFiles(prefix)
+	if len(files) == 0 {
+		result.warn(severityWarning, "monitor", "fio wrote no bandwidth logs")
+		return
+	}
+	series, err := parseBWLogs(files, writeCliffLogMsec)
+	if err == nil {
+		result.WriteCliff, err = findWriteCliff(series, writeCliffLogMsec)
+	}
+	if err != nil {
+		result.warn(severityWarning, "monitor", "write cliff analysis unavailable: %v", err)
+	}
+	result.Artifacts = append(result.Artifacts, compressArtifacts(files, result)...)
+}
+
+func (c *WriteCliff) String() string {
+	if !c.Detected {
+		return fmt.Sprintf("none, %.2f MB/s steady over %.1f GiB", c.PreCliffMBps, float64(c.WrittenBytes)/(1<<30))
+	}
+	return fmt.Sprintf("after %.1f GiB at %.0fs, %.2f → %.2f MB/s (-%.0f%%)",
+		float64(c.CacheSizeBytes)/(1<<30), c.CliffSeconds, c.PreCliffMBps, c.PostCliffMBps, c.DropPercent)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The workbook is written as plain Office Open XML: a zip of one XML part
+// per sheet plus the few parts Excel requires, with strings stored inline so
+// no shared string table is needed. The first row of every sheet is a bold
+// header that stays visible while scrolling.
+
+// xlsxSheet is a sheet of the workbook, its cells are strings or numbers
+type xlsxSheet struct {
+	Name string
+	Rows [][]interface{}
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const xlsxContentTypes = xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`%s</Types>`
+
+const xlsxRootRels = xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines the default cell style and a bold one for headers
+const xlsxStyles = xlsxHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// writeXLSX writes the sheets as an .xlsx workbook
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	var overrides, entries, rels strings.Builder
+	for i, sheet := range sheets {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&entries, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.Name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, overrides.String())},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + entries.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheetXML(sheet)})
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// sheetXML renders the rows of a sheet, the first row as the header
+func sheetXML(sheet xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xlsxHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+	for r, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		style := ""
+		if r == 0 {
+			style = ` s="1"`
+		}
+		for c, cell := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			switch v := cell.(type) {
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			case int64:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			default:
+				s := fmt.Sprint(v)
+				if s == "" {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(s))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn returns the letters of a column, 0 is A
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// resultsWorkbook lays out the results as a summary of the run, the
+// details and the latency percentiles of every test and the configuration
+// the tests ran with
+func resultsWorkbook(results JSONResults) []xlsxSheet {
+	summary := xlsxSheet{Name: tr("Summary"), Rows: [][]interface{}{
+		{tr("Field"), tr("Value")},
+		{tr("Run ID"), results.RunID},
+		{tr("Namespace"), results.Namespace},
+		{tr("Suite"), results.Suite},
+		{tr("Total Tests"), results.Summary.TotalTests},
+		{tr("Passed"), results.Summary.Passed},
+		{tr("Failed"), results.Summary.Failed},
+		{tr("Warnings"), results.Summary.Warnings},
+		{tr("Total Duration"), results.Summary.TotalDuration},
+	}}
+	if results.Score != nil {
+		summary.Rows = append(summary.Rows, []interface{}{tr("Score"), results.Score.Score}, []interface{}{tr("Grade"), results.Score.Grade})
+	}
+	highlights := results.PerformanceHighlights
+	for _, h := range []struct {
+		label     string
+		highlight JSONHighlight
+	}{
+		{"Highest IOPS", highlights.HighestIOPS},
+		{"Highest Bandwidth", highlights.HighestBandwidth},
+		{"Lowest Latency", highlights.LowestLatency},
+	} {
+		if h.highlight.TestName != "" {
+			summary.Rows = append(summary.Rows, []interface{}{tr(h.label), fmt.Sprintf("%s: %.2f %s", h.highlight.TestName, h.highlight.Value, h.highlight.Unit)})
+		}
+	}
+
+	details := xlsxSheet{Name: tr("Tests"), Rows: [][]interface{}{{}}}
+	for _, label := range []string{
+		"Test", "Description", "Status", "Duration", "IOPS", "MB/s", "Lat Avg (us)",
+		"Read IOPS", "Read IOPS Min", "Read IOPS Max", "Read IOPS StdDev",
+		"Write IOPS", "Write IOPS Min", "Write IOPS Max", "Write IOPS StdDev",
+		"Read MB/s", "Write MB/s",
+		"Read slat Avg (us)", "Read clat Avg (us)", "Read clat Max (us)", "Read clat StdDev (us)", "Read lat Avg (us)",
+		"Write slat Avg (us)", "Write clat Avg (us)", "Write clat Max (us)", "Write clat StdDev (us)", "Write lat Avg (us)",
+		"User CPU %", "System CPU %", "Context Switches", "Warnings", "Error",
+	} {
+		details.Rows[0] = append(details.Rows[0], tr(label))
+	}
+	for _, r := range results.TestResults {
+		read, write := r.LatencyStats.Read, r.LatencyStats.Write
+		details.Rows = append(details.Rows, []interface{}{
+			r.TestName, r.Description, tr(r.Status), r.Duration, r.IOPS, r.BandwidthMBps, r.LatencyUs,
+			r.IOPSStats.Read.IOPS, r.IOPSStats.Read.Min, r.IOPSStats.Read.Max, r.IOPSStats.Read.StdDev,
+			r.IOPSStats.Write.IOPS, r.IOPSStats.Write.Min, r.IOPSStats.Write.Max, r.IOPSStats.Write.StdDev,
+			r.BandwidthStats.Read.BandwidthMBps, r.BandwidthStats.Write.BandwidthMBps,
+			read.SubmissionLat.Avg, read.CompletionLat.Avg, read.CompletionLat.Max, read.CompletionLat.StdDev, read.TotalLat.Avg,
+			write.SubmissionLat.Avg, write.CompletionLat.Avg, write.CompletionLat.Max, write.CompletionLat.StdDev, write.TotalLat.Avg,
+			r.CPUUsage.UserCPU, r.CPUUsage.SystemCPU, r.CPUUsage.ContextSwitches, len(r.Warnings), r.Error,
+		})
+	}
+
+	// One column per percentile of the results file, in its order
+	percentiles := xlsxSheet{Name: tr("Percentiles"), Rows: [][]interface{}{{tr("Test")}}}
+	percentileType := reflect.TypeOf(JSONPercentiles{})
+	for i := 0; i < percentileType.NumField(); i++ {
+		name := strings.Split(percentileType.Field(i).Tag.Get("json"), ",")[0]
+		percentiles.Rows[0] = append(percentiles.Rows[0], strings.ReplaceAll(name, "_", ".")+" (us)")
+	}
+	for _, r := range results.TestResults {
+		row := []interface{}{r.TestName}
+		values := reflect.ValueOf(r.Percentiles)
+		for i := 0; i < values.NumField(); i++ {
+			row = append(row, values.Field(i).Float())
+		}
+		percentiles.Rows = append(percentiles.Rows, row)
+	}
+
+	config := xlsxSheet{Name: tr("Config"), Rows: [][]interface{}{{tr("Test"), tr("Option"), tr("Value")}}}
+	for _, r := range results.TestResults {
+		for _, option := range configOptions(r.Config) {
+			config.Rows = append(config.Rows, []interface{}{r.TestName, option[0], option[1]})
+		}
+		if len(r.FioArgs) > 0 {
+			config.Rows = append(config.Rows, []interface{}{r.TestName, tr("fio command"), "fio " + strings.Join(r.FioArgs, " ")})
+		}
+	}
+
+	return []xlsxSheet{summary, details, percentiles, config}
+}
+
+// configOptions lists the options a test case sets, as in its JSON
+func configOptions(test FioTest) [][2]string {
+	data, err := json.Marshal(test)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	var options [][2]string
+	for name, value := range fields {
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			s = string(value)
+		}
+		options = append(options, [2]string{name, s})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i][0] < options[j][0] })
+	return options
+}
+
+// saveResultsToXLSX saves the results as an Excel workbook
+func saveResultsToXLSX(results JSONResults, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeXLSX(f, resultsWorkbook(results)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write workbook: %v", err)
+	}
+	return f.Close()
+}